@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/looper-ai/looper/pkg/agent"
+)
+
+// slashCommand describes an interactive command for the help listing and
+// tab completion
+type slashCommand struct {
+	name        string
+	args        string // Argument syntax shown in help, e.g. "[name]"
+	description string
+
+	// complete returns the candidates for the argument at position arg
+	// (0 for the first), or nil if the command takes none there
+	complete func(ag *agent.Agent, arg int) []string
+}
+
+// slashCommands lists the interactive commands in help order
+var slashCommands = []slashCommand{
+	{name: "/quit", description: "Exit the agent (also /exit)"},
+	{name: "/exit", description: "Exit the agent"},
+	{name: "/clear", description: "Clear conversation history"},
+	{name: "/skills", args: "[name]", description: "List loaded skills, or show one", complete: completeSkills},
+	{name: "/skill", args: "load <name>", description: "Load a skill from disk, e.g. one added or edited since startup", complete: completeSkillCommand},
+	{name: "/tools", args: "[name]", description: "List available tools, or describe one", complete: completeTools},
+	{name: "/prompts", args: "[id]", description: "List loaded prompts, or show one", complete: completePrompts},
+	{name: "/system", description: "Show the system prompt, including project context"},
+	{name: "/tokens", description: "Show where the context's tokens go, by category and by tool"},
+	{name: "/retry-tool", args: "[record]", description: "Re-run the last tool call (add 'record' to save the result)", complete: completeFixed("record")},
+	{name: "/verbosity", args: "[preset]", description: "Show or set the response style (concise, normal, verbose)", complete: completeVerbosity},
+	{name: "/plan", description: "Toggle plan mode (read-only tools until a plan is approved)"},
+	{name: "/approve", args: "[instructions]", description: "Approve the plan, leave plan mode and carry it out"},
+	{name: "/help", description: "Show this help"},
+}
+
+// printCommands prints the command listing, highlighting command names
+// when color is set
+func printCommands(color bool) {
+	width := 0
+	for _, cmd := range slashCommands {
+		if cmd.name != "/exit" {
+			width = max(width, len(commandUsage(cmd)))
+		}
+	}
+	for _, cmd := range slashCommands {
+		if cmd.name == "/exit" {
+			continue // Listed with /quit
+		}
+		usage := commandUsage(cmd)
+		padding := strings.Repeat(" ", width-len(usage))
+		if color {
+			usage = colorYellow + usage + colorReset
+		}
+		fmt.Printf("  %s%s  - %s\n", usage, padding, cmd.description)
+	}
+	fmt.Println("  Press Tab to complete commands and their arguments.")
+}
+
+func commandUsage(cmd slashCommand) string {
+	if cmd.args == "" {
+		return cmd.name
+	}
+	return cmd.name + " " + cmd.args
+}
+
+func completeSkills(ag *agent.Agent, arg int) []string {
+	if arg != 0 {
+		return nil
+	}
+	return sortedKeys(ag.Context().LoadedSkills)
+}
+
+// completeSkillCommand completes "/skill load <name>" with every discovered
+// skill, loaded or not
+func completeSkillCommand(ag *agent.Agent, arg int) []string {
+	switch arg {
+	case 0:
+		return []string{"load"}
+	case 1:
+		return ag.Discovery().List()
+	}
+	return nil
+}
+
+func completeTools(ag *agent.Agent, arg int) []string {
+	if arg != 0 {
+		return nil
+	}
+	return ag.Registry().Names()
+}
+
+func completePrompts(ag *agent.Agent, arg int) []string {
+	if arg != 0 {
+		return nil
+	}
+	return sortedKeys(ag.PromptLoader().GetAll())
+}
+
+func completeVerbosity(ag *agent.Agent, arg int) []string {
+	if arg != 0 {
+		return nil
+	}
+	return ag.VerbosityPresets()
+}
+
+func completeFixed(options ...string) func(*agent.Agent, int) []string {
+	return func(ag *agent.Agent, arg int) []string {
+		if arg != 0 {
+			return nil
+		}
+		return options
+	}
+}
+
+// completeLine returns the completions for the last word of line: command
+// names for the first word of a slash command, and the command's argument
+// candidates after it. The candidates replace the word, which starts at
+// offset start of line.
+func completeLine(ag *agent.Agent, line string) (start int, candidates []string) {
+	if !strings.HasPrefix(line, "/") {
+		return len(line), nil
+	}
+	start = strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	words := strings.Fields(line[:start])
+
+	if len(words) == 0 {
+		names := make([]string, len(slashCommands))
+		for i, cmd := range slashCommands {
+			names[i] = cmd.name
+		}
+		return start, fuzzyMatch(word, names)
+	}
+
+	name := strings.ToLower(words[0])
+	for _, cmd := range slashCommands {
+		if cmd.name == name && cmd.complete != nil {
+			return start, fuzzyMatch(word, cmd.complete(ag, len(words)-1))
+		}
+	}
+	return start, nil
+}
+
+// fuzzyMatch returns the options starting with query or, if there are
+// none, those containing its characters in order, so "/sk" and "/skl" both
+// find "/skills". Matching ignores case and the result is sorted.
+func fuzzyMatch(query string, options []string) []string {
+	query = strings.ToLower(query)
+	var prefixed, fuzzy []string
+	for _, option := range options {
+		lower := strings.ToLower(option)
+		switch {
+		case strings.HasPrefix(lower, query):
+			prefixed = append(prefixed, option)
+		case isSubsequence(query, lower):
+			fuzzy = append(fuzzy, option)
+		}
+	}
+	if len(prefixed) > 0 {
+		sort.Strings(prefixed)
+		return prefixed
+	}
+	sort.Strings(fuzzy)
+	return fuzzy
+}
+
+// isSubsequence reports whether the runes of query appear in s in order
+func isSubsequence(query, s string) bool {
+	for _, r := range s {
+		if query == "" {
+			return true
+		}
+		if q, size := utf8.DecodeRuneInString(query); q == r {
+			query = query[size:]
+		}
+	}
+	return query == ""
+}
+
+// commonPrefix returns the longest prefix shared by all of values,
+// ignoring case, taken from the first value
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := []rune(values[0])
+	for _, v := range values[1:] {
+		runes := []rune(v)
+		n := 0
+		for n < len(prefix) && n < len(runes) && strings.EqualFold(string(prefix[n]), string(runes[n])) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// userPrompt is printed before each line of interactive input
+const userPrompt = colorBold + colorGreen + "You:" + colorReset + " "
+
+// Control characters handled by the line editor
+const (
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// completeFunc returns completions for the word of line that starts at
+// offset start
+type completeFunc func(line string) (start int, candidates []string)
+
+// lineResult is a line read from the terminal, or the error that ended input
+type lineResult struct {
+	line string
+	err  error
+}
+
+// lineEditor reads interactive input a line at a time. On a terminal it
+// reads character by character and echoes the input itself, so that Tab can
+// complete slash commands and their arguments; elsewhere it reads plain
+// lines. Lines are read in a goroutine, one per call to nextLine, so that
+// waiting for input can be raced against the idle timer while the terminal
+// stays in its normal mode between prompts.
+type lineEditor struct {
+	in       *bufio.Reader
+	fd       int
+	out      io.Writer
+	requests chan completeFunc // Asks the reader for one line
+	lines    chan lineResult
+
+	// pending is set while a requested line has not been received, such
+	// as after an idle timeout, so the next call waits for it rather than
+	// asking again
+	pending bool
+
+	mu      sync.Mutex
+	restore func() // Restores the terminal while it is in character mode
+	closed  bool
+}
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	e := &lineEditor{
+		in:       bufio.NewReader(in),
+		fd:       int(in.Fd()),
+		out:      out,
+		requests: make(chan completeFunc, 1),
+		lines:    make(chan lineResult),
+	}
+	go e.serve()
+	return e
+}
+
+// serve reads a line for each request until input ends. The goroutine stops
+// after the first error.
+func (e *lineEditor) serve() {
+	charMode := true
+	for complete := range e.requests {
+		var line string
+		var err error
+		if charMode {
+			line, err = e.readLine(complete)
+			if err == errNoCharMode {
+				charMode = false
+			}
+		}
+		if !charMode {
+			line, err = e.in.ReadString('\n')
+		}
+		e.lines <- lineResult{line: line, err: err}
+		if err != nil {
+			close(e.lines)
+			return
+		}
+	}
+}
+
+// nextLine reads the next line of input; call it after printing the
+// prompt. complete, if not nil, completes the word before the cursor when
+// Tab is pressed. With a positive idleTimeout it returns errIdleTimeout if
+// no line arrives within that duration. Calls must not overlap.
+func (e *lineEditor) nextLine(complete completeFunc, idleTimeout time.Duration) (string, error) {
+	if !e.pending {
+		e.pending = true
+		e.requests <- complete
+	}
+
+	var timeout <-chan time.Time
+	if idleTimeout > 0 {
+		timer := time.NewTimer(idleTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case res, ok := <-e.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		e.pending = false
+		return res.line, res.err
+	case <-timeout:
+		return "", errIdleTimeout
+	}
+}
+
+// close restores the terminal mode and stops character mode from being
+// entered again, before the program exits. It is safe to call more than
+// once and on a nil editor.
+func (e *lineEditor) close() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	e.restoreMode()
+}
+
+// restoreMode leaves character mode, if the terminal is in it
+func (e *lineEditor) restoreMode() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.restore != nil {
+		e.restore()
+		e.restore = nil
+	}
+}
+
+// errNoCharMode is returned by readLine when the input is not a terminal
+// that supports character mode
+var errNoCharMode = errors.New("terminal character mode unavailable")
+
+// readLine reads one line in character mode, ending with "\n" like
+// bufio.Reader.ReadString
+func (e *lineEditor) readLine(complete completeFunc) (string, error) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return "", io.EOF
+	}
+	restore, err := enableCharMode(e.fd)
+	if err != nil {
+		e.mu.Unlock()
+		return "", errNoCharMode
+	}
+	e.restore = restore
+	e.mu.Unlock()
+	defer e.restoreMode()
+
+	var line []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(line), err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(line) + "\n", nil
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+		case keyDelete, keyBackspace:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				e.erase(1)
+			}
+		case keyCtrlU:
+			e.erase(len(line))
+			line = line[:0]
+		case keyTab:
+			if complete != nil {
+				line = e.completeWord(line, complete)
+			}
+		case keyEscape:
+			e.skipEscapeSequence()
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// completeWord completes the last word of line: a single candidate replaces
+// it, several extend it to their common prefix, or if that adds nothing are
+// listed below the line
+func (e *lineEditor) completeWord(line []rune, complete completeFunc) []rune {
+	text := string(line)
+	start, candidates := complete(text)
+	word := text[start:]
+
+	var replacement string
+	switch prefix := commonPrefix(candidates); {
+	case len(candidates) == 0:
+		fmt.Fprint(e.out, "\a")
+		return line
+	case len(candidates) == 1:
+		replacement = candidates[0] + " "
+	case len([]rune(prefix)) > len([]rune(word)) && strings.HasPrefix(strings.ToLower(prefix), strings.ToLower(word)):
+		replacement = prefix
+	default:
+		fmt.Fprintf(e.out, "\n%s\n%s%s", strings.Join(candidates, "  "), userPrompt, text)
+		return line
+	}
+
+	e.erase(len([]rune(word)))
+	fmt.Fprint(e.out, replacement)
+	return []rune(text[:start] + replacement)
+}
+
+// erase removes the last n characters from the screen
+func (e *lineEditor) erase(n int) {
+	fmt.Fprint(e.out, strings.Repeat("\b \b", n))
+}
+
+// skipEscapeSequence discards the rest of an escape sequence, such as an
+// arrow key, which the editor does not handle
+func (e *lineEditor) skipEscapeSequence() {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return
+	}
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil || (b >= 0x40 && b <= 0x7e) {
+			return
+		}
+	}
+}
@@ -3,17 +3,25 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/looper-ai/looper/pkg/agent"
 	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/sandbox"
+	"github.com/looper-ai/looper/pkg/skills"
+	"github.com/looper-ai/looper/pkg/tools"
+	"github.com/looper-ai/looper/pkg/truncate"
 )
 
 // ANSI color codes for terminal output
@@ -44,21 +52,51 @@ func main() {
 		workspace        = flag.String("workspace", "", "Workspace directory path")
 		provider         = flag.String("provider", "", "LLM provider (anthropic, openai)")
 		model            = flag.String("model", "", "Model name (defaults to provider's default)")
+		downgradeModel   = flag.String("downgrade-model", "", "Cheaper model to switch to once the context reaches -downgrade-at tokens")
+		downgradeAt      = flag.Int("downgrade-at", 0, "Estimated context size in tokens at which -downgrade-model takes over")
+		contextWindow    = flag.Int("context-window", 0, "Model context window in tokens (defaults to the known window of -model)")
 		prompt           = flag.String("prompt", "", "Single prompt to execute (non-interactive mode)")
 		systemPrompt     = flag.String("system", "", "Custom system prompt (overrides -system-prompt-id)")
+		extraSystem      = flag.String("extra-system", "", "Additional instructions appended to the system prompt")
 		systemPromptID   = flag.String("system-prompt-id", "", "ID of prompt template to use as system prompt")
 		promptsPath      = flag.String("prompts-path", "", "Path to prompts directory")
+		projectFile      = flag.String("project-file", "", "Workspace file of project facts added to the system prompt (default LOOPER.md)")
+		verbosity        = flag.String("verbosity", "", "Response style preset: concise, normal or verbose")
+		mode             = flag.String("mode", "", "Agent mode: full (default) or readonly, which can only read, search and list files")
+		planMode         = flag.Bool("plan", false, "Start in plan mode: only read-only tools run until the plan is approved")
 		maxIter          = flag.Int("max-iterations", 50, "Maximum tool call iterations")
 		showVersion      = flag.Bool("version", false, "Show version")
+		verbose          = flag.Bool("verbose", false, "Print the sandbox capabilities (interpreters, network, isolation) on startup")
+		skipProbe        = flag.Bool("skip-sandbox-probe", false, "Skip probing the sandbox for interpreters and isolation at startup")
 		listSkills       = flag.Bool("list-skills", false, "List available skills and exit")
 		listPrompts      = flag.Bool("list-prompts", false, "List available prompts and exit")
 		disableBlacklist = flag.Bool("no-blacklist", false, "Disable command blacklist (dangerous)")
 		blacklistFile    = flag.String("blacklist", "", "Path to custom blacklist file (one pattern per line)")
+		allowlistFile    = flag.String("allowlist", "", "Path to command allowlist file (one program per line); only these may run")
+		dryRun           = flag.Bool("dry-run", false, "Show what sandboxed commands would run instead of running them")
+		noNetwork        = flag.Bool("no-network", false, "Run sandboxed commands without network access (Linux)")
+		allowedCaps      = flag.String("allowed-capabilities", "", "Comma-separated tool capabilities to allow: read, write, execute, network (default: all tools)")
+		isolation        = flag.String("isolation", "", "Sandbox confinement backend: process, bwrap, firejail, nsjail or gvisor (Linux)")
+		nice             = flag.Int("nice", 0, "Nice value for sandboxed commands, 1 (slightly lower) to 19 (lowest CPU priority) (Linux)")
+		ioPriority       = flag.String("io-priority", "", "Disk priority for sandboxed commands: low or idle (Linux)")
+		auditLog         = flag.Bool("audit-log", false, "Record every sandboxed command in an audit log (JSON lines)")
+		auditLogPath     = flag.String("audit-log-path", "", "Audit log file (default .looper/audit.jsonl in the workspace; implies -audit-log)")
+		allowEnv         = flag.String("allow-env", "", "Comma-separated environment variable patterns to pass to commands (e.g. GO*,npm_config_*)")
+		inheritEnv       = flag.Bool("inherit-env", false, "Pass the whole environment to commands, except secrets such as *_API_KEY")
+		noRedact         = flag.Bool("no-redact", false, "Show secrets such as API keys in command output (debugging only)")
+		toolsFile        = flag.String("tools-file", "", "Path to a JSON file of external tool definitions")
+		skillTimeout     = flag.Duration("skill-discovery-timeout", 10*time.Second, "How long to scan skill directories at startup before using the skills found so far (0 waits)")
+		killGrace        = flag.Duration("kill-grace", 5*time.Second, "Time a timed-out command gets to exit after SIGTERM before it is killed (0 kills at once)")
+		idleTimeout      = flag.Duration("idle-timeout", 0, "Exit interactive mode after this long without input (e.g. 15m; 0 disables)")
+		writeDiffs       = flag.Bool("write-diffs", false, "Include a diff of each change in write_file results")
+		confirmOverwrite = flag.Bool("confirm-overwrite", false, "Ask before write_file overwrites a non-empty file (interactive mode)")
 	)
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Looper - AI Agent Framework\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: looper [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: looper [options]\n")
+		fmt.Fprintf(os.Stderr, "       echo \"prompt\" | looper [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Without -prompt, input piped to stdin is read as a single prompt.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nEnvironment Variables:\n")
@@ -66,9 +104,29 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  OPENAI_API_KEY         API key for OpenAI\n")
 		fmt.Fprintf(os.Stderr, "  LOOPER_PROVIDER        Default provider\n")
 		fmt.Fprintf(os.Stderr, "  LOOPER_MODEL           Default model\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_DOWNGRADE_MODEL Model used once the context reaches LOOPER_DOWNGRADE_AT_TOKENS\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_DOWNGRADE_AT_TOKENS  Context size in tokens for the downgrade model\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_CONTEXT_WINDOW  Model context window in tokens\n")
 		fmt.Fprintf(os.Stderr, "  LOOPER_WORKSPACE       Default workspace path\n")
 		fmt.Fprintf(os.Stderr, "  LOOPER_PROMPTS_PATH    Path to prompts directory\n")
 		fmt.Fprintf(os.Stderr, "  LOOPER_SYSTEM_PROMPT   System prompt ID to use\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_EXTRA_SYSTEM_PROMPT  Instructions appended to the system prompt\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_PROJECT_FILE    Project facts file (default LOOPER.md)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_VERBOSITY       Response style preset (concise, normal, verbose)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_MODE            Agent mode (full, readonly)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_SKIP_SANDBOX_PROBE  Set to 1 to skip the startup sandbox probe\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_SKILLS_PATH     Colon-separated global skill directories (default ~/.looper/skills)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_EXTRA_SKILLS_PATH  Colon-separated additional skill directories\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_SKILL_DISCOVERY_TIMEOUT  Time to scan skill directories at startup (e.g. 30s; 0 waits)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_TOOLS_FILE      JSON file of external tool definitions\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_ALLOWED_CAPABILITIES  Tool capabilities to allow (read, write, execute, network)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_ISOLATION       Sandbox confinement backend (process, bwrap, firejail, nsjail, gvisor)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_NICE            Nice value for sandboxed commands (1-19)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_IO_PRIORITY     Disk priority for sandboxed commands (low, idle)\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_AUDIT_LOG       Audit log file; enables the execution audit log\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_ALLOWED_ENV     Comma-separated env var patterns passed to commands\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_INHERIT_ENV     Set to 1 to pass the whole environment to commands\n")
+		fmt.Fprintf(os.Stderr, "  LOOPER_KILL_GRACE      Time a timed-out command gets after SIGTERM (e.g. 10s)\n")
 	}
 
 	flag.Parse()
@@ -93,37 +151,150 @@ func main() {
 	if *model != "" {
 		config.Model = *model
 	}
+	if *downgradeModel != "" {
+		config.DowngradeModel = *downgradeModel
+	}
+	if *downgradeAt > 0 {
+		config.DowngradeAtTokens = *downgradeAt
+	}
+	if *contextWindow > 0 {
+		config.ContextWindow = *contextWindow
+	}
 	if *maxIter != 50 {
 		config.MaxIterations = *maxIter
 	}
+	if *systemPromptID != "" {
+		config.SystemPromptID = *systemPromptID
+	}
 	if *systemPrompt != "" {
+		// An explicit system prompt takes precedence over a template
 		config.SystemPrompt = *systemPrompt
+		config.SystemPromptID = ""
 	}
-	if *systemPromptID != "" {
-		config.SystemPromptID = *systemPromptID
+	if *extraSystem != "" {
+		config.ExtraSystemPrompt = *extraSystem
 	}
 	if *promptsPath != "" {
 		config.PromptsPath = *promptsPath
 	}
+	if *projectFile != "" {
+		config.ProjectFile = *projectFile
+	}
+	if *verbosity != "" {
+		config.Verbosity = *verbosity
+	}
+	if *mode != "" {
+		config.Mode = *mode
+	}
+	if *skipProbe {
+		config.SkipSandboxProbe = true
+	}
+	if *planMode {
+		config.PlanMode = true
+	}
 	if *disableBlacklist {
 		config.DisableBlacklist = true
 	}
+	if *noNetwork {
+		config.DisableNetwork = true
+	}
+	if *dryRun {
+		config.DryRun = true
+	}
+	if flagPassed("skill-discovery-timeout") {
+		config.SkillDiscoveryTimeout = *skillTimeout
+	}
+	if flagPassed("kill-grace") {
+		config.KillGrace = *killGrace
+	}
+	if *allowedCaps != "" {
+		config.AllowedCapabilities = tools.ParseCapabilities(*allowedCaps)
+	}
+	if *isolation != "" {
+		config.Isolation = *isolation
+	}
+	if flagPassed("nice") {
+		config.NicePriority = *nice
+	}
+	if *ioPriority != "" {
+		config.IOPriority = *ioPriority
+	}
 	if *blacklistFile != "" {
-		patterns, err := loadBlacklistFile(*blacklistFile)
+		patterns, err := loadListFile(*blacklistFile)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading blacklist file: %v\n", err)
 			os.Exit(1)
 		}
 		config.CommandBlacklist = patterns
 	}
+	if *noRedact {
+		config.DisableRedaction = true
+	}
+	if *allowEnv != "" {
+		for _, pattern := range strings.Split(*allowEnv, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				config.AllowedEnv = append(config.AllowedEnv, pattern)
+			}
+		}
+	}
+	if *inheritEnv {
+		config.InheritEnv = true
+	}
+	if *auditLog {
+		config.AuditLog = true
+	}
+	if *auditLogPath != "" {
+		config.AuditLog = true
+		config.AuditLogPath = *auditLogPath
+	}
+	if *writeDiffs {
+		config.ShowWriteDiffs = true
+	}
+	if *toolsFile != "" {
+		config.ExternalToolsPath = *toolsFile
+	}
+	if *allowlistFile != "" {
+		programs, err := loadListFile(*allowlistFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading allowlist file: %v\n", err)
+			os.Exit(1)
+		}
+		config.CommandAllowlist = programs
+	}
+
+	// Piped input is the prompt, e.g. echo "summarize this" | looper
+	if *prompt == "" && !*listSkills && !*listPrompts && !stdinIsTerminal() {
+		piped, err := readPipedPrompt(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		*prompt = piped
+	}
+
+	// In interactive mode overwrite confirmations and chat input share one
+	// line editor
+	var editor *lineEditor
+	if *prompt == "" {
+		editor = newLineEditor(os.Stdin, os.Stdout)
+		if *confirmOverwrite {
+			config.ConfirmOverwrite = confirmOverwritePrompt(editor)
+		}
+	}
 
 	// Create agent
-	ag, err := agent.New(config)
+	ag, err := agent.NewFromConfig(config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *verbose {
+		if caps := ag.SandboxCapabilities(); caps != nil {
+			fmt.Fprintf(os.Stderr, "%sSandbox: %s%s\n", colorDim, caps.Summary(), colorReset)
+		}
+	}
+
 	// List skills if requested
 	if *listSkills {
 		skills := ag.Context().LoadedSkills
@@ -132,8 +303,8 @@ func main() {
 		} else {
 			fmt.Println("Loaded Skills:")
 			fmt.Println("--------------")
-			for name, skill := range skills {
-				fmt.Printf("  %s\n    %s\n\n", name, skill.Description)
+			for _, name := range sortedKeys(skills) {
+				fmt.Printf("  %s%s\n    %s\n\n", name, originLabel(skills[name]), skills[name].Description)
 			}
 		}
 		os.Exit(0)
@@ -148,7 +319,8 @@ func main() {
 		} else {
 			fmt.Println("Loaded Prompts:")
 			fmt.Println("---------------")
-			for id, p := range promptsList {
+			for _, id := range sortedKeys(promptsList) {
+				p := promptsList[id]
 				fmt.Printf("  %s%s%s\n", colorCyan, id, colorReset)
 				if p.Description != "" {
 					fmt.Printf("    %s\n", p.Description)
@@ -171,8 +343,10 @@ func main() {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
+		editor.close()
 		fmt.Println("\nInterrupted. Exiting...")
 		cancel()
+		ag.Close()
 		os.Exit(0)
 	}()
 
@@ -180,40 +354,44 @@ func main() {
 	if *prompt != "" {
 		runSinglePrompt(ctx, ag, *prompt)
 	} else {
-		runInteractive(ctx, ag)
+		runInteractive(ctx, ag, editor, *idleTimeout)
+		editor.close()
 	}
+	ag.Close()
 }
 
 func runSinglePrompt(ctx context.Context, ag *agent.Agent, prompt string) {
-	handler := createStreamHandler()
+	out := newStreamWriter(os.Stdout)
+	handler := createStreamHandler(out)
 	_, err := ag.RunStream(ctx, prompt, handler)
+	out.EndLine()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "\n%sError: %v%s\n", colorRed, err, colorReset)
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed, err, colorReset)
+		ag.Close()
 		os.Exit(1)
 	}
-	fmt.Println()
 }
 
-func runInteractive(ctx context.Context, ag *agent.Agent) {
-	reader := bufio.NewReader(os.Stdin)
-
+func runInteractive(ctx context.Context, ag *agent.Agent, editor *lineEditor, idleTimeout time.Duration) {
 	fmt.Printf("%s%sLooper AI Agent%s\n", colorBold, colorCyan, colorReset)
 	fmt.Printf("%s===============%s\n", colorCyan, colorReset)
 	fmt.Printf("%sWorkspace:%s %s\n", colorDim, colorReset, ag.Context().WorkspacePath)
-	fmt.Printf("%sProvider:%s %s\n", colorDim, colorReset, ag.Registry().Names())
+	fmt.Printf("%sProvider:%s %s [%s]\n", colorDim, colorReset, ag.Provider().Name(), strings.Join(ag.Capabilities().List(), ", "))
 	fmt.Println()
 	fmt.Println("Type your message and press Enter. Commands:")
-	fmt.Printf("  %s/quit, /exit%s  - Exit the agent\n", colorYellow, colorReset)
-	fmt.Printf("  %s/clear%s        - Clear conversation history\n", colorYellow, colorReset)
-	fmt.Printf("  %s/skills%s       - List loaded skills\n", colorYellow, colorReset)
-	fmt.Printf("  %s/tools%s        - List available tools\n", colorYellow, colorReset)
-	fmt.Printf("  %s/prompts%s      - List loaded prompts\n", colorYellow, colorReset)
-	fmt.Printf("  %s/help%s         - Show this help\n", colorYellow, colorReset)
+	printCommands(true)
 	fmt.Println()
 
+	complete := func(line string) (int, []string) {
+		return completeLine(ag, line)
+	}
 	for {
-		fmt.Printf("%s%sYou:%s ", colorBold, colorGreen, colorReset)
-		input, err := reader.ReadString('\n')
+		fmt.Print(userPrompt)
+		input, err := editor.nextLine(complete, idleTimeout)
+		if errors.Is(err, errIdleTimeout) {
+			fmt.Printf("\n%sIdle timeout (%s without input), exiting.%s\n", colorYellow, idleTimeout, colorReset)
+			return
+		}
 		if err != nil {
 			break
 		}
@@ -225,66 +403,185 @@ func runInteractive(ctx context.Context, ag *agent.Agent) {
 
 		// Handle commands
 		if strings.HasPrefix(input, "/") {
-			if handleCommand(ag, input) {
+			if handleCommand(ctx, ag, input) {
 				continue
 			}
 			return // Exit command
 		}
 
-		// Run agent with streaming
+		if !runTurn(ctx, ag, input) {
+			return // Context cancelled
+		}
+	}
+}
+
+// runTurn sends input to the agent and streams the reply. It returns false
+// if the context was cancelled.
+func runTurn(ctx context.Context, ag *agent.Agent, input string) bool {
+	fmt.Println()
+	out := newStreamWriter(os.Stdout)
+	out.Printf("%s%sAssistant:%s ", colorBold, colorBlue, colorReset)
+
+	handler := createStreamHandler(out)
+	_, err := ag.RunStream(ctx, input, handler)
+	out.EndLine()
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		fmt.Printf("%sError: %v%s\n", colorRed, err, colorReset)
+		if errors.Is(err, agent.ErrPartialResponse) {
+			fmt.Printf("%sThe partial response was kept; say \"continue\" to resume.%s\n", colorDim, colorReset)
+		}
+		if errors.Is(err, agent.ErrContextWindowExceeded) {
+			fmt.Printf("%sUse /clear to start a new conversation.%s\n", colorDim, colorReset)
+		}
 		fmt.Println()
-		fmt.Printf("%s%sAssistant:%s ", colorBold, colorBlue, colorReset)
+		return true
+	}
+
+	// Show token usage
+	agCtx := ag.Context()
+	fmt.Printf("%s[Tokens: %s | Iterations: %d]%s\n\n",
+		colorDim, agCtx.Usage(), agCtx.IterationCount, colorReset)
+	return true
+}
+
+// errIdleTimeout is returned by nextLine when no input arrives in time
+var errIdleTimeout = errors.New("idle timeout")
+
+// stdinIsTerminal reports whether stdin is a terminal or other character
+// device, such as /dev/null, rather than a pipe or file
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return true
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// readPipedPrompt reads all of r as a prompt, failing if it is empty
+func readPipedPrompt(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt from stdin: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", fmt.Errorf("stdin is empty; pipe a prompt, pass -prompt, or run looper in a terminal for interactive mode")
+	}
+	return prompt, nil
+}
+
+// originLabel returns " (global)", " (workspace)" and so on for a skill's
+// origin, so listings show where a skill came from
+func originLabel(skill *skills.Skill) string {
+	if skill.Origin == "" {
+		return ""
+	}
+	return " (" + string(skill.Origin) + ")"
+}
 
-		handler := createStreamHandler()
-		_, err = ag.RunStream(ctx, input, handler)
+// sortedKeys returns the keys of m in sorted order so listings are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// confirmOverwritePrompt returns an overwrite hook that asks on the terminal.
+// Anything but "y" or "yes" declines.
+func confirmOverwritePrompt(editor *lineEditor) tools.OverwriteConfirmFunc {
+	return func(ctx context.Context, req tools.OverwriteRequest) bool {
+		fmt.Printf("\n%s%sOverwrite %s (%s -> %s)? [y/N]:%s ", colorBold, colorYellow,
+			req.Path, sandbox.FormatBytes(req.OldSize), sandbox.FormatBytes(req.NewSize), colorReset)
+		answer, err := editor.nextLine(nil, 0)
 		if err != nil {
-			if ctx.Err() != nil {
-				return // Context cancelled
-			}
-			fmt.Printf("\n%sError: %v%s\n\n", colorRed, err, colorReset)
-			continue
+			return false
 		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
 
-		fmt.Println()
+// streamWriter writes streamed agent output. It flushes after every write
+// so output appears promptly even when stdout is a pipe, serializes writes
+// so text and tool output never interleave mid-line, and tracks whether the
+// output ends with a newline so the closing newline is not doubled.
+type streamWriter struct {
+	mu          sync.Mutex
+	w           *bufio.Writer
+	atLineStart bool
+}
+
+func newStreamWriter(w io.Writer) *streamWriter {
+	return &streamWriter{w: bufio.NewWriter(w), atLineStart: true}
+}
 
-		// Show token usage
-		agCtx := ag.Context()
-		fmt.Printf("%s[Tokens: %d in / %d out | Iterations: %d]%s\n\n",
-			colorDim, agCtx.TotalInputTokens, agCtx.TotalOutputTokens, agCtx.IterationCount, colorReset)
+// Printf formats and writes output, then flushes it
+func (sw *streamWriter) Printf(format string, args ...interface{}) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	text := fmt.Sprintf(format, args...)
+	if text == "" {
+		return
 	}
+	sw.w.WriteString(text)
+	sw.w.Flush()
+	sw.atLineStart = strings.HasSuffix(strings.TrimSuffix(text, colorReset), "\n")
 }
 
-// createStreamHandler creates a StreamHandler with colored output
-func createStreamHandler() *agent.StreamHandler {
+// EndLine terminates the current line unless the output already ends with
+// a newline
+func (sw *streamWriter) EndLine() {
+	sw.mu.Lock()
+	atLineStart := sw.atLineStart
+	sw.mu.Unlock()
+
+	if !atLineStart {
+		sw.Printf("\n")
+	}
+}
+
+// createStreamHandler creates a StreamHandler with colored output written
+// to out
+func createStreamHandler(out *streamWriter) *agent.StreamHandler {
 	return &agent.StreamHandler{
 		OnText: func(text string) {
-			fmt.Print(text)
+			out.Printf("%s", text)
 		},
 		OnToolStart: func(tc llm.ToolCall) {
-			fmt.Printf("\n\n%s%s▶ Tool Call: %s%s\n", colorBold, colorMagenta, tc.Name, colorReset)
-			// Pretty print the arguments
-			var args map[string]interface{}
-			if err := json.Unmarshal(tc.Arguments, &args); err == nil {
-				prettyArgs, _ := json.MarshalIndent(args, "  ", "  ")
-				fmt.Printf("  %s%s%s\n", colorDim, string(prettyArgs), colorReset)
-			} else {
-				fmt.Printf("  %s%s%s\n", colorDim, string(tc.Arguments), colorReset)
+			out.Printf("\n\n%s%s▶ Tool Call:%s\n  %s%s%s\n", colorBold, colorMagenta, colorReset,
+				colorDim, strings.ReplaceAll(tc.String(), "\n", "\n  "), colorReset)
+		},
+		OnToolOutput: func(tc llm.ToolCall, stream, chunk string) {
+			// Tail command output live while the tool runs
+			if !strings.HasSuffix(chunk, "\n") {
+				chunk += "\n"
 			}
+			out.Printf("  %s%s%s", colorDim, chunk, colorReset)
+		},
+		OnToolProgress: func(tc llm.ToolCall, message string) {
+			out.Printf("  %s⏳ %s%s\n", colorYellow, message, colorReset)
 		},
 		OnToolEnd: func(tc llm.ToolCall, result string, err error) {
 			if err != nil {
-				fmt.Printf("%s%s✗ Error: %s%s\n", colorBold, colorRed, err.Error(), colorReset)
+				out.Printf("%s%s✗ Error: %s%s\n", colorBold, colorRed, err.Error(), colorReset)
 			} else {
 				// Truncate long results for display
-				displayResult := result
-				if len(displayResult) > 500 {
-					displayResult = displayResult[:500] + "... (truncated)"
-				}
+				displayResult := truncate.Bytes(result, 500)
 				// Replace newlines with indented newlines for readability
 				displayResult = strings.ReplaceAll(displayResult, "\n", "\n  ")
-				fmt.Printf("%s%s✓ Result:%s\n  %s%s%s\n", colorBold, colorGreen, colorReset, colorDim, displayResult, colorReset)
+				out.Printf("%s%s✓ Result:%s\n  %s%s%s\n", colorBold, colorGreen, colorReset, colorDim, displayResult, colorReset)
 			}
-			fmt.Printf("\n%s%sAssistant:%s ", colorBold, colorBlue, colorReset)
+			out.Printf("\n%s%sAssistant:%s ", colorBold, colorBlue, colorReset)
 		},
 		OnUsage: func(inputTokens, outputTokens int) {
 			// Usage is displayed after the loop
@@ -296,7 +593,7 @@ func createStreamHandler() *agent.StreamHandler {
 }
 
 // handleCommand processes CLI commands. Returns false if should exit.
-func handleCommand(ag *agent.Agent, input string) bool {
+func handleCommand(ctx context.Context, ag *agent.Agent, input string) bool {
 	parts := strings.Fields(input)
 	cmd := strings.ToLower(parts[0])
 
@@ -313,19 +610,66 @@ func handleCommand(ag *agent.Agent, input string) bool {
 
 	case "/skills":
 		skills := ag.Context().LoadedSkills
+		if len(parts) > 1 {
+			skill, ok := skills[parts[1]]
+			if !ok {
+				fmt.Printf("Unknown skill: %s\n\n", parts[1])
+				return true
+			}
+			fmt.Printf("%s%s%s: %s\n", colorCyan, skill.Name, colorReset, skill.Description)
+			fmt.Printf("%sSource: %s%s%s\n\n", colorDim, skill.FilePath, originLabel(skill), colorReset)
+			fmt.Println(skill.Content)
+			fmt.Println()
+			return true
+		}
 		if len(skills) == 0 {
 			fmt.Println("No skills loaded.")
 			fmt.Println()
 		} else {
 			fmt.Println("Loaded Skills:")
-			for name, skill := range skills {
-				fmt.Printf("  - %s: %s\n", name, skill.Description)
+			for _, name := range sortedKeys(skills) {
+				fmt.Printf("  - %s%s: %s\n", name, originLabel(skills[name]), skills[name].Description)
 			}
 			fmt.Println()
 		}
 		return true
 
+	case "/skill":
+		if len(parts) != 3 || strings.ToLower(parts[1]) != "load" {
+			fmt.Println("Usage: /skill load <name>")
+			fmt.Println()
+			return true
+		}
+		// Rescan so skills added or edited since startup are found
+		if err := ag.Discovery().Refresh(); err != nil {
+			fmt.Printf("%sWarning: skill discovery: %v%s\n", colorYellow, err, colorReset)
+		}
+		if err := ag.LoadSkill(parts[2]); err != nil {
+			fmt.Printf("%sError: %v%s\n\n", colorRed, err, colorReset)
+			return true
+		}
+		skill := ag.Context().LoadedSkills[parts[2]]
+		fmt.Printf("Loaded skill %s%s%s%s: %s\n\n", colorCyan, skill.Name, colorReset, originLabel(skill), skill.Description)
+		return true
+
 	case "/tools":
+		if len(parts) > 1 {
+			tool, ok := ag.Registry().Get(parts[1])
+			if !ok {
+				fmt.Printf("Unknown tool: %s\n\n", parts[1])
+				return true
+			}
+			fmt.Printf("%s%s%s: %s\n", colorCyan, tool.Name(), colorReset, tool.Description())
+			if capable, ok := tool.(tools.CapableTool); ok {
+				caps := make([]string, len(capable.Capabilities()))
+				for i, c := range capable.Capabilities() {
+					caps[i] = string(c)
+				}
+				fmt.Printf("%sCapabilities: %s%s\n", colorDim, strings.Join(caps, ", "), colorReset)
+			}
+			fmt.Println()
+			return true
+		}
 		tools := ag.Registry().Names()
 		fmt.Println("Available Tools:")
 		for _, name := range tools {
@@ -336,12 +680,26 @@ func handleCommand(ag *agent.Agent, input string) bool {
 
 	case "/prompts":
 		promptsList := ag.PromptLoader().GetAll()
+		if len(parts) > 1 {
+			p, ok := promptsList[parts[1]]
+			if !ok {
+				fmt.Printf("Unknown prompt: %s\n\n", parts[1])
+				return true
+			}
+			if p.SourceFile != "" {
+				fmt.Printf("%sSource: %s%s\n", colorDim, p.SourceFile, colorReset)
+			}
+			fmt.Println(p.Content)
+			fmt.Println()
+			return true
+		}
 		if len(promptsList) == 0 {
 			fmt.Println("No prompts loaded.")
 			fmt.Println()
 		} else {
 			fmt.Println("Loaded Prompts:")
-			for id, p := range promptsList {
+			for _, id := range sortedKeys(promptsList) {
+				p := promptsList[id]
 				fmt.Printf("  - %s%s%s", colorCyan, id, colorReset)
 				if p.Description != "" {
 					fmt.Printf(": %s", p.Description)
@@ -352,14 +710,92 @@ func handleCommand(ag *agent.Agent, input string) bool {
 		}
 		return true
 
+	case "/system":
+		if path := ag.ProjectFile(); path != "" {
+			fmt.Printf("%sProject file:%s %s\n", colorDim, colorReset, path)
+		} else {
+			fmt.Printf("%sNo project file loaded.%s\n", colorDim, colorReset)
+		}
+		fmt.Println(ag.SystemPrompt())
+		fmt.Println()
+		return true
+
+	case "/tokens":
+		fmt.Println("Estimated tokens in the next request:")
+		fmt.Println(ag.TokenBreakdown())
+		fmt.Println()
+		fmt.Println("Tokens spent answering each tool's results:")
+		fmt.Println(ag.TokenUsageReport())
+		fmt.Println()
+		return true
+
+	case "/retry-tool":
+		tc := ag.Context().GetLastToolCall()
+		if tc == nil {
+			fmt.Println("No tool call to retry.")
+			fmt.Println()
+			return true
+		}
+
+		handler := createStreamHandler(newStreamWriter(os.Stdout))
+		handler.OnToolStart(*tc)
+		result, err := ag.ExecuteToolCall(ctx, *tc)
+		if err != nil {
+			fmt.Printf("%s%s✗ Error: %s%s\n", colorBold, colorRed, err.Error(), colorReset)
+			result = fmt.Sprintf("Error: %s", err.Error())
+		} else {
+			fmt.Printf("%s%s✓ Result:%s\n  %s%s\n", colorBold, colorGreen, colorReset,
+				strings.ReplaceAll(result, "\n", "\n  "), colorReset)
+		}
+
+		// Only touch the conversation when explicitly asked to
+		if len(parts) > 1 && parts[1] == "record" {
+			ag.Context().AddUserMessage(fmt.Sprintf("I re-ran the %s tool call manually. Result:\n%s", tc.Name, result))
+			fmt.Println("Result recorded in the conversation.")
+		}
+		fmt.Println()
+		return true
+
+	case "/verbosity":
+		if len(parts) < 2 {
+			fmt.Printf("Verbosity: %s (available: %s)\n\n", ag.Verbosity(), strings.Join(ag.VerbosityPresets(), ", "))
+			return true
+		}
+		if err := ag.SetVerbosity(parts[1]); err != nil {
+			fmt.Printf("%s%v%s\n\n", colorRed, err, colorReset)
+			return true
+		}
+		fmt.Printf("Verbosity set to %s.\n\n", ag.Verbosity())
+		return true
+
+	case "/plan":
+		ag.SetPlanMode(!ag.PlanMode())
+		if ag.PlanMode() {
+			fmt.Println("Plan mode on: only read-only tools will run. Use /approve once the plan looks right.")
+		} else {
+			fmt.Println("Plan mode off.")
+		}
+		fmt.Println()
+		return true
+
+	case "/approve":
+		if !ag.PlanMode() {
+			fmt.Println("Not in plan mode.")
+			fmt.Println()
+			return true
+		}
+		ag.SetPlanMode(false)
+		fmt.Println("Plan approved. Plan mode off.")
+		message := agent.PlanApprovedMessage
+		if len(parts) > 1 {
+			// Extra instructions ride along with the approval
+			message += " " + strings.TrimSpace(strings.TrimPrefix(input, parts[0]))
+		}
+		return runTurn(ctx, ag, message)
+
 	case "/help":
 		fmt.Println("Commands:")
-		fmt.Println("  /quit, /exit  - Exit the agent")
-		fmt.Println("  /clear        - Clear conversation history")
-		fmt.Println("  /skills       - List loaded skills")
-		fmt.Println("  /tools        - List available tools")
-		fmt.Println("  /prompts      - List loaded prompts")
-		fmt.Println("  /help         - Show this help")
+		printCommands(false)
 		fmt.Println()
 		return true
 
@@ -369,8 +805,19 @@ func handleCommand(ag *agent.Agent, input string) bool {
 	}
 }
 
-// loadBlacklistFile reads a blacklist file with one pattern per line
-func loadBlacklistFile(path string) ([]string, error) {
+// flagPassed reports whether the named flag was set on the command line
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// loadListFile reads a blacklist or allowlist file with one entry per line
+func loadListFile(path string) ([]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// enableCharMode is unsupported here, so input is read a line at a time
+// without tab completion
+func enableCharMode(fd int) (restore func(), err error) {
+	return nil, errors.New("terminal character mode is not supported on this platform")
+}
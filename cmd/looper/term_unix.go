@@ -0,0 +1,34 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// enableCharMode switches the terminal on fd to reading a character at a
+// time without echo, so the line editor can handle Tab, and returns a
+// function restoring the previous mode. Output processing and signals such
+// as Ctrl-C are left as they were.
+func enableCharMode(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	if err := termiosIoctl(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+	mode := old
+	mode.Lflag &^= syscall.ICANON | syscall.ECHO
+	mode.Cc[syscall.VMIN] = 1
+	mode.Cc[syscall.VTIME] = 0
+	if err := termiosIoctl(fd, ioctlSetTermios, &mode); err != nil {
+		return nil, err
+	}
+	return func() { termiosIoctl(fd, ioctlSetTermios, &old) }, nil
+}
+
+func termiosIoctl(fd int, request uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -3,6 +3,6 @@ module github.com/looper-ai/looper
 go 1.23.4
 
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/joho/godotenv v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -3,9 +3,17 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/prompts"
 	"github.com/looper-ai/looper/pkg/sandbox"
 	"github.com/looper-ai/looper/pkg/skills"
 	"github.com/looper-ai/looper/pkg/tools"
@@ -13,15 +21,34 @@ import (
 
 // Agent represents an AI agent with tools and skills
 type Agent struct {
-	config    *Config
-	provider  llm.Provider
-	registry  *tools.Registry
-	discovery *skills.Discovery
-	ctx       *Context
+	config        *Config
+	provider      llm.Provider
+	sandbox       sandbox.Sandbox
+	auditLog      *os.File
+	planMode      atomic.Bool
+	downgraded    atomic.Bool // Whether the last request used DowngradeModel
+	contextWarned atomic.Bool // Whether the context window warning is in effect
+	registry      *tools.Registry
+	discovery     *skills.Discovery
+	promptLoader  *prompts.Loader
+	project       *projectContext
+	capabilities  *sandbox.Capabilities // Probed at creation, if enabled
+	ctx           *Context
 }
 
-// New creates a new agent with the given configuration
-func New(config *Config) (*Agent, error) {
+// New creates a new agent from DefaultConfig with the given options applied
+//
+//	ag, err := agent.New(agent.WithProvider("openai"), agent.WithModel("gpt-4o"))
+func New(opts ...Option) (*Agent, error) {
+	config := DefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return NewFromConfig(config)
+}
+
+// NewFromConfig creates a new agent with the given configuration
+func NewFromConfig(config *Config) (_ *Agent, err error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
@@ -38,46 +65,133 @@ func New(config *Config) (*Agent, error) {
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", config.Provider)
 	}
+	if err := validateDowngrade(config); err != nil {
+		return nil, err
+	}
+	if err := validateMode(config); err != nil {
+		return nil, err
+	}
+	if err := tools.CheckCapabilities(config.AllowedCapabilities); err != nil {
+		return nil, err
+	}
+	readOnly := config.Mode == ModeReadOnly
 
 	// Create tool registry
 	registry := tools.NewRegistry()
 
-	// Create sandbox
-	sandboxConfig := sandbox.DefaultConfig(config.WorkspacePath)
+	// Read-only agents get no sandbox and only the tools that read the
+	// workspace
+	var sb *sandbox.ProcessSandbox
+	var auditLog *os.File
+	var capabilities *sandbox.Capabilities
+	if readOnly {
+		registerReadOnlyTools(registry, config)
+	} else {
+		sb, auditLog, err = newSandbox(config)
+		if err != nil {
+			return nil, err
+		}
+		// Release the sandbox and audit log if a later step fails
+		defer func() {
+			if err != nil {
+				sb.Close()
+				if auditLog != nil {
+					auditLog.Close()
+				}
+			}
+		}()
+		if !config.SkipSandboxProbe {
+			if capabilities, err = probeSandbox(config, sb); err != nil {
+				return nil, err
+			}
+		}
+		registerTools(registry, config, sb)
 
-	// Configure command blacklist
-	if config.DisableBlacklist {
-		sandboxConfig.CommandBlacklist = nil
-	} else if config.CommandBlacklist != nil {
-		sandboxConfig.CommandBlacklist = config.CommandBlacklist
+		// Register external tools
+		if config.ExternalToolsPath != "" {
+			externalTools, err := tools.LoadExternalTools(config.ExternalToolsPath, sb)
+			if err != nil {
+				return nil, err
+			}
+			for _, tool := range externalTools {
+				if err := registry.Register(tool); err != nil {
+					return nil, fmt.Errorf("failed to register external tool: %w", err)
+				}
+			}
+		}
 	}
-	// else use the default blacklist from sandbox.DefaultConfig
 
-	sb := sandbox.NewProcessSandbox(sandboxConfig)
-
-	// Register built-in tools
-	registry.Register(tools.NewReadFileTool(config.WorkspacePath))
-	registry.Register(tools.NewWriteFileTool(config.WorkspacePath))
-	registry.Register(tools.NewGrepTool(config.WorkspacePath))
-	registry.Register(tools.NewListDirTool(config.WorkspacePath))
-	registry.Register(tools.NewExecuteTool(sb))
-	registry.Register(tools.NewBashTool(sb))
+	for _, tool := range config.Tools {
+		if err := registry.Register(tool); err != nil {
+			return nil, fmt.Errorf("failed to register tool: %w", err)
+		}
+	}
+	warnUndeclaredCapabilities(config, registry.List())
 
 	// Create skill discovery
-	discovery := skills.NewDiscovery(config.WorkspacePath)
-	discovery.Discover()
+	discovery := skills.NewDiscovery(&skills.DiscoveryConfig{
+		WorkspaceRoot:       config.WorkspacePath,
+		GlobalSkillDirs:     config.GlobalSkillDirs,
+		AdditionalSkillDirs: config.ExtraSkillDirs,
+	})
+	discoverCtx := context.Background()
+	if config.SkillDiscoveryTimeout > 0 {
+		var cancel context.CancelFunc
+		discoverCtx, cancel = context.WithTimeout(discoverCtx, config.SkillDiscoveryTimeout)
+		defer cancel()
+	}
+	if err := discovery.DiscoverWithContext(discoverCtx); errors.Is(err, skills.ErrDiscoveryIncomplete) {
+		log.Printf("WARNING: skill discovery did not finish within %s; using the skills found so far", config.SkillDiscoveryTimeout)
+	}
+
+	// Create prompt loader and resolve the system prompt template
+	promptsPath := config.PromptsPath
+	if promptsPath == "" {
+		promptsPath = filepath.Join(config.WorkspacePath, "prompts")
+	}
+	promptLoader := prompts.NewLoader(promptsPath)
+	if config.SystemPromptID != "" {
+		prompt, ok := promptLoader.Get(config.SystemPromptID)
+		if !ok {
+			return nil, fmt.Errorf("system prompt %q not found in prompts directory %q", config.SystemPromptID, promptLoader.Directory())
+		}
+		config.SystemPrompt = prompt.Content
+	}
+	if readOnly && config.SystemPrompt == defaultSystemPrompt {
+		config.SystemPrompt = readOnlySystemPrompt
+	}
+
+	if config.Verbosity != "" {
+		if _, ok := config.verbosityPresets()[config.Verbosity]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownVerbosity, config.Verbosity)
+		}
+	}
+
+	project, err := loadProjectContext(config)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create context
 	agentCtx := NewContext(config.WorkspacePath)
 
 	agent := &Agent{
-		config:    config,
-		provider:  provider,
-		registry:  registry,
-		discovery: discovery,
-		ctx:       agentCtx,
+		config:       config,
+		provider:     provider,
+		auditLog:     auditLog,
+		registry:     registry,
+		discovery:    discovery,
+		promptLoader: promptLoader,
+		project:      project,
+		capabilities: capabilities,
+		ctx:          agentCtx,
 	}
 
+	if sb != nil {
+		agent.sandbox = sb
+	}
+	agent.planMode.Store(config.PlanMode)
+
 	// Auto-load all discovered skills
 	allSkills, _ := discovery.GetAll()
 	for _, skill := range allSkills {
@@ -87,21 +201,163 @@ func New(config *Config) (*Agent, error) {
 	return agent, nil
 }
 
+// newSandbox creates the sandbox tools run commands in, and opens the audit
+// log if one is configured
+func newSandbox(config *Config) (*sandbox.ProcessSandbox, *os.File, error) {
+	sandboxConfig := sandbox.DefaultConfig(config.WorkspacePath)
+
+	// Configure command blacklist
+	if config.DisableBlacklist {
+		sandboxConfig.CommandBlacklist = nil
+	} else if config.CommandBlacklist != nil {
+		sandboxConfig.CommandBlacklist = config.CommandBlacklist
+	}
+	// else use the default blacklist from sandbox.DefaultConfig
+
+	sandboxConfig.CommandAllowlist = config.CommandAllowlist
+	sandboxConfig.DisableNetwork = config.DisableNetwork
+	sandboxConfig.DryRun = config.DryRun
+	sandboxConfig.Isolation = sandbox.IsolationBackend(config.Isolation)
+	sandboxConfig.NicePriority = config.NicePriority
+	sandboxConfig.IOPriority = config.IOPriority
+	sandboxConfig.DisableRedaction = config.DisableRedaction
+	sandboxConfig.AllowedEnv = append(sandboxConfig.AllowedEnv, config.AllowedEnv...)
+	sandboxConfig.InheritAllEnv = config.InheritEnv
+	if config.DeniedEnv != nil {
+		sandboxConfig.DeniedEnv = config.DeniedEnv
+	}
+	sandboxConfig.Interpreters = config.Interpreters
+	for name, limits := range config.PerInterpreterLimits {
+		sandboxConfig.PerInterpreterLimits[name] = limits
+	}
+	if config.MaxCommandTimeout > 0 {
+		sandboxConfig.MaxTimeout = config.MaxCommandTimeout
+	}
+	sandboxConfig.KillGrace = config.KillGrace
+	sandboxConfig.OnBlocked = config.OnBlockedCommand
+
+	var auditLog *os.File
+	if config.AuditLog {
+		f, prevHash, err := openAuditLog(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		auditLog = f
+		sandboxConfig.AuditLog = f
+		sandboxConfig.AuditPrevHash = prevHash
+	}
+
+	sb, err := sandbox.NewProcessSandbox(sandboxConfig)
+	if err != nil {
+		if auditLog != nil {
+			auditLog.Close()
+		}
+		return nil, nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	return sb, auditLog, nil
+}
+
+// registerTools registers the built-in tools of ModeFull
+func registerTools(registry *tools.Registry, config *Config, sb *sandbox.ProcessSandbox) {
+	registry.Register(tools.NewReadFileTool(config.WorkspacePath, tools.WithReadOnlyRoots(config.skillDirs()...)))
+	registry.Register(tools.NewWriteFileTool(config.WorkspacePath,
+		tools.WithOverwriteConfirm(config.ConfirmOverwrite),
+		tools.WithShowDiffs(config.ShowWriteDiffs)))
+	registry.Register(tools.NewGrepTool(config.WorkspacePath))
+	registry.Register(tools.NewSearchReadTool(config.WorkspacePath))
+	registry.Register(tools.NewListDirTool(config.WorkspacePath))
+	registry.Register(tools.NewExecuteTool(sb))
+	registry.Register(tools.NewShellTool(sb))
+	registry.Register(tools.NewWaitTool(config.WorkspacePath, sb, config.MaxWaitTimeout))
+	registry.Register(tools.NewProcessTool(sb))
+	registry.Register(tools.NewFormatTool(config.WorkspacePath, sb, config.Formatters))
+}
+
+// Close stops any background processes the agent started and closes the
+// audit log
+func (a *Agent) Close() error {
+	var err error
+	if a.sandbox != nil {
+		err = a.sandbox.Close()
+	}
+	if a.auditLog != nil {
+		if closeErr := a.auditLog.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// openAuditLog opens the audit log for appending and returns the hash its
+// new entries chain from
+func openAuditLog(config *Config) (*os.File, string, error) {
+	path := config.AuditLogPath
+	if path == "" {
+		path = filepath.Join(config.WorkspacePath, ".looper", "audit.jsonl")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return f, sandbox.AuditChainHead(existing), nil
+}
+
 // Context returns the agent's conversation context
 func (a *Agent) Context() *Context {
 	return a.ctx
 }
 
+// Provider returns the agent's LLM provider
+func (a *Agent) Provider() llm.Provider {
+	return a.provider
+}
+
+// Capabilities reports the features supported by the agent's provider and model
+func (a *Agent) Capabilities() llm.Capabilities {
+	return a.provider.Capabilities()
+}
+
 // Registry returns the agent's tool registry
 func (a *Agent) Registry() *tools.Registry {
 	return a.registry
 }
 
+// SetRegistry replaces the agent's tool registry. Subsequent iterations
+// advertise and execute only the tools in the new registry.
+func (a *Agent) SetRegistry(r *tools.Registry) {
+	if r == nil {
+		r = tools.NewRegistry()
+	}
+	a.registry = r
+}
+
+// AddTool registers an additional tool with the agent
+func (a *Agent) AddTool(t tools.Tool) error {
+	if err := a.registry.Register(t); err != nil {
+		return fmt.Errorf("failed to add tool: %w", err)
+	}
+	warnUndeclaredCapabilities(a.config, []tools.Tool{t})
+	return nil
+}
+
 // Discovery returns the skill discovery instance
 func (a *Agent) Discovery() *skills.Discovery {
 	return a.discovery
 }
 
+// PromptLoader returns the prompt template loader
+func (a *Agent) PromptLoader() *prompts.Loader {
+	return a.promptLoader
+}
+
 // LoadSkill loads a skill by name
 func (a *Agent) LoadSkill(name string) error {
 	skill, err := a.discovery.Get(name)
@@ -120,26 +376,36 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 	// Add user message to context
 	a.ctx.AddUserMessage(userMessage)
 
+	// partial is the latest text from the model, reported if the run stops
+	// at the iteration limit
+	var partial string
+
+	// resumed is the text of paused responses the final response continues
+	var resumed string
+
+	// prefill seeds only the first response of the run
+	prefill := a.config.AssistantPrefill
+
 	// Run the agent loop
 	for {
 		// Check iteration limit
 		if a.config.MaxIterations > 0 && a.ctx.IterationCount >= a.config.MaxIterations {
-			return "", fmt.Errorf("max iterations (%d) reached", a.config.MaxIterations)
+			return "", &MaxIterationsError{Iterations: a.config.MaxIterations, PartialResult: partial}
 		}
 		a.ctx.IterationCount++
 
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return "", cancelledError(ctx)
 		default:
 		}
 
 		// Build system prompt with active skills
-		systemPrompt := a.config.SystemPrompt + a.ctx.GetSkillPrompt()
+		systemPrompt := a.buildSystemPrompt()
 
 		// Build tool definitions
-		toolDefs := tools.ToDefinitions(a.registry.List())
+		toolDefs := tools.ToDefinitions(a.availableTools())
 
 		// Create completion request
 		req := &llm.CompletionRequest{
@@ -149,18 +415,50 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 			MaxTokens: a.config.MaxTokens,
 			System:    systemPrompt,
 		}
+		req.AssistantPrefill = a.assistantPrefill(prefill)
+		req.Model = a.requestModel(req)
+		if err := a.checkContextWindow(req); err != nil {
+			return "", err
+		}
 
 		// Call LLM
 		resp, err := a.provider.Complete(ctx, req)
 		if err != nil {
-			return "", fmt.Errorf("LLM error: %w", err)
+			if ctx.Err() != nil {
+				return "", cancelledError(ctx)
+			}
+			return "", newProviderError(a.provider.Name(), err)
 		}
+		prefill = ""
 
 		// Update usage stats
 		a.ctx.UpdateUsage(resp.Usage)
+		a.ctx.attributeToolUsage(resp.Usage)
+
+		// A refusal or filtered response ends the turn; asking again would
+		// only repeat it
+		if llm.Declined(resp.StopReason) {
+			a.ctx.AddAssistantMessage(resp.Content)
+			return resp.Content, nil
+		}
+
+		if resp.Content != "" {
+			partial = resp.Content
+		}
+
+		if llm.Paused(resp.StopReason) && len(resp.ToolCalls) == 0 {
+			kept, err := a.pauseTurn(resp.Content)
+			if err != nil {
+				return "", err
+			}
+			resumed += kept
+			continue
+		}
 
 		// Handle response
 		if len(resp.ToolCalls) > 0 {
+			resumed = ""
+
 			// Add assistant message with tool calls
 			a.ctx.AddMessage(llm.NewAssistantToolCallMessage(resp.ToolCalls))
 
@@ -168,9 +466,10 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 			for _, tc := range resp.ToolCalls {
 				result, err := a.executeTool(ctx, tc)
 				if err != nil {
-					result = fmt.Sprintf("Error: %s", err.Error())
+					a.addToolError(tc.ID, err)
+					continue
 				}
-				a.ctx.AddToolResult(tc.ID, result)
+				a.addToolResult(tc.ID, result)
 			}
 
 			// Continue the loop to get next response
@@ -182,30 +481,204 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 			a.ctx.AddAssistantMessage(resp.Content)
 		}
 
-		return resp.Content, nil
+		return resumed + resp.Content, nil
+	}
+}
+
+// assistantPrefill returns the prefill for a request, or "" when the
+// conversation ends with a paused response the model should continue as is
+func (a *Agent) assistantPrefill(prefill string) string {
+	if n := len(a.ctx.Messages); n > 0 && a.ctx.Messages[n-1].Role == llm.RoleAssistant {
+		return ""
 	}
+	return prefill
+}
+
+// pauseTurn keeps the text of a paused response in the conversation, so the
+// next request continues it, and returns the text kept. Trailing whitespace
+// is dropped because Anthropic rejects a final assistant message ending in
+// whitespace. A paused response with no text is an ErrEmptyPause: resuming
+// would send the same request again.
+func (a *Agent) pauseTurn(content string) (string, error) {
+	content = strings.TrimRight(content, " \t\r\n")
+	if content == "" {
+		return "", ErrEmptyPause
+	}
+	a.ctx.AddAssistantMessage(content)
+	return content, nil
+}
+
+// ExecuteToolCall runs a tool call directly, without involving the model or
+// modifying the conversation. It is useful for replaying a call while
+// debugging a tool.
+func (a *Agent) ExecuteToolCall(ctx context.Context, tc llm.ToolCall) (string, error) {
+	result, err := a.executeTool(ctx, tc)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// addToolResult adds a tool result to the conversation. Images are kept
+// only if the provider can see them; otherwise a note replaces them.
+func (a *Agent) addToolResult(toolCallID string, result *tools.Result) {
+	if len(result.Images) == 0 {
+		a.ctx.AddToolResult(toolCallID, result.Text)
+		return
+	}
+	if !a.provider.Capabilities().Vision {
+		a.ctx.AddToolResult(toolCallID, fmt.Sprintf("%s\n[%d image(s) omitted: the model does not support images]", result.Text, len(result.Images)))
+		return
+	}
+	a.ctx.AddToolResultWithImages(toolCallID, result.Text, result.Images)
+}
+
+// addToolError adds the error of a failed tool call to the conversation as
+// an errored tool result
+func (a *Agent) addToolError(toolCallID string, err error) {
+	a.ctx.AddToolError(toolCallID, fmt.Sprintf("Error: %s", err.Error()))
+}
+
+// buildSystemPrompt combines the configured prompts with the active skills
+func (a *Agent) buildSystemPrompt() string {
+	return a.baseSystemPrompt() + a.ctx.GetSkillPrompt()
+}
+
+// baseSystemPrompt is the system prompt without the skill references
+func (a *Agent) baseSystemPrompt() string {
+	prompt := a.config.SystemPrompt
+	if a.config.ExtraSystemPrompt != "" {
+		prompt += "\n" + a.config.ExtraSystemPrompt
+	}
+	if a.project != nil {
+		prompt += a.project.prompt()
+	}
+	prompt += a.environmentPrompt()
+	if directive := a.verbosityDirective(); directive != "" {
+		prompt += "\n\n" + directive
+	}
+	if a.PlanMode() {
+		prompt += a.planModeSystemPrompt()
+	}
+	return prompt
 }
 
 // executeTool runs a tool and returns the result
-func (a *Agent) executeTool(ctx context.Context, tc llm.ToolCall) (string, error) {
+func (a *Agent) executeTool(ctx context.Context, tc llm.ToolCall) (*tools.Result, error) {
 	tool, ok := a.registry.Get(tc.Name)
 	if !ok {
-		return "", fmt.Errorf("unknown tool: %s", tc.Name)
+		return nil, &ToolNotFoundError{Name: tc.Name}
 	}
 
 	// Parse arguments
 	var args map[string]interface{}
 	if err := json.Unmarshal(tc.Arguments, &args); err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
+		return nil, fmt.Errorf("invalid arguments: %w", err)
 	}
 
-	// Execute tool
-	result, err := tool.Execute(ctx, args)
-	if err != nil {
-		return "", err
+	if err := a.checkPlanMode(tool); err != nil {
+		return nil, err
+	}
+	if err := a.checkCapabilities(tool); err != nil {
+		return nil, err
+	}
+
+	if a.config.ToolPolicy != nil {
+		if allowed, reason := a.config.ToolPolicy(tc.Name, args); !allowed {
+			return nil, fmt.Errorf("%w: %s", ErrToolDenied, reason)
+		}
+	}
+
+	policy, hasPolicy := a.config.ToolRetries[tc.Name]
+
+	var retryReasons []string
+	for {
+		// Execute tool
+		report := &tools.ExecutionReport{}
+		timeout := a.config.toolTimeout(tc.Name)
+		toolCtx, cancel := withTimeout(ctx, timeout)
+		result, err := tools.ExecuteResult(tools.WithExecutionReport(toolCtx, report), tool, args)
+		if ctx.Err() == nil && errors.Is(toolCtx.Err(), context.DeadlineExceeded) {
+			if err != nil {
+				err = fmt.Errorf("%w after %s: %w", ErrToolTimeout, timeout, err)
+			} else {
+				result.Text = fmt.Sprintf("[%s after %s]\n%s", ErrToolTimeout, timeout, result.Text)
+			}
+		}
+		cancel()
+
+		reason := ""
+		if hasPolicy && len(retryReasons) < policy.MaxRetries {
+			reason = policy.retryReason(err, report)
+		}
+		if reason == "" {
+			if len(retryReasons) > 0 {
+				note := fmt.Sprintf("retried %d time(s): %s", len(retryReasons), strings.Join(retryReasons, "; "))
+				if err != nil {
+					return nil, toolError(tc.Name, fmt.Errorf("%w (%s)", err, note))
+				}
+				result.Text = fmt.Sprintf("[%s]\n%s", note, result.Text)
+			}
+			if err != nil {
+				return nil, toolError(tc.Name, err)
+			}
+			return result, nil
+		}
+		retryReasons = append(retryReasons, reason)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(policy.Delay):
+		}
+	}
+}
+
+// interruptedToolResult answers tool calls kept from an interrupted stream
+const interruptedToolResult = "Error: not executed because the response was interrupted; call the tool again if it is still needed"
+
+// keepPartialResponse adds the output of an interrupted stream to the
+// conversation. Tool calls are answered without running them, since the
+// rest of the response that may have depended on them is lost.
+func (a *Agent) keepPartialResponse(content string, toolCalls []llm.ToolCall) {
+	if len(toolCalls) == 0 {
+		a.ctx.AddAssistantMessage(content)
+		return
 	}
+	msg := llm.NewAssistantToolCallMessage(toolCalls)
+	msg.Content = content
+	a.ctx.AddMessage(msg)
+	for _, tc := range toolCalls {
+		a.ctx.AddToolError(tc.ID, interruptedToolResult)
+	}
+}
 
-	return result, nil
+// withTimeout is context.WithTimeout, or context.WithCancel when timeout is
+// not positive
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retryReason returns why a tool call should be retried under this policy,
+// or "" if it should not. Blacklist, cancellation, and validation errors are
+// never retried.
+func (p RetryPolicy) retryReason(err error, report *tools.ExecutionReport) string {
+	if err != nil {
+		if sandbox.ClassifyError(err) == sandbox.ErrorClassTransient {
+			return "transient error: " + err.Error()
+		}
+		return ""
+	}
+	switch {
+	case report.Ran && report.TimedOut && p.RetryOnTimeout:
+		return "timed out"
+	case report.Ran && !report.TimedOut && report.ExitCode != 0 && p.RetryOnNonZeroExit:
+		return fmt.Sprintf("exit code %d", report.ExitCode)
+	}
+	return ""
 }
 
 // Reset clears the conversation context
@@ -225,6 +698,20 @@ type StreamHandler struct {
 	OnToolEnd   func(toolCall llm.ToolCall, result string, err error)
 	OnUsage     func(inputTokens, outputTokens int)
 	OnDone      func()
+
+	// OnToolOutput receives command output line by line while a tool runs.
+	// Calls for a tool happen after its OnToolStart and before its OnToolEnd.
+	OnToolOutput func(toolCall llm.ToolCall, stream, chunk string)
+
+	// OnToolProgress receives periodic progress messages while a tool runs
+	// a slow command, e.g. "still running after 30s: <latest stderr line>".
+	// Calls happen between the tool's OnToolStart and OnToolEnd.
+	OnToolProgress func(toolCall llm.ToolCall, message string)
+
+	// FlushOnSentence buffers text deltas and calls OnText only at sentence
+	// boundaries, which smooths rendering in clients that re-render on every
+	// delta. By default deltas are passed through as they arrive.
+	FlushOnSentence bool
 }
 
 // RunStream executes the agent loop with streaming output
@@ -251,26 +738,36 @@ func (a *Agent) RunStream(ctx context.Context, userMessage string, handler *Stre
 
 	var finalContent string
 
+	// partial is the latest text from the model, reported if the run stops
+	// at the iteration limit
+	var partial string
+
+	// resumed is the text of paused responses the final response continues
+	var resumed string
+
+	// prefill seeds only the first response of the run
+	prefill := a.config.AssistantPrefill
+
 	// Run the agent loop
 	for {
 		// Check iteration limit
 		if a.config.MaxIterations > 0 && a.ctx.IterationCount >= a.config.MaxIterations {
-			return "", fmt.Errorf("max iterations (%d) reached", a.config.MaxIterations)
+			return "", &MaxIterationsError{Iterations: a.config.MaxIterations, PartialResult: partial}
 		}
 		a.ctx.IterationCount++
 
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return "", cancelledError(ctx)
 		default:
 		}
 
 		// Build system prompt with active skills
-		systemPrompt := a.config.SystemPrompt + a.ctx.GetSkillPrompt()
+		systemPrompt := a.buildSystemPrompt()
 
 		// Build tool definitions
-		toolDefs := tools.ToDefinitions(a.registry.List())
+		toolDefs := tools.ToDefinitions(a.availableTools())
 
 		// Create completion request
 		req := &llm.CompletionRequest{
@@ -280,26 +777,34 @@ func (a *Agent) RunStream(ctx context.Context, userMessage string, handler *Stre
 			MaxTokens: a.config.MaxTokens,
 			System:    systemPrompt,
 		}
+		req.AssistantPrefill = a.assistantPrefill(prefill)
+		req.Model = a.requestModel(req)
+		if err := a.checkContextWindow(req); err != nil {
+			return "", err
+		}
 
 		// Start streaming
 		eventChan, err := streamProvider.CompleteStream(ctx, req)
 		if err != nil {
-			return "", fmt.Errorf("LLM error: %w", err)
+			if ctx.Err() != nil {
+				return "", cancelledError(ctx)
+			}
+			return "", newProviderError(a.provider.Name(), err)
 		}
 
 		// Process stream events
+		emitter := newTextEmitter(handler)
 		var content string
 		var toolCalls []llm.ToolCall
 		currentToolCalls := make(map[int]*llm.ToolCall)
 		var usage llm.Usage
+		var stopReason string
 
 		for event := range eventChan {
 			switch event.Type {
 			case llm.StreamEventText:
 				content += event.Text
-				if handler != nil && handler.OnText != nil {
-					handler.OnText(event.Text)
-				}
+				emitter.write(event.Text)
 
 			case llm.StreamEventToolCallStart:
 				tc := &llm.ToolCall{
@@ -316,20 +821,57 @@ func (a *Agent) RunStream(ctx context.Context, userMessage string, handler *Stre
 
 			case llm.StreamEventDone:
 				usage = event.Usage
+				stopReason = event.StopReason
 
 			case llm.StreamEventError:
-				return "", event.Error
+				emitter.flush()
+				if ctx.Err() != nil {
+					return "", cancelledError(ctx)
+				}
+				err := newProviderError(a.provider.Name(), event.Error)
+				if content == "" && len(toolCalls) == 0 {
+					return "", err
+				}
+				a.keepPartialResponse(content, toolCalls)
+				return "", &PartialResponseError{Content: content, ToolCalls: toolCalls, Err: err}
 			}
 		}
+		emitter.flush()
+		prefill = ""
 
 		// Update usage stats
 		a.ctx.UpdateUsage(usage)
+		a.ctx.attributeToolUsage(usage)
 		if handler != nil && handler.OnUsage != nil {
 			handler.OnUsage(usage.InputTokens, usage.OutputTokens)
 		}
 
+		// A refusal or filtered response ends the turn without running tools
+		if llm.Declined(stopReason) {
+			a.ctx.AddAssistantMessage(content)
+			if handler != nil && handler.OnDone != nil {
+				handler.OnDone()
+			}
+			return content, nil
+		}
+
+		if content != "" {
+			partial = content
+		}
+
+		if llm.Paused(stopReason) && len(toolCalls) == 0 {
+			kept, err := a.pauseTurn(content)
+			if err != nil {
+				return "", err
+			}
+			resumed += kept
+			continue
+		}
+
 		// Handle tool calls
 		if len(toolCalls) > 0 {
+			resumed = ""
+
 			// Add assistant message with tool calls
 			a.ctx.AddMessage(llm.NewAssistantToolCallMessage(toolCalls))
 
@@ -339,17 +881,33 @@ func (a *Agent) RunStream(ctx context.Context, userMessage string, handler *Stre
 					handler.OnToolStart(tc)
 				}
 
-				result, err := a.executeTool(ctx, tc)
+				toolCtx := ctx
+				if handler != nil && handler.OnToolOutput != nil {
+					toolCtx = tools.WithOutputHandler(ctx, func(stream, chunk string) {
+						handler.OnToolOutput(tc, stream, chunk)
+					})
+				}
+				if handler != nil && handler.OnToolProgress != nil {
+					toolCtx = tools.WithProgressHandler(toolCtx, func(message string) {
+						handler.OnToolProgress(tc, message)
+					})
+				}
+
+				result, err := a.executeTool(toolCtx, tc)
 				toolErr := err
 				if err != nil {
-					result = fmt.Sprintf("Error: %s", err.Error())
+					result = &tools.Result{Text: fmt.Sprintf("Error: %s", err.Error())}
 				}
 
 				if handler != nil && handler.OnToolEnd != nil {
-					handler.OnToolEnd(tc, result, toolErr)
+					handler.OnToolEnd(tc, result.Text, toolErr)
 				}
 
-				a.ctx.AddToolResult(tc.ID, result)
+				if toolErr != nil {
+					a.addToolError(tc.ID, toolErr)
+				} else {
+					a.addToolResult(tc.ID, result)
+				}
 			}
 
 			// Continue the loop to get next response
@@ -361,7 +919,7 @@ func (a *Agent) RunStream(ctx context.Context, userMessage string, handler *Stre
 			a.ctx.AddAssistantMessage(content)
 		}
 
-		finalContent = content
+		finalContent = resumed + content
 
 		if handler != nil && handler.OnDone != nil {
 			handler.OnDone()
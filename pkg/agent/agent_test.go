@@ -0,0 +1,345 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/llm"
+)
+
+// fakeProvider returns scripted responses in order and records the requests
+// it receives
+type fakeProvider struct {
+	responses []*llm.Response
+	requests  []*llm.CompletionRequest
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Capabilities() llm.Capabilities {
+	return llm.Capabilities{Tools: true}
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.Response, error) {
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return nil, errors.New("fake provider: no response scripted")
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+// newTestAgent returns a read-only agent in a temporary workspace that
+// talks to provider
+func newTestAgent(t testing.TB, provider llm.Provider) *Agent {
+	t.Helper()
+	a, err := New(
+		WithWorkspace(t.TempDir()),
+		WithConfig(func(c *Config) {
+			c.Mode = ModeReadOnly
+			c.GlobalSkillDirs = nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	a.provider = provider
+	return a
+}
+
+// echoTool is a minimal custom tool
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echo the message back" }
+
+func (echoTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+func (echoTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	message, _ := args["message"].(string)
+	return message, nil
+}
+
+func TestAddTool(t *testing.T) {
+	provider := &fakeProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "echo", Arguments: json.RawMessage(`{"message":"hi"}`)}}},
+		{Content: "done"},
+	}}
+	a := newTestAgent(t, provider)
+
+	if err := a.AddTool(echoTool{}); err != nil {
+		t.Fatalf("AddTool: %v", err)
+	}
+	if err := a.AddTool(echoTool{}); err == nil {
+		t.Error("adding a tool twice succeeded")
+	}
+
+	result, err := a.Run(context.Background(), "say hi")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != "done" {
+		t.Errorf("result = %q, want %q", result, "done")
+	}
+
+	if len(provider.requests) == 0 {
+		t.Fatal("provider received no requests")
+	}
+	if !hasToolDefinition(provider.requests[0].Tools, "echo") {
+		t.Errorf("request tools do not include echo: %v", toolNames(provider.requests[0].Tools))
+	}
+
+	// The tool result for the call is in the conversation sent next
+	var answered bool
+	for _, msg := range provider.requests[1].Messages {
+		if msg.ToolCallID == "call_1" && msg.Content == "hi" {
+			answered = true
+		}
+	}
+	if !answered {
+		t.Error("second request has no echo result for call_1")
+	}
+}
+
+func hasToolDefinition(defs []llm.ToolDefinition, name string) bool {
+	for _, def := range defs {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toolNames(defs []llm.ToolDefinition) []string {
+	names := make([]string, len(defs))
+	for i, def := range defs {
+		names[i] = def.Name
+	}
+	return names
+}
+
+// fakeStreamProvider streams scripted events, one list per request
+type fakeStreamProvider struct {
+	fakeProvider
+	streams [][]llm.StreamEvent
+}
+
+func (p *fakeStreamProvider) CompleteStream(ctx context.Context, req *llm.CompletionRequest) (<-chan llm.StreamEvent, error) {
+	p.requests = append(p.requests, req)
+	if len(p.streams) == 0 {
+		return nil, errors.New("fake provider: no stream scripted")
+	}
+	events := p.streams[0]
+	p.streams = p.streams[1:]
+
+	ch := make(chan llm.StreamEvent, len(events))
+	for _, event := range events {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
+}
+
+func textEvent(text string) llm.StreamEvent {
+	return llm.StreamEvent{Type: llm.StreamEventText, Text: text}
+}
+
+func errorEvent(err error) llm.StreamEvent {
+	return llm.StreamEvent{Type: llm.StreamEventError, Error: err}
+}
+
+var errConnectionReset = errors.New("read: connection reset by peer")
+
+func TestRunStreamPartialResponse(t *testing.T) {
+	provider := &fakeStreamProvider{streams: [][]llm.StreamEvent{
+		{textEvent("The answer"), textEvent(" is"), errorEvent(errConnectionReset)},
+		{textEvent(" 42."), {Type: llm.StreamEventDone, StopReason: "end_turn"}},
+	}}
+	a := newTestAgent(t, provider)
+
+	var streamed string
+	handler := &StreamHandler{OnText: func(text string) { streamed += text }}
+	_, err := a.RunStream(context.Background(), "question", handler)
+
+	var partial *PartialResponseError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want a PartialResponseError", err)
+	}
+	if partial.Content != "The answer is" {
+		t.Errorf("Content = %q, want the text received", partial.Content)
+	}
+	if !errors.Is(err, ErrPartialResponse) || !errors.Is(err, ErrProvider) || !errors.Is(err, errConnectionReset) {
+		t.Errorf("err = %v does not unwrap to ErrPartialResponse, ErrProvider and the stream error", err)
+	}
+	if streamed != "The answer is" {
+		t.Errorf("streamed %q before the error", streamed)
+	}
+
+	// The partial text is kept in the conversation, so "continue" resumes
+	messages := a.Context().Messages
+	if last := messages[len(messages)-1]; last.Role != llm.RoleAssistant || last.Content != "The answer is" {
+		t.Errorf("last message = %+v, want the partial response", last)
+	}
+	if _, err := a.RunStream(context.Background(), "continue", handler); err != nil {
+		t.Fatalf("RunStream after the partial response: %v", err)
+	}
+	resumed := provider.requests[1].Messages
+	if len(resumed) != 3 || resumed[1].Content != "The answer is" {
+		t.Errorf("follow-up request messages = %+v", resumed)
+	}
+}
+
+func TestRunStreamPartialToolCalls(t *testing.T) {
+	call := &llm.ToolCall{ID: "call_1", Name: "echo", Arguments: json.RawMessage(`{"message":"hi"}`)}
+	provider := &fakeStreamProvider{streams: [][]llm.StreamEvent{{
+		textEvent("Running it."),
+		{Type: llm.StreamEventToolCallStart, ToolCall: call},
+		{Type: llm.StreamEventToolCallEnd, ToolCall: call},
+		{Type: llm.StreamEventToolCallStart, ToolCall: &llm.ToolCall{ID: "call_2", Name: "echo"}, ToolCallIndex: 1},
+		errorEvent(errConnectionReset),
+	}}}
+	a := newTestAgent(t, provider)
+	if err := a.AddTool(echoTool{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := a.RunStream(context.Background(), "say hi", nil)
+	var partial *PartialResponseError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want a PartialResponseError", err)
+	}
+
+	// Only the complete tool call is kept, answered as not executed
+	if len(partial.ToolCalls) != 1 || partial.ToolCalls[0].ID != "call_1" {
+		t.Fatalf("ToolCalls = %+v, want call_1 only", partial.ToolCalls)
+	}
+	messages := a.Context().Messages
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want user, assistant and tool result", len(messages))
+	}
+	if assistant := messages[1]; assistant.Content != "Running it." || len(assistant.ToolCalls) != 1 {
+		t.Errorf("assistant message = %+v", assistant)
+	}
+	if result := messages[2]; result.ToolCallID != "call_1" || !result.IsError {
+		t.Errorf("tool result = %+v, want an error result for call_1", result)
+	}
+}
+
+func TestRunStreamErrorBeforeContent(t *testing.T) {
+	provider := &fakeStreamProvider{streams: [][]llm.StreamEvent{{errorEvent(errConnectionReset)}}}
+	a := newTestAgent(t, provider)
+
+	_, err := a.RunStream(context.Background(), "question", nil)
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !errors.Is(err, errConnectionReset) {
+		t.Fatalf("err = %v, want a ProviderError", err)
+	}
+	if errors.Is(err, ErrPartialResponse) {
+		t.Error("error before any content reported as a partial response")
+	}
+	if n := len(a.Context().Messages); n != 1 {
+		t.Errorf("got %d messages, want only the user message", n)
+	}
+}
+
+func TestReadGlobalSkill(t *testing.T) {
+	skillsDir := t.TempDir()
+	skillPath := filepath.Join(skillsDir, "deploy", "SKILL.md")
+	if err := os.MkdirAll(filepath.Dir(skillPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\nname: deploy\ndescription: Deploy the service\n---\n\nRun make deploy from the repository root.\n"
+	if err := os.WriteFile(skillPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, _ := json.Marshal(map[string]string{"path": skillPath})
+	provider := &fakeProvider{responses: []*llm.Response{
+		{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "read_file", Arguments: args}}},
+		{Content: "done"},
+	}}
+	a, err := New(
+		WithWorkspace(t.TempDir()),
+		WithConfig(func(c *Config) {
+			c.Mode = ModeReadOnly
+			c.GlobalSkillDirs = []string{skillsDir}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	a.provider = provider
+
+	if _, err := a.Run(context.Background(), "deploy it"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// The model is pointed at the skill's absolute path and can read it
+	if !strings.Contains(provider.requests[0].System, skillPath) {
+		t.Errorf("system prompt does not list %s:\n%s", skillPath, provider.requests[0].System)
+	}
+	var read bool
+	for _, msg := range provider.requests[1].Messages {
+		if msg.ToolCallID == "call_1" && strings.Contains(msg.Content, "Run make deploy") {
+			read = true
+		}
+	}
+	if !read {
+		t.Errorf("read_file did not return the global skill: %+v", provider.requests[1].Messages)
+	}
+}
+
+// openFiles returns how many of this process's file descriptors refer to
+// path, or skips the test where /proc is not available
+func openFiles(t *testing.T, path string) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot list open files: %v", err)
+	}
+	n := 0
+	for _, fd := range fds {
+		if target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); err == nil && target == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNewFromConfigClosesAuditLogOnError(t *testing.T) {
+	workspace := t.TempDir()
+	auditPath := filepath.Join(workspace, "audit.jsonl")
+
+	_, err := New(
+		WithWorkspace(workspace),
+		WithConfig(func(c *Config) {
+			c.GlobalSkillDirs = nil
+			c.SkipSandboxProbe = true
+			c.AuditLog = true
+			c.AuditLogPath = auditPath
+			c.SystemPromptID = "missing"
+		}),
+	)
+	if err == nil || !strings.Contains(err.Error(), `system prompt "missing" not found`) {
+		t.Fatalf("New: err = %v, want a missing system prompt error", err)
+	}
+	if _, statErr := os.Stat(auditPath); statErr != nil {
+		t.Fatalf("audit log was not opened: %v", statErr)
+	}
+	if n := openFiles(t, auditPath); n != 0 {
+		t.Errorf("audit log is still open %d times after New failed", n)
+	}
+}
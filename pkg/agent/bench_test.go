@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/skills"
+)
+
+// benchContextSizes are the conversation lengths the context benchmarks run
+// at
+var benchContextSizes = []int{100, 1000, 10000}
+
+// newBenchContext returns a context holding n messages in the shape of an
+// agent loop, user turns followed by tool calls and their results, and ten
+// loaded skills
+func newBenchContext(n int) *Context {
+	c := NewContext("/workspace")
+	for i := 0; len(c.Messages) < n; i++ {
+		switch i % 3 {
+		case 0:
+			c.AddUserMessage(fmt.Sprintf("Run the tests in package %d and fix any failures", i))
+		case 1:
+			msg := llm.NewAssistantMessage("Running the tests.")
+			msg.ToolCalls = []llm.ToolCall{{ID: fmt.Sprintf("call_%d", i), Name: "bash", Arguments: json.RawMessage(`{"command":"go test ./..."}`)}}
+			c.AddMessage(msg)
+		case 2:
+			c.AddToolResult(fmt.Sprintf("call_%d", i-1), "ok  \tgithub.com/example/pkg\t0.012s")
+		}
+	}
+	for i := 0; i < 10; i++ {
+		c.LoadSkill(&skills.Skill{
+			Name:        fmt.Sprintf("skill-%d", i),
+			Description: "Conventions for writing and reviewing code in this repository",
+			FilePath:    fmt.Sprintf(".looper/skills/skill-%d/SKILL.md", i),
+			Dir:         fmt.Sprintf(".looper/skills/skill-%d", i),
+		})
+	}
+	c.UpdateUsage(llm.Usage{InputTokens: 120000, OutputTokens: 8000})
+	return c
+}
+
+func BenchmarkContextBuildPrompt(b *testing.B) {
+	for _, n := range benchContextSizes {
+		b.Run(fmt.Sprintf("messages=%d", n), func(b *testing.B) {
+			c := newBenchContext(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.GetSkillPrompt()
+			}
+		})
+	}
+}
+
+func BenchmarkContextClone(b *testing.B) {
+	for _, n := range benchContextSizes {
+		b.Run(fmt.Sprintf("messages=%d", n), func(b *testing.B) {
+			c := newBenchContext(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.Clone()
+			}
+		})
+	}
+}
+
+func BenchmarkContextAddMessages(b *testing.B) {
+	msg := llm.NewToolResultMessage("call_1", "ok  \tgithub.com/example/pkg\t0.012s")
+	for _, n := range benchContextSizes {
+		b.Run(fmt.Sprintf("messages=%d", n), func(b *testing.B) {
+			c := newBenchContext(n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.AddMessage(msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import "strings"
+
+// textEmitter delivers streamed text to a StreamHandler, optionally
+// buffering deltas until a sentence boundary is reached
+type textEmitter struct {
+	handler *StreamHandler
+	buf     strings.Builder
+}
+
+func newTextEmitter(handler *StreamHandler) *textEmitter {
+	return &textEmitter{handler: handler}
+}
+
+// write forwards a text delta, or buffers it when the handler flushes on
+// sentence boundaries
+func (e *textEmitter) write(text string) {
+	if e.handler == nil || e.handler.OnText == nil {
+		return
+	}
+	if !e.handler.FlushOnSentence {
+		e.handler.OnText(text)
+		return
+	}
+
+	e.buf.WriteString(text)
+	buffered := e.buf.String()
+	cut := lastSentenceBoundary(buffered)
+	if cut <= 0 {
+		return
+	}
+
+	e.handler.OnText(buffered[:cut])
+	e.buf.Reset()
+	e.buf.WriteString(buffered[cut:])
+}
+
+// flush delivers any buffered text
+func (e *textEmitter) flush() {
+	if e.buf.Len() == 0 {
+		return
+	}
+	text := e.buf.String()
+	e.buf.Reset()
+	e.handler.OnText(text)
+}
+
+// lastSentenceBoundary returns the index just past the last sentence
+// boundary in s: a newline, or terminal punctuation followed by whitespace.
+// It returns 0 when s contains no complete sentence.
+func lastSentenceBoundary(s string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case '\n':
+			return i + 1
+		case ' ', '\t':
+			if i > 0 && strings.ContainsRune(".!?:;", rune(s[i-1])) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
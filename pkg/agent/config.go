@@ -2,8 +2,15 @@ package agent
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/sandbox"
+	"github.com/looper-ai/looper/pkg/skills"
+	"github.com/looper-ai/looper/pkg/tools"
 )
 
 // Config holds the agent configuration
@@ -17,9 +24,57 @@ type Config struct {
 	// WorkspacePath is the root directory for file operations
 	WorkspacePath string
 
+	// DowngradeModel, when set, replaces Model for requests whose estimated
+	// input reaches DowngradeAtTokens, e.g. to continue long conversations on
+	// a cheaper long-context model. It must belong to the same provider.
+	DowngradeModel    string
+	DowngradeAtTokens int
+
+	// ContextWindow is the model's context window in tokens, used to refuse
+	// requests that cannot fit (see ContextWindowError). 0 looks it up by
+	// model name; unknown models are not checked.
+	ContextWindow int
+
 	// SystemPrompt is the base system prompt for the agent
 	SystemPrompt string
 
+	// SystemPromptID selects a prompt from PromptsPath to use as the system
+	// prompt. When set, NewFromConfig replaces SystemPrompt with its content and
+	// fails if no such prompt exists.
+	SystemPromptID string
+
+	// PromptsPath is the directory of prompt templates (default: prompts
+	// under WorkspacePath)
+	PromptsPath string
+
+	// ExtraSystemPrompt is appended to SystemPrompt, separated by a newline.
+	// It adds project-specific instructions without replacing the default.
+	ExtraSystemPrompt string
+
+	// ProjectFile is a file of project facts (tech stack, conventions,
+	// directory map) included in the system prompt of every request,
+	// relative to WorkspacePath unless absolute. A missing file is skipped;
+	// empty disables it. Defaults to DefaultProjectFile.
+	ProjectFile string
+
+	// Verbosity names a preset whose directive is appended to the system
+	// prompt: "concise", "normal" (the default) or "verbose", or any name in
+	// VerbosityPresets
+	Verbosity string
+
+	// VerbosityPresets adds presets or overrides the built-in directives
+	// (see DefaultVerbosityPresets)
+	VerbosityPresets map[string]string
+
+	// Mode is ModeFull (the default when empty) or ModeReadOnly, which
+	// registers only read_file, grep, search_read and list_dir and creates
+	// no sandbox. Tools passed in Tools are registered in either mode.
+	Mode string
+
+	// PlanMode starts the agent in plan mode, where only read-only tools run
+	// until the plan is approved (see Agent.SetPlanMode)
+	PlanMode bool
+
 	// MaxIterations limits the number of tool call iterations (0 = unlimited)
 	MaxIterations int
 
@@ -29,6 +84,12 @@ type Config struct {
 	// Temperature controls response randomness
 	Temperature float64
 
+	// AssistantPrefill is text the first response of each run starts with,
+	// such as "{" to steer the model towards JSON (see
+	// llm.CompletionRequest). Follow-up requests after tool results and
+	// continuations of a paused response are not prefilled.
+	AssistantPrefill string
+
 	// ProviderConfig holds provider-specific configuration
 	ProviderConfig *llm.ProviderConfig
 
@@ -38,18 +99,195 @@ type Config struct {
 
 	// DisableBlacklist disables the command blacklist entirely
 	DisableBlacklist bool
+
+	// CommandAllowlist, when non-empty, only allows these programs to run
+	// in the sandbox
+	CommandAllowlist []string
+
+	// DryRun makes sandboxed commands and scripts report what they would
+	// run instead of running (see sandbox.Config.DryRun)
+	DryRun bool
+
+	// DisableNetwork runs sandboxed commands without network access.
+	// NewFromConfig fails if this cannot be enforced, unless
+	// SkipSandboxProbe is set.
+	DisableNetwork bool
+
+	// Isolation selects the sandbox confinement backend: "process" (the
+	// default), "bwrap" or "nsjail" to hide everything but the workspace and
+	// system paths from commands, "firejail" to drop privileges, or "gvisor"
+	// to run commands on gVisor's user-space kernel with runsc (Linux).
+	// NewFromConfig fails if the backend is unusable, unless
+	// SkipSandboxProbe is set.
+	Isolation string
+
+	// NicePriority and IOPriority run sandboxed commands at a lower CPU and
+	// disk priority (Linux): a nice value from 1 to 19, and "low" or
+	// "idle". The zero values leave priorities unchanged.
+	NicePriority int
+	IOPriority   string
+
+	// AuditLog records every sandboxed execution attempt, including blocked
+	// ones, as a JSON line in AuditLogPath (see sandbox.AuditEntry)
+	AuditLog bool
+
+	// AuditLogPath is the audit log file, appended to across sessions
+	// (default: .looper/audit.jsonl under WorkspacePath)
+	AuditLogPath string
+
+	// OnBlockedCommand, when set, is called with each command or script the
+	// sandbox blacklist refuses, so a deployment can alert on them. It is
+	// called concurrently when tools run in parallel.
+	OnBlockedCommand func(*sandbox.BlacklistError)
+
+	// AllowedEnv adds environment variable patterns, such as "GO*" or
+	// "npm_config_*", to those passed through to sandboxed commands
+	AllowedEnv []string
+
+	// InheritEnv passes the whole environment through to sandboxed commands
+	// except variables matching DeniedEnv
+	InheritEnv bool
+
+	// DeniedEnv lists environment variable patterns never passed through,
+	// even if allowed. Set to nil to use sandbox.DefaultDeniedEnv, empty
+	// slice to deny nothing.
+	DeniedEnv []string
+
+	// DisableRedaction keeps secrets such as API keys in command output
+	// instead of redacting them (for debugging only)
+	DisableRedaction bool
+
+	// Interpreters adds or overrides script interpreters for the execute
+	// tool (see sandbox.DefaultInterpreters)
+	Interpreters map[string]sandbox.InterpreterSpec
+
+	// PerInterpreterLimits adds or overrides per-program timeouts and
+	// output ceilings (see sandbox.DefaultInterpreterLimits)
+	PerInterpreterLimits map[string]sandbox.Limits
+
+	// MaxCommandTimeout caps the per-call timeout the model may request for
+	// bash and execute (0 uses the sandbox default cap)
+	MaxCommandTimeout time.Duration
+
+	// KillGrace is how long a timed-out command has to exit after SIGTERM
+	// before it is killed (0 kills it at once)
+	KillGrace time.Duration
+
+	// SkipSandboxProbe skips the capability probe NewFromConfig runs on the
+	// sandbox. Without the probe the system prompt does not describe the
+	// environment, and unavailable isolation falls back to plain processes
+	// with a warning instead of failing.
+	SkipSandboxProbe bool
+
+	// MaxWaitTimeout caps how long the wait tool may block on a single call
+	MaxWaitTimeout time.Duration
+
+	// GlobalSkillDirs are user-level skill directories shared by every
+	// workspace, scanned before ExtraSkillDirs and the workspace skills
+	// directory, which override them on name conflicts. DefaultConfig sets
+	// skills.DefaultGlobalSkillDirs (LOOPER_SKILLS_PATH or ~/.looper/skills).
+	GlobalSkillDirs []string
+
+	// SkillDiscoveryTimeout bounds the scan of the skill directories in New.
+	// When it runs out, the skills found so far are used and a warning is
+	// logged. Zero waits for the scan to finish.
+	SkillDiscoveryTimeout time.Duration
+
+	// ExtraSkillDirs are additional skill directories scanned before the
+	// workspace skills directory. Later entries take precedence on name
+	// conflicts, and workspace skills override all of them.
+	ExtraSkillDirs []string
+
+	// Tools are registered after the built-in and external tools
+	Tools []tools.Tool
+
+	// ExternalToolsPath is a JSON file of external tool definitions to
+	// register alongside the built-in tools (see tools.LoadExternalTools)
+	ExternalToolsPath string
+
+	// ConfirmOverwrite, when set, is asked before write_file overwrites a
+	// non-empty file the agent did not create itself. Nil allows all writes,
+	// which suits non-interactive runs.
+	ConfirmOverwrite tools.OverwriteConfirmFunc
+
+	// Formatters maps a file extension such as ".go" to the command the
+	// format tool runs on files of that type, with the files appended (nil
+	// uses tools.DefaultFormatters)
+	Formatters map[string][]string
+
+	// ShowWriteDiffs makes write_file results include a unified diff of each
+	// change (truncated for large files), at the cost of extra tokens
+	ShowWriteDiffs bool
+
+	// AllowedCapabilities, when non-nil, limits the tools offered to the
+	// model to those whose declared capabilities (see tools.CapableTool) are
+	// all in the list; calls to other tools are denied. Tools that do not
+	// declare capabilities are excluded. An empty list allows no tools.
+	AllowedCapabilities []tools.ToolCapability
+
+	// ToolPolicy, when set, is consulted before every tool call with the
+	// parsed arguments and can deny the call with a reason for the model
+	// (see PathPrefixPolicy)
+	ToolPolicy ToolPolicy
+
+	// ToolTimeouts bounds each call of a tool, keyed by tool name or by a
+	// name prefix ending in "*" (e.g. "mcp_*"). An exact name wins over a
+	// prefix, and longer prefixes over shorter ones. Tools without an entry
+	// are only bounded by the sandbox timeout, if they run commands.
+	ToolTimeouts map[string]time.Duration
+
+	// ToolRetries configures automatic retries per tool name. Only transient
+	// failures are retried; blacklist and validation errors never are.
+	ToolRetries map[string]RetryPolicy
+}
+
+// toolTimeout returns the ToolTimeouts entry that applies to a tool, or 0
+func (c *Config) toolTimeout(name string) time.Duration {
+	if timeout, ok := c.ToolTimeouts[name]; ok {
+		return timeout
+	}
+	var timeout time.Duration
+	longest := -1
+	for key, d := range c.ToolTimeouts {
+		prefix, ok := strings.CutSuffix(key, "*")
+		if ok && len(prefix) > longest && strings.HasPrefix(name, prefix) {
+			timeout, longest = d, len(prefix)
+		}
+	}
+	return timeout
+}
+
+// RetryPolicy controls automatic retries of a tool call
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+
+	// Delay is the pause before each retry
+	Delay time.Duration
+
+	// RetryOnTimeout retries commands that exceeded the sandbox timeout
+	RetryOnTimeout bool
+
+	// RetryOnNonZeroExit retries commands that ran but exited non-zero
+	RetryOnNonZeroExit bool
 }
 
 // DefaultConfig returns a default agent configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Provider:      "anthropic",
-		Model:         "claude-sonnet-4-20250514",
-		WorkspacePath: ".",
-		SystemPrompt:  defaultSystemPrompt,
-		MaxIterations: 50,
-		MaxTokens:     4096,
-		Temperature:   0.7,
+		Provider:       "anthropic",
+		Model:          "claude-sonnet-4-20250514",
+		WorkspacePath:  ".",
+		SystemPrompt:   defaultSystemPrompt,
+		ProjectFile:    DefaultProjectFile,
+		MaxIterations:  50,
+		MaxTokens:      4096,
+		Temperature:    0.7,
+		KillGrace:      5 * time.Second,
+		MaxWaitTimeout: 5 * time.Minute,
+
+		GlobalSkillDirs:       skills.DefaultGlobalSkillDirs(),
+		SkillDiscoveryTimeout: 10 * time.Second,
 	}
 }
 
@@ -61,9 +299,90 @@ func (c *Config) LoadFromEnv() {
 	if model := os.Getenv("LOOPER_MODEL"); model != "" {
 		c.Model = model
 	}
+	if model := os.Getenv("LOOPER_DOWNGRADE_MODEL"); model != "" {
+		c.DowngradeModel = model
+	}
+	if threshold, err := strconv.Atoi(os.Getenv("LOOPER_DOWNGRADE_AT_TOKENS")); err == nil && threshold > 0 {
+		c.DowngradeAtTokens = threshold
+	}
+	if window, err := strconv.Atoi(os.Getenv("LOOPER_CONTEXT_WINDOW")); err == nil && window > 0 {
+		c.ContextWindow = window
+	}
 	if workspace := os.Getenv("LOOPER_WORKSPACE"); workspace != "" {
 		c.WorkspacePath = workspace
 	}
+	if promptsPath := os.Getenv("LOOPER_PROMPTS_PATH"); promptsPath != "" {
+		c.PromptsPath = promptsPath
+	}
+	if promptID := os.Getenv("LOOPER_SYSTEM_PROMPT"); promptID != "" {
+		c.SystemPromptID = promptID
+	}
+	if projectFile := os.Getenv("LOOPER_PROJECT_FILE"); projectFile != "" {
+		c.ProjectFile = projectFile
+	}
+	if verbosity := os.Getenv("LOOPER_VERBOSITY"); verbosity != "" {
+		c.Verbosity = verbosity
+	}
+	if extraPrompt := os.Getenv("LOOPER_EXTRA_SYSTEM_PROMPT"); extraPrompt != "" {
+		c.ExtraSystemPrompt = extraPrompt
+	}
+	if isolation := os.Getenv("LOOPER_ISOLATION"); isolation != "" {
+		c.Isolation = isolation
+	}
+	if nice, err := strconv.Atoi(os.Getenv("LOOPER_NICE")); err == nil {
+		c.NicePriority = nice
+	}
+	if ioPriority := os.Getenv("LOOPER_IO_PRIORITY"); ioPriority != "" {
+		c.IOPriority = ioPriority
+	}
+	if auditPath := os.Getenv("LOOPER_AUDIT_LOG"); auditPath != "" {
+		c.AuditLog = true
+		c.AuditLogPath = auditPath
+	}
+	if caps := os.Getenv("LOOPER_ALLOWED_CAPABILITIES"); caps != "" {
+		c.AllowedCapabilities = tools.ParseCapabilities(caps)
+	}
+	if allowed := os.Getenv("LOOPER_ALLOWED_ENV"); allowed != "" {
+		for _, pattern := range strings.Split(allowed, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				c.AllowedEnv = append(c.AllowedEnv, pattern)
+			}
+		}
+	}
+	if skip := os.Getenv("LOOPER_SKIP_SANDBOX_PROBE"); skip == "1" || skip == "true" {
+		c.SkipSandboxProbe = true
+	}
+	if mode := os.Getenv("LOOPER_MODE"); mode != "" {
+		c.Mode = mode
+	}
+	if inherit := os.Getenv("LOOPER_INHERIT_ENV"); inherit == "1" || inherit == "true" {
+		c.InheritEnv = true
+	}
+	if grace, err := time.ParseDuration(os.Getenv("LOOPER_KILL_GRACE")); err == nil && grace >= 0 {
+		c.KillGrace = grace
+	}
+	if toolsPath := os.Getenv("LOOPER_TOOLS_FILE"); toolsPath != "" {
+		c.ExternalToolsPath = toolsPath
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("LOOPER_SKILL_DISCOVERY_TIMEOUT")); err == nil && timeout >= 0 {
+		c.SkillDiscoveryTimeout = timeout
+	}
+	if os.Getenv(skills.GlobalSkillsPathEnv) != "" {
+		c.GlobalSkillDirs = skills.DefaultGlobalSkillDirs()
+	}
+	if extra := os.Getenv("LOOPER_EXTRA_SKILLS_PATH"); extra != "" {
+		for _, dir := range filepath.SplitList(extra) {
+			if dir != "" {
+				c.ExtraSkillDirs = append(c.ExtraSkillDirs, dir)
+			}
+		}
+	}
+}
+
+// skillDirs returns the skill directories outside the workspace, which
+// read_file may read so the model can open the skills it is told about
+func (c *Config) skillDirs() []string {
+	return append(append([]string(nil), c.GlobalSkillDirs...), c.ExtraSkillDirs...)
 }
 
 // GetProviderConfig returns the LLM provider configuration
@@ -92,14 +411,14 @@ const defaultSystemPrompt = `You are an AI assistant with access to tools for re
 
 ## Core Capabilities
 - Read and write files in the workspace
-- Search for patterns in files (grep)
+- Search for patterns in files (grep), or read the code around each match (search_read)
 - List directory contents  
 - Execute code in bash, Python, Node.js, or Go
 
 ## Workflow
 1. Understand what the user wants to accomplish
-2. Explore the codebase using read_file, grep, and list_dir
-3. Make changes carefully using write_file
+2. Explore the codebase using read_file, grep, search_read and list_dir
+3. Make changes carefully using write_file, then tidy them with format
 4. Test changes using the execute tool when appropriate
 
 Always explain what you're doing and why.`
@@ -27,15 +27,26 @@ type Context struct {
 
 	// IterationCount tracks the number of tool call iterations
 	IterationCount int
+
+	// ToolTokenUsage accumulates, per tool name, the tokens of the LLM
+	// calls made to answer that tool's results. A call answering several
+	// tools' results is split evenly between them.
+	ToolTokenUsage map[string]llm.Usage
+
+	// ToolIterations counts, per tool name, the LLM calls its results
+	// triggered
+	ToolIterations map[string]int
 }
 
 // NewContext creates a new agent context
 func NewContext(workspacePath string) *Context {
 	return &Context{
-		Messages:      make([]llm.Message, 0),
-		LoadedSkills:  make(map[string]*skills.Skill),
-		WorkspacePath: workspacePath,
-		Metadata:      make(map[string]interface{}),
+		Messages:       make([]llm.Message, 0),
+		LoadedSkills:   make(map[string]*skills.Skill),
+		WorkspacePath:  workspacePath,
+		Metadata:       make(map[string]interface{}),
+		ToolTokenUsage: make(map[string]llm.Usage),
+		ToolIterations: make(map[string]int),
 	}
 }
 
@@ -59,6 +70,16 @@ func (c *Context) AddToolResult(toolCallID, content string) {
 	c.AddMessage(llm.NewToolResultMessage(toolCallID, content))
 }
 
+// AddToolError adds a tool result message reporting a failed tool call
+func (c *Context) AddToolError(toolCallID, errMessage string) {
+	c.AddMessage(llm.NewToolResultErrorMessage(toolCallID, errMessage))
+}
+
+// AddToolResultWithImages adds a tool result message carrying images
+func (c *Context) AddToolResultWithImages(toolCallID, content string, images []llm.Image) {
+	c.AddMessage(llm.NewToolResultMessageWithImages(toolCallID, content, images))
+}
+
 // LoadSkill adds a skill to the context
 func (c *Context) LoadSkill(skill *skills.Skill) {
 	if skill != nil {
@@ -87,6 +108,76 @@ func (c *Context) UpdateUsage(usage llm.Usage) {
 	c.TotalOutputTokens += usage.OutputTokens
 }
 
+// attributeToolUsage charges the usage of an LLM call to the tools whose
+// results end the conversation, which the call was made to answer. Calls
+// that follow a user message are not attributed.
+func (c *Context) attributeToolUsage(usage llm.Usage) {
+	names := c.triggeringTools()
+	if len(names) == 0 {
+		return
+	}
+	if c.ToolTokenUsage == nil {
+		c.ToolTokenUsage = make(map[string]llm.Usage)
+		c.ToolIterations = make(map[string]int)
+	}
+	n := len(names)
+	for i, name := range names {
+		share := llm.Usage{InputTokens: usage.InputTokens / n, OutputTokens: usage.OutputTokens / n}
+		if i == 0 {
+			share.InputTokens += usage.InputTokens % n
+			share.OutputTokens += usage.OutputTokens % n
+		}
+		total := c.ToolTokenUsage[name]
+		total.InputTokens += share.InputTokens
+		total.OutputTokens += share.OutputTokens
+		c.ToolTokenUsage[name] = total
+		c.ToolIterations[name]++
+	}
+}
+
+// triggeringTools returns the names of the tools whose results end the
+// conversation, one entry per result
+func (c *Context) triggeringTools() []string {
+	i := len(c.Messages)
+	for i > 0 && c.Messages[i-1].Role == llm.RoleTool {
+		i--
+	}
+	if i == len(c.Messages) || i == 0 {
+		return nil
+	}
+
+	names := make(map[string]string)
+	for _, tc := range c.Messages[i-1].ToolCalls {
+		names[tc.ID] = tc.Name
+	}
+	var triggered []string
+	for _, msg := range c.Messages[i:] {
+		if name, ok := names[msg.ToolCallID]; ok {
+			triggered = append(triggered, name)
+		}
+	}
+	return triggered
+}
+
+// GetTokenUsageByTool returns a copy of ToolTokenUsage
+func (c *Context) GetTokenUsageByTool() map[string]llm.Usage {
+	usage := make(map[string]llm.Usage, len(c.ToolTokenUsage))
+	for name, u := range c.ToolTokenUsage {
+		usage[name] = u
+	}
+	return usage
+}
+
+// TotalTokens returns the cumulative input and output tokens
+func (c *Context) TotalTokens() int {
+	return c.TotalInputTokens + c.TotalOutputTokens
+}
+
+// Usage returns the cumulative token usage
+func (c *Context) Usage() llm.Usage {
+	return llm.Usage{InputTokens: c.TotalInputTokens, OutputTokens: c.TotalOutputTokens}
+}
+
 // GetLastAssistantMessage returns the last assistant message, if any
 func (c *Context) GetLastAssistantMessage() *llm.Message {
 	for i := len(c.Messages) - 1; i >= 0; i-- {
@@ -97,6 +188,17 @@ func (c *Context) GetLastAssistantMessage() *llm.Message {
 	return nil
 }
 
+// GetLastToolCall returns the most recent tool call made by the assistant, if any
+func (c *Context) GetLastToolCall() *llm.ToolCall {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		msg := c.Messages[i]
+		if msg.Role == llm.RoleAssistant && len(msg.ToolCalls) > 0 {
+			return &msg.ToolCalls[len(msg.ToolCalls)-1]
+		}
+	}
+	return nil
+}
+
 // Clear resets the conversation while preserving workspace and skills
 func (c *Context) Clear() {
 	c.Messages = make([]llm.Message, 0)
@@ -113,6 +215,12 @@ func (c *Context) Clone() *Context {
 		TotalInputTokens:  c.TotalInputTokens,
 		TotalOutputTokens: c.TotalOutputTokens,
 		IterationCount:    c.IterationCount,
+		ToolTokenUsage:    c.GetTokenUsageByTool(),
+		ToolIterations:    make(map[string]int, len(c.ToolIterations)),
+	}
+
+	for k, v := range c.ToolIterations {
+		clone.ToolIterations[k] = v
 	}
 
 	copy(clone.Messages, c.Messages)
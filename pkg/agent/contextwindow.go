@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"log"
+
+	"github.com/looper-ai/looper/pkg/llm"
+)
+
+// contextWarnRatio is the share of the context window at which a warning is
+// logged
+const contextWarnRatio = 0.9
+
+// contextLimit returns the input a model accepts in tokens: its context
+// window less the response reserve, or 0 if the window is unknown
+func (a *Agent) contextLimit(model string) int {
+	window := a.config.ContextWindow
+	if window <= 0 {
+		window = llm.ContextWindow(model)
+	}
+	if window <= 0 {
+		return 0
+	}
+	return max(window-a.config.MaxTokens, 0)
+}
+
+// checkContextWindow returns a ContextWindowError if the request's estimated
+// input exceeds the model's limit. A warning is logged once the input
+// passes 90% of the limit, and again after it has dropped below.
+func (a *Agent) checkContextWindow(req *llm.CompletionRequest) error {
+	limit := a.contextLimit(req.Model)
+	if limit == 0 {
+		return nil
+	}
+
+	estimated := llm.EstimateRequestTokens(req)
+	if estimated > limit {
+		return &ContextWindowError{Model: req.Model, Estimated: estimated, Limit: limit}
+	}
+
+	near := float64(estimated) >= contextWarnRatio*float64(limit)
+	if !a.contextWarned.Swap(near) && near {
+		log.Printf("WARNING: context is ~%d tokens, %d%% of the %d %s accepts; consider /clear", estimated, estimated*100/limit, limit, req.Model)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/looper-ai/looper/pkg/llm"
+)
+
+// validateDowngrade checks that the downgrade model is served by the
+// configured provider, so requests never go out with the wrong API key
+func validateDowngrade(config *Config) error {
+	if config.DowngradeModel == "" {
+		return nil
+	}
+	if provider := llm.ProviderForModel(config.DowngradeModel); provider != "" && provider != config.Provider {
+		return fmt.Errorf("downgrade model %q belongs to provider %s, not %s", config.DowngradeModel, provider, config.Provider)
+	}
+	return nil
+}
+
+// requestModel returns the model for a request: DowngradeModel once the
+// request's estimated input reaches DowngradeAtTokens, Model otherwise.
+// Switching in either direction is logged.
+func (a *Agent) requestModel(req *llm.CompletionRequest) string {
+	if a.config.DowngradeModel == "" || a.config.DowngradeAtTokens <= 0 {
+		return a.config.Model
+	}
+
+	estimated := llm.EstimateRequestTokens(req)
+	downgrade := estimated >= a.config.DowngradeAtTokens
+	if a.downgraded.Swap(downgrade) != downgrade {
+		if downgrade {
+			log.Printf("Context is ~%d tokens (threshold %d); switching from %s to %s", estimated, a.config.DowngradeAtTokens, a.config.Model, a.config.DowngradeModel)
+		} else {
+			log.Printf("Context is ~%d tokens (threshold %d); switching back to %s", estimated, a.config.DowngradeAtTokens, a.config.Model)
+		}
+	}
+	if downgrade {
+		return a.config.DowngradeModel
+	}
+	return a.config.Model
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/llm"
+)
+
+func TestRequestModelConcurrent(t *testing.T) {
+	a := newTestAgent(t, &fakeProvider{})
+	a.config.Model = "claude-sonnet-4-5"
+	a.config.DowngradeModel = "claude-haiku-4-5"
+	a.config.DowngradeAtTokens = 1000
+	a.config.ContextWindow = 4000
+	a.config.MaxTokens = 0
+
+	small := &llm.CompletionRequest{Model: a.config.Model, Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}}}
+	large := &llm.CompletionRequest{Model: a.config.Model, Messages: []llm.Message{{Role: llm.RoleUser, Content: strings.Repeat("word ", 1500)}}}
+
+	// Run with -race: requests from several goroutines share the flags
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				req := small
+				if j%2 == 0 {
+					req = large
+				}
+				a.requestModel(req)
+				a.checkContextWindow(req)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := a.requestModel(large); got != a.config.DowngradeModel {
+		t.Errorf("requestModel(large) = %q, want %q", got, a.config.DowngradeModel)
+	}
+	if !a.downgraded.Load() {
+		t.Error("downgraded not set after a large request")
+	}
+	if got := a.requestModel(small); got != a.config.Model {
+		t.Errorf("requestModel(small) = %q, want %q", got, a.config.Model)
+	}
+	if a.downgraded.Load() {
+		t.Error("downgraded still set after a small request")
+	}
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// sandboxProbeTimeout bounds the capability probe in NewFromConfig
+const sandboxProbeTimeout = 10 * time.Second
+
+// probeSandbox runs the sandbox capability probe. It fails if isolation the
+// config asks for cannot be enforced, rather than letting every command run
+// unconfined later.
+func probeSandbox(config *Config, sb sandbox.Sandbox) (*sandbox.Capabilities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sandboxProbeTimeout)
+	defer cancel()
+
+	caps, err := sb.Capabilities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: capability probe failed: %w", ErrSandboxUnavailable, err)
+	}
+	if caps.IsolationError != "" {
+		return nil, fmt.Errorf("%w: %s isolation: %s", ErrSandboxUnavailable, config.Isolation, caps.IsolationError)
+	}
+	if caps.NetworkError != "" {
+		return nil, fmt.Errorf("%w: network isolation: %s", ErrSandboxUnavailable, caps.NetworkError)
+	}
+	return caps, nil
+}
+
+// SandboxCapabilities returns the capabilities probed when the agent was
+// created, or nil if the probe was skipped or the agent has no sandbox
+func (a *Agent) SandboxCapabilities() *sandbox.Capabilities {
+	return a.capabilities
+}
+
+// environmentPrompt returns the system prompt section describing the
+// sandbox, so the model knows up front which interpreters it can use
+func (a *Agent) environmentPrompt() string {
+	if a.capabilities == nil {
+		return ""
+	}
+	return "\n\n## Environment\n\n" + a.capabilities.Summary()
+}
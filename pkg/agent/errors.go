@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+var (
+	// ErrMaxIterations is returned when a run hits Config.MaxIterations
+	ErrMaxIterations = errors.New("max iterations reached")
+
+	// ErrContextCancelled is returned when the caller's context ends a run.
+	// The error also wraps the context's own error.
+	ErrContextCancelled = errors.New("agent run cancelled")
+
+	// ErrProvider is returned when the LLM provider fails a request
+	ErrProvider = errors.New("LLM error")
+
+	// ErrPartialResponse is returned when a response stream fails after the
+	// model produced output, which is kept in the conversation
+	ErrPartialResponse = errors.New("response interrupted")
+
+	// ErrEmptyPause is returned when the model pauses its turn without
+	// producing any text to resume from
+	ErrEmptyPause = errors.New("paused response has no content to resume")
+
+	// ErrContextWindowExceeded is returned when a request would not fit in
+	// the model's context window
+	ErrContextWindowExceeded = errors.New("context window exceeded")
+
+	// ErrToolNotFound is returned for a call to a tool that is not registered
+	ErrToolNotFound = errors.New("unknown tool")
+
+	// ErrToolExecutionFailed is returned when a tool runs and fails
+	ErrToolExecutionFailed = errors.New("tool execution failed")
+
+	// ErrToolTimeout is returned when a tool exceeds its Config.ToolTimeouts
+	// entry
+	ErrToolTimeout = errors.New("tool timed out")
+
+	// ErrBlacklistedCommand is returned when a tool's command is blocked by
+	// the sandbox blacklist
+	ErrBlacklistedCommand = errors.New("command blocked by blacklist")
+
+	// ErrSandboxUnavailable is returned by NewFromConfig when the capability
+	// probe finds that requested isolation cannot be enforced
+	ErrSandboxUnavailable = errors.New("sandbox unavailable")
+)
+
+// MaxIterationsError is returned when a run stops at the iteration limit.
+// It unwraps to ErrMaxIterations.
+type MaxIterationsError struct {
+	Iterations int
+
+	// PartialResult is the last text the model produced before the limit
+	PartialResult string
+}
+
+func (e *MaxIterationsError) Error() string {
+	return fmt.Sprintf("max iterations (%d) reached", e.Iterations)
+}
+
+func (e *MaxIterationsError) Unwrap() error {
+	return ErrMaxIterations
+}
+
+// ProviderError is returned when the LLM provider fails a request. It
+// unwraps to ErrProvider and to the provider's error, so llm.ErrAPIError
+// still matches.
+type ProviderError struct {
+	Provider   string
+	StatusCode int // HTTP status of an API error response, if any
+	Message    string
+	Err        error
+}
+
+func newProviderError(provider string, err error) *ProviderError {
+	e := &ProviderError{Provider: provider, Message: err.Error(), Err: err}
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		e.StatusCode = apiErr.StatusCode
+		e.Message = apiErr.Message
+	}
+	return e
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrProvider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() []error {
+	return []error{ErrProvider, e.Err}
+}
+
+// PartialResponseError is returned by RunStream when the provider stream
+// fails mid-response. The text and complete tool calls received so far are
+// added to the conversation, with the tool calls answered as not executed,
+// so a follow-up message such as "continue" resumes from there. It unwraps
+// to ErrPartialResponse and to the ProviderError.
+type PartialResponseError struct {
+	Content   string
+	ToolCalls []llm.ToolCall
+	Err       error
+}
+
+func (e *PartialResponseError) Error() string {
+	return fmt.Sprintf("%s after %d characters: %v", ErrPartialResponse, len(e.Content), e.Err)
+}
+
+func (e *PartialResponseError) Unwrap() []error {
+	return []error{ErrPartialResponse, e.Err}
+}
+
+// ContextWindowError is returned before a request is sent when its
+// estimated input does not fit in the model's context window. Callers can
+// trim or summarize the conversation (see Agent.Context) and retry. It
+// unwraps to ErrContextWindowExceeded.
+type ContextWindowError struct {
+	Model string
+
+	// Estimated is the estimated input of the request in tokens: system
+	// prompt, messages and tool definitions
+	Estimated int
+
+	// Limit is the input the model accepts: its context window less the
+	// tokens reserved for the response (Config.MaxTokens)
+	Limit int
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("%s: request is ~%d tokens but %s accepts %d; clear or trim the conversation", ErrContextWindowExceeded, e.Estimated, e.Model, e.Limit)
+}
+
+func (e *ContextWindowError) Unwrap() error {
+	return ErrContextWindowExceeded
+}
+
+// ToolNotFoundError is returned for a call to an unregistered tool. It
+// unwraps to ErrToolNotFound.
+type ToolNotFoundError struct {
+	Name string
+}
+
+func (e *ToolNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrToolNotFound, e.Name)
+}
+
+func (e *ToolNotFoundError) Unwrap() error {
+	return ErrToolNotFound
+}
+
+// ToolExecutionError is returned when a tool fails. Its message is the
+// cause's, which is what the model sees as the tool result. It unwraps to
+// ErrToolExecutionFailed and to the cause.
+type ToolExecutionError struct {
+	Name  string
+	Cause error
+}
+
+func (e *ToolExecutionError) Error() string {
+	return e.Cause.Error()
+}
+
+func (e *ToolExecutionError) Unwrap() []error {
+	return []error{ErrToolExecutionFailed, e.Cause}
+}
+
+// BlacklistedCommandError is the cause of a ToolExecutionError when the
+// sandbox blacklist blocked a command. It unwraps to ErrBlacklistedCommand
+// and to the sandbox error, so sandbox.ErrBlacklistedCommand still matches.
+type BlacklistedCommandError struct {
+	Pattern string
+	Command string
+	Err     error
+}
+
+func (e *BlacklistedCommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BlacklistedCommandError) Unwrap() []error {
+	return []error{ErrBlacklistedCommand, e.Err}
+}
+
+// toolError wraps an error returned by a tool
+func toolError(name string, err error) error {
+	var blocked *sandbox.BlacklistError
+	if errors.As(err, &blocked) {
+		err = &BlacklistedCommandError{Pattern: blocked.Pattern, Command: blocked.Command, Err: err}
+	}
+	return &ToolExecutionError{Name: name, Cause: err}
+}
+
+// cancelledError wraps the error of a cancelled context
+func cancelledError(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrContextCancelled, ctx.Err())
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// Agent modes for Config.Mode
+const (
+	// ModeFull registers every built-in tool, including file writes and
+	// sandboxed execution
+	ModeFull = "full"
+
+	// ModeReadOnly registers only tools that read the workspace and creates
+	// no sandbox, for question answering over a codebase
+	ModeReadOnly = "readonly"
+)
+
+// ErrUnknownMode is returned for a Config.Mode that is not defined
+var ErrUnknownMode = errors.New("unknown agent mode")
+
+// NewReadOnly creates an agent in ModeReadOnly: it can read, search and list
+// workspace files but cannot write files or run commands
+func NewReadOnly(config *Config) (*Agent, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	config.Mode = ModeReadOnly
+	return NewFromConfig(config)
+}
+
+// ReadOnly reports whether the agent runs in ModeReadOnly
+func (a *Agent) ReadOnly() bool {
+	return a.config.Mode == ModeReadOnly
+}
+
+// validateMode checks Config.Mode and settings that conflict with it
+func validateMode(config *Config) error {
+	switch config.Mode {
+	case "", ModeFull:
+		return nil
+	case ModeReadOnly:
+		if config.ExternalToolsPath != "" {
+			return fmt.Errorf("external tools run commands and cannot be used in %s mode", ModeReadOnly)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (available: %s, %s)", ErrUnknownMode, config.Mode, ModeFull, ModeReadOnly)
+	}
+}
+
+// registerReadOnlyTools registers the built-in tools that only read the
+// workspace
+func registerReadOnlyTools(registry *tools.Registry, config *Config) {
+	workspace := config.WorkspacePath
+	registry.Register(tools.NewReadFileTool(workspace, tools.WithReadOnlyRoots(config.skillDirs()...)))
+	registry.Register(tools.NewGrepTool(workspace))
+	registry.Register(tools.NewSearchReadTool(workspace))
+	registry.Register(tools.NewListDirTool(workspace))
+}
+
+// readOnlySystemPrompt replaces the default system prompt in ModeReadOnly,
+// so the model is not told about tools it does not have
+const readOnlySystemPrompt = `You are an AI assistant that answers questions about the code in a workspace. You can read files, search them and list directories, but you cannot modify files or execute code.
+
+## Core Capabilities
+- Read files in the workspace (read_file)
+- Search for patterns in files (grep), or read the code around each match (search_read)
+- List directory contents (list_dir)
+
+## Workflow
+1. Understand what the user wants to know
+2. Explore the codebase using read_file, grep, search_read and list_dir
+3. Answer based on what you found, citing file paths and line numbers
+
+If answering would require changing files or running code, say so and describe what should be done instead.`
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// Option adjusts the configuration an agent is created with by New
+type Option func(*Config)
+
+// WithProvider selects the LLM provider: "anthropic" or "openai"
+func WithProvider(provider string) Option {
+	return func(c *Config) {
+		c.Provider = provider
+	}
+}
+
+// WithModel sets the model name
+func WithModel(model string) Option {
+	return func(c *Config) {
+		c.Model = model
+	}
+}
+
+// WithWorkspace sets the root directory for file operations and commands
+func WithWorkspace(path string) Option {
+	return func(c *Config) {
+		c.WorkspacePath = path
+	}
+}
+
+// WithMaxIterations limits the tool call iterations per run (0 = unlimited)
+func WithMaxIterations(n int) Option {
+	return func(c *Config) {
+		c.MaxIterations = n
+	}
+}
+
+// WithSystemPrompt replaces the default system prompt
+func WithSystemPrompt(prompt string) Option {
+	return func(c *Config) {
+		c.SystemPrompt = prompt
+	}
+}
+
+// WithTool registers an additional tool alongside the built-in ones
+func WithTool(t tools.Tool) Option {
+	return func(c *Config) {
+		c.Tools = append(c.Tools, t)
+	}
+}
+
+// WithToolTimeout bounds each call of the named tool, or of every tool
+// matching a prefix ending in "*" (see Config.ToolTimeouts)
+func WithToolTimeout(name string, d time.Duration) Option {
+	return func(c *Config) {
+		if c.ToolTimeouts == nil {
+			c.ToolTimeouts = make(map[string]time.Duration)
+		}
+		c.ToolTimeouts[name] = d
+	}
+}
+
+// WithConfig applies fn to the configuration, for settings without a
+// dedicated option
+func WithConfig(fn func(*Config)) Option {
+	return Option(fn)
+}
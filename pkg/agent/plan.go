@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// ErrBlockedInPlanMode is returned for a tool call that could change the
+// workspace while the agent is in plan mode
+var ErrBlockedInPlanMode = errors.New("blocked in plan mode")
+
+// planModeCapabilities are the capabilities a tool may need to stay
+// available in plan mode: tools that only read the workspace
+var planModeCapabilities = []tools.ToolCapability{tools.CapabilityRead}
+
+// planModePrompt is appended to the system prompt in plan mode; %s is the
+// list of tools that stay available
+const planModePrompt = `
+
+## Plan Mode
+
+You are in plan mode. Only %s are available; every other tool is blocked.
+Investigate the workspace as needed, then reply with a concise, numbered plan
+of the changes you intend to make. Do not attempt the changes: the user will
+review the plan and approve it first.`
+
+// PlanApprovedMessage is the message sent to the agent when the user
+// approves its plan
+const PlanApprovedMessage = "The plan is approved. Carry it out now."
+
+// PlanMode reports whether mutating tools are blocked
+func (a *Agent) PlanMode() bool {
+	return a.planMode.Load()
+}
+
+// SetPlanMode enables or disables plan mode. In plan mode only read-only
+// tools run; other tool calls fail with ErrBlockedInPlanMode, steering the
+// model to produce a plan for the user to approve.
+func (a *Agent) SetPlanMode(enabled bool) {
+	a.planMode.Store(enabled)
+}
+
+// planModeTools returns the sorted names of the registered tools plan
+// mode allows
+func (a *Agent) planModeTools() []string {
+	var names []string
+	for _, t := range a.availableTools() {
+		if tools.CapabilitiesAllowed(t, planModeCapabilities) {
+			names = append(names, t.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// planModeSystemPrompt returns the plan mode section of the system prompt
+func (a *Agent) planModeSystemPrompt() string {
+	names := a.planModeTools()
+	if len(names) == 0 {
+		return fmt.Sprintf(planModePrompt, "read-only tools")
+	}
+	return fmt.Sprintf(planModePrompt, strings.Join(names, ", "))
+}
+
+// checkPlanMode rejects tool calls that plan mode does not allow: tools that
+// need any capability other than reading, or that do not declare theirs
+func (a *Agent) checkPlanMode(t tools.Tool) error {
+	if a.planMode.Load() && !tools.CapabilitiesAllowed(t, planModeCapabilities) {
+		return fmt.Errorf("%w: %s may change the workspace; present your plan and wait for the user to approve it", ErrBlockedInPlanMode, t.Name())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// writeTool is a custom tool that declares it writes the workspace
+type writeTool struct{ echoTool }
+
+func (writeTool) Name() string { return "write_notes" }
+func (writeTool) Capabilities() []tools.ToolCapability {
+	return []tools.ToolCapability{tools.CapabilityWrite}
+}
+
+// readTool is a custom tool that declares it only reads the workspace
+type readTool struct{ echoTool }
+
+func (readTool) Name() string { return "read_notes" }
+func (readTool) Capabilities() []tools.ToolCapability {
+	return []tools.ToolCapability{tools.CapabilityRead}
+}
+
+func TestPlanModeTools(t *testing.T) {
+	a := newTestAgent(t, &fakeProvider{})
+	for _, tool := range []tools.Tool{echoTool{}, writeTool{}, readTool{}} {
+		if err := a.AddTool(tool); err != nil {
+			t.Fatalf("AddTool(%s): %v", tool.Name(), err)
+		}
+	}
+
+	want := []string{"grep", "list_dir", "read_file", "read_notes", "search_read"}
+	if got := a.planModeTools(); !reflect.DeepEqual(got, want) {
+		t.Errorf("planModeTools() = %v, want %v", got, want)
+	}
+
+	a.SetPlanMode(true)
+	if prompt := a.buildSystemPrompt(); !strings.Contains(prompt, "Only "+strings.Join(want, ", ")+" are available") {
+		t.Errorf("plan mode prompt does not list %v:\n%s", want, prompt)
+	}
+
+	for _, tt := range []struct {
+		tool    tools.Tool
+		blocked bool
+	}{
+		{readTool{}, false},
+		{writeTool{}, true},
+		{echoTool{}, true}, // declares no capabilities
+	} {
+		err := a.checkPlanMode(tt.tool)
+		if blocked := errors.Is(err, ErrBlockedInPlanMode); blocked != tt.blocked {
+			t.Errorf("checkPlanMode(%s) = %v, want blocked %v", tt.tool.Name(), err, tt.blocked)
+		}
+	}
+
+	a.SetPlanMode(false)
+	if err := a.checkPlanMode(writeTool{}); err != nil {
+		t.Errorf("checkPlanMode outside plan mode = %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrToolDenied is returned for a tool call rejected by Config.ToolPolicy
+var ErrToolDenied = errors.New("tool call denied by policy")
+
+// ToolPolicy decides whether a tool call may run, given the tool name and
+// its parsed arguments. A denied call is not executed; the reason is
+// returned to the model as the tool result so it can adjust.
+type ToolPolicy func(toolName string, args map[string]interface{}) (allowed bool, reason string)
+
+// PathPrefixPolicy returns a policy that confines tools to workspace
+// directories. rules maps a tool name to the workspace-relative prefixes its
+// "path" and "cwd" arguments must fall under. A call with neither argument
+// works on the workspace root, which is only allowed by a "." prefix. Tools
+// without a rule are not restricted.
+//
+//	PathPrefixPolicy(map[string][]string{"write_file": {"src", "docs"}})
+func PathPrefixPolicy(rules map[string][]string) ToolPolicy {
+	return func(toolName string, args map[string]interface{}) (bool, string) {
+		prefixes, ok := rules[toolName]
+		if !ok {
+			return true, ""
+		}
+
+		checked := false
+		for _, name := range []string{"path", "cwd"} {
+			value, ok := args[name].(string)
+			if !ok || value == "" {
+				continue
+			}
+			checked = true
+			if !underAnyPrefix(value, prefixes) {
+				return false, fmt.Sprintf("%s %q is outside the allowed directories for %s: %s",
+					name, value, toolName, strings.Join(prefixes, ", "))
+			}
+		}
+		if !checked && !underAnyPrefix(".", prefixes) {
+			return false, fmt.Sprintf("%s may only be used in: %s", toolName, strings.Join(prefixes, ", "))
+		}
+		return true, ""
+	}
+}
+
+// underAnyPrefix reports whether a workspace-relative path lies within one
+// of the prefix directories
+func underAnyPrefix(path string, prefixes []string) bool {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return false
+	}
+	for _, prefix := range prefixes {
+		prefix = filepath.Clean(prefix)
+		if prefix == "." || path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultProjectFile is the workspace file of project facts loaded into
+// every conversation
+const DefaultProjectFile = "LOOPER.md"
+
+// maxProjectFileBytes caps the project file, which is sent with every
+// request
+const maxProjectFileBytes = 32 * 1024
+
+// projectContext is the content of the project file
+type projectContext struct {
+	path    string
+	content string
+}
+
+// loadProjectContext reads Config.ProjectFile, resolved against the
+// workspace. A missing file, or an empty ProjectFile, yields nil.
+func loadProjectContext(config *Config) (*projectContext, error) {
+	if config.ProjectFile == "" {
+		return nil, nil
+	}
+	path := config.ProjectFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.WorkspacePath, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	if len(data) > maxProjectFileBytes {
+		log.Printf("WARNING: project file %s is %d bytes; only the first %d are used", path, len(data), maxProjectFileBytes)
+		data = trimPartialRune(data[:maxProjectFileBytes])
+	}
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return nil, nil
+	}
+	return &projectContext{path: path, content: content}, nil
+}
+
+// trimPartialRune drops a multi-byte rune cut off at the end of data.
+// Invalid bytes elsewhere are left alone.
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// prompt returns the project facts as a note for the system prompt
+func (p *projectContext) prompt() string {
+	return fmt.Sprintf("\n\n## Project Context\nThe following project facts come from %s. Follow its conventions.\n\n%s",
+		filepath.Base(p.path), p.content)
+}
+
+// ProjectFile returns the path of the loaded project file, or "" if none
+// was found
+func (a *Agent) ProjectFile() string {
+	if a.project == nil {
+		return ""
+	}
+	return a.project.path
+}
+
+// SystemPrompt returns the system prompt sent with the next request,
+// including the project context, verbosity directive and active skills
+func (a *Agent) SystemPrompt() string {
+	return a.buildSystemPrompt()
+}
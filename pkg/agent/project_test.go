@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"ascii", "abc", "abc"},
+		{"whole rune", "ab€", "ab€"},
+		{"one byte of three", "ab\xe2", "ab"},
+		{"two bytes of three", "ab\xe2\x82", "ab"},
+		{"three bytes of four", "ab\xf0\x9f\x98", "ab"},
+		{"invalid byte before the end", "a\xffbc", "a\xffbc"},
+		{"stray continuation byte", "ab\x82", "ab\x82"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(trimPartialRune([]byte(tt.in))); got != tt.want {
+				t.Errorf("trimPartialRune(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProjectContextTruncates(t *testing.T) {
+	workspace := t.TempDir()
+	// A three-byte rune straddles the limit
+	content := strings.Repeat("a", maxProjectFileBytes-1) + "€ and more"
+	if err := os.WriteFile(filepath.Join(workspace, DefaultProjectFile), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := loadProjectContext(&Config{WorkspacePath: workspace, ProjectFile: DefaultProjectFile})
+	if err != nil {
+		t.Fatalf("loadProjectContext: %v", err)
+	}
+	if want := strings.Repeat("a", maxProjectFileBytes-1); project.content != want {
+		t.Errorf("content is %d bytes ending %q, want %d bytes of the file", len(project.content), project.content[len(project.content)-3:], len(want))
+	}
+}
@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/looper-ai/looper/pkg/llm"
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// TokenBreakdown is the estimated size in tokens of each part of the next
+// request, to show which parts are worth trimming. The estimates are those
+// of the context window check (see llm.EstimateRequestTokens).
+type TokenBreakdown struct {
+	// SystemPrompt covers the configured prompts, project file,
+	// environment and directives
+	SystemPrompt int
+
+	// Skills covers the skill references added to the system prompt
+	Skills int
+
+	// ToolDefinitions covers the names, descriptions and schemas of the
+	// tools offered to the model
+	ToolDefinitions int
+
+	// History covers user and assistant messages, including tool calls
+	History int
+
+	// ToolResults covers the tool result messages
+	ToolResults int
+}
+
+// Total returns the estimated size of the whole request
+func (b TokenBreakdown) Total() int {
+	return b.SystemPrompt + b.Skills + b.ToolDefinitions + b.History + b.ToolResults
+}
+
+// String renders the breakdown as a table with each category's share of
+// the total
+func (b TokenBreakdown) String() string {
+	total := b.Total()
+	rows := []struct {
+		name   string
+		tokens int
+	}{
+		{"System prompt", b.SystemPrompt},
+		{"Skills", b.Skills},
+		{"Tool definitions", b.ToolDefinitions},
+		{"History", b.History},
+		{"Tool results", b.ToolResults},
+	}
+
+	var sb strings.Builder
+	for _, row := range rows {
+		share := 0
+		if total > 0 {
+			share = row.tokens * 100 / total
+		}
+		fmt.Fprintf(&sb, "%-17s %8d  %3d%%\n", row.name, row.tokens, share)
+	}
+	fmt.Fprintf(&sb, "%-17s %8d", "Total", total)
+	return sb.String()
+}
+
+// TokenBreakdown estimates the tokens the conversation and the given
+// request parts take up. systemPrompt should not include the skill
+// references, which are counted from LoadedSkills.
+func (c *Context) TokenBreakdown(systemPrompt string, toolDefs []llm.ToolDefinition) TokenBreakdown {
+	b := TokenBreakdown{
+		SystemPrompt: llm.EstimateTokens(systemPrompt),
+		Skills:       llm.EstimateTokens(c.GetSkillPrompt()),
+	}
+	for _, def := range toolDefs {
+		b.ToolDefinitions += llm.EstimateToolTokens(def)
+	}
+	for _, msg := range c.Messages {
+		if msg.Role == llm.RoleTool {
+			b.ToolResults += llm.EstimateMessageTokens(msg)
+		} else {
+			b.History += llm.EstimateMessageTokens(msg)
+		}
+	}
+	return b
+}
+
+// TokenBreakdown estimates the tokens each part of the next request takes
+// up: system prompt, skills, tool definitions, history and tool results
+func (a *Agent) TokenBreakdown() TokenBreakdown {
+	return a.ctx.TokenBreakdown(a.baseSystemPrompt(), tools.ToDefinitions(a.availableTools()))
+}
+
+// TokenUsageReport renders the LLM tokens each tool's results caused as a
+// table, costliest first, e.g. "read_file  3  12000  3000  15000". See
+// Context.ToolTokenUsage.
+func (a *Agent) TokenUsageReport() string {
+	usage := a.ctx.GetTokenUsageByTool()
+	if len(usage) == 0 {
+		return "No LLM calls have followed tool results yet."
+	}
+
+	names := make([]string, 0, len(usage))
+	for name := range usage {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := usage[names[i]].TotalTokens(), usage[names[j]].TotalTokens()
+		if ti != tj {
+			return ti > tj
+		}
+		return names[i] < names[j]
+	})
+
+	width := len("Tool")
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-*s %10s %10s %10s %10s", width, "Tool", "Iterations", "Input", "Output", "Total")
+	for _, name := range names {
+		u := usage[name]
+		fmt.Fprintf(&sb, "\n%-*s %10d %10d %10d %10d", width, name, a.ctx.ToolIterations[name], u.InputTokens, u.OutputTokens, u.TotalTokens())
+	}
+	return sb.String()
+}
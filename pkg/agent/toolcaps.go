@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/looper-ai/looper/pkg/tools"
+)
+
+// availableTools returns the registered tools the model may use: all of
+// them, or with Config.AllowedCapabilities set, those whose capabilities
+// are all allowed
+func (a *Agent) availableTools() []tools.Tool {
+	all := a.registry.List()
+	if a.config.AllowedCapabilities == nil {
+		return all
+	}
+	allowed := make([]tools.Tool, 0, len(all))
+	for _, t := range all {
+		if tools.CapabilitiesAllowed(t, a.config.AllowedCapabilities) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// checkCapabilities rejects calls to tools that Config.AllowedCapabilities
+// hides from the model
+func (a *Agent) checkCapabilities(t tools.Tool) error {
+	if a.config.AllowedCapabilities == nil || tools.CapabilitiesAllowed(t, a.config.AllowedCapabilities) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s needs capabilities that are not allowed", ErrToolDenied, t.Name())
+}
+
+// warnUndeclaredCapabilities logs the tools that do not declare their
+// capabilities, which Config.AllowedCapabilities hides
+func warnUndeclaredCapabilities(config *Config, list []tools.Tool) {
+	if config.AllowedCapabilities == nil {
+		return
+	}
+	for _, t := range list {
+		if _, ok := t.(tools.CapableTool); !ok {
+			log.Printf("WARNING: tool %s does not implement Capabilities() and is hidden by the allowed capabilities", t.Name())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownVerbosity is returned for a verbosity preset that is not defined
+var ErrUnknownVerbosity = errors.New("unknown verbosity preset")
+
+// DefaultVerbosityPresets returns the built-in verbosity presets, keyed by
+// name. Each value is a directive appended to the system prompt; "normal"
+// adds nothing.
+func DefaultVerbosityPresets() map[string]string {
+	return map[string]string{
+		"concise": "Be terse: minimize prose, skip preamble and recaps, and explain only when asked.",
+		"normal":  "",
+		"verbose": "Explain everything: describe what you are about to do and why before acting, walk through your reasoning, and summarize what changed at the end.",
+	}
+}
+
+// verbosityPresets merges Config.VerbosityPresets over the defaults
+func (c *Config) verbosityPresets() map[string]string {
+	presets := DefaultVerbosityPresets()
+	for name, directive := range c.VerbosityPresets {
+		presets[name] = directive
+	}
+	return presets
+}
+
+// Verbosity returns the name of the active verbosity preset
+func (a *Agent) Verbosity() string {
+	if a.config.Verbosity == "" {
+		return "normal"
+	}
+	return a.config.Verbosity
+}
+
+// VerbosityPresets returns the names of the available presets in order
+func (a *Agent) VerbosityPresets() []string {
+	presets := a.config.verbosityPresets()
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetVerbosity switches to the named verbosity preset for subsequent turns
+func (a *Agent) SetVerbosity(name string) error {
+	if _, ok := a.config.verbosityPresets()[name]; !ok {
+		return fmt.Errorf("%w: %q (available: %s)", ErrUnknownVerbosity, name, strings.Join(a.VerbosityPresets(), ", "))
+	}
+	a.config.Verbosity = name
+	return nil
+}
+
+// verbosityDirective returns the system prompt directive of the active preset
+func (a *Agent) verbosityDirective() string {
+	return a.config.verbosityPresets()[a.config.Verbosity]
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -32,7 +33,7 @@ func NewAnthropicProvider(config *ProviderConfig) *AnthropicProvider {
 	}
 	return &AnthropicProvider{
 		config: config,
-		client: &http.Client{},
+		client: newHTTPClient(config),
 	}
 }
 
@@ -40,6 +41,22 @@ func (p *AnthropicProvider) Name() string {
 	return "anthropic"
 }
 
+// Capabilities reports the features supported by the configured Claude model.
+// Every Claude 3 and later model supports tools, vision, and prompt caching;
+// extended thinking arrived with Claude 3.7 Sonnet and the Claude 4 family.
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	model := p.config.Model
+	modern := !hasModelPrefix(model, "claude-2", "claude-instant")
+	return Capabilities{
+		Streaming:     true,
+		Tools:         modern,
+		Vision:        modern,
+		Thinking:      hasModelPrefix(model, "claude-3-7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4", "claude-4"),
+		PromptCaching: modern,
+		Prefill:       true,
+	}
+}
+
 // anthropicRequest represents a request to the Anthropic API
 type anthropicRequest struct {
 	Model     string          `json:"model"`
@@ -68,9 +85,16 @@ type anthropicToolUse struct {
 }
 
 type anthropicToolResult struct {
+	Type      string      `json:"type"`
+	ToolUseID string      `json:"tool_use_id"`
+	Content   interface{} `json:"content"` // string, or content blocks with images
+	IsError   bool        `json:"is_error,omitempty"`
+}
+
+type anthropicImageSource struct {
 	Type      string `json:"type"`
-	ToolUseID string `json:"tool_use_id"`
-	Content   string `json:"content"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 // anthropicResponse represents a response from the Anthropic API
@@ -117,21 +141,14 @@ type anthropicEventDelta struct {
 	StopReason  string `json:"stop_reason,omitempty"`
 }
 
-func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*Response, error) {
-	if p.config.APIKey == "" {
-		return nil, ErrNoAPIKey
-	}
-
-	// Convert messages to Anthropic format
-	msgs := make([]anthropicMsg, 0, len(req.Messages))
-	systemPrompt := req.System
+// convertAnthropicMessages converts messages to Anthropic format. System
+// messages are merged into the top-level system prompt (see MergeSystemPrompt).
+func convertAnthropicMessages(req *CompletionRequest) (string, []anthropicMsg) {
+	systemPrompt, messages := MergeSystemPrompt(req.System, req.Messages)
+	msgs := make([]anthropicMsg, 0, len(messages))
 
-	for _, msg := range req.Messages {
+	for _, msg := range messages {
 		switch msg.Role {
-		case RoleSystem:
-			if systemPrompt == "" {
-				systemPrompt = msg.Content
-			}
 		case RoleUser:
 			msgs = append(msgs, anthropicMsg{
 				Role:    "user",
@@ -172,24 +189,77 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 				Content: []anthropicToolResult{{
 					Type:      "tool_result",
 					ToolUseID: msg.ToolCallID,
-					Content:   msg.Content,
+					Content:   anthropicToolResultContent(msg),
+					IsError:   msg.IsError,
 				}},
 			})
 		}
 	}
 
-	// Convert tools to Anthropic format
-	var tools []anthropicTool
-	if len(req.Tools) > 0 {
-		tools = make([]anthropicTool, len(req.Tools))
-		for i, t := range req.Tools {
-			tools[i] = anthropicTool{
-				Name:        t.Name,
-				Description: t.Description,
-				InputSchema: t.Parameters,
-			}
+	// The response continues a trailing assistant message
+	if prefill := anthropicPrefill(req); prefill != "" {
+		msgs = append(msgs, anthropicMsg{Role: "assistant", Content: prefill})
+	}
+
+	return systemPrompt, msgs
+}
+
+// anthropicPrefill returns the request's assistant prefill without trailing
+// whitespace, which Anthropic rejects at the end of an assistant message
+func anthropicPrefill(req *CompletionRequest) string {
+	return strings.TrimRight(req.AssistantPrefill, " \t\r\n")
+}
+
+// anthropicToolResultContent returns a tool result's text, or text and
+// base64 image blocks when the result carries images
+func anthropicToolResultContent(msg Message) interface{} {
+	if len(msg.Images) == 0 {
+		return msg.Content
+	}
+	blocks := make([]interface{}, 0, len(msg.Images)+1)
+	if msg.Content != "" {
+		blocks = append(blocks, map[string]string{
+			"type": "text",
+			"text": msg.Content,
+		})
+	}
+	for _, img := range msg.Images {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "image",
+			"source": anthropicImageSource{
+				Type:      "base64",
+				MediaType: img.MediaType,
+				Data:      base64.StdEncoding.EncodeToString(img.Data),
+			},
+		})
+	}
+	return blocks
+}
+
+// convertAnthropicTools converts tool definitions to Anthropic format
+func convertAnthropicTools(defs []ToolDefinition) []anthropicTool {
+	if len(defs) == 0 {
+		return nil
+	}
+	tools := make([]anthropicTool, len(defs))
+	for i, t := range defs {
+		tools[i] = anthropicTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.Parameters,
 		}
 	}
+	return tools
+}
+
+func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*Response, error) {
+	if p.config.APIKey == "" {
+		return nil, ErrNoAPIKey
+	}
+
+	// Convert messages and tools to Anthropic format
+	systemPrompt, msgs := convertAnthropicMessages(req)
+	tools := convertAnthropicTools(req.Tools)
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -213,18 +283,9 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.config.APIKey)
-	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
-
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.send(ctx, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -239,11 +300,12 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 	}
 
 	if anthropicResp.Error != nil {
-		return nil, fmt.Errorf("%w: %s - %s", ErrAPIError, anthropicResp.Error.Type, anthropicResp.Error.Message)
+		return nil, &APIError{StatusCode: resp.StatusCode, Type: anthropicResp.Error.Type, Message: anthropicResp.Error.Message}
 	}
 
 	// Convert response to common format
 	response := &Response{
+		Content:    anthropicPrefill(req),
 		StopReason: anthropicResp.StopReason,
 		Usage: Usage{
 			InputTokens:  anthropicResp.Usage.InputTokens,
@@ -267,77 +329,33 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 	return response, nil
 }
 
+// send posts a request body to the API, retrying overloaded responses
+// according to the overload policy. For streams this happens before any of
+// the body is consumed.
+func (p *AnthropicProvider) send(ctx context.Context, body []byte) (*http.Response, error) {
+	newReq := func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("x-api-key", p.config.APIKey)
+		httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+		return httpReq, nil
+	}
+
+	return doWithOverloadRetry(ctx, p.client, p.config.Overload, p.Name(), newReq)
+}
+
 // CompleteStream sends messages to the LLM and streams the response
 func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionRequest) (<-chan StreamEvent, error) {
 	if p.config.APIKey == "" {
 		return nil, ErrNoAPIKey
 	}
 
-	// Convert messages to Anthropic format (same as Complete)
-	msgs := make([]anthropicMsg, 0, len(req.Messages))
-	systemPrompt := req.System
-
-	for _, msg := range req.Messages {
-		switch msg.Role {
-		case RoleSystem:
-			if systemPrompt == "" {
-				systemPrompt = msg.Content
-			}
-		case RoleUser:
-			msgs = append(msgs, anthropicMsg{
-				Role:    "user",
-				Content: msg.Content,
-			})
-		case RoleAssistant:
-			if len(msg.ToolCalls) > 0 {
-				content := make([]interface{}, 0)
-				if msg.Content != "" {
-					content = append(content, map[string]string{
-						"type": "text",
-						"text": msg.Content,
-					})
-				}
-				for _, tc := range msg.ToolCalls {
-					content = append(content, map[string]interface{}{
-						"type":  "tool_use",
-						"id":    tc.ID,
-						"name":  tc.Name,
-						"input": json.RawMessage(tc.Arguments),
-					})
-				}
-				msgs = append(msgs, anthropicMsg{
-					Role:    "assistant",
-					Content: content,
-				})
-			} else {
-				msgs = append(msgs, anthropicMsg{
-					Role:    "assistant",
-					Content: msg.Content,
-				})
-			}
-		case RoleTool:
-			msgs = append(msgs, anthropicMsg{
-				Role: "user",
-				Content: []anthropicToolResult{{
-					Type:      "tool_result",
-					ToolUseID: msg.ToolCallID,
-					Content:   msg.Content,
-				}},
-			})
-		}
-	}
-
-	var tools []anthropicTool
-	if len(req.Tools) > 0 {
-		tools = make([]anthropicTool, len(req.Tools))
-		for i, t := range req.Tools {
-			tools[i] = anthropicTool{
-				Name:        t.Name,
-				Description: t.Description,
-				InputSchema: t.Parameters,
-			}
-		}
-	}
+	// Convert messages and tools to Anthropic format
+	systemPrompt, msgs := convertAnthropicMessages(req)
+	tools := convertAnthropicTools(req.Tools)
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -370,24 +388,15 @@ func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionR
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL, bytes.NewReader(body))
+	resp, err := p.send(ctx, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.config.APIKey)
-	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
-
-	resp, err := p.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("%w: status %d: %s", ErrAPIError, resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	eventChan := make(chan StreamEvent, 100)
@@ -401,6 +410,11 @@ func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *CompletionR
 		var outputTokens int
 		var stopReason string
 
+		// The prefill is the start of the response text
+		if prefill := anthropicPrefill(req); prefill != "" {
+			eventChan <- StreamEvent{Type: StreamEventText, Text: prefill}
+		}
+
 		// Track tool calls being built
 		toolCalls := make(map[int]*ToolCall)
 		toolCallArgs := make(map[int]string)
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAnthropicComplete(t *testing.T) {
+	toolResp := anthropicTextResponse("Let me check.", 20, 8)
+	toolResp.Content = append(toolResp.Content, anthropicBlock{Type: "tool_use", ID: "toolu_1", Name: "bash", Input: json.RawMessage(`{"command":"ls"}`)})
+	toolResp.StopReason = "tool_use"
+
+	var received anthropicRequest
+	handler := anthropicSuccessHandler([]anthropicResponse{toolResp})
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		handler(w, r)
+	})
+	provider := NewAnthropicProvider(testProviderConfig(server))
+
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{
+		System:   "Be brief.",
+		Messages: []Message{{Role: RoleUser, Content: "List the files"}},
+		Tools:    []ToolDefinition{{Name: "bash", Description: "Run a command", Parameters: map[string]interface{}{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if received.Model != "test-model" || received.System != "Be brief." || len(received.Messages) != 1 || len(received.Tools) != 1 {
+		t.Errorf("request = %+v", received)
+	}
+	if resp.Content != "Let me check." || resp.StopReason != "tool_use" {
+		t.Errorf("Content = %q, StopReason = %q", resp.Content, resp.StopReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || string(resp.ToolCalls[0].Arguments) != `{"command":"ls"}` {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	if resp.Usage.InputTokens != 20 || resp.Usage.OutputTokens != 8 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicCompletePrefill(t *testing.T) {
+	var received anthropicRequest
+	handler := anthropicSuccessHandler([]anthropicResponse{anthropicTextResponse(`"ok": true}`, 5, 3)})
+	server := newAnthropicTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		handler(w, r)
+	})
+	provider := NewAnthropicProvider(testProviderConfig(server))
+
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{
+		Messages:         []Message{{Role: RoleUser, Content: "Reply in JSON"}},
+		AssistantPrefill: "{ ",
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	// The prefill is sent without trailing whitespace as the last message
+	last := received.Messages[len(received.Messages)-1]
+	if last.Role != "assistant" || last.Content != "{" {
+		t.Errorf("last request message = %+v, want the prefill", last)
+	}
+	if resp.Content != `{"ok": true}` {
+		t.Errorf("Content = %q, want the prefill and the continuation", resp.Content)
+	}
+}
+
+func TestAnthropicCompleteStream(t *testing.T) {
+	start := anthropicTextResponse("", 12, 0)
+	start.Content = nil
+	server := newAnthropicTestServer(t, anthropicStreamHandler([]anthropicStreamEvent{
+		{Type: "message_start", Message: &start},
+		{Type: "content_block_start", Index: 0, ContentBlock: &anthropicBlock{Type: "text"}},
+		{Type: "content_block_delta", Index: 0, Delta: &anthropicEventDelta{Type: "text_delta", Text: "Hello"}},
+		{Type: "content_block_delta", Index: 0, Delta: &anthropicEventDelta{Type: "text_delta", Text: ", world"}},
+		{Type: "content_block_stop", Index: 0},
+		{Type: "content_block_start", Index: 1, ContentBlock: &anthropicBlock{Type: "tool_use", ID: "toolu_1", Name: "bash"}},
+		{Type: "content_block_delta", Index: 1, Delta: &anthropicEventDelta{Type: "input_json_delta", PartialJSON: `{"command":`}},
+		{Type: "content_block_delta", Index: 1, Delta: &anthropicEventDelta{Type: "input_json_delta", PartialJSON: `"ls"}`}},
+		{Type: "content_block_stop", Index: 1},
+		{Type: "message_delta", Delta: &anthropicEventDelta{StopReason: "tool_use"}, Usage: &struct {
+			OutputTokens int `json:"output_tokens"`
+		}{OutputTokens: 9}},
+		{Type: "message_stop"},
+	}))
+	provider := NewAnthropicProvider(testProviderConfig(server))
+
+	events, err := provider.CompleteStream(context.Background(), &CompletionRequest{
+		Messages: []Message{{Role: RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+
+	var text string
+	var calls []ToolCall
+	var done *StreamEvent
+	for event := range events {
+		switch event.Type {
+		case StreamEventText:
+			text += event.Text
+		case StreamEventToolCallEnd:
+			calls = append(calls, *event.ToolCall)
+		case StreamEventDone:
+			event := event
+			done = &event
+		case StreamEventError:
+			t.Fatalf("stream error: %v", event.Error)
+		}
+	}
+
+	if text != "Hello, world" {
+		t.Errorf("text = %q", text)
+	}
+	if len(calls) != 1 || calls[0].Name != "bash" || string(calls[0].Arguments) != `{"command":"ls"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if done == nil || done.StopReason != "tool_use" || done.Usage.InputTokens != 12 || done.Usage.OutputTokens != 9 {
+		t.Errorf("done event = %+v", done)
+	}
+}
+
+func TestAnthropicAPIError(t *testing.T) {
+	server := newAnthropicTestServer(t, anthropicErrorHandler(http.StatusBadRequest, "invalid_request_error", "max_tokens is too large"))
+	provider := NewAnthropicProvider(testProviderConfig(server))
+
+	_, err := provider.Complete(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Type != "invalid_request_error" || apiErr.Message != "max_tokens is too large" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+	if !errors.Is(err, ErrAPIError) {
+		t.Error("APIError does not unwrap to ErrAPIError")
+	}
+
+	_, err = provider.CompleteStream(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("CompleteStream: err = %v, want an APIError with status 400", err)
+	}
+}
+
+func TestAnthropicNoAPIKey(t *testing.T) {
+	provider := NewAnthropicProvider(&ProviderConfig{})
+	if _, err := provider.Complete(context.Background(), &CompletionRequest{}); !errors.Is(err, ErrNoAPIKey) {
+		t.Errorf("err = %v, want ErrNoAPIKey", err)
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import "strings"
+
+// Capabilities describes the features a provider supports for its configured
+// model
+type Capabilities struct {
+	Streaming     bool `json:"streaming"`
+	Tools         bool `json:"tools"`
+	Vision        bool `json:"vision"`
+	Thinking      bool `json:"thinking"`
+	PromptCaching bool `json:"prompt_caching"`
+
+	// Prefill reports whether the model continues
+	// CompletionRequest.AssistantPrefill rather than being asked to use it
+	Prefill bool `json:"prefill"`
+}
+
+// List returns the names of the supported capabilities
+func (c Capabilities) List() []string {
+	var names []string
+	if c.Streaming {
+		names = append(names, "streaming")
+	}
+	if c.Tools {
+		names = append(names, "tools")
+	}
+	if c.Vision {
+		names = append(names, "vision")
+	}
+	if c.Thinking {
+		names = append(names, "thinking")
+	}
+	if c.PromptCaching {
+		names = append(names, "prompt-caching")
+	}
+	if c.Prefill {
+		names = append(names, "prefill")
+	}
+	return names
+}
+
+// prefillInstruction is added to the system prompt of providers that
+// cannot continue an assistant prefill
+func prefillInstruction(prefill string) string {
+	return "Begin your response with exactly the following text, then continue it:\n" + prefill
+}
+
+// hasModelPrefix reports whether model starts with any of the prefixes
+func hasModelPrefix(model string, prefixes ...string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
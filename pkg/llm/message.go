@@ -1,6 +1,12 @@
 package llm
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Role represents the role of a message sender
 type Role string
@@ -18,6 +24,21 @@ type Message struct {
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// IsError marks a tool result that reports a failed tool call, so the
+	// model reconsiders its approach rather than using the content as output
+	IsError bool `json:"is_error,omitempty"`
+
+	// Images are attached to tool results by tools that produce them
+	Images []Image `json:"images,omitempty"`
+}
+
+// Image is an image attached to a message
+type Image struct {
+	// MediaType is the image format: "image/png", "image/jpeg", "image/gif"
+	// or "image/webp"
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"data"`
 }
 
 // ToolCall represents a tool invocation request from the LLM
@@ -27,6 +48,60 @@ type ToolCall struct {
 	Arguments json.RawMessage `json:"arguments"`
 }
 
+// maxToolCallDisplay is the length at which String truncates arguments, and
+// maxToolCallValue the length for each value in ShortString
+const (
+	maxToolCallDisplay = 200
+	maxToolCallValue   = 40
+)
+
+// String returns the call as name(arguments) with the arguments
+// pretty-printed and truncated for display
+func (tc ToolCall) String() string {
+	args := string(tc.Arguments)
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, tc.Arguments, "", "  "); err == nil {
+		args = pretty.String()
+	}
+	return tc.Name + "(" + truncateDisplay(args, maxToolCallDisplay) + ")"
+}
+
+// ShortString returns the call on one line as name(key=value, ...) with keys
+// sorted and long values shortened, for log lines
+func (tc ToolCall) ShortString() string {
+	var args map[string]interface{}
+	if err := json.Unmarshal(tc.Arguments, &args); err != nil {
+		return tc.Name + "(" + truncateDisplay(string(tc.Arguments), maxToolCallValue) + ")"
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		value, ok := args[key].(string)
+		if !ok {
+			encoded, _ := json.Marshal(args[key])
+			value = string(encoded)
+		}
+		value = strings.Join(strings.Fields(value), " ")
+		parts[i] = key + "=" + truncateDisplay(value, maxToolCallValue)
+	}
+	return tc.Name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// truncateDisplay shortens s to at most max runes, marking the cut with "..."
+func truncateDisplay(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
+
 // ToolDefinition describes a tool for the LLM
 type ToolDefinition struct {
 	Name        string                 `json:"name"`
@@ -42,12 +117,81 @@ type Response struct {
 	Usage      Usage      `json:"usage,omitempty"`
 }
 
+// Stop reasons for responses the model declined to give. The response
+// content explains the refusal; the agent ends the turn instead of executing
+// tool calls or asking again.
+const (
+	// StopReasonRefusal means the model refused the request
+	StopReasonRefusal = "refusal"
+
+	// StopReasonContentFilter means the provider's content filter withheld
+	// or cut off the output
+	StopReasonContentFilter = "content_filter"
+)
+
+// contentFilterMessage is the response content when a content filter
+// removed all of it
+const contentFilterMessage = "[response withheld by the provider's content filter]"
+
+// Declined reports whether a stop reason means the model refused or its
+// output was filtered
+func Declined(stopReason string) bool {
+	return stopReason == StopReasonRefusal || stopReason == StopReasonContentFilter
+}
+
+// StopReasonPauseTurn means the provider paused a long-running turn, for
+// example during server-side tool use. Sending the conversation back with
+// the partial response as the last assistant message resumes it.
+const StopReasonPauseTurn = "pause_turn"
+
+// Paused reports whether a stop reason asks for the turn to be resumed
+// rather than treated as complete
+func Paused(stopReason string) bool {
+	return stopReason == StopReasonPauseTurn
+}
+
 // Usage tracks token usage
 type Usage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
 }
 
+// TotalTokens returns the sum of input and output tokens
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// String formats usage as "123 in / 456 out (579 total)"
+func (u Usage) String() string {
+	return fmt.Sprintf("%d in / %d out (%d total)", u.InputTokens, u.OutputTokens, u.TotalTokens())
+}
+
+// MergeSystemPrompt applies the system-message policy shared by all
+// providers: the request's System prompt and the content of every
+// RoleSystem message, in order, are joined with blank lines into a single
+// system prompt, and the remaining messages are returned without them.
+// System messages therefore act as additions to the system prompt no matter
+// where they appear in the conversation.
+func MergeSystemPrompt(system string, messages []Message) (string, []Message) {
+	parts := make([]string, 0, 1)
+	if system != "" {
+		parts = append(parts, system)
+	}
+
+	rest := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			if msg.Content != "" {
+				parts = append(parts, msg.Content)
+			}
+			continue
+		}
+		rest = append(rest, msg)
+	}
+
+	return strings.Join(parts, "\n\n"), rest
+}
+
 // NewUserMessage creates a new user message
 func NewUserMessage(content string) Message {
 	return Message{
@@ -81,6 +225,22 @@ func NewToolResultMessage(toolCallID, content string) Message {
 	}
 }
 
+// NewToolResultErrorMessage creates a tool result message reporting that
+// the tool call failed
+func NewToolResultErrorMessage(toolCallID, errMessage string) Message {
+	msg := NewToolResultMessage(toolCallID, errMessage)
+	msg.IsError = true
+	return msg
+}
+
+// NewToolResultMessageWithImages creates a tool result message carrying
+// images alongside its text
+func NewToolResultMessageWithImages(toolCallID, content string, images []Image) Message {
+	msg := NewToolResultMessage(toolCallID, content)
+	msg.Images = images
+	return msg
+}
+
 // NewAssistantToolCallMessage creates an assistant message with tool calls
 func NewAssistantToolCallMessage(toolCalls []ToolCall) Message {
 	return Message{
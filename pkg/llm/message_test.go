@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		system     string
+		messages   []Message
+		wantSystem string
+		wantRoles  []Role
+	}{
+		{
+			name:       "system only",
+			system:     "Be brief.",
+			messages:   []Message{NewUserMessage("hi")},
+			wantSystem: "Be brief.",
+			wantRoles:  []Role{RoleUser},
+		},
+		{
+			name:       "leading and mid-conversation messages",
+			system:     "Be brief.",
+			messages:   []Message{{Role: RoleSystem, Content: "Use Go."}, NewUserMessage("hi"), NewAssistantMessage("hello"), {Role: RoleSystem, Content: "Context is 80% full."}, NewUserMessage("go on")},
+			wantSystem: "Be brief.\n\nUse Go.\n\nContext is 80% full.",
+			wantRoles:  []Role{RoleUser, RoleAssistant, RoleUser},
+		},
+		{
+			name:       "no top-level prompt",
+			messages:   []Message{NewUserMessage("hi"), {Role: RoleSystem, Content: "Use Go."}},
+			wantSystem: "Use Go.",
+			wantRoles:  []Role{RoleUser},
+		},
+		{
+			name:       "empty system messages are dropped",
+			messages:   []Message{{Role: RoleSystem}, NewUserMessage("hi")},
+			wantSystem: "",
+			wantRoles:  []Role{RoleUser},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, rest := MergeSystemPrompt(tt.system, tt.messages)
+			if system != tt.wantSystem {
+				t.Errorf("system = %q, want %q", system, tt.wantSystem)
+			}
+			roles := make([]Role, len(rest))
+			for i, msg := range rest {
+				roles[i] = msg.Role
+			}
+			if !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+// TestSystemPromptParity checks that both providers send the model the same
+// system prompt and the same conversation for a request with system messages
+// scattered through it
+func TestSystemPromptParity(t *testing.T) {
+	req := &CompletionRequest{
+		System: "Be brief.",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "Use Go."},
+			NewUserMessage("List the files"),
+			NewAssistantMessage("main.go"),
+			{Role: RoleSystem, Content: "Context is 80% full."},
+			NewUserMessage("Summarize main.go"),
+		},
+	}
+	const want = "Be brief.\n\nUse Go.\n\nContext is 80% full."
+
+	anthropicSystem, anthropicMsgs := convertAnthropicMessages(req)
+	if anthropicSystem != want {
+		t.Errorf("anthropic system = %q, want %q", anthropicSystem, want)
+	}
+
+	openaiMsgs := convertOpenAIMessages(req)
+	if len(openaiMsgs) == 0 || openaiMsgs[0].Role != "system" || openaiMsgs[0].Content != want {
+		t.Fatalf("openai messages = %+v, want a leading system message %q", openaiMsgs, want)
+	}
+	openaiMsgs = openaiMsgs[1:]
+
+	if len(anthropicMsgs) != len(openaiMsgs) {
+		t.Fatalf("anthropic sent %d messages, openai %d", len(anthropicMsgs), len(openaiMsgs))
+	}
+	for i := range anthropicMsgs {
+		if openaiMsgs[i].Role == "system" {
+			t.Errorf("openai message %d is a second system message", i+1)
+		}
+		if anthropicMsgs[i].Role != openaiMsgs[i].Role || anthropicMsgs[i].Content != openaiMsgs[i].Content {
+			t.Errorf("message %d: anthropic %+v, openai %+v", i, anthropicMsgs[i], openaiMsgs[i])
+		}
+	}
+}
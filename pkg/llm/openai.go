@@ -29,7 +29,7 @@ func NewOpenAIProvider(config *ProviderConfig) *OpenAIProvider {
 	}
 	return &OpenAIProvider{
 		config: config,
-		client: &http.Client{},
+		client: newHTTPClient(config),
 	}
 }
 
@@ -37,6 +37,20 @@ func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
+// Capabilities reports the features supported by the configured OpenAI model
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	model := p.config.Model
+	reasoning := hasModelPrefix(model, "o1", "o3", "o4", "gpt-5")
+	earlyReasoning := hasModelPrefix(model, "o1-mini", "o1-preview")
+	return Capabilities{
+		Streaming:     true,
+		Tools:         !earlyReasoning,
+		Vision:        hasModelPrefix(model, "gpt-4o", "gpt-4-turbo", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4") && !earlyReasoning,
+		Thinking:      reasoning,
+		PromptCaching: hasModelPrefix(model, "gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"),
+	}
+}
+
 // openaiRequest represents a request to the OpenAI API
 type openaiRequest struct {
 	Model       string       `json:"model"`
@@ -49,6 +63,7 @@ type openaiRequest struct {
 type openaiMsg struct {
 	Role       string           `json:"role"`
 	Content    string           `json:"content,omitempty"`
+	Refusal    string           `json:"refusal,omitempty"`
 	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string           `json:"tool_call_id,omitempty"`
 }
@@ -117,6 +132,7 @@ type openaiStreamResponse struct {
 type openaiStreamDelta struct {
 	Role      string                 `json:"role,omitempty"`
 	Content   string                 `json:"content,omitempty"`
+	Refusal   string                 `json:"refusal,omitempty"`
 	ToolCalls []openaiStreamToolCall `json:"tool_calls,omitempty"`
 }
 
@@ -130,29 +146,27 @@ type openaiStreamToolCall struct {
 	} `json:"function"`
 }
 
-func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*Response, error) {
-	if p.config.APIKey == "" {
-		return nil, ErrNoAPIKey
-	}
+// convertOpenAIMessages converts messages to OpenAI format. System messages
+// are merged into a single leading system message (see MergeSystemPrompt).
+func convertOpenAIMessages(req *CompletionRequest) []openaiMsg {
+	systemPrompt, messages := MergeSystemPrompt(req.System, req.Messages)
+	msgs := make([]openaiMsg, 0, len(messages)+1)
 
-	// Convert messages to OpenAI format
-	msgs := make([]openaiMsg, 0, len(req.Messages)+1)
+	// Chat completions cannot continue an assistant message, so the model
+	// is asked to start with the prefill instead
+	if req.AssistantPrefill != "" {
+		systemPrompt = strings.TrimSpace(systemPrompt + "\n\n" + prefillInstruction(req.AssistantPrefill))
+	}
 
-	// Add system message if provided
-	if req.System != "" {
+	if systemPrompt != "" {
 		msgs = append(msgs, openaiMsg{
 			Role:    "system",
-			Content: req.System,
+			Content: systemPrompt,
 		})
 	}
 
-	for _, msg := range req.Messages {
+	for _, msg := range messages {
 		switch msg.Role {
-		case RoleSystem:
-			msgs = append(msgs, openaiMsg{
-				Role:    "system",
-				Content: msg.Content,
-			})
 		case RoleUser:
 			msgs = append(msgs, openaiMsg{
 				Role:    "user",
@@ -176,29 +190,49 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			}
 			msgs = append(msgs, oaiMsg)
 		case RoleTool:
+			// Tool messages only carry text in the Chat Completions API
+			content := msg.Content
+			if len(msg.Images) > 0 {
+				content += fmt.Sprintf("\n[%d image(s) omitted: not supported in tool results by this provider]", len(msg.Images))
+			}
 			msgs = append(msgs, openaiMsg{
 				Role:       "tool",
-				Content:    msg.Content,
+				Content:    content,
 				ToolCallID: msg.ToolCallID,
 			})
 		}
 	}
 
-	// Convert tools to OpenAI format
-	var tools []openaiTool
-	if len(req.Tools) > 0 {
-		tools = make([]openaiTool, len(req.Tools))
-		for i, t := range req.Tools {
-			tools[i] = openaiTool{
-				Type: "function",
-				Function: openaiFunction{
-					Name:        t.Name,
-					Description: t.Description,
-					Parameters:  t.Parameters,
-				},
-			}
+	return msgs
+}
+
+// convertOpenAITools converts tool definitions to OpenAI format
+func convertOpenAITools(defs []ToolDefinition) []openaiTool {
+	if len(defs) == 0 {
+		return nil
+	}
+	tools := make([]openaiTool, len(defs))
+	for i, t := range defs {
+		tools[i] = openaiTool{
+			Type: "function",
+			Function: openaiFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
 		}
 	}
+	return tools
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*Response, error) {
+	if p.config.APIKey == "" {
+		return nil, ErrNoAPIKey
+	}
+
+	// Convert messages and tools to OpenAI format
+	msgs := convertOpenAIMessages(req)
+	tools := convertOpenAITools(req.Tools)
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -252,7 +286,7 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 	}
 
 	if openaiResp.Error != nil {
-		return nil, fmt.Errorf("%w: %s - %s", ErrAPIError, openaiResp.Error.Type, openaiResp.Error.Message)
+		return nil, &APIError{StatusCode: resp.StatusCode, Type: openaiResp.Error.Type, Message: openaiResp.Error.Message}
 	}
 
 	if len(openaiResp.Choices) == 0 {
@@ -260,9 +294,17 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 	}
 
 	choice := openaiResp.Choices[0]
+	content, stopReason := choice.Message.Content, choice.FinishReason
+	switch {
+	case choice.Message.Refusal != "":
+		content, stopReason = choice.Message.Refusal, StopReasonRefusal
+	case stopReason == StopReasonContentFilter && content == "":
+		content = contentFilterMessage
+	}
+
 	response := &Response{
-		Content:    choice.Message.Content,
-		StopReason: choice.FinishReason,
+		Content:    content,
+		StopReason: stopReason,
 		Usage: Usage{
 			InputTokens:  openaiResp.Usage.PromptTokens,
 			OutputTokens: openaiResp.Usage.CompletionTokens,
@@ -287,68 +329,9 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 		return nil, ErrNoAPIKey
 	}
 
-	// Convert messages to OpenAI format (same as Complete)
-	msgs := make([]openaiMsg, 0, len(req.Messages)+1)
-
-	if req.System != "" {
-		msgs = append(msgs, openaiMsg{
-			Role:    "system",
-			Content: req.System,
-		})
-	}
-
-	for _, msg := range req.Messages {
-		switch msg.Role {
-		case RoleSystem:
-			msgs = append(msgs, openaiMsg{
-				Role:    "system",
-				Content: msg.Content,
-			})
-		case RoleUser:
-			msgs = append(msgs, openaiMsg{
-				Role:    "user",
-				Content: msg.Content,
-			})
-		case RoleAssistant:
-			oaiMsg := openaiMsg{
-				Role:    "assistant",
-				Content: msg.Content,
-			}
-			if len(msg.ToolCalls) > 0 {
-				oaiMsg.ToolCalls = make([]openaiToolCall, len(msg.ToolCalls))
-				for i, tc := range msg.ToolCalls {
-					oaiMsg.ToolCalls[i] = openaiToolCall{
-						ID:   tc.ID,
-						Type: "function",
-					}
-					oaiMsg.ToolCalls[i].Function.Name = tc.Name
-					oaiMsg.ToolCalls[i].Function.Arguments = string(tc.Arguments)
-				}
-			}
-			msgs = append(msgs, oaiMsg)
-		case RoleTool:
-			msgs = append(msgs, openaiMsg{
-				Role:       "tool",
-				Content:    msg.Content,
-				ToolCallID: msg.ToolCallID,
-			})
-		}
-	}
-
-	var tools []openaiTool
-	if len(req.Tools) > 0 {
-		tools = make([]openaiTool, len(req.Tools))
-		for i, t := range req.Tools {
-			tools[i] = openaiTool{
-				Type: "function",
-				Function: openaiFunction{
-					Name:        t.Name,
-					Description: t.Description,
-					Parameters:  t.Parameters,
-				},
-			}
-		}
-	}
+	// Convert messages and tools to OpenAI format
+	msgs := convertOpenAIMessages(req)
+	tools := convertOpenAITools(req.Tools)
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -408,7 +391,7 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("%w: status %d: %s", ErrAPIError, resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	eventChan := make(chan StreamEvent, 100)
@@ -420,6 +403,7 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 		reader := bufio.NewReader(resp.Body)
 		var inputTokens, outputTokens int
 		var stopReason string
+		var sentText, refused bool
 
 		// Track tool calls being built
 		toolCalls := make(map[int]*ToolCall)
@@ -481,12 +465,22 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 
 			// Handle text content
 			if choice.Delta.Content != "" {
+				sentText = true
 				eventChan <- StreamEvent{
 					Type: StreamEventText,
 					Text: choice.Delta.Content,
 				}
 			}
 
+			// A refusal streams in place of content
+			if choice.Delta.Refusal != "" {
+				sentText, refused = true, true
+				eventChan <- StreamEvent{
+					Type: StreamEventText,
+					Text: choice.Delta.Refusal,
+				}
+			}
+
 			// Handle tool calls
 			for _, tcDelta := range choice.Delta.ToolCalls {
 				idx := tcDelta.Index
@@ -529,6 +523,13 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *CompletionRequ
 			}
 		}
 
+		if refused {
+			stopReason = StopReasonRefusal
+		}
+		if stopReason == StopReasonContentFilter && !sentText {
+			eventChan <- StreamEvent{Type: StreamEventText, Text: contentFilterMessage}
+		}
+
 		// Finalize any pending tool calls
 		for idx, tc := range toolCalls {
 			tc.Arguments = json.RawMessage(toolCallArgs[idx])
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestOpenAIComplete(t *testing.T) {
+	var received openaiRequest
+	handler := jsonHandler(map[string]interface{}{
+		"id": "chatcmpl-test",
+		"choices": []interface{}{map[string]interface{}{
+			"message": map[string]interface{}{
+				"role":    "assistant",
+				"content": "Let me check.",
+				"tool_calls": []interface{}{map[string]interface{}{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]string{"name": "bash", "arguments": `{"command":"ls"}`},
+				}},
+			},
+			"finish_reason": "tool_calls",
+		}},
+		"usage": map[string]int{"prompt_tokens": 20, "completion_tokens": 8, "total_tokens": 28},
+	})
+	server := newOpenAITestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		handler(w, r)
+	})
+	provider := NewOpenAIProvider(testProviderConfig(server))
+
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{
+		System:   "Be brief.",
+		Messages: []Message{{Role: RoleUser, Content: "List the files"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if received.Model != "test-model" || len(received.Messages) != 2 || received.Messages[0].Role != "system" {
+		t.Errorf("request = %+v, want the system prompt as the first message", received)
+	}
+	if resp.Content != "Let me check." || resp.StopReason != "tool_calls" {
+		t.Errorf("Content = %q, StopReason = %q", resp.Content, resp.StopReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "bash" || string(resp.ToolCalls[0].Arguments) != `{"command":"ls"}` {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	if resp.Usage.InputTokens != 20 || resp.Usage.OutputTokens != 8 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestOpenAICompleteStream(t *testing.T) {
+	server := newOpenAITestServer(t, openAIStreamHandler([]string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"bash","arguments":"{\"comm"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"and\":\"ls\"}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":9,"total_tokens":21}}`,
+	}))
+	provider := NewOpenAIProvider(testProviderConfig(server))
+
+	events, err := provider.CompleteStream(context.Background(), &CompletionRequest{
+		Messages: []Message{{Role: RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream: %v", err)
+	}
+
+	var text string
+	var calls []ToolCall
+	var done *StreamEvent
+	for event := range events {
+		switch event.Type {
+		case StreamEventText:
+			text += event.Text
+		case StreamEventToolCallEnd:
+			calls = append(calls, *event.ToolCall)
+		case StreamEventDone:
+			event := event
+			done = &event
+		case StreamEventError:
+			t.Fatalf("stream error: %v", event.Error)
+		}
+	}
+
+	if text != "Hello" {
+		t.Errorf("text = %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || string(calls[0].Arguments) != `{"command":"ls"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if done == nil || done.StopReason != "tool_calls" || done.Usage.InputTokens != 12 || done.Usage.OutputTokens != 9 {
+		t.Errorf("done event = %+v", done)
+	}
+}
+
+func TestOpenAIAPIError(t *testing.T) {
+	server := newOpenAITestServer(t, openAIErrorHandler(http.StatusUnauthorized, "Incorrect API key provided"))
+	provider := NewOpenAIProvider(testProviderConfig(server))
+
+	_, err := provider.Complete(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Incorrect API key provided" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+
+	_, err = provider.CompleteStream(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("CompleteStream: err = %v, want an APIError with status 401", err)
+	}
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusOverloaded is the status Anthropic returns with overloaded_error
+const statusOverloaded = 529
+
+// OverloadPolicy controls retries when the provider reports that it is
+// overloaded (HTTP 529). Overload clears more slowly than ordinary errors,
+// so backoff is long and jittered to avoid synchronized retries.
+type OverloadPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	// (0 disables overload retries)
+	MaxRetries int
+
+	// BaseDelay is the backoff before the first retry; it doubles with each
+	// further retry up to MaxDelay. A Retry-After header, if longer, wins.
+	BaseDelay time.Duration
+
+	// MaxDelay caps a single backoff
+	MaxDelay time.Duration
+
+	// MaxWait caps the total time spent backing off for one request
+	// (0 = no cap beyond MaxRetries)
+	MaxWait time.Duration
+}
+
+// DefaultOverloadPolicy returns the default overload retry policy
+func DefaultOverloadPolicy() *OverloadPolicy {
+	return &OverloadPolicy{
+		MaxRetries: 5,
+		BaseDelay:  2 * time.Second,
+		MaxDelay:   30 * time.Second,
+		MaxWait:    2 * time.Minute,
+	}
+}
+
+// backoff returns the jittered delay before the given retry (1-based): a
+// random duration between half and all of the exponential delay
+func (p *OverloadPolicy) backoff(retry int, resp *http.Response) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		if after := time.Duration(seconds) * time.Second; after > delay {
+			delay = after
+		}
+	}
+	return delay
+}
+
+// doWithOverloadRetry sends the request built by newReq, backing off and
+// resending while the provider answers 529. When retries or the wait budget
+// run out, the last 529 response is returned unread for the caller's usual
+// error handling. Every backoff is logged so overload pressure is visible.
+func doWithOverloadRetry(ctx context.Context, client *http.Client, policy *OverloadPolicy, provider string, newReq func() (*http.Request, error)) (*http.Response, error) {
+	var waited time.Duration
+	for retry := 1; ; retry++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if resp.StatusCode != statusOverloaded || policy == nil || retry > policy.MaxRetries {
+			return resp, nil
+		}
+
+		delay := policy.backoff(retry, resp)
+		if policy.MaxWait > 0 && waited+delay > policy.MaxWait {
+			log.Printf("llm: %s overloaded; giving up after %d retries (%s waited, cap %s)", provider, retry-1, waited.Round(time.Millisecond), policy.MaxWait)
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.Printf("llm: %s overloaded (HTTP %d); retry %d/%d in %s", provider, statusOverloaded, retry, policy.MaxRetries, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		waited += delay
+	}
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAnthropicOverloadRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := newAnthropicTestServer(t, overloadedHandler(2, anthropicSuccessHandler([]anthropicResponse{anthropicTextResponse("ok", 1, 1)}), &attempts))
+	provider := NewAnthropicProvider(testProviderConfig(server))
+
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+}
+
+func TestAnthropicOverloadRetriesExhausted(t *testing.T) {
+	var attempts atomic.Int32
+	server := newAnthropicTestServer(t, overloadedHandler(100, nil, &attempts))
+	config := testProviderConfig(server)
+	config.Overload.MaxRetries = 2
+	provider := NewAnthropicProvider(config)
+
+	_, err := provider.Complete(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != statusOverloaded || apiErr.Type != "overloaded_error" {
+		t.Fatalf("err = %v, want an overloaded APIError", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("%d attempts, want the first and 2 retries", n)
+	}
+
+	// Without a policy the first 529 is returned
+	attempts.Store(0)
+	config.Overload = nil
+	if _, err := provider.CompleteStream(context.Background(), &CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}); !errors.As(err, &apiErr) {
+		t.Fatalf("CompleteStream: err = %v, want an APIError", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("%d attempts without an overload policy, want 1", n)
+	}
+}
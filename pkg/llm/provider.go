@@ -3,6 +3,9 @@ package llm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 )
 
 var (
@@ -11,6 +14,25 @@ var (
 	ErrAPIError       = errors.New("API error")
 )
 
+// APIError is an error response from a provider's API. It unwraps to
+// ErrAPIError.
+type APIError struct {
+	StatusCode int    // HTTP status, if the error came with one
+	Type       string // Provider error type, e.g. "invalid_request_error"
+	Message    string // Error message or response body
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: %s - %s", ErrAPIError, e.Type, e.Message)
+	}
+	return fmt.Sprintf("%s: status %d: %s", ErrAPIError, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return ErrAPIError
+}
+
 // Provider is the interface that LLM providers must implement
 type Provider interface {
 	// Name returns the provider name
@@ -18,6 +40,9 @@ type Provider interface {
 
 	// Complete sends messages to the LLM and returns a response
 	Complete(ctx context.Context, req *CompletionRequest) (*Response, error)
+
+	// Capabilities reports the features supported by the configured model
+	Capabilities() Capabilities
 }
 
 // StreamProvider extends Provider with streaming support
@@ -28,7 +53,9 @@ type StreamProvider interface {
 	CompleteStream(ctx context.Context, req *CompletionRequest) (<-chan StreamEvent, error)
 }
 
-// CompletionRequest contains the parameters for a completion request
+// CompletionRequest contains the parameters for a completion request.
+// Messages with RoleSystem are not sent in place; every provider merges them
+// into System (see MergeSystemPrompt).
 type CompletionRequest struct {
 	Model       string           `json:"model"`
 	Messages    []Message        `json:"messages"`
@@ -36,6 +63,13 @@ type CompletionRequest struct {
 	MaxTokens   int              `json:"max_tokens,omitempty"`
 	Temperature float64          `json:"temperature,omitempty"`
 	System      string           `json:"system,omitempty"`
+
+	// AssistantPrefill is text the response starts with, such as "{" to
+	// force JSON. Providers with Capabilities().Prefill continue the text
+	// and include it at the start of the returned content. Others, such as
+	// OpenAI, are instead told to begin their response with it, which
+	// models usually but not always follow.
+	AssistantPrefill string `json:"assistant_prefill,omitempty"`
 }
 
 // ProviderConfig holds configuration for LLM providers
@@ -45,6 +79,17 @@ type ProviderConfig struct {
 	Model       string
 	MaxTokens   int
 	Temperature float64
+
+	// Connection pooling. Zero values keep Go's default transport settings
+	// (http.DefaultTransport: 100 idle connections in total, 2 per host, no
+	// per-host connection cap, 90s idle timeout).
+	MaxIdleConns    int           // Maximum idle connections across all hosts
+	MaxConnsPerHost int           // Maximum connections per host, including active ones
+	IdleConnTimeout time.Duration // How long an idle connection stays in the pool
+
+	// Overload is the retry policy for overloaded responses (HTTP 529).
+	// Nil disables overload retries.
+	Overload *OverloadPolicy
 }
 
 // DefaultConfig returns a default provider configuration
@@ -52,5 +97,29 @@ func DefaultConfig() *ProviderConfig {
 	return &ProviderConfig{
 		MaxTokens:   4096,
 		Temperature: 0.7,
+		Overload:    DefaultOverloadPolicy(),
+	}
+}
+
+// newHTTPClient returns the HTTP client used by a provider. A custom
+// transport is only created when the config overrides a pooling setting.
+func newHTTPClient(config *ProviderConfig) *http.Client {
+	if config.MaxIdleConns == 0 && config.MaxConnsPerHost == 0 && config.IdleConnTimeout == 0 {
+		return &http.Client{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+		// Keep per-host idle connections in step so the pool can actually
+		// be used against a single API endpoint.
+		transport.MaxIdleConnsPerHost = config.MaxIdleConns
+	}
+	if config.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = config.MaxConnsPerHost
+	}
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
 	}
+	return &http.Client{Transport: transport}
 }
@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testAPIKey is the API key providers send to the test servers
+const testAPIKey = "test-key"
+
+// newAnthropicTestServer starts a server standing in for the Anthropic
+// messages API. It fails the test on requests without the headers the API
+// requires before passing them to handler.
+func newAnthropicTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("request method = %s, want POST", r.Method)
+		}
+		if got := r.Header.Get("x-api-key"); got != testAPIKey {
+			t.Errorf("x-api-key = %q, want %q", got, testAPIKey)
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Error("request has no anthropic-version header")
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newOpenAITestServer starts a server standing in for the OpenAI chat
+// completions API
+func newOpenAITestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("request method = %s, want POST", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testAPIKey {
+			t.Errorf("Authorization = %q, want the bearer test key", got)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testProviderConfig returns a provider configuration for a test server.
+// Overload retries back off for a millisecond instead of seconds.
+func testProviderConfig(server *httptest.Server) *ProviderConfig {
+	config := DefaultConfig()
+	config.BaseURL = server.URL
+	config.APIKey = testAPIKey
+	config.Model = "test-model"
+	config.Overload = &OverloadPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return config
+}
+
+// jsonHandler answers each request with the next response, encoded as
+// JSON, and fails requests beyond the last one
+func jsonHandler(responses ...interface{}) http.HandlerFunc {
+	var next atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		i := int(next.Add(1)) - 1
+		if i >= len(responses) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses[i])
+	}
+}
+
+// anthropicSuccessHandler answers successive requests with responses
+func anthropicSuccessHandler(responses []anthropicResponse) http.HandlerFunc {
+	values := make([]interface{}, len(responses))
+	for i := range responses {
+		values[i] = responses[i]
+	}
+	return jsonHandler(values...)
+}
+
+// anthropicTextResponse is a complete response with a single text block
+func anthropicTextResponse(text string, inputTokens, outputTokens int) anthropicResponse {
+	resp := anthropicResponse{
+		ID:         "msg_test",
+		Type:       "message",
+		Role:       "assistant",
+		Content:    []anthropicBlock{{Type: "text", Text: text}},
+		StopReason: "end_turn",
+	}
+	resp.Usage.InputTokens = inputTokens
+	resp.Usage.OutputTokens = outputTokens
+	return resp
+}
+
+// anthropicStreamHandler streams events as server-sent events
+func anthropicStreamHandler(events []anthropicStreamEvent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chunks := make([]string, len(events))
+		for i, event := range events {
+			data, _ := json.Marshal(event)
+			chunks[i] = fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		writeSSE(w, chunks)
+	}
+}
+
+// anthropicErrorHandler answers every request with an API error
+func anthropicErrorHandler(code int, errorType, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type":  "error",
+			"error": map[string]string{"type": errorType, "message": message},
+		})
+	}
+}
+
+// openAIStreamHandler streams JSON chunks as server-sent events, followed
+// by the [DONE] marker
+func openAIStreamHandler(chunks []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lines := make([]string, 0, len(chunks)+1)
+		for _, chunk := range chunks {
+			lines = append(lines, "data: "+chunk+"\n\n")
+		}
+		writeSSE(w, append(lines, "data: [DONE]\n\n"))
+	}
+}
+
+// openAIErrorHandler answers every request with an API error
+func openAIErrorHandler(code int, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]string{"type": "invalid_request_error", "message": message},
+		})
+	}
+}
+
+// overloadedHandler answers the first n requests with HTTP 529 and passes
+// the rest to next. attempts, if not nil, counts every request.
+func overloadedHandler(n int, next http.HandlerFunc, attempts *atomic.Int32) http.HandlerFunc {
+	var seen atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if attempts != nil {
+			attempts.Add(1)
+		}
+		if int(seen.Add(1)) <= n {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusOverloaded)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeSSE writes server-sent event chunks, flushing after each one
+func writeSSE(w http.ResponseWriter, chunks []string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	for _, chunk := range chunks {
+		fmt.Fprint(w, chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
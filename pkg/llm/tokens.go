@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+const (
+	// charsPerToken is the rough number of characters per token in English
+	// text and code for current tokenizers
+	charsPerToken = 4
+
+	// imageTokens is a typical cost of one image in a request
+	imageTokens = 1600
+
+	// messageOverheadTokens covers the role and framing of each message
+	messageOverheadTokens = 4
+)
+
+// EstimateTokens returns a rough token count for text, at about four
+// characters per token. It needs no tokenizer and is meant for budgeting,
+// not billing.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessageTokens returns a rough token count for a message,
+// including its tool calls and images
+func EstimateMessageTokens(msg Message) int {
+	tokens := messageOverheadTokens + EstimateTokens(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		tokens += EstimateTokens(tc.Name) + EstimateTokens(string(tc.Arguments))
+	}
+	return tokens + len(msg.Images)*imageTokens
+}
+
+// EstimateRequestTokens returns a rough count of the input tokens a request
+// will use: the system prompt, messages and tool definitions
+func EstimateRequestTokens(req *CompletionRequest) int {
+	tokens := EstimateTokens(req.System)
+	for _, msg := range req.Messages {
+		tokens += EstimateMessageTokens(msg)
+	}
+	for _, def := range req.Tools {
+		tokens += EstimateToolTokens(def)
+	}
+	return tokens
+}
+
+// EstimateToolTokens returns a rough token count for a tool definition:
+// its name, description and parameter schema
+func EstimateToolTokens(def ToolDefinition) int {
+	schema, _ := json.Marshal(def.Parameters)
+	return EstimateTokens(def.Name) + EstimateTokens(def.Description) + EstimateTokens(string(schema))
+}
+
+// ProviderForModel returns the provider that serves a model, judged by its
+// name: "anthropic" for Claude models, "openai" for GPT and o-series
+// models, or "" if the name is not recognized
+func ProviderForModel(model string) string {
+	switch {
+	case hasModelPrefix(model, "claude"):
+		return "anthropic"
+	case hasModelPrefix(model, "gpt-", "o1", "o3", "o4", "chatgpt-"):
+		return "openai"
+	}
+	return ""
+}
+
+// ContextWindow returns the context window in tokens of a known model, or 0
+// if the model is not recognized
+func ContextWindow(model string) int {
+	switch {
+	case hasModelPrefix(model, "claude"):
+		return 200000
+	case hasModelPrefix(model, "gpt-5"):
+		return 400000
+	case hasModelPrefix(model, "gpt-4.1"):
+		return 1047576
+	case hasModelPrefix(model, "gpt-4o", "gpt-4-turbo", "gpt-4.5", "chatgpt-4o"):
+		return 128000
+	case hasModelPrefix(model, "gpt-4"):
+		return 8192
+	case hasModelPrefix(model, "gpt-3.5-turbo"):
+		return 16385
+	case hasModelPrefix(model, "o1-mini"):
+		return 128000
+	case hasModelPrefix(model, "o1", "o3", "o4"):
+		return 200000
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prompt is a reusable prompt template loaded from a file
+type Prompt struct {
+	// ID identifies the prompt, e.g. for --system-prompt-id. It defaults to
+	// the file name without its extension.
+	ID string `yaml:"id" json:"id"`
+
+	// Description says what the prompt is for
+	Description string `yaml:"description" json:"description"`
+
+	// Content is the prompt text, without frontmatter
+	Content string `json:"content"`
+
+	// SourceFile is the path of the file the prompt was loaded from
+	SourceFile string `json:"source_file"`
+}
+
+// frontmatter is the optional YAML header of a prompt file
+type frontmatter struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+}
+
+// Loader loads prompt files (.md and .txt) from a directory. Files may start
+// with YAML frontmatter giving an id and description. The directory is
+// scanned on first use.
+type Loader struct {
+	dir string
+
+	mu      sync.RWMutex
+	prompts map[string]*Prompt
+	loaded  bool
+}
+
+// NewLoader creates a prompt loader for a directory. An empty dir yields a
+// loader with no prompts.
+func NewLoader(dir string) *Loader {
+	return &Loader{
+		dir:     dir,
+		prompts: make(map[string]*Prompt),
+	}
+}
+
+// Directory returns the directory prompts are loaded from
+func (l *Loader) Directory() string {
+	return l.dir
+}
+
+// Load rescans the prompts directory. A missing directory is not an error;
+// unreadable or malformed files are skipped.
+func (l *Loader) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load()
+}
+
+func (l *Loader) load() error {
+	l.prompts = make(map[string]*Prompt)
+	l.loaded = true
+
+	if l.dir == "" {
+		return nil
+	}
+	if _, err := os.Stat(l.dir); os.IsNotExist(err) {
+		return nil // No prompts directory is fine
+	}
+
+	return filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+
+		if info.IsDir() {
+			// Skip hidden directories
+			if strings.HasPrefix(info.Name(), ".") && path != l.dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(info.Name())
+		if ext != ".md" && ext != ".txt" {
+			return nil
+		}
+
+		prompt, err := loadFile(path)
+		if err != nil {
+			return nil // Skip malformed prompt files
+		}
+		l.prompts[prompt.ID] = prompt
+		return nil
+	})
+}
+
+// ensureLoaded scans the directory if it has not been scanned yet
+func (l *Loader) ensureLoaded() {
+	l.mu.RLock()
+	loaded := l.loaded
+	l.mu.RUnlock()
+	if loaded {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.loaded {
+		l.load()
+	}
+}
+
+// GetAll returns all loaded prompts by ID
+func (l *Loader) GetAll() map[string]*Prompt {
+	l.ensureLoaded()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make(map[string]*Prompt, len(l.prompts))
+	for id, prompt := range l.prompts {
+		result[id] = prompt
+	}
+	return result
+}
+
+// Get returns the prompt with the given ID
+func (l *Loader) Get(id string) (*Prompt, bool) {
+	l.ensureLoaded()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	prompt, ok := l.prompts[id]
+	return prompt, ok
+}
+
+// loadFile reads a prompt file, parsing frontmatter if present
+func loadFile(path string) (*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	var meta frontmatter
+
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		header, body, found := strings.Cut(rest, "\n---")
+		if !found {
+			return nil, fmt.Errorf("unclosed frontmatter (missing closing ---)")
+		}
+		if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
+		}
+		// Drop the rest of the closing delimiter line
+		if i := strings.IndexByte(body, '\n'); i >= 0 {
+			body = body[i+1:]
+		} else {
+			body = ""
+		}
+		content = body
+	}
+
+	id := meta.ID
+	if id == "" {
+		id = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return &Prompt{
+		ID:          id,
+		Description: meta.Description,
+		Content:     strings.TrimSpace(content),
+		SourceFile:  path,
+	}, nil
+}
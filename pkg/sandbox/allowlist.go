@@ -0,0 +1,101 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// checkAllowlist verifies that every program run by command is on the
+// allowlist. A shell running a command string (bash -c, powershell -Command,
+// cmd /C) is not checked by its own name; instead the first word of each
+// pipeline segment of its script is.
+func (s *ProcessSandbox) checkAllowlist(command string, args []string) error {
+	if len(s.config.CommandAllowlist) == 0 {
+		return nil
+	}
+
+	if script, ok := shellScriptArg(command, args); ok {
+		return s.checkShellAllowlist(script)
+	}
+	return s.checkProgram(command)
+}
+
+// checkScriptAllowlist verifies that a script interpreter is on the
+// allowlist. For a launcher such as "bundle exec ruby" both the launcher
+// program and the interpreter must be listed. Shell scripts additionally
+// have every program they run checked.
+func (s *ProcessSandbox) checkScriptAllowlist(launcher []string, script string) error {
+	if len(s.config.CommandAllowlist) == 0 {
+		return nil
+	}
+
+	interpreter := launcher[len(launcher)-1]
+	if err := s.checkProgram(launcher[0]); err != nil {
+		return err
+	}
+	if err := s.checkProgram(interpreter); err != nil {
+		return err
+	}
+	if isShell(interpreter) {
+		return s.checkShellAllowlist(script)
+	}
+	return nil
+}
+
+// checkShellAllowlist checks the programs of a shell script
+func (s *ProcessSandbox) checkShellAllowlist(script string) error {
+	for _, program := range shellPrograms(script) {
+		if err := s.checkProgram(program); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkProgram reports whether a single program is allowed. A bare name
+// must be listed. A path is only allowed if it is absolute and names the
+// same file as a listed path or as a listed name resolved through PATH, so
+// "go" allows /usr/local/go/bin/go when that is the go on PATH, but not
+// ./go or /tmp/go.
+func (s *ProcessSandbox) checkProgram(program string) error {
+	if !hasPathSeparator(program) {
+		if containsString(s.config.CommandAllowlist, program) {
+			return nil
+		}
+		return fmt.Errorf("%w: %q", ErrCommandNotAllowed, program)
+	}
+
+	if filepath.IsAbs(program) {
+		if info, err := os.Stat(program); err == nil {
+			for _, allowed := range s.config.CommandAllowlist {
+				if sameProgram(info, allowed) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrCommandNotAllowed, program)
+}
+
+// sameProgram reports whether an allowlist entry, a path or a name looked
+// up on PATH, is the file described by info
+func sameProgram(info os.FileInfo, allowed string) bool {
+	path := allowed
+	if !hasPathSeparator(allowed) {
+		resolved, err := exec.LookPath(allowed)
+		if err != nil {
+			return false
+		}
+		path = resolved
+	}
+	allowedInfo, err := os.Stat(path)
+	return err == nil && os.SameFile(info, allowedInfo)
+}
+
+// hasPathSeparator reports whether program is a path rather than a name
+func hasPathSeparator(program string) bool {
+	return strings.ContainsRune(program, '/') || strings.ContainsRune(program, filepath.Separator)
+}
@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newAllowlistSandbox returns a sandbox that only runs a few programs
+func newAllowlistSandbox(t *testing.T) *ProcessSandbox {
+	return newTestSandbox(t, func(c *Config) {
+		c.CommandAllowlist = []string{"bash", "go", "grep", "echo", "cat", "ruby"}
+	})
+}
+
+func TestAllowlistShellScripts(t *testing.T) {
+	sb := newAllowlistSandbox(t)
+
+	tests := []struct {
+		script  string
+		blocked string // The program reported as not allowed, if any
+	}{
+		{script: "go test ./..."},
+		{script: "FOO=1 go test ./..."},
+		{script: "FOO=1 BAR='a b' go vet ./..."},
+		{script: "go test ./... | grep FAIL"},
+		{script: "cat go.mod | grep module | cat"},
+		{script: "go build ./... && go test ./..."},
+		{script: "go build ./... || echo failed"},
+		{script: "go build ./...; echo done"},
+		{script: "FOO=1 curl https://example.com", blocked: "curl"},
+		{script: "go test ./... | sh", blocked: "sh"},
+		{script: "go build ./... && curl https://example.com", blocked: "curl"},
+		{script: "echo ok || python3 -c 1", blocked: "python3"},
+		{script: "go test; rm -rf build", blocked: "rm"},
+		{script: "cat go.mod | FOO=1 wc -l", blocked: "wc"},
+		{script: "sudo go test", blocked: "sudo"},
+	}
+	for _, tt := range tests {
+		err := sb.checkAllowlist("bash", []string{"-c", tt.script})
+		if tt.blocked == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.script, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("%q: err = %v, want ErrCommandNotAllowed", tt.script, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), `"`+tt.blocked+`"`) {
+			t.Errorf("%q: error %q does not name %s", tt.script, err, tt.blocked)
+		}
+	}
+}
+
+func TestAllowlistCommands(t *testing.T) {
+	sb := newAllowlistSandbox(t)
+
+	if err := sb.checkAllowlist("go", []string{"test"}); err != nil {
+		t.Errorf("go: %v", err)
+	}
+	if err := sb.checkAllowlist("curl", nil); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("curl: err = %v, want ErrCommandNotAllowed", err)
+	}
+
+	// Only the interpreters listed may run scripts, and shell scripts have
+	// their programs checked too
+	if err := sb.checkScriptAllowlist([]string{"python3"}, "print(1)"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("python3 script: err = %v, want ErrCommandNotAllowed", err)
+	}
+	if err := sb.checkScriptAllowlist([]string{"bundle", "exec", "ruby"}, "puts 1"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("bundle exec ruby: err = %v, want ErrCommandNotAllowed for bundle", err)
+	}
+	if err := sb.checkScriptAllowlist([]string{"bash"}, "go test ./... && echo ok"); err != nil {
+		t.Errorf("bash script: %v", err)
+	}
+	if err := sb.checkScriptAllowlist([]string{"bash"}, "go test && make"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("bash script running make: err = %v, want ErrCommandNotAllowed", err)
+	}
+}
+
+func TestAllowlistExecute(t *testing.T) {
+	sb := newAllowlistSandbox(t)
+
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", "echo ran && touch marker"})
+	if !errors.Is(err, ErrCommandNotAllowed) {
+		t.Fatalf("err = %v, want ErrCommandNotAllowed", err)
+	}
+	if result != nil && result.Stdout != "" {
+		t.Errorf("blocked command ran: %q", result.Stdout)
+	}
+}
+
+func TestAllowlistPaths(t *testing.T) {
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not on PATH")
+	}
+	sb := newAllowlistSandbox(t)
+
+	// The go that PATH resolves to may be run by its absolute path
+	if err := sb.checkAllowlist(goPath, []string{"test"}); err != nil {
+		t.Errorf("%s: %v", goPath, err)
+	}
+
+	// A program of the same name anywhere else may not
+	evil := filepath.Join(t.TempDir(), "go")
+	if err := os.WriteFile(evil, []byte("#!/bin/sh\necho pwned\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, program := range []string{evil, "./go", "../x/go", "bin/go", "go/../go", filepath.Join(filepath.Dir(evil), ".", "go")} {
+		if err := sb.checkAllowlist(program, nil); !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("%s: err = %v, want ErrCommandNotAllowed", program, err)
+		}
+	}
+	for _, script := range []string{"./go build", "echo ok && ./go test", "bin/go vet | grep x", evil + " test"} {
+		if err := sb.checkAllowlist("bash", []string{"-c", script}); !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("%q: err = %v, want ErrCommandNotAllowed", script, err)
+		}
+	}
+	if err := sb.checkScriptAllowlist([]string{"./bash"}, "echo hi"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("./bash script: err = %v, want ErrCommandNotAllowed", err)
+	}
+
+	// A listed absolute path allows exactly that file
+	sb = newTestSandbox(t, func(c *Config) { c.CommandAllowlist = []string{evil} })
+	if err := sb.checkAllowlist(evil, nil); err != nil {
+		t.Errorf("listed path %s: %v", evil, err)
+	}
+	if err := sb.checkAllowlist("go", nil); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("go with only a path listed: err = %v, want ErrCommandNotAllowed", err)
+	}
+}
@@ -0,0 +1,201 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// auditPreviewLength is the number of characters of a script kept in the
+// audit log next to its hash
+const auditPreviewLength = 200
+
+// AuditEntry is one line of the execution audit log. Entries are chained:
+// PrevHash is the SHA-256 of the previous line as written, so removing or
+// editing a line breaks the chain from that point on.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"` // "command", "script" or "background"
+	WorkingDir string    `json:"cwd"`
+
+	// Command is the program and its arguments, for commands, with secret
+	// values redacted
+	Command []string `json:"command,omitempty"`
+
+	// Interpreter, ScriptSHA256 and ScriptPreview describe scripts; the
+	// preview is the first 200 characters of the script after redaction,
+	// and the hash is of the script as run
+	Interpreter   string `json:"interpreter,omitempty"`
+	ScriptSHA256  string `json:"script_sha256,omitempty"`
+	ScriptPreview string `json:"script_preview,omitempty"`
+
+	// Priority is the CPU and I/O priority commands run at, e.g. "nice 10,
+	// io idle", if changed
+	Priority string `json:"priority,omitempty"`
+
+	// Blocked is set when the blacklist or allowlist refused the attempt
+	Blocked bool `json:"blocked,omitempty"`
+
+	// Outcome of the execution. ExitCode is -1 when the command did not
+	// run to completion, and always for background processes, which are
+	// logged when they start.
+	ExitCode          int    `json:"exit_code"`
+	DurationMs        int64  `json:"duration_ms"`
+	TimedOut          bool   `json:"timed_out,omitempty"`
+	Signal            string `json:"signal,omitempty"`
+	StdoutTruncated   bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated   bool   `json:"stderr_truncated,omitempty"`
+	CombinedTruncated bool   `json:"combined_truncated,omitempty"`
+	Error             string `json:"error,omitempty"`
+
+	// ResourceUsage is what the command consumed, when it ran to exit
+	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+}
+
+// auditCommand records an attempt to run or start a command; kind is
+// "command" or "background"
+func (s *ProcessSandbox) auditCommand(kind string, start time.Time, command string, args []string, opts *ExecOptions, result *ExecutionResult, err error) {
+	s.reportBlocked(err)
+	if s.config.AuditLog == nil {
+		return
+	}
+	r := s.newRedactor()
+	entry := s.newAuditEntry(kind, start, opts, result, err, r)
+	entry.Command = make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{command}, args...) {
+		entry.Command = append(entry.Command, r.redact(arg))
+	}
+	s.writeAudit(entry)
+}
+
+// auditScript records a script execution attempt. The script itself is
+// stored as a hash and a short preview.
+func (s *ProcessSandbox) auditScript(start time.Time, interpreter, script string, opts *ExecOptions, result *ExecutionResult, err error) {
+	s.reportBlocked(err)
+	if s.config.AuditLog == nil {
+		return
+	}
+	r := s.newRedactor()
+	entry := s.newAuditEntry("script", start, opts, result, err, r)
+	sum := sha256.Sum256([]byte(script))
+	entry.Interpreter = interpreter
+	entry.ScriptSHA256 = hex.EncodeToString(sum[:])
+	// Redact before cutting, so a secret across the cut leaves no prefix
+	entry.ScriptPreview = r.redact(script)
+	if runes := []rune(entry.ScriptPreview); len(runes) > auditPreviewLength {
+		entry.ScriptPreview = string(runes[:auditPreviewLength])
+	}
+	s.writeAudit(entry)
+}
+
+// reportBlocked passes a blacklist refusal to Config.OnBlocked
+func (s *ProcessSandbox) reportBlocked(err error) {
+	if s.config.OnBlocked == nil {
+		return
+	}
+	var blocked *BlacklistError
+	if errors.As(err, &blocked) {
+		s.config.OnBlocked(blocked)
+	}
+}
+
+// newAuditEntry fills in the fields common to every entry. Errors, which
+// may quote the command, are redacted with r.
+func (s *ProcessSandbox) newAuditEntry(kind string, start time.Time, opts *ExecOptions, result *ExecutionResult, err error, r *redactor) *AuditEntry {
+	entry := &AuditEntry{
+		Time:       start.UTC(),
+		Kind:       kind,
+		ExitCode:   -1,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if prioritySupported {
+		entry.Priority = s.config.prioritySummary()
+	}
+
+	var override string
+	if opts != nil {
+		override = opts.WorkingDir
+	}
+	entry.WorkingDir = override
+	if dir, dirErr := s.ResolveWorkingDir(override); dirErr == nil {
+		entry.WorkingDir = dir
+	}
+
+	if result != nil {
+		entry.ExitCode = result.ExitCode
+		entry.DurationMs = result.Duration.Milliseconds()
+		entry.TimedOut = result.TimedOut
+		entry.Signal = result.Signal
+		entry.StdoutTruncated = result.StdoutTruncated
+		entry.StderrTruncated = result.StderrTruncated
+		entry.CombinedTruncated = result.CombinedTruncated
+		entry.ResourceUsage = result.ResourceUsage
+	}
+	if err != nil {
+		entry.Error = r.redact(err.Error())
+		entry.Blocked = errors.Is(err, ErrBlacklistedCommand) || errors.Is(err, ErrCommandNotAllowed)
+	}
+	return entry
+}
+
+// writeAudit appends an entry to the audit log. A failed write is logged
+// and never fails the execution.
+func (s *ProcessSandbox) writeAudit(entry *AuditEntry) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	entry.PrevHash = s.auditPrev
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("WARNING: sandbox: failed to encode audit entry: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	if _, err := s.config.AuditLog.Write(line); err != nil {
+		log.Printf("WARNING: sandbox: failed to write audit log: %v", err)
+		return
+	}
+
+	sum := sha256.Sum256(line)
+	s.auditPrev = hex.EncodeToString(sum[:])
+}
+
+// AuditChainHead returns the hash of the last line of an existing audit log,
+// for Config.AuditPrevHash, so that entries appended to it continue its chain
+func AuditChainHead(data []byte) string {
+	lines := strings.SplitAfter(strings.TrimRight(string(data), "\n"), "\n")
+	last := lines[len(lines)-1]
+	if last == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(last + "\n"))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditLog checks the hash chain of an audit log and returns the
+// 1-based number of the first line that does not follow from the one before
+// it, or 0 if the chain is intact
+func VerifyAuditLog(data []byte) (int, error) {
+	var prev string
+	for i, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return i + 1, err
+		}
+		if entry.PrevHash != prev {
+			return i + 1, nil
+		}
+		sum := sha256.Sum256([]byte(line))
+		prev = hex.EncodeToString(sum[:])
+	}
+	return 0, nil
+}
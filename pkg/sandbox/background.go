@@ -0,0 +1,336 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStopGrace is how long Close waits for background processes to exit
+// after SIGTERM before killing them
+const defaultStopGrace = 2 * time.Second
+
+// ProcessHandle controls a process started with StartBackground. The
+// process runs in its own process group, so signals reach every process it
+// spawns.
+type ProcessHandle interface {
+	// ID returns the sandbox-unique identifier of the process
+	ID() string
+
+	// Running reports whether the process has not yet exited
+	Running() bool
+
+	// ExitCode returns the exit code once the process has exited, or -1
+	// while it is running or if it was killed by a signal
+	ExitCode() int
+
+	// Output returns the last tailLines lines of combined stdout and
+	// stderr, or everything still buffered when tailLines <= 0. The buffer
+	// keeps at most Config.MaxOutputBytes, dropping the oldest output.
+	Output(tailLines int) string
+
+	// Signal sends sig to the process group
+	Signal(sig os.Signal) error
+
+	// Stop asks the process group to terminate and kills it if it has not
+	// exited within grace. It returns once the process has exited.
+	Stop(grace time.Duration) error
+}
+
+// backgroundProcess is the ProcessSandbox implementation of ProcessHandle
+type backgroundProcess struct {
+	id       string
+	cmd      *exec.Cmd
+	output   *ringBuffer
+	redactor *redactor
+	done     chan struct{}
+
+	mu       sync.Mutex
+	exitCode int
+}
+
+func (p *backgroundProcess) ID() string {
+	return p.id
+}
+
+func (p *backgroundProcess) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *backgroundProcess) ExitCode() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exitCode
+}
+
+func (p *backgroundProcess) Output(tailLines int) string {
+	return p.redactor.redact(p.output.Tail(tailLines))
+}
+
+func (p *backgroundProcess) Signal(sig os.Signal) error {
+	if !p.Running() {
+		return nil
+	}
+	return signalProcessGroup(p.cmd.Process, sig)
+}
+
+func (p *backgroundProcess) Stop(grace time.Duration) error {
+	if !p.Running() {
+		return nil
+	}
+	if err := terminateProcessGroup(p.cmd.Process); err != nil {
+		return p.kill()
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(grace):
+		return p.kill()
+	}
+}
+
+// kill kills the process group and waits for the process to exit
+func (p *backgroundProcess) kill() error {
+	if err := killProcessGroup(p.cmd.Process); err != nil && p.Running() {
+		return fmt.Errorf("failed to kill process %s: %w", p.id, err)
+	}
+	<-p.done
+	return nil
+}
+
+// wait reaps the process and records its exit code
+func (p *backgroundProcess) wait() {
+	err := p.cmd.Wait()
+
+	p.mu.Lock()
+	p.exitCode = p.cmd.ProcessState.ExitCode()
+	p.mu.Unlock()
+
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			log.Printf("sandbox: background process %s: %v", p.id, err)
+		}
+	}
+	close(p.done)
+}
+
+// StartBackground starts a long-running command, such as a dev server, and
+// returns without waiting for it. The allowlist, blacklist and confinement
+// apply as for ExecuteWithOptions; opts.Timeout and opts.OnOutput are
+// ignored. ctx only governs the start: the process keeps running until it
+// exits, is stopped through its handle, or the sandbox is closed.
+func (s *ProcessSandbox) StartBackground(ctx context.Context, command string, args []string, opts *ExecOptions) (ProcessHandle, error) {
+	if s.config.DryRun {
+		return nil, ErrDryRun
+	}
+	start := time.Now()
+	handle, err := s.startBackground(ctx, command, args, opts)
+	s.auditCommand("background", start, command, args, opts, nil, err)
+	return handle, err
+}
+
+func (s *ProcessSandbox) startBackground(ctx context.Context, command string, args []string, opts *ExecOptions) (ProcessHandle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.checkAllowlist(command, args); err != nil {
+		return nil, err
+	}
+	if err := s.checkCommandBlacklist(command, args); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+
+	cmd := exec.Command(command, args...)
+	if err := s.prepareCommand(cmd, opts); err != nil {
+		return nil, err
+	}
+	limitedAtExec := s.wrapResourceLimits(cmd)
+	setProcessGroup(cmd)
+
+	output := newRingBuffer(s.config.MaxOutputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+
+	if s.closed {
+		return nil, ErrSandboxClosed
+	}
+	if limit := s.config.MaxBackgroundProcesses; limit > 0 && s.runningBackground() >= limit {
+		return nil, fmt.Errorf("%w: %d running; stop one first", ErrTooManyBackgroundProcesses, limit)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start background process: %w", err)
+	}
+	if resourceLimitsSupported && s.config.hasResourceLimits() && !limitedAtExec {
+		if limitErr := applyResourceLimits(cmd.Process.Pid, s.config); limitErr != nil {
+			log.Printf("sandbox: %v", limitErr)
+		}
+	}
+	if prioritySupported && s.config.hasPriority() {
+		if prioErr := applyPriority(cmd.Process.Pid, s.config); prioErr != nil {
+			log.Printf("WARNING: sandbox: %v", prioErr)
+		}
+	}
+
+	s.nextProcID++
+	p := &backgroundProcess{
+		id:       fmt.Sprintf("bg-%d", s.nextProcID),
+		cmd:      cmd,
+		output:   output,
+		redactor: s.newRedactor(),
+		done:     make(chan struct{}),
+		exitCode: -1,
+	}
+	if s.procs == nil {
+		s.procs = make(map[string]*backgroundProcess)
+	}
+	s.procs[p.id] = p
+	go func() {
+		p.wait()
+		s.forgetBackground(p.id)
+	}()
+
+	return p, nil
+}
+
+// RunningBackground returns the number of background processes that have
+// not exited, for metrics
+func (s *ProcessSandbox) RunningBackground() int {
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+	return s.runningBackground()
+}
+
+// forgetBackground drops an exited process, which Close no longer needs to
+// stop; its handle keeps working for whoever holds it
+func (s *ProcessSandbox) forgetBackground(id string) {
+	s.procMu.Lock()
+	delete(s.procs, id)
+	s.procMu.Unlock()
+}
+
+// runningBackground counts the running background processes; the caller
+// holds procMu
+func (s *ProcessSandbox) runningBackground() int {
+	n := 0
+	for _, p := range s.procs {
+		if p.Running() {
+			n++
+		}
+	}
+	return n
+}
+
+// Close stops every background process and rejects further ones. Each is
+// sent SIGTERM and killed if it has not exited shortly after. The sandbox's
+// own go caches, if any, are removed.
+func (s *ProcessSandbox) Close() error {
+	s.procMu.Lock()
+	s.closed = true
+	procs := s.procs
+	s.procs = nil
+	s.procMu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(procs))
+	for _, p := range procs {
+		wg.Add(1)
+		go func(p *backgroundProcess) {
+			defer wg.Done()
+			if err := p.Stop(defaultStopGrace); err != nil {
+				errs <- err
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(errs)
+
+	var msgs []string
+	for err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	cacheErr := s.removeGoCache()
+	if len(msgs) > 0 {
+		return fmt.Errorf("failed to stop background processes: %s", strings.Join(msgs, "; "))
+	}
+	if cacheErr != nil {
+		return fmt.Errorf("failed to remove go cache: %w", cacheErr)
+	}
+	return nil
+}
+
+// ringBuffer keeps the most recent limit bytes written to it, dropping
+// whole lines from the front when it overflows. It is safe for concurrent
+// use, since stdout and stderr share it.
+type ringBuffer struct {
+	mu      sync.Mutex
+	ring    *byteRing
+	dropped bool // Output has been overwritten, so the oldest line is partial
+}
+
+func newRingBuffer(limit int64) *ringBuffer {
+	if limit <= 0 {
+		limit = 1024 * 1024
+	}
+	return &ringBuffer{ring: newByteRing(int(limit))}
+}
+
+func (rb *ringBuffer) Write(p []byte) (int, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.ring.write(p) > 0 {
+		rb.dropped = true
+	}
+	return len(p), nil
+}
+
+// contents returns the buffered output. Once output has been dropped it
+// resumes at a line boundary when one is close by.
+func (rb *ringBuffer) contents() string {
+	rb.mu.Lock()
+	buf := rb.ring.bytes()
+	dropped := rb.dropped
+	rb.mu.Unlock()
+
+	if dropped {
+		if i := bytes.IndexByte(buf[:min(len(buf), 4096)], '\n'); i >= 0 {
+			buf = buf[i+1:]
+		}
+	}
+	return string(buf)
+}
+
+// Tail returns the last n lines, or everything when n <= 0
+func (rb *ringBuffer) Tail(n int) string {
+	out := rb.contents()
+
+	if n <= 0 {
+		return out
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) <= n {
+		return out
+	}
+	tail := strings.Join(lines[len(lines)-n:], "")
+	if strings.HasSuffix(out, "\n") {
+		tail += "\n"
+	}
+	return tail
+}
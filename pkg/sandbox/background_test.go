@@ -0,0 +1,205 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitForOutput polls a background process until its output contains want
+func waitForOutput(t *testing.T, p ProcessHandle, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(p.Output(0), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("output never contained %q: %q", want, p.Output(0))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether pid is a running process. Zombies, which a
+// container's init may never reap, count as exited.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	// The state follows the parenthesized command name
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestBackgroundOutputTail(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+
+	p, err := sb.StartBackground(context.Background(), "bash", []string{"-c", "for i in 1 2 3 4 5; do echo line $i; done; echo oops >&2; sleep 30"}, nil)
+	if err != nil {
+		t.Fatalf("StartBackground: %v", err)
+	}
+	defer p.Stop(0)
+	waitForOutput(t, p, "oops")
+
+	if !p.Running() {
+		t.Error("Running() = false for a sleeping process")
+	}
+	if got, want := p.Output(3), "line 4\nline 5\noops\n"; got != want {
+		t.Errorf("Output(3) = %q, want %q", got, want)
+	}
+	if got := p.Output(0); !strings.HasPrefix(got, "line 1\n") {
+		t.Errorf("Output(0) = %q, want all the output", got)
+	}
+}
+
+func TestBackgroundStop(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+
+	// A process that exits on SIGTERM stops within the grace period
+	p, err := sb.StartBackground(context.Background(), "bash", []string{"-c", "trap 'echo stopping; exit 0' TERM; echo ready; while :; do sleep 0.1; done"}, nil)
+	if err != nil {
+		t.Fatalf("StartBackground: %v", err)
+	}
+	waitForOutput(t, p, "ready")
+	if err := p.Stop(5 * time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if p.Running() {
+		t.Error("Running() = true after Stop")
+	}
+	if !strings.Contains(p.Output(0), "stopping") {
+		t.Errorf("SIGTERM handler did not run: %q", p.Output(0))
+	}
+	if p.ExitCode() != 0 {
+		t.Errorf("ExitCode() = %d, want 0", p.ExitCode())
+	}
+
+	// One that ignores SIGTERM is killed once the grace period is over
+	p, err = sb.StartBackground(context.Background(), "bash", []string{"-c", "trap '' TERM; echo ready; while :; do sleep 0.1; done"}, nil)
+	if err != nil {
+		t.Fatalf("StartBackground: %v", err)
+	}
+	waitForOutput(t, p, "ready")
+	start := time.Now()
+	if err := p.Stop(200 * time.Millisecond); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Stop with a 200ms grace took %s", elapsed)
+	}
+	if p.Running() || p.ExitCode() != -1 {
+		t.Errorf("Running() = %v, ExitCode() = %d after kill", p.Running(), p.ExitCode())
+	}
+
+	// Stopping an exited process is a no-op
+	if err := p.Stop(0); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
+
+func TestBackgroundCleanupOnClose(t *testing.T) {
+	requirePrograms(t, "bash", "sleep")
+	sb := newTestSandbox(t, nil)
+
+	// Each process leaves a child of its own that Close must stop too
+	var pids []int
+	for i := 0; i < 2; i++ {
+		p, err := sb.StartBackground(context.Background(), "bash", []string{"-c", "sleep 300 & echo child $!; wait"}, nil)
+		if err != nil {
+			t.Fatalf("StartBackground: %v", err)
+		}
+		waitForOutput(t, p, "\n")
+		child, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(p.Output(0), "child ")))
+		if err != nil {
+			t.Fatalf("no child pid in %q", p.Output(0))
+		}
+		pids = append(pids, p.(*backgroundProcess).cmd.Process.Pid, child)
+	}
+
+	if err := sb.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, pid := range pids {
+		for processAlive(pid) {
+			if time.Now().After(deadline) {
+				t.Fatalf("process %d still running after Close", pid)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	_, err := sb.StartBackground(context.Background(), "sleep", []string{"1"}, nil)
+	if !errors.Is(err, ErrSandboxClosed) {
+		t.Errorf("StartBackground after Close: err = %v, want ErrSandboxClosed", err)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	rb := newRingBuffer(16)
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(rb, "line %d\n", i)
+	}
+
+	// Overflow drops whole lines from the front
+	if got, want := rb.Tail(0), "line 5\nline 6\n"; got != want {
+		t.Errorf("Tail(0) = %q, want %q", got, want)
+	}
+	if got, want := rb.Tail(1), "line 6\n"; got != want {
+		t.Errorf("Tail(1) = %q, want %q", got, want)
+	}
+	if got, want := rb.Tail(5), "line 5\nline 6\n"; got != want {
+		t.Errorf("Tail(5) = %q, want %q", got, want)
+	}
+}
+
+func TestBackgroundForgetsExited(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+
+	for i := 0; i < 3; i++ {
+		p, err := sb.StartBackground(context.Background(), "bash", []string{"-c", "echo done"}, nil)
+		if err != nil {
+			t.Fatalf("StartBackground: %v", err)
+		}
+		waitForOutput(t, p, "done")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sb.procMu.Lock()
+		n := len(sb.procs)
+		sb.procMu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d exited processes still tracked", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkRingBufferWrite writes lines far past the buffer size, which must
+// cost the same per write however much output came before
+func BenchmarkRingBufferWrite(b *testing.B) {
+	line := []byte(strings.Repeat("x", 79) + "\n")
+	rb := newRingBuffer(1 << 20)
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rb.Write(line)
+	}
+}
@@ -0,0 +1,324 @@
+package sandbox
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PatternKind is the form of a blacklist pattern
+type PatternKind string
+
+const (
+	// PatternLiteral matches the pattern as plain text
+	PatternLiteral PatternKind = "literal"
+
+	// PatternGlob matches with * as a wildcard for any text; write \* for a
+	// literal asterisk
+	PatternGlob PatternKind = "glob"
+
+	// PatternRegex matches a raw regular expression, written with a "re:"
+	// prefix
+	PatternRegex PatternKind = "regex"
+)
+
+const (
+	// regexPrefix marks a blacklist pattern as a raw regular expression
+	regexPrefix = "re:"
+
+	// anywherePrefix marks a blacklist pattern as strict: it matches
+	// anywhere in the input text instead of only at command positions
+	anywherePrefix = "any:"
+)
+
+// whitespace collapses runs of whitespace before matching
+var whitespace = regexp.MustCompile(`\s+`)
+
+// BlacklistError is returned when a command matches a blacklist pattern. It
+// unwraps to ErrBlacklistedCommand.
+type BlacklistError struct {
+	Pattern string      // Pattern as configured
+	Kind    PatternKind // Form of the pattern that matched
+	Command string      // Command or script that was blocked
+}
+
+func (e *BlacklistError) Error() string {
+	return fmt.Sprintf("%s: matches %s pattern %q", ErrBlacklistedCommand, e.Kind, e.Pattern)
+}
+
+func (e *BlacklistError) Unwrap() error {
+	return ErrBlacklistedCommand
+}
+
+// blacklistMatcher is a compiled blacklist pattern.
+//
+// Patterns are token-aware by default. A literal or glob pattern is split
+// into pipeline stages on "|", and each stage into words: the first word
+// must be the program of a simple command (directly or behind a wrapper
+// such as sudo or xargs) and the remaining words must appear in order among
+// its arguments, so "shutdown" blocks "sudo shutdown now" but not
+// "git log --grep shutdown". A regex pattern matches the text of each
+// simple command. Patterns prefixed with "any:" are strict and match
+// anywhere in the input, which is needed for things like fork bombs.
+type blacklistMatcher struct {
+	pattern string
+	kind    PatternKind
+	strict  bool
+
+	literal string           // Strict literal patterns
+	re      *regexp.Regexp   // Globs (strict only) and regexes
+	stages  [][]tokenMatcher // Token-aware literal and glob patterns
+}
+
+// tokenMatcher matches a single word of a command
+type tokenMatcher struct {
+	literal string
+	re      *regexp.Regexp // Set for words containing a wildcard
+}
+
+func (t tokenMatcher) matches(word string) bool {
+	if t.re != nil {
+		return t.re.MatchString(word)
+	}
+	return word == t.literal
+}
+
+// blacklistInput is a command or script prepared for matching
+type blacklistInput struct {
+	text     string         // Lowercased with whitespace collapsed
+	commands []shellCommand // Lowercased simple commands
+}
+
+func (m *blacklistMatcher) matches(in *blacklistInput) bool {
+	switch {
+	case m.strict && m.kind == PatternLiteral:
+		return strings.Contains(in.text, m.literal)
+	case m.strict:
+		return m.re.MatchString(in.text)
+	case m.kind == PatternRegex:
+		for _, cmd := range in.commands {
+			if m.re.MatchString(strings.Join(cmd.words, " ")) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range in.commands {
+		if m.matchesPipeline(in.commands[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPipeline reports whether the pattern's stages match the commands
+// starting at cmds[0], each piped into the next
+func (m *blacklistMatcher) matchesPipeline(cmds []shellCommand) bool {
+	if len(cmds) < len(m.stages) {
+		return false
+	}
+	for i, stage := range m.stages {
+		if i > 0 && !cmds[i-1].pipe {
+			return false
+		}
+		if !stageMatches(stage, cmds[i].words) {
+			return false
+		}
+	}
+	return true
+}
+
+// stageMatches reports whether a command runs the stage's program with the
+// stage's arguments in order
+func stageMatches(stage []tokenMatcher, words []string) bool {
+	for _, start := range commandStarts(words) {
+		program := words[start]
+		if !stage[0].matches(program) && !stage[0].matches(filepath.Base(program)) {
+			continue
+		}
+
+		next := 1
+		for _, arg := range words[start+1:] {
+			if next == len(stage) {
+				break
+			}
+			if stage[next].matches(arg) {
+				next++
+			}
+		}
+		if next == len(stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileBlacklist compiles blacklist patterns. Matching is case-insensitive.
+// All invalid patterns are reported together.
+func compileBlacklist(patterns []string) ([]blacklistMatcher, error) {
+	matchers := make([]blacklistMatcher, 0, len(patterns))
+	var invalid []string
+
+	for _, pattern := range patterns {
+		m, err := compileBlacklistPattern(pattern)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q (%v)", pattern, err))
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBlacklistPattern, strings.Join(invalid, ", "))
+	}
+	return matchers, nil
+}
+
+func compileBlacklistPattern(pattern string) (blacklistMatcher, error) {
+	m := blacklistMatcher{pattern: pattern}
+
+	body := pattern
+	if rest, ok := strings.CutPrefix(body, anywherePrefix); ok {
+		m.strict = true
+		body = rest
+	}
+
+	if expr, ok := strings.CutPrefix(body, regexPrefix); ok {
+		if strings.TrimSpace(expr) == "" {
+			return m, fmt.Errorf("empty regex")
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return m, err
+		}
+		m.kind = PatternRegex
+		m.re = regexp.MustCompile("(?i)" + expr)
+		return m, nil
+	}
+
+	normalized := strings.TrimSpace(whitespace.ReplaceAllString(strings.ToLower(body), " "))
+	if normalized == "" {
+		return m, fmt.Errorf("empty pattern")
+	}
+
+	m.kind = PatternLiteral
+	if hasWildcard(normalized) {
+		m.kind = PatternGlob
+	}
+
+	if m.strict {
+		if m.kind == PatternLiteral {
+			m.literal = unescapeGlob(normalized)
+		} else {
+			m.re = regexp.MustCompile(globToRegex(normalized))
+		}
+		return m, nil
+	}
+
+	for _, stage := range strings.Split(normalized, "|") {
+		fields := strings.Fields(stage)
+		if len(fields) == 0 {
+			return m, fmt.Errorf("empty pipeline stage")
+		}
+		tokens := make([]tokenMatcher, len(fields))
+		for i, field := range fields {
+			if hasWildcard(field) {
+				tokens[i].re = regexp.MustCompile("^" + globToRegex(field) + "$")
+			} else {
+				tokens[i].literal = unescapeGlob(field)
+			}
+		}
+		m.stages = append(m.stages, tokens)
+	}
+	return m, nil
+}
+
+// hasWildcard reports whether a glob contains an unescaped *
+func hasWildcard(glob string) bool {
+	return strings.Contains(strings.ReplaceAll(glob, `\*`, ""), "*")
+}
+
+// unescapeGlob turns a glob without wildcards into its literal text
+func unescapeGlob(glob string) string {
+	return strings.ReplaceAll(glob, `\*`, "*")
+}
+
+// globToRegex converts a glob to an unanchored regular expression: * matches
+// any text, \* is a literal asterisk and everything else is literal
+func globToRegex(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case glob[i] == '\\' && i+1 < len(glob) && glob[i+1] == '*':
+			expr.WriteString(`\*`)
+			i++
+		case glob[i] == '*':
+			expr.WriteString(`.*`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return expr.String()
+}
+
+// checkCommandBlacklist checks a command and its arguments. A shell running
+// a command string has that string parsed into commands.
+func (s *ProcessSandbox) checkCommandBlacklist(command string, args []string) error {
+	if len(s.blacklist) == 0 {
+		return nil
+	}
+
+	input := command + " " + strings.Join(args, " ")
+	var commands []shellCommand
+	if script, ok := shellScriptArg(command, args); ok {
+		commands = parseShell(script)
+	} else {
+		commands = []shellCommand{{words: append([]string{command}, args...)}}
+	}
+	return s.checkBlacklist(input, commands)
+}
+
+// checkScriptBlacklist checks a script. Shell scripts are parsed into
+// commands; for other languages comments are ignored and every string
+// literal is checked as a shell command, which catches calls such as
+// os.system("reboot").
+func (s *ProcessSandbox) checkScriptBlacklist(interpreter, script string) error {
+	if len(s.blacklist) == 0 {
+		return nil
+	}
+
+	var commands []shellCommand
+	if isShell(interpreter) {
+		commands = parseShell(script)
+	} else {
+		for _, literal := range scriptLiterals(interpreter, script) {
+			commands = append(commands, parseShell(literal)...)
+		}
+	}
+	return s.checkBlacklist(script, commands)
+}
+
+// checkBlacklist matches input against the compiled blacklist
+func (s *ProcessSandbox) checkBlacklist(input string, commands []shellCommand) error {
+	commands = expandShellCommands(commands)
+	for i := range commands {
+		words := make([]string, len(commands[i].words))
+		for j, word := range commands[i].words {
+			words[j] = strings.ToLower(word)
+		}
+		commands[i].words = words
+	}
+
+	in := &blacklistInput{
+		text:     whitespace.ReplaceAllString(strings.ToLower(input), " "),
+		commands: commands,
+	}
+	for i := range s.blacklist {
+		m := &s.blacklist[i]
+		if m.matches(in) {
+			return &BlacklistError{Pattern: m.pattern, Kind: m.kind, Command: input}
+		}
+	}
+	return nil
+}
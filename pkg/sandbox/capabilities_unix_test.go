@@ -0,0 +1,46 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapabilitiesHiddenInterpreters(t *testing.T) {
+	// The only interpreter on the PATH is a fake python3 that reports a
+	// different version if it can see a variable the sandbox withholds
+	bin := t.TempDir()
+	fake := "#!/bin/sh\nif [ -n \"$LOOPER_TEST_TOKEN\" ]; then echo 'Python 9.9.9'; else echo 'Python 3.99.1'; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "python3"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("LOOPER_TEST_TOKEN", "secret")
+
+	sb := newTestSandbox(t, func(c *Config) {
+		c.Interpreters = map[string]InterpreterSpec{"elixir": {Extension: ".exs"}}
+	})
+	caps, err := sb.Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+
+	if len(caps.Interpreters) != 1 || caps.Interpreters[0].Name != "python3" {
+		t.Fatalf("Interpreters = %+v, want only python3", caps.Interpreters)
+	}
+	if v := caps.Interpreters[0].Version; v != "3.99.1" {
+		t.Errorf("python3 version = %q, want 3.99.1 from a probe without LOOPER_TEST_TOKEN", v)
+	}
+	for _, name := range []string{"bash", "node", "go", "elixir"} {
+		if !containsString(caps.Missing, name) {
+			t.Errorf("Missing = %v, want %s", caps.Missing, name)
+		}
+	}
+	if summary := caps.Summary(); !strings.Contains(summary, "python3 3.99.1") || !strings.Contains(summary, "not installed: bash") {
+		t.Errorf("Summary() = %q", summary)
+	}
+}
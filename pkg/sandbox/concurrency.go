@@ -0,0 +1,33 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+)
+
+// acquireSlot waits until fewer than Config.MaxConcurrentExecutions commands
+// are running and returns the function that frees the slot again. Waiting
+// gives up when ctx ends; the per-call timeout only starts once a slot is
+// held.
+func (s *ProcessSandbox) acquireSlot(ctx context.Context) (func(), error) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w while waiting for an execution slot: %w", ErrExecutionCancelled, ctx.Err())
+		}
+	}
+	s.inFlight.Add(1)
+	return func() {
+		s.inFlight.Add(-1)
+		if s.slots != nil {
+			<-s.slots
+		}
+	}, nil
+}
+
+// InFlight returns the number of commands and scripts currently executing,
+// for metrics. Background processes are counted by RunningBackground.
+func (s *ProcessSandbox) InFlight() int {
+	return int(s.inFlight.Load())
+}
@@ -0,0 +1,139 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitInFlight polls until n executions are running
+func waitInFlight(t *testing.T, sb *ProcessSandbox, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for sb.InFlight() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("InFlight() = %d, want %d", sb.InFlight(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, func(c *Config) { c.MaxConcurrentExecutions = 2 })
+
+	// Sample the in-flight count while six commands compete for two slots
+	var peak int
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			peak = max(peak, sb.InFlight())
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sb.Execute(context.Background(), "sleep", []string{"0.1"}); err != nil {
+				t.Errorf("Execute: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-sampled
+
+	if peak != 2 {
+		t.Errorf("peak InFlight() = %d, want 2", peak)
+	}
+	if n := sb.InFlight(); n != 0 {
+		t.Errorf("InFlight() = %d after every execution finished", n)
+	}
+}
+
+func TestConcurrencyLimitCancelWhileWaiting(t *testing.T) {
+	requirePrograms(t, "sleep", "true")
+	sb := newTestSandbox(t, func(c *Config) { c.MaxConcurrentExecutions = 1 })
+
+	held := make(chan error, 1)
+	go func() {
+		_, err := sb.Execute(context.Background(), "sleep", []string{"1"})
+		held <- err
+	}()
+	waitInFlight(t, sb, 1)
+
+	// A call waiting for the slot gives up when its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := sb.Execute(ctx, "true", nil)
+	if !errors.Is(err, ErrExecutionCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want ErrExecutionCancelled wrapping the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled wait returned after %s", elapsed)
+	}
+	if n := sb.InFlight(); n != 1 {
+		t.Errorf("InFlight() = %d after the waiter gave up, want 1", n)
+	}
+
+	if err := <-held; err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	// The slot is free again
+	if _, err := sb.Execute(context.Background(), "true", nil); err != nil {
+		t.Errorf("Execute after the slot was released: %v", err)
+	}
+}
+
+func TestBackgroundProcessLimit(t *testing.T) {
+	requirePrograms(t, "sleep", "true")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxConcurrentExecutions = 1
+		c.MaxBackgroundProcesses = 1
+	})
+
+	p, err := sb.StartBackground(context.Background(), "sleep", []string{"30"}, nil)
+	if err != nil {
+		t.Fatalf("StartBackground: %v", err)
+	}
+	if n := sb.RunningBackground(); n != 1 {
+		t.Errorf("RunningBackground() = %d, want 1", n)
+	}
+
+	_, err = sb.StartBackground(context.Background(), "sleep", []string{"30"}, nil)
+	if !errors.Is(err, ErrTooManyBackgroundProcesses) {
+		t.Fatalf("second StartBackground: err = %v, want ErrTooManyBackgroundProcesses", err)
+	}
+	if ClassifyError(err) != ErrorClassTransient {
+		t.Errorf("ClassifyError = %s, want transient", ClassifyError(err))
+	}
+
+	// A background process does not take the slot commands run in
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := sb.Execute(ctx, "true", nil); err != nil {
+		t.Errorf("Execute while a background process runs: %v", err)
+	}
+
+	if err := p.Stop(time.Second); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	p, err = sb.StartBackground(context.Background(), "sleep", []string{"30"}, nil)
+	if err != nil {
+		t.Fatalf("StartBackground after stopping the first: %v", err)
+	}
+	p.Stop(0)
+}
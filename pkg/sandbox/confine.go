@@ -0,0 +1,185 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// IsolationBackend selects how commands are confined beyond the process
+// sandbox's environment, output and resource controls
+type IsolationBackend string
+
+const (
+	// BackendProcess runs commands as plain child processes (the default)
+	BackendProcess IsolationBackend = "process"
+
+	// BackendBwrap runs commands under bubblewrap (Linux), which hides
+	// everything but the workspace and read-only system paths
+	BackendBwrap IsolationBackend = "bwrap"
+
+	// BackendFirejail runs commands under firejail (Linux) with a private
+	// /tmp, no capabilities and a seccomp filter. The host filesystem stays
+	// visible.
+	BackendFirejail IsolationBackend = "firejail"
+
+	// BackendNsjail runs commands under nsjail (Linux), which like bwrap
+	// only shows the workspace and read-only system paths
+	BackendNsjail IsolationBackend = "nsjail"
+
+	// BackendGVisor runs commands under gVisor's runsc (Linux), whose
+	// user-space kernel stands between commands and the host kernel. The
+	// host filesystem stays visible. Failures of runsc itself are reported
+	// in ExecutionResult.IsolationFailure.
+	BackendGVisor IsolationBackend = "gvisor"
+)
+
+// ErrUnknownBackend is returned by NewProcessSandbox for an unrecognized
+// isolation backend
+var ErrUnknownBackend = errors.New("unknown isolation backend")
+
+// DefaultReadOnlyPaths returns the host paths visible read-only inside a
+// confined sandbox. Paths that do not exist on the host are skipped.
+func DefaultReadOnlyPaths() []string {
+	return []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt"}
+}
+
+// validateIsolation checks the configured backend name
+func validateIsolation(backend IsolationBackend) error {
+	switch backend {
+	case "", BackendProcess, BackendBwrap, BackendFirejail, BackendNsjail, BackendGVisor:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+}
+
+// confined reports whether commands run under a confinement backend.
+// Availability is probed on first use; if the backend cannot run, a warning
+// is logged and commands run as plain processes.
+func (s *ProcessSandbox) confined() bool {
+	var probe func() (string, error)
+	switch s.config.Isolation {
+	case BackendBwrap:
+		probe = probeBwrap
+	case BackendFirejail:
+		probe = probeFirejail
+	case BackendNsjail:
+		probe = probeNsjail
+	case BackendGVisor:
+		probe = probeGVisor
+	default:
+		return false
+	}
+	s.confineOnce.Do(func() {
+		s.confinePath, s.confineErr = probe()
+		if s.confineErr != nil {
+			log.Printf("WARNING: sandbox: %s isolation requested but unavailable (%v); commands will run as plain processes with full filesystem access", s.config.Isolation, s.confineErr)
+		}
+	})
+	return s.confineErr == nil
+}
+
+// hidesHostFilesystem reports whether commands run under a backend that
+// only shows them the workspace and read-only system paths
+func (s *ProcessSandbox) hidesHostFilesystem() bool {
+	switch s.config.Isolation {
+	case BackendBwrap, BackendNsjail:
+		return s.confined()
+	}
+	return false
+}
+
+// wrapConfined rewrites cmd to run under the configured backend, which
+// also isolates the network if that is disabled
+func (s *ProcessSandbox) wrapConfined(cmd *exec.Cmd) error {
+	switch s.config.Isolation {
+	case BackendFirejail:
+		return s.wrapFirejail(cmd)
+	case BackendNsjail:
+		return s.wrapNsjail(cmd)
+	case BackendGVisor:
+		return s.wrapGVisor(cmd)
+	default:
+		return s.wrapBwrap(cmd)
+	}
+}
+
+// probeBwrap locates bwrap and checks that it can create namespaces here
+func probeBwrap() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("bubblewrap requires Linux")
+	}
+	path, err := exec.LookPath("bwrap")
+	if err != nil {
+		return "", fmt.Errorf("bwrap not found on PATH; install bubblewrap")
+	}
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command(path, "--ro-bind", "/", "/", "--unshare-pid", truePath).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("bwrap cannot create namespaces: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return path, nil
+}
+
+// wrapBwrap rewrites cmd to run under bubblewrap. The workspace is bound
+// read-write at its own path so absolute paths keep working, system paths
+// are read-only, /tmp is a private tmpfs, and the home directory and the
+// rest of the host filesystem are not visible.
+func (s *ProcessSandbox) wrapBwrap(cmd *exec.Cmd) error {
+	workspace, err := filepath.Abs(s.config.WorkingDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWorkingDir, err)
+	}
+
+	args := []string{
+		"--die-with-parent",
+		"--new-session",
+		"--unshare-pid", "--unshare-ipc", "--unshare-uts",
+	}
+	if s.NetworkDisabled() {
+		args = append(args, "--unshare-net")
+	}
+
+	readOnly := s.config.ReadOnlyPaths
+	if readOnly == nil {
+		readOnly = DefaultReadOnlyPaths()
+	}
+	for _, path := range readOnly {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+
+	args = append(args,
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--bind", workspace, workspace,
+	)
+
+	// Script files may be configured to live outside the workspace
+	if s.scriptDir != "" && !withinDir(workspace, s.scriptDir) {
+		args = append(args, "--ro-bind", s.scriptDir, s.scriptDir)
+	}
+	// Go builds read GOPATH from the host and write their own caches
+	for _, kv := range s.goEnv {
+		_, dir, _ := strings.Cut(kv, "=")
+		if s.goDirWritable(dir) {
+			args = append(args, "--bind-try", dir, dir)
+		} else {
+			args = append(args, "--ro-bind-try", dir, dir)
+		}
+	}
+
+	args = append(args, "--chdir", cmd.Dir, "--")
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = s.confinePath
+	cmd.Args = append([]string{"bwrap"}, args...)
+	return nil
+}
@@ -0,0 +1,134 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBwrapSandbox returns a sandbox confined by bubblewrap, skipping the
+// test where bwrap cannot run
+func newBwrapSandbox(t *testing.T) *ProcessSandbox {
+	t.Helper()
+	if _, err := probeBwrap(); err != nil {
+		t.Skipf("bubblewrap unavailable: %v", err)
+	}
+	return newTestSandbox(t, func(c *Config) { c.Isolation = BackendBwrap })
+}
+
+func TestBwrapHidesHome(t *testing.T) {
+	sb := newBwrapSandbox(t)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	for _, path := range []string{"/home", "/root", home, outside} {
+		result, err := sb.Execute(context.Background(), "test", []string{"-e", path})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if result.ExitCode == 0 {
+			t.Errorf("%s is visible under bwrap", path)
+		}
+	}
+
+	// The workspace itself is visible and writable
+	result, err := sb.Execute(context.Background(), "touch", []string{"marker"})
+	if err != nil || result.ExitCode != 0 {
+		t.Fatalf("touch in the workspace: %v, %+v", err, result)
+	}
+	if _, err := os.Stat(filepath.Join(sb.config.WorkingDir, "marker")); err != nil {
+		t.Errorf("file written under bwrap is missing: %v", err)
+	}
+}
+
+func TestBwrapGOPATHReadOnly(t *testing.T) {
+	sb := newBwrapSandbox(t)
+	requirePrograms(t, "go")
+
+	gopath := ""
+	for _, kv := range sb.goCacheEnv() {
+		if value, ok := strings.CutPrefix(kv, "GOPATH="); ok {
+			gopath = value
+		}
+	}
+	if gopath == "" {
+		t.Skip("go reports no GOPATH")
+	}
+	if _, err := os.Stat(gopath); err != nil {
+		t.Skipf("GOPATH %s does not exist", gopath)
+	}
+
+	result, err := sb.Execute(context.Background(), "touch", []string{filepath.Join(gopath, "looper-planted")})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode == 0 {
+		os.Remove(filepath.Join(gopath, "looper-planted"))
+		t.Error("GOPATH is writable under bwrap")
+	}
+}
+
+// TestConfinedGoCaches checks the mounts go builds get under bwrap, which
+// does not need bwrap installed
+func TestConfinedGoCaches(t *testing.T) {
+	requirePrograms(t, "go")
+	sb := newTestSandbox(t, func(c *Config) { c.Isolation = BackendBwrap })
+	// Pretend the probe found bwrap
+	sb.confineOnce.Do(func() { sb.confinePath = "/usr/bin/bwrap" })
+
+	env := map[string]string{}
+	for _, kv := range sb.goCacheEnv() {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+	host, err := exec.Command("go", "env", "GOPATH").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["GOPATH"] != strings.TrimSpace(string(host)) {
+		t.Errorf("GOPATH = %q, want the host's %q", env["GOPATH"], host)
+	}
+	for _, name := range []string{"GOCACHE", "GOMODCACHE"} {
+		if sb.goCacheDir == "" || !withinDir(sb.goCacheDir, env[name]) {
+			t.Errorf("%s = %q, want a directory in the sandbox's cache %q", name, env[name], sb.goCacheDir)
+		}
+	}
+
+	cmd := exec.Command("true")
+	cmd.Dir = sb.config.WorkingDir
+	if err := sb.wrapBwrap(cmd); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join(cmd.Args, " ")
+	for _, mount := range []string{
+		"--ro-bind-try " + env["GOPATH"] + " ",
+		"--bind-try " + env["GOCACHE"] + " ",
+		"--bind-try " + env["GOMODCACHE"] + " ",
+	} {
+		if !strings.Contains(args, mount) {
+			t.Errorf("bwrap args %q do not contain %q", args, mount)
+		}
+	}
+
+	// The module cache is read-only, which must not stop Close removing it
+	locked := filepath.Join(env["GOMODCACHE"], "example.com", "m@v1.0.0")
+	if err := os.MkdirAll(locked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(locked, "go.mod"), []byte("module example.com/m\n"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(locked, 0555)
+	if err := sb.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(sb.goCacheDir); !os.IsNotExist(err) {
+		t.Errorf("go cache %s remains after Close: %v", sb.goCacheDir, err)
+	}
+}
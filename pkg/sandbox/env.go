@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// DefaultDeniedEnv returns the default patterns of environment variables
+// that are never passed through to commands, even with InheritAllEnv
+func DefaultDeniedEnv() []string {
+	return []string{
+		"*_API_KEY",
+		"*TOKEN*",
+		"*SECRET*",
+		"AWS_*",
+	}
+}
+
+// matchesEnvPattern reports whether an environment variable name matches
+// any of the patterns. Patterns may use * and ? wildcards; names compare
+// case-insensitively on Windows.
+func matchesEnvPattern(name string, patterns []string) bool {
+	name = foldEnvKey(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(foldEnvKey(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// passEnv reports whether a variable of the parent environment is passed
+// through to commands. DeniedEnv takes precedence over AllowedEnv, which
+// takes precedence over InheritAllEnv.
+func (c *Config) passEnv(name string) bool {
+	if matchesEnvPattern(name, c.DeniedEnv) {
+		return false
+	}
+	return c.InheritAllEnv || matchesEnvPattern(name, c.AllowedEnv)
+}
+
+// parentEnvironment returns the variables of the parent environment that
+// pass the configured filters
+func (c *Config) parentEnvironment() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, ok := strings.Cut(kv, "=")
+		// Windows keeps per-drive working directories in variables such as
+		// "=C:", which have no name
+		if !ok || name == "" {
+			continue
+		}
+		if c.passEnv(name) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
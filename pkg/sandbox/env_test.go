@@ -0,0 +1,93 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPassEnvPrecedence(t *testing.T) {
+	allowed := []string{"PATH", "GO*", "npm_config_*", "CI_TOKEN_HELPER"}
+	denied := DefaultDeniedEnv()
+
+	tests := []struct {
+		name       string
+		allow      bool // Passed with AllowedEnv only
+		inheritAll bool // Passed with InheritAllEnv as well
+	}{
+		{name: "PATH", allow: true, inheritAll: true},
+		{name: "GOPATH", allow: true, inheritAll: true},
+		{name: "GOFLAGS", allow: true, inheritAll: true},
+		{name: "npm_config_cache", allow: true, inheritAll: true},
+		{name: "EDITOR", allow: false, inheritAll: true},
+		{name: "CARGO_HOME", allow: false, inheritAll: true},
+
+		// Denied patterns win over allowed ones and over InheritAllEnv
+		{name: "GO_TOKEN", allow: false, inheritAll: false},
+		{name: "CI_TOKEN_HELPER", allow: false, inheritAll: false},
+		{name: "GITHUB_TOKEN", allow: false, inheritAll: false},
+		{name: "OPENAI_API_KEY", allow: false, inheritAll: false},
+		{name: "CLIENT_SECRET_FILE", allow: false, inheritAll: false},
+		{name: "AWS_REGION", allow: false, inheritAll: false},
+	}
+
+	for _, inheritAll := range []bool{false, true} {
+		config := &Config{AllowedEnv: allowed, DeniedEnv: denied, InheritAllEnv: inheritAll}
+		for _, tt := range tests {
+			want := tt.allow
+			if inheritAll {
+				want = tt.inheritAll
+			}
+			if got := config.passEnv(tt.name); got != want {
+				t.Errorf("InheritAllEnv=%v: passEnv(%q) = %v, want %v", inheritAll, tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestExecuteEnvironment(t *testing.T) {
+	requirePrograms(t, "env")
+	t.Setenv("LOOPER_TEST_PLAIN", "plain")
+	t.Setenv("GOLOOPER_TEST", "go")
+	t.Setenv("LOOPER_TEST_TOKEN", "denied-value")
+
+	run := func(configure func(*Config)) string {
+		t.Helper()
+		sb := newTestSandbox(t, configure)
+		result, err := sb.Execute(context.Background(), "env", nil)
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		return result.Stdout
+	}
+
+	// Allowed patterns only
+	out := run(func(c *Config) {
+		c.AllowedEnv = append(c.AllowedEnv, "GO*", "LOOPER_TEST_TOKEN")
+	})
+	if !strings.Contains(out, "GOLOOPER_TEST=go\n") {
+		t.Error("variable matching an allowed pattern not passed")
+	}
+	if strings.Contains(out, "LOOPER_TEST_PLAIN") {
+		t.Error("variable outside AllowedEnv passed without InheritAllEnv")
+	}
+	if strings.Contains(out, "LOOPER_TEST_TOKEN") {
+		t.Error("denied variable passed because it is allowed")
+	}
+
+	// Everything but the denied variables, with custom variables set even
+	// when denied (their values are redacted from the output)
+	out = run(func(c *Config) {
+		c.InheritAllEnv = true
+		c.CustomEnv["LOOPER_CUSTOM_TOKEN"] = "custom"
+	})
+	if !strings.Contains(out, "LOOPER_TEST_PLAIN=plain\n") {
+		t.Error("variable not passed with InheritAllEnv")
+	}
+	if strings.Contains(out, "LOOPER_TEST_TOKEN") {
+		t.Error("denied variable passed with InheritAllEnv")
+	}
+	if !strings.Contains(out, "LOOPER_CUSTOM_TOKEN=") {
+		t.Error("CustomEnv variable matching DeniedEnv not set")
+	}
+}
@@ -0,0 +1,64 @@
+package sandbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ErrorClass categorizes errors returned by a Sandbox so callers can decide
+// how to react, for example whether a retry might succeed
+type ErrorClass int
+
+const (
+	// ErrorClassNone means there was no error
+	ErrorClassNone ErrorClass = iota
+	// ErrorClassBlacklisted means the command was refused by policy
+	ErrorClassBlacklisted
+	// ErrorClassTimeout means the command exceeded its timeout
+	ErrorClassTimeout
+	// ErrorClassCancelled means the caller cancelled the execution
+	ErrorClassCancelled
+	// ErrorClassTransient means the command could not start due to a
+	// temporary condition (resource exhaustion, busy executable)
+	ErrorClassTransient
+	// ErrorClassExecFailure covers every other failure to run the command
+	ErrorClassExecFailure
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassNone:
+		return "none"
+	case ErrorClassBlacklisted:
+		return "blacklisted"
+	case ErrorClassTimeout:
+		return "timeout"
+	case ErrorClassCancelled:
+		return "cancelled"
+	case ErrorClassTransient:
+		return "transient"
+	default:
+		return "exec failure"
+	}
+}
+
+// ClassifyError reports the class of an error returned by a Sandbox. It
+// follows wrapped errors, so errors from tools that wrap sandbox errors with
+// %w classify the same way.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, ErrBlacklistedCommand), errors.Is(err, ErrCommandNotAllowed):
+		return ErrorClassBlacklisted
+	case errors.Is(err, ErrExecutionTimeout):
+		return ErrorClassTimeout
+	case errors.Is(err, ErrExecutionCancelled):
+		return ErrorClassCancelled
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.ETXTBSY), errors.Is(err, syscall.EBUSY),
+		errors.Is(err, ErrTooManyBackgroundProcesses):
+		return ErrorClassTransient
+	default:
+		return ErrorClassExecFailure
+	}
+}
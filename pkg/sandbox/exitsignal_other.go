@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sandbox
+
+import "os"
+
+// exitSignal returns "" where processes are not terminated by signals
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// signalNames maps the signals that commonly end a process to their names
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// exitSignal returns the name of the signal that terminated a process, such
+// as "SIGSEGV", or "" if it exited on its own
+func exitSignal(state *os.ProcessState) string {
+	if state == nil {
+		return ""
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	if name, ok := signalNames[status.Signal()]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(status.Signal()))
+}
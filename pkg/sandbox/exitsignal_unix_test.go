@@ -0,0 +1,52 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExitSemantics(t *testing.T) {
+	requirePrograms(t, "bash", "sleep")
+	sb := newTestSandbox(t, func(c *Config) { c.KillGrace = 0 })
+
+	tests := []struct {
+		name         string
+		script       string
+		timeout      time.Duration
+		wantErr      error
+		wantExitCode int
+		wantSignal   string
+		wantTimedOut bool
+	}{
+		{name: "normal exit", script: "exit 0"},
+		{name: "non-zero exit", script: "exit 3", wantExitCode: 3},
+		{name: "SIGKILL", script: "kill -KILL $$", wantExitCode: -1, wantSignal: "SIGKILL"},
+		{name: "SIGSEGV", script: "kill -SEGV $$", wantExitCode: -1, wantSignal: "SIGSEGV"},
+		{name: "timeout", script: "sleep 10", timeout: 200 * time.Millisecond, wantErr: ErrExecutionTimeout, wantExitCode: -1, wantSignal: "SIGKILL", wantTimedOut: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sb.ExecuteWithOptions(context.Background(), "bash", []string{"-c", tt.script}, &ExecOptions{Timeout: tt.timeout})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if result.ExitCode != tt.wantExitCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.wantExitCode)
+			}
+			if result.Signal != tt.wantSignal {
+				t.Errorf("Signal = %q, want %q", result.Signal, tt.wantSignal)
+			}
+			if result.TimedOut != tt.wantTimedOut {
+				t.Errorf("TimedOut = %v, want %v", result.TimedOut, tt.wantTimedOut)
+			}
+			if tt.timeout > 0 && result.Limits.Timeout != tt.timeout {
+				t.Errorf("Limits.Timeout = %v, want %v", result.Limits.Timeout, tt.timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package sandbox
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// FuzzCheckBlacklist compiles an arbitrary pattern and checks arbitrary
+// input against it. Run with:
+//
+//	go test ./pkg/sandbox -fuzz=FuzzCheckBlacklist -fuzztime=60s
+func FuzzCheckBlacklist(f *testing.F) {
+	inputs := []string{"", "ls -la", "sudo shutdown now", "echo 'a|b' | grep a"}
+	for _, tt := range blacklistRegressions {
+		inputs = append(inputs, tt.input)
+	}
+	patterns := append(DefaultBlacklist(),
+		`\*`, `(.*)`, `*`, `**`, `\`, `|`, `a||b`, "re:", "re:(", "re:[a-", `re:(?i)^rm\b`,
+		"any:", "any:re:.*", "any:*", `any:\*`, "$(reboot)", "`reboot`", "re\u0000boot", "ＲＥＢＯＯＴ",
+	)
+	for i, pattern := range patterns {
+		f.Add(inputs[i%len(inputs)], pattern)
+	}
+
+	f.Fuzz(func(t *testing.T, input, pattern string) {
+		matchers, err := compileBlacklist([]string{pattern})
+		if err != nil {
+			if !errors.Is(err, ErrInvalidBlacklistPattern) {
+				t.Fatalf("compile %q: error %v does not wrap ErrInvalidBlacklistPattern", pattern, err)
+			}
+			return
+		}
+
+		// An invalid regex must be reported rather than compiled away
+		expr := strings.TrimPrefix(pattern, anywherePrefix)
+		if re, ok := strings.CutPrefix(expr, regexPrefix); ok {
+			if _, err := regexp.Compile(re); err != nil {
+				t.Fatalf("pattern %q compiled despite invalid regex: %v", pattern, err)
+			}
+		}
+
+		sb := &ProcessSandbox{blacklist: matchers}
+		first := sb.checkCommandBlacklist("bash", []string{"-c", input})
+		if again := sb.checkCommandBlacklist("bash", []string{"-c", input}); (first == nil) != (again == nil) {
+			t.Fatalf("pattern %q gave %v then %v for %q", pattern, first, again, input)
+		}
+		if first != nil {
+			var blErr *BlacklistError
+			if !errors.As(first, &blErr) || blErr.Pattern != pattern {
+				t.Fatalf("pattern %q: err = %v, want a BlacklistError for the pattern", pattern, first)
+			}
+		}
+
+		first = sb.checkScriptBlacklist("python3", input)
+		if again := sb.checkScriptBlacklist("python3", input); (first == nil) != (again == nil) {
+			t.Fatalf("script: pattern %q gave %v then %v for %q", pattern, first, again, input)
+		}
+	})
+}
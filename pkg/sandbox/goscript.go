@@ -0,0 +1,195 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// goScratchModule is the module path of the persistent go scratch module
+const goScratchModule = "looper.local/scratch"
+
+// executeGoScript runs a go snippet inside a persistent scratch module so
+// that the build and module caches stay warm across calls. Each snippet is
+// built in its own package directory and the binary runs in the requested
+// working directory. Snippets importing third-party packages trigger
+// "go mod tidy" first, bounded by the same timeout as the run. Every step
+// captures output up to limits.MaxOutputBytes.
+func (s *ProcessSandbox) executeGoScript(ctx context.Context, script string, opts *ExecOptions, limits Limits) (*ExecutionResult, error) {
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+
+	scratch, err := s.goScratchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	runDir, err := os.MkdirTemp(scratch, "run-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create go scratch package: %w", err)
+	}
+	defer os.RemoveAll(runDir)
+
+	if err := os.WriteFile(filepath.Join(runDir, "main.go"), []byte(script), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write script: %w", err)
+	}
+
+	binary := filepath.Join(runDir, "main")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	// Compile steps stream their output so build errors show up live, but
+	// never see the snippet's stdin
+	buildOpts := &ExecOptions{OnOutput: opts.OnOutput, WorkingDir: opts.WorkingDir}
+
+	// Tidying rewrites go.mod and go.sum, which concurrent builds read
+	s.goMu.Lock()
+	if needsModTidy(script) {
+		result, err := s.goCommand(ctx, scratch, buildOpts, limits, "mod", "tidy")
+		if err != nil || result.ExitCode != 0 || result.TimedOut {
+			s.goMu.Unlock()
+			return result, err
+		}
+	}
+	result, err := s.goCommand(ctx, scratch, buildOpts, limits, "build", "-o", binary, "./"+filepath.Base(runDir))
+	s.goMu.Unlock()
+	if err != nil || result.ExitCode != 0 || result.TimedOut {
+		return result, err
+	}
+
+	cmd := exec.CommandContext(ctx, binary)
+	return s.runCommand(ctx, cmd, opts, limits)
+}
+
+// goCommand runs the go tool in dir with the persistent cache environment
+func (s *ProcessSandbox) goCommand(ctx context.Context, dir string, opts *ExecOptions, limits Limits, args ...string) (*ExecutionResult, error) {
+	cmd := exec.CommandContext(ctx, "go", append([]string{"-C", dir}, args...)...)
+	cmd.Env = s.goCacheEnv()
+	return s.runCommand(ctx, cmd, opts, limits)
+}
+
+// goScratchDir returns the persistent scratch module directory, creating it
+// and its go.mod on first use. It defaults to .looper/go-scratch under the
+// sandbox working directory.
+func (s *ProcessSandbox) goScratchDir() (string, error) {
+	dir := s.config.GoScratchDir
+	if dir == "" {
+		dir = filepath.Join(s.config.WorkingDir, ".looper", "go-scratch")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid go scratch directory: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create go scratch module: %w", err)
+	}
+
+	goMod := filepath.Join(dir, "go.mod")
+	if _, err := os.Stat(goMod); os.IsNotExist(err) {
+		cmd := exec.Command("go", "mod", "init", goScratchModule)
+		cmd.Dir = dir
+		cmd.Env = append(s.buildEnvironment(), s.goCacheEnv()...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to initialize go scratch module: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return dir, nil
+}
+
+// goCacheEnv returns GOCACHE, GOMODCACHE and GOPATH as the host go tool
+// resolves them, so sandboxed builds reuse the host caches even when those
+// variables (or HOME) are not passed through AllowedEnv. Backends that hide
+// the host filesystem mount these read-only, so there builds get a build and
+// module cache of their own for the life of the sandbox instead: a confined
+// command must not be able to plant entries the host's builds would use.
+// The values are looked up once.
+func (s *ProcessSandbox) goCacheEnv() []string {
+	s.goEnvOnce.Do(func() {
+		names := []string{"GOCACHE", "GOMODCACHE", "GOPATH"}
+		out, err := exec.Command("go", append([]string{"env"}, names...)...).Output()
+		if err != nil {
+			return
+		}
+		values := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		for i, value := range values {
+			if i >= len(names) || strings.TrimSpace(value) == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if names[i] != "GOPATH" && s.hidesHostFilesystem() {
+				if value, err = s.sessionGoCache(names[i]); err != nil {
+					log.Printf("WARNING: sandbox: %v; go builds will fail without a writable %s", err, names[i])
+					continue
+				}
+			}
+			s.goEnv = append(s.goEnv, names[i]+"="+value)
+		}
+	})
+	return s.goEnv
+}
+
+// sessionGoCache returns the directory for a go cache private to this
+// sandbox, which Close removes
+func (s *ProcessSandbox) sessionGoCache(name string) (string, error) {
+	if s.goCacheDir == "" {
+		dir, err := os.MkdirTemp("", "looper-gocache-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create go cache directory: %w", err)
+		}
+		s.goCacheDir = dir
+	}
+	return filepath.Join(s.goCacheDir, strings.ToLower(name)), nil
+}
+
+// goDirWritable reports whether a go directory from goEnv is mounted
+// read-write under confinement; only the sandbox's own caches are
+func (s *ProcessSandbox) goDirWritable(dir string) bool {
+	return s.goCacheDir != "" && withinDir(s.goCacheDir, dir)
+}
+
+// removeGoCache deletes the sandbox's own go caches. The module cache is
+// read-only, so directories are made writable first.
+func (s *ProcessSandbox) removeGoCache() error {
+	if s.goCacheDir == "" {
+		return nil
+	}
+	filepath.WalkDir(s.goCacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(s.goCacheDir)
+}
+
+// needsModTidy reports whether a go snippet imports packages outside the
+// standard library, whose import paths start with a domain
+func needsModTidy(script string) bool {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", script, parser.ImportsOnly)
+	if err != nil {
+		return false // Let the build report the syntax error
+	}
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		first, _, _ := strings.Cut(path, "/")
+		if strings.Contains(first, ".") {
+			return true
+		}
+	}
+	return false
+}
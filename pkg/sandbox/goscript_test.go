@@ -0,0 +1,117 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goSnippet prints its argument using only the standard library
+func goSnippet(msg string) string {
+	return fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(%q) }\n", msg)
+}
+
+// countFiles returns the number of regular files under dir
+func countFiles(t testing.TB, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// newGoScriptSandbox returns a sandbox whose go builds use a build cache of
+// their own, so the test sees exactly what each run adds to it
+func newGoScriptSandbox(t testing.TB) (*ProcessSandbox, string) {
+	t.Helper()
+	cache := filepath.Join(t.TempDir(), "gocache")
+	t.Setenv("GOCACHE", cache)
+	config := DefaultConfig(t.TempDir())
+	config.Timeout = 5 * time.Minute
+	sb, err := NewProcessSandbox(config)
+	if err != nil {
+		t.Fatalf("NewProcessSandbox: %v", err)
+	}
+	t.Cleanup(func() { sb.Close() })
+	return sb, cache
+}
+
+func runGoSnippet(t testing.TB, sb *ProcessSandbox, msg string) {
+	t.Helper()
+	result, err := sb.ExecuteScript(context.Background(), "go", goSnippet(msg))
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != msg {
+		t.Fatalf("exit %d, stdout %q, stderr %q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
+
+func TestGoScriptReusesBuildCache(t *testing.T) {
+	requirePrograms(t, "go")
+	if testing.Short() {
+		t.Skip("builds the standard library into an empty cache")
+	}
+	sb, cache := newGoScriptSandbox(t)
+
+	runGoSnippet(t, sb, "first")
+	scratch, err := sb.goScratchDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold := countFiles(t, cache)
+	if cold == 0 {
+		t.Fatalf("the build did not use GOCACHE %s", cache)
+	}
+
+	// The second snippet only compiles its own package: fmt and the runtime
+	// come from the cache filled by the first run
+	runGoSnippet(t, sb, "second")
+	warm := countFiles(t, cache) - cold
+	if warm*4 > cold {
+		t.Errorf("second run added %d cache files after the first added %d; the cache was not reused", warm, cold)
+	}
+
+	if again, err := sb.goScratchDir(); err != nil || again != scratch {
+		t.Errorf("scratch module moved from %s to %s (%v)", scratch, again, err)
+	}
+	if n := countFiles(t, scratch); n != 1 {
+		t.Errorf("scratch module holds %d files, want only go.mod", n)
+	}
+}
+
+// BenchmarkGoScriptWarm measures a go snippet run once the scratch module
+// and build cache are warm
+func BenchmarkGoScriptWarm(b *testing.B) {
+	requirePrograms(b, "go")
+	sb, _ := newGoScriptSandbox(b)
+	runGoSnippet(b, sb, "warm-up")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runGoSnippet(b, sb, fmt.Sprintf("run %d", i))
+	}
+}
+
+// BenchmarkGoScriptCold measures a go snippet run with an empty build cache,
+// the cost of every run before the scratch module kept the cache warm
+func BenchmarkGoScriptCold(b *testing.B) {
+	requirePrograms(b, "go")
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		sb, _ := newGoScriptSandbox(b)
+		b.StartTimer()
+		runGoSnippet(b, sb, fmt.Sprintf("run %d", i))
+	}
+}
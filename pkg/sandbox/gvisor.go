@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// runscErrorPrefixes start the messages runsc prints on stderr when it
+// fails itself, as opposed to the output of the command it runs. Exit
+// statuses cannot tell the two apart: runsc passes the command's status
+// through, and commands such as git exit 128 themselves.
+var runscErrorPrefixes = []string{
+	"creating container:",
+	"starting container:",
+	"running container:",
+	"waiting for container:",
+	"cannot create sandbox:",
+}
+
+// probeGVisor locates runsc and checks that it can start a rootless sandbox
+func probeGVisor() (string, error) {
+	return probeJail("runsc", "--rootless", "--network=none", "--ignore-cgroups", "do", "-quiet", "--")
+}
+
+// wrapGVisor rewrites cmd to run under gVisor with "runsc do": commands see
+// the host filesystem through gVisor's user-space kernel, which implements
+// system calls itself instead of passing them to the host kernel. The
+// overlay runsc puts over the root by default is disabled so that writes to
+// the workspace persist.
+func (s *ProcessSandbox) wrapGVisor(cmd *exec.Cmd) error {
+	network := "host"
+	if s.NetworkDisabled() {
+		network = "none"
+	}
+	args := []string{
+		"--rootless",
+		"--network=" + network,
+		"--ignore-cgroups",
+		"do",
+		"-quiet",
+		"-force-overlay=false",
+		"-cwd", cmd.Dir,
+		"--",
+	}
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = s.confinePath
+	cmd.Args = append([]string{"runsc"}, args...)
+	return nil
+}
+
+// detectGVisorFailure explains a failed command whose cause is runsc
+// rather than the command, going by the error runsc prints as the last line
+// of stderr. It returns "" for ordinary failures.
+func detectGVisorFailure(result *ExecutionResult) string {
+	if result.ExitCode == 0 {
+		return ""
+	}
+	stderr := strings.TrimSpace(result.Stderr + result.Combined)
+	last := strings.TrimSpace(stderr[strings.LastIndexByte(stderr, '\n')+1:])
+	for _, prefix := range runscErrorPrefixes {
+		if strings.HasPrefix(last, prefix) {
+			return "runsc failed: " + last
+		}
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+//go:build integration && linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run with: go test -tags integration ./pkg/sandbox -run GVisor
+
+func newGVisorSandbox(t *testing.T) *ProcessSandbox {
+	t.Helper()
+	if _, err := probeGVisor(); err != nil {
+		t.Skipf("gVisor unavailable: %v", err)
+	}
+	sb := newTestSandbox(t, func(c *Config) { c.Isolation = BackendGVisor })
+	if !sb.confined() {
+		t.Fatalf("sandbox not confined: %v", sb.confineErr)
+	}
+	return sb
+}
+
+func TestGVisorExecute(t *testing.T) {
+	sb := newGVisorSandbox(t)
+
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", "echo hello > out.txt; cat out.txt; exit 3"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode != 3 || result.IsolationFailure != "" {
+		t.Errorf("ExitCode = %d, IsolationFailure = %q; want the command's own status", result.ExitCode, result.IsolationFailure)
+	}
+	if strings.TrimSpace(result.Stdout) != "hello" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+
+	// Writes reach the workspace rather than an overlay
+	data, err := os.ReadFile(filepath.Join(sb.config.WorkingDir, "out.txt"))
+	if err != nil || strings.TrimSpace(string(data)) != "hello" {
+		t.Errorf("workspace file = %q, %v", data, err)
+	}
+}
+
+func TestGVisorScript(t *testing.T) {
+	sb := newGVisorSandbox(t)
+	requirePrograms(t, "python3")
+
+	result, err := sb.ExecuteScript(context.Background(), "python3", "import platform\nprint(platform.system())\n")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != "Linux" {
+		t.Errorf("exit %d, stdout %q, stderr %q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
@@ -0,0 +1,41 @@
+package sandbox
+
+import "testing"
+
+func TestDetectGVisorFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ExecutionResult
+		want   string
+	}{
+		{"success", ExecutionResult{ExitCode: 0}, ""},
+		{"ordinary failure", ExecutionResult{ExitCode: 1, Stderr: "no such file"}, ""},
+		{
+			"runsc failure",
+			ExecutionResult{ExitCode: 128, Stderr: "running container\ncreating container: cannot create sandbox: permission denied\n"},
+			"runsc failed: creating container: cannot create sandbox: permission denied",
+		},
+		{
+			"runsc failure with another status",
+			ExecutionResult{ExitCode: 1, Combined: "starting container: starting sandbox: EOF"},
+			"runsc failed: starting container: starting sandbox: EOF",
+		},
+		{"status 128 without output", ExecutionResult{ExitCode: 128}, ""},
+		{"git exiting 128", ExecutionResult{ExitCode: 128, Stderr: "fatal: not a git repository (or any of the parent directories): .git\n"}, ""},
+		{"command failing with ENOSYS", ExecutionResult{ExitCode: 1, Combined: "io_uring_setup: Function not implemented"}, ""},
+		{
+			"runsc message quoted by the command",
+			ExecutionResult{ExitCode: 1, Stderr: "creating container: quota exceeded\nError: deploy failed, see above"},
+			"",
+		},
+		{"runsc message on success", ExecutionResult{ExitCode: 0, Stderr: "creating container: retrying"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectGVisorFailure(&tt.result); got != tt.want {
+				t.Errorf("detectGVisorFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
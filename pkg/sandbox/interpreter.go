@@ -0,0 +1,163 @@
+package sandbox
+
+import (
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// InterpreterSpec describes how ExecuteScript runs a script for a language
+type InterpreterSpec struct {
+	// Extension is the temp script file extension, including the dot.
+	// Some interpreters pick the file type from it, e.g. deno for ".ts".
+	Extension string
+
+	// Args are placed between the interpreter and the script path, e.g.
+	// "run" for "go run script.go"
+	Args []string
+
+	// Executable marks the script file executable before it runs (ignored
+	// on Windows)
+	Executable bool
+}
+
+// DefaultInterpreters returns the built-in interpreter table, keyed by the
+// interpreter name as passed to ExecuteScript (the last word of a launcher
+// such as "bundle exec ruby")
+func DefaultInterpreters() map[string]InterpreterSpec {
+	return map[string]InterpreterSpec{
+		"python":     {Extension: ".py"},
+		"python3":    {Extension: ".py"},
+		"node":       {Extension: ".js"},
+		"nodejs":     {Extension: ".js"},
+		"bash":       {Extension: ".sh", Executable: true},
+		"sh":         {Extension: ".sh", Executable: true},
+		"go":         {Extension: ".go", Args: []string{"run"}},
+		"ruby":       {Extension: ".rb"},
+		"perl":       {Extension: ".pl"},
+		"php":        {Extension: ".php"},
+		"deno":       {Extension: ".ts", Args: []string{"run"}},
+		"bun":        {Extension: ".ts", Args: []string{"run"}},
+		"powershell": {Extension: ".ps1", Args: []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}},
+		"pwsh":       {Extension: ".ps1", Args: []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}},
+		"cmd":        {Extension: ".cmd", Args: []string{"/C"}},
+		"wasmtime":   {Extension: ".wasm", Args: []string{"run", "--dir=."}},
+	}
+}
+
+// interpreterSpec returns how to run a script for an interpreter.
+// Config.Interpreters takes precedence over the defaults; unknown
+// interpreters get a .tmp file passed as the only argument.
+func (s *ProcessSandbox) interpreterSpec(interpreter string) InterpreterSpec {
+	if spec, ok := s.config.Interpreters[interpreter]; ok {
+		return spec
+	}
+	if spec, ok := DefaultInterpreters()[interpreter]; ok {
+		return spec
+	}
+	return InterpreterSpec{Extension: ".tmp"}
+}
+
+// ConfiguredInterpreters returns the names of the interpreters added or
+// overridden through Config.Interpreters, sorted
+func (s *ProcessSandbox) ConfiguredInterpreters() []string {
+	names := make([]string, 0, len(s.config.Interpreters))
+	for name := range s.config.Interpreters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InterpreterAvailable reports whether a program can be found on the PATH
+func InterpreterAvailable(program string) bool {
+	_, err := exec.LookPath(program)
+	return err == nil
+}
+
+// Limits are the timeout and output ceiling a call runs with. In
+// Config.PerInterpreterLimits a zero field keeps the global setting.
+type Limits struct {
+	Timeout        time.Duration `json:"timeout,omitempty"`
+	MaxOutputBytes int64         `json:"max_output_bytes,omitempty"`
+}
+
+// DefaultInterpreterLimits returns the built-in per-program limits: longer
+// timeouts for compilers and package managers, which legitimately run for
+// minutes
+func DefaultInterpreterLimits() map[string]Limits {
+	return map[string]Limits{
+		"go":    {Timeout: 5 * time.Minute},
+		"cargo": {Timeout: 10 * time.Minute},
+		"npm":   {Timeout: 10 * time.Minute},
+		"npx":   {Timeout: 10 * time.Minute},
+		"yarn":  {Timeout: 10 * time.Minute},
+		"pnpm":  {Timeout: 10 * time.Minute},
+		"pip":   {Timeout: 5 * time.Minute},
+		"pip3":  {Timeout: 5 * time.Minute},
+	}
+}
+
+// DefaultLimits returns the limits calls run with when neither a per-call
+// override nor Config.PerInterpreterLimits applies
+func (s *ProcessSandbox) DefaultLimits() Limits {
+	return Limits{Timeout: s.config.Timeout, MaxOutputBytes: s.config.MaxOutputBytes}
+}
+
+// InterpreterLimits returns a copy of Config.PerInterpreterLimits
+func (s *ProcessSandbox) InterpreterLimits() map[string]Limits {
+	limits := make(map[string]Limits, len(s.config.PerInterpreterLimits))
+	for name, l := range s.config.PerInterpreterLimits {
+		limits[name] = l
+	}
+	return limits
+}
+
+// effectiveLimits returns the limits for a call to program (an interpreter
+// name or a command's program name). The per-call timeout, capped at
+// MaxTimeout, beats the program's entry in PerInterpreterLimits, which
+// beats the global settings.
+func (s *ProcessSandbox) effectiveLimits(program string, opts *ExecOptions) Limits {
+	limits := s.DefaultLimits()
+	if l, ok := s.config.PerInterpreterLimits[program]; ok {
+		if l.Timeout > 0 {
+			limits.Timeout = l.Timeout
+		}
+		if l.MaxOutputBytes > 0 {
+			limits.MaxOutputBytes = l.MaxOutputBytes
+		}
+	}
+	if opts != nil && opts.Timeout > 0 {
+		limits.Timeout = opts.Timeout
+		if s.config.MaxTimeout > 0 && limits.Timeout > s.config.MaxTimeout {
+			limits.Timeout = s.config.MaxTimeout
+		}
+	}
+	return limits
+}
+
+// limitsProgram returns the name a command's limits are looked up by: its
+// program name, or for a shell running a command string, the first program
+// in that string if PerInterpreterLimits has an entry for it. This gives
+// "bash -c 'npm install'" the limits of npm.
+func (s *ProcessSandbox) limitsProgram(command string, args []string) string {
+	program := programName(command)
+	if script, ok := shellScriptArg(command, args); ok {
+		for _, c := range parseShell(script) {
+			i := commandStart(c.words)
+			if i < 0 {
+				continue
+			}
+			if name := programName(c.words[i]); s.hasInterpreterLimits(name) {
+				return name
+			}
+			break
+		}
+	}
+	return program
+}
+
+func (s *ProcessSandbox) hasInterpreterLimits(program string) bool {
+	_, ok := s.config.PerInterpreterLimits[program]
+	return ok
+}
@@ -0,0 +1,106 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEffectiveLimits(t *testing.T) {
+	sb := newTestSandbox(t, func(c *Config) {
+		c.Timeout = 30 * time.Second
+		c.MaxOutputBytes = 1000
+		c.MaxTimeout = 20 * time.Minute
+		c.PerInterpreterLimits = map[string]Limits{
+			"go":     {Timeout: 5 * time.Minute},
+			"python": {MaxOutputBytes: 50},
+			"node":   {Timeout: time.Second, MaxOutputBytes: 10},
+		}
+	})
+
+	tests := []struct {
+		program string
+		opts    *ExecOptions
+		want    Limits
+	}{
+		// The global settings apply to programs without an entry
+		{"bash", nil, Limits{30 * time.Second, 1000}},
+		// An entry overrides only the fields it sets
+		{"go", nil, Limits{5 * time.Minute, 1000}},
+		{"python", nil, Limits{30 * time.Second, 50}},
+		{"node", nil, Limits{time.Second, 10}},
+		// A per-call timeout beats the entry and the global timeout
+		{"go", &ExecOptions{Timeout: 10 * time.Second}, Limits{10 * time.Second, 1000}},
+		{"bash", &ExecOptions{Timeout: 2 * time.Minute}, Limits{2 * time.Minute, 1000}},
+		{"node", &ExecOptions{Timeout: 3 * time.Second}, Limits{3 * time.Second, 10}},
+		// but is capped at MaxTimeout
+		{"go", &ExecOptions{Timeout: time.Hour}, Limits{20 * time.Minute, 1000}},
+		// A zero per-call timeout keeps the entry
+		{"go", &ExecOptions{}, Limits{5 * time.Minute, 1000}},
+	}
+	for _, tt := range tests {
+		if got := sb.effectiveLimits(tt.program, tt.opts); got != tt.want {
+			t.Errorf("effectiveLimits(%q, %+v) = %+v, want %+v", tt.program, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestLimitsProgram(t *testing.T) {
+	sb := newTestSandbox(t, func(c *Config) {
+		c.PerInterpreterLimits = map[string]Limits{"npm": {Timeout: 10 * time.Minute}}
+	})
+
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"npm", []string{"install"}, "npm"},
+		{"/usr/bin/npm", []string{"ci"}, "npm"},
+		{"git", []string{"status"}, "git"},
+		// A shell takes the limits of the program it runs
+		{"bash", []string{"-c", "npm install"}, "npm"},
+		{"bash", []string{"-c", "NODE_ENV=ci npm install"}, "npm"},
+		{"bash", []string{"-c", "git status"}, "bash"},
+		// Only the first command counts
+		{"bash", []string{"-c", "echo start && npm install"}, "bash"},
+		{"pwsh", []string{"-Command", "npm test"}, "npm"},
+	}
+	for _, tt := range tests {
+		if got := sb.limitsProgram(tt.command, tt.args); got != tt.want {
+			t.Errorf("limitsProgram(%q, %q) = %q, want %q", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestInterpreterLimitsApplied(t *testing.T) {
+	requirePrograms(t, "bash", "sleep")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.Timeout = time.Minute
+		c.KillGrace = 100 * time.Millisecond
+		c.PerInterpreterLimits = map[string]Limits{"sleep": {Timeout: 200 * time.Millisecond}}
+	})
+
+	// The entry for sleep beats the global minute
+	start := time.Now()
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", "sleep 5"})
+	if !errors.Is(err, ErrExecutionTimeout) || !result.TimedOut {
+		t.Fatalf("err = %v, TimedOut = %v; want a timeout", err, result != nil && result.TimedOut)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("took %v, want the 200ms limit of sleep", elapsed)
+	}
+	if result.Limits.Timeout != 200*time.Millisecond {
+		t.Errorf("reported timeout %v, want 200ms", result.Limits.Timeout)
+	}
+
+	// and a per-call timeout beats the entry
+	result, err = sb.ExecuteWithOptions(context.Background(), "sleep", []string{"0.5"}, &ExecOptions{Timeout: 10 * time.Second})
+	if err != nil || result.TimedOut || result.ExitCode != 0 {
+		t.Fatalf("err = %v, result = %+v; want sleep to finish within the per-call timeout", err, result)
+	}
+	if result.Limits.Timeout != 10*time.Second {
+		t.Errorf("reported timeout %v, want 10s", result.Limits.Timeout)
+	}
+}
@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// probeFirejail locates firejail and checks that it can start a command
+func probeFirejail() (string, error) {
+	return probeJail("firejail", "--quiet", "--noprofile")
+}
+
+// probeNsjail locates nsjail and checks that it can create namespaces here
+func probeNsjail() (string, error) {
+	return probeJail("nsjail", "--mode", "o", "--quiet", "--chroot", "/", "--time_limit", "0", "--")
+}
+
+// probeJail locates a confinement binary and runs true under it with the
+// given arguments
+func probeJail(name string, args ...string) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("%s requires Linux", name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found on PATH", name)
+	}
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return "", err
+	}
+	if out, err := exec.Command(path, append(args, truePath)...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s cannot run commands: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return path, nil
+}
+
+// wrapFirejail rewrites cmd to run under firejail without a profile, with
+// all capabilities dropped, no new privileges and the default seccomp
+// filter. /tmp is private unless the workspace or the script directory is
+// under it, since firejail cannot bind them back in.
+func (s *ProcessSandbox) wrapFirejail(cmd *exec.Cmd) error {
+	workspace, err := filepath.Abs(s.config.WorkingDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWorkingDir, err)
+	}
+
+	args := []string{
+		"--quiet",
+		"--noprofile",
+		"--caps.drop=all",
+		"--nonewprivs",
+		"--seccomp",
+	}
+	if !withinDir("/tmp", workspace) && (s.scriptDir == "" || !withinDir("/tmp", s.scriptDir)) {
+		args = append(args, "--private-tmp")
+	}
+	if s.NetworkDisabled() {
+		args = append(args, "--net=none")
+	}
+
+	args = append(args, "--")
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = s.confinePath
+	cmd.Args = append([]string{"firejail"}, args...)
+	return nil
+}
+
+// wrapNsjail rewrites cmd to run under nsjail. As with bwrap, the workspace
+// is bound read-write at its own path, system paths are read-only and /tmp
+// is a private tmpfs. nsjail's own time and resource limits are lifted,
+// since the sandbox enforces its own.
+func (s *ProcessSandbox) wrapNsjail(cmd *exec.Cmd) error {
+	workspace, err := filepath.Abs(s.config.WorkingDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWorkingDir, err)
+	}
+
+	args := []string{
+		"--mode", "o",
+		"--quiet",
+		"--keep_env",
+		"--time_limit", "0",
+		"--rlimit_as", "soft",
+		"--rlimit_cpu", "soft",
+		"--rlimit_fsize", "soft",
+		"--rlimit_nofile", "soft",
+	}
+	// nsjail isolates the network unless told otherwise
+	if !s.NetworkDisabled() {
+		args = append(args, "--disable_clone_newnet")
+	}
+
+	readOnly := s.config.ReadOnlyPaths
+	if readOnly == nil {
+		readOnly = DefaultReadOnlyPaths()
+	}
+	// nsjail fails on mounts whose source is missing
+	for _, path := range append(readOnly, "/dev/null", "/dev/zero", "/dev/urandom") {
+		if _, err := os.Stat(path); err == nil {
+			args = append(args, "--bindmount_ro", path)
+		}
+	}
+
+	args = append(args,
+		"--tmpfsmount", "/tmp",
+		"--bindmount", workspace,
+	)
+
+	// Script files may be configured to live outside the workspace
+	if s.scriptDir != "" && !withinDir(workspace, s.scriptDir) {
+		args = append(args, "--bindmount_ro", s.scriptDir)
+	}
+	// Go builds read GOPATH from the host and write their own caches
+	for _, kv := range s.goEnv {
+		_, dir, _ := strings.Cut(kv, "=")
+		if s.goDirWritable(dir) {
+			os.MkdirAll(dir, 0755)
+			args = append(args, "--bindmount", dir)
+		} else if _, err := os.Stat(dir); err == nil {
+			args = append(args, "--bindmount_ro", dir)
+		}
+	}
+
+	args = append(args, "--cwd", cmd.Dir, "--")
+	args = append(args, cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = s.confinePath
+	cmd.Args = append([]string{"nsjail"}, args...)
+	return nil
+}
@@ -0,0 +1,98 @@
+package sandbox
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Limit names reported in ExecutionResult.LimitExceeded
+const (
+	LimitCPU       = "cpu"
+	LimitMemory    = "memory"
+	LimitProcesses = "processes"
+	LimitOpenFiles = "open files"
+)
+
+// Suggested process-count and open-file limits. They are far above what
+// builds and test suites need, but stop a fork bomb or a descriptor leak
+// from exhausting the machine. DefaultConfig sets only DefaultMaxOpenFiles:
+// RLIMIT_NPROC counts every process and thread the user owns, not just the
+// sandboxed command's, so on a busy desktop or CI host a process limit can
+// make harmless commands fail to fork.
+const (
+	DefaultMaxProcesses = 4096
+	DefaultMaxOpenFiles = 4096
+)
+
+// memoryFailureSignatures are stderr fragments printed by common runtimes
+// when an allocation fails under RLIMIT_AS
+var memoryFailureSignatures = []string{
+	"MemoryError",
+	"Cannot allocate memory",
+	"out of memory",
+	"std::bad_alloc",
+}
+
+// processFailureSignatures are stderr fragments printed when fork or thread
+// creation fails with EAGAIN under RLIMIT_NPROC
+var processFailureSignatures = []string{
+	"fork: retry",
+	"fork: Resource temporarily unavailable",
+	"Cannot fork",
+	"can't fork",
+	"BlockingIOError: [Errno 11]",
+	"pthread_create failed",
+	"failed to create new OS thread",
+}
+
+// openFileFailureSignatures are stderr fragments printed when opening a file
+// or socket fails with EMFILE under RLIMIT_NOFILE
+var openFileFailureSignatures = []string{
+	"Too many open files",
+	"EMFILE",
+}
+
+// hasResourceLimits reports whether any rlimit is configured
+func (c *Config) hasResourceLimits() bool {
+	return c.MaxCPUSeconds > 0 || c.MaxMemoryBytes > 0 || c.MaxProcesses > 0 || c.MaxOpenFiles > 0
+}
+
+// wrapResourceLimits rewrites cmd to start under prlimit(1), so the
+// configured rlimits are in place before the command runs its first
+// instruction. It wraps any confinement backend too, as the limits applied
+// after start always have. It reports false if there is nothing to wrap or
+// prlimit is not installed; the caller then applies the limits once the
+// command has started.
+func (s *ProcessSandbox) wrapResourceLimits(cmd *exec.Cmd) bool {
+	if !resourceLimitsSupported || !s.config.hasResourceLimits() || cmd.Err != nil {
+		return false
+	}
+	s.prlimitOnce.Do(func() {
+		path, err := exec.LookPath("prlimit")
+		if err != nil {
+			log.Printf("WARNING: sandbox: prlimit not found on PATH; resource limits will be applied just after each command starts")
+			return
+		}
+		s.prlimitPath = path
+	})
+	if s.prlimitPath == "" {
+		return false
+	}
+
+	args := append([]string{"prlimit"}, prlimitArgs(s.config)...)
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = s.prlimitPath
+	return true
+}
+
+// containsAny reports whether s contains any of the fragments
+func containsAny(s string, fragments []string) bool {
+	for _, fragment := range fragments {
+		if strings.Contains(s, fragment) {
+			return true
+		}
+	}
+	return false
+}
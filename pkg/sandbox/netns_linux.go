@@ -0,0 +1,36 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// networkIsolationAttr returns process attributes that start the child in a
+// fresh network namespace containing only a downed loopback interface.
+// Unprivileged users get a user namespace mapping their own uid and gid so
+// the kernel permits creating the network namespace.
+func networkIsolationAttr() *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if uid := os.Geteuid(); uid != 0 {
+		gid := os.Getegid()
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+	}
+	return attr
+}
+
+// probeNetworkIsolation checks that namespaces can be created by running a
+// trivial command inside one
+func probeNetworkIsolation() error {
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(truePath)
+	cmd.SysProcAttr = networkIsolationAttr()
+	return cmd.Run()
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNetworkIsolation(t *testing.T) {
+	requirePrograms(t, "bash")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// bash's /dev/tcp connects without needing curl
+	script := fmt.Sprintf("echo > /dev/tcp/127.0.0.1/%d && echo connected", listener.Addr().(*net.TCPAddr).Port)
+
+	open := newTestSandbox(t, nil)
+	result, err := open.Execute(context.Background(), "bash", []string{"-c", script})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != "connected" {
+		t.Fatalf("connection with the network enabled failed: exit %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+
+	isolated := newTestSandbox(t, func(c *Config) { c.DisableNetwork = true })
+	if !isolated.NetworkDisabled() {
+		t.Skipf("network namespaces unavailable: %v", isolated.netErr)
+	}
+	result, err = isolated.Execute(context.Background(), "bash", []string{"-c", script})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode == 0 || strings.Contains(result.Stdout, "connected") {
+		t.Errorf("connected to the host's loopback from the isolated namespace: stdout %q", result.Stdout)
+	}
+
+	// Only a loopback interface exists inside the namespace
+	result, err = isolated.Execute(context.Background(), "bash", []string{"-c", "tail -n +3 /proc/net/dev | cut -d: -f1"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "lo" {
+		t.Errorf("interfaces in the namespace = %q, want only lo", got)
+	}
+}
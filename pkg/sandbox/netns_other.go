@@ -0,0 +1,16 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+func networkIsolationAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func probeNetworkIsolation() error {
+	return errors.New("network namespaces are not supported on this platform")
+}
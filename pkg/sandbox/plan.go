@@ -0,0 +1,228 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExecutionPlan describes what the sandbox would run for a command or
+// script, without running it
+type ExecutionPlan struct {
+	Kind string // "command" or "script"
+
+	// WorkingDir is the resolved directory the process starts in
+	WorkingDir string
+
+	// Argv is the final argument vector, including any confinement and
+	// resource limit wrappers; Program is the executable it resolves to
+	Argv    []string
+	Program string
+
+	// Interpreter and Script describe scripts. Script is the code as it
+	// would be written to the temporary file, after any wrapping.
+	Interpreter string
+	Script      string
+
+	// EnvKeys are the names of the environment variables passed, sorted
+	EnvKeys []string
+
+	Timeout         time.Duration // 0 = no timeout
+	Isolation       IsolationBackend
+	NetworkDisabled bool
+
+	// Priority is the CPU and I/O priority, e.g. "nice 10, io idle", or
+	// empty when unchanged
+	Priority string
+
+	// Blocked is set when the allowlist or blacklist would refuse the
+	// execution; Reason says why
+	Blocked bool
+	Reason  string
+
+	// Notes are other things worth knowing, e.g. a missing interpreter
+	Notes []string
+}
+
+// Plan reports what ExecuteWithOptions would run for a command. A command
+// the blacklist or allowlist would refuse yields a plan with Blocked set,
+// not an error.
+func (s *ProcessSandbox) Plan(ctx context.Context, command string, args []string, opts *ExecOptions) (*ExecutionPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	plan := &ExecutionPlan{Kind: "command", Timeout: s.effectiveLimits(s.limitsProgram(command, args), opts).Timeout}
+	if err := s.checkAllowlist(command, args); err != nil {
+		plan.block(err)
+	} else if err := s.checkCommandBlacklist(command, args); err != nil {
+		plan.block(err)
+	}
+
+	if err := s.planCommand(plan, exec.Command(command, args...), opts); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanScript reports what ExecuteScriptWithOptions would run for a script
+func (s *ProcessSandbox) PlanScript(ctx context.Context, interpreter string, script string, opts *ExecOptions) (*ExecutionPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	launcher := strings.Fields(interpreter)
+	if len(launcher) == 0 {
+		return nil, fmt.Errorf("interpreter is required")
+	}
+	language := launcher[len(launcher)-1]
+
+	plan := &ExecutionPlan{
+		Kind:        "script",
+		Interpreter: interpreter,
+		Script:      script,
+		Timeout:     s.effectiveLimits(language, opts).Timeout,
+	}
+	if err := s.checkScriptAllowlist(launcher, script); err != nil {
+		plan.block(err)
+	} else if err := s.checkScriptBlacklist(language, script); err != nil {
+		plan.block(err)
+	}
+	if !InterpreterAvailable(launcher[0]) {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%s was not found on PATH; execution would fail", launcher[0]))
+	}
+
+	if language == "python" || language == "python3" {
+		plan.Script = wrapPythonScript(script)
+		plan.Notes = append(plan.Notes, "the script is wrapped to print the value of its final expression")
+	}
+
+	if _, custom := s.config.Interpreters["go"]; interpreter == "go" && !custom {
+		plan.Notes = append(plan.Notes, "the script is built in the go scratch module and the binary runs in the working directory")
+		if err := s.planCommand(plan, exec.Command("go", "build", "-o", "main", "main.go"), opts); err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+
+	spec := s.interpreterSpec(language)
+	dir, err := s.config.scriptDirPath()
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := filepath.Join(dir, scriptFilePrefix+"*"+spec.Extension)
+	if language == wasmInterpreter {
+		plan.Notes = append(plan.Notes, "the script is a WebAssembly module path or its base64 encoding, not source text")
+		if trimmed := strings.TrimSpace(script); isWasmPath(trimmed) {
+			tmpPath = trimmed
+		}
+	}
+	args := append(append(append([]string{}, launcher[1:]...), spec.Args...), tmpPath)
+	if err := s.planCommand(plan, exec.Command(launcher[0], args...), opts); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planCommand fills in a plan from cmd prepared as it would be for running
+func (s *ProcessSandbox) planCommand(plan *ExecutionPlan, cmd *exec.Cmd, opts *ExecOptions) error {
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+	if err := s.prepareCommand(cmd, opts); err != nil {
+		return err
+	}
+	s.wrapResourceLimits(cmd)
+
+	plan.WorkingDir = cmd.Dir
+	plan.Argv = cmd.Args
+	plan.Program = cmd.Path
+	if cmd.Err != nil {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%v; execution would fail", cmd.Err))
+	}
+
+	seen := make(map[string]bool)
+	for _, kv := range cmd.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		if !seen[key] {
+			seen[key] = true
+			plan.EnvKeys = append(plan.EnvKeys, key)
+		}
+	}
+	sort.Strings(plan.EnvKeys)
+
+	plan.Isolation = BackendProcess
+	if s.confined() {
+		plan.Isolation = s.config.Isolation
+	}
+	plan.NetworkDisabled = s.NetworkDisabled()
+	if prioritySupported {
+		plan.Priority = s.config.prioritySummary()
+	}
+	return nil
+}
+
+// block marks the plan as refused by the allowlist or blacklist
+func (p *ExecutionPlan) block(err error) {
+	p.Blocked = true
+	p.Reason = err.Error()
+}
+
+// String renders the plan for people and models
+func (p *ExecutionPlan) String() string {
+	var b strings.Builder
+	if p.Blocked {
+		fmt.Fprintf(&b, "Dry run: this %s would be BLOCKED: %s\n", p.Kind, p.Reason)
+	} else {
+		fmt.Fprintf(&b, "Dry run: this %s would run as follows (nothing was executed)\n", p.Kind)
+	}
+	fmt.Fprintf(&b, "Working directory: %s\n", p.WorkingDir)
+	fmt.Fprintf(&b, "Command: %s\n", quoteArgv(p.Argv))
+	if p.Program != "" && p.Argv[0] != p.Program {
+		fmt.Fprintf(&b, "Program: %s\n", p.Program)
+	}
+	if p.Timeout > 0 {
+		fmt.Fprintf(&b, "Timeout: %s\n", p.Timeout)
+	} else {
+		b.WriteString("Timeout: none\n")
+	}
+	network := "enabled"
+	if p.NetworkDisabled {
+		network = "disabled"
+	}
+	fmt.Fprintf(&b, "Isolation: %s (network %s)\n", p.Isolation, network)
+	if p.Priority != "" {
+		fmt.Fprintf(&b, "Priority: %s\n", p.Priority)
+	}
+	fmt.Fprintf(&b, "Environment: %s\n", strings.Join(p.EnvKeys, ", "))
+	for _, note := range p.Notes {
+		fmt.Fprintf(&b, "Note: %s\n", note)
+	}
+	if p.Kind == "script" {
+		fmt.Fprintf(&b, "Interpreter: %s\nScript:\n%s", p.Interpreter, p.Script)
+		if !strings.HasSuffix(p.Script, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// quoteArgv joins arguments with spaces, quoting those that need it
+func quoteArgv(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`|&;<>()*?[]{}~#") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// dryRunResult is what Execute returns under Config.DryRun: the rendered
+// plan with exit code 0
+func dryRunResult(plan *ExecutionPlan) *ExecutionResult {
+	return &ExecutionResult{Stdout: plan.String()}
+}
@@ -0,0 +1,172 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanAllowed(t *testing.T) {
+	// Without rlimits the command is not wrapped in prlimit
+	sb := newTestSandbox(t, func(c *Config) { c.MaxOpenFiles = 0 })
+	root := sb.config.WorkingDir
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := sb.Plan(context.Background(), "bash", []string{"-c", "touch marker"}, &ExecOptions{WorkingDir: "sub", Timeout: 2 * time.Minute})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sub", "marker")); err == nil {
+		t.Error("Plan ran the command")
+	}
+
+	if plan.Blocked || plan.Reason != "" {
+		t.Errorf("allowed command planned as blocked: %s", plan.Reason)
+	}
+	if plan.Kind != "command" || plan.WorkingDir != filepath.Join(root, "sub") || plan.Timeout != 2*time.Minute {
+		t.Errorf("plan = %+v", plan)
+	}
+	if want := []string{"bash", "-c", "touch marker"}; !reflect.DeepEqual(plan.Argv, want) {
+		t.Errorf("Argv = %q, want %q", plan.Argv, want)
+	}
+	if !sort.StringsAreSorted(plan.EnvKeys) || !containsString(plan.EnvKeys, "PATH") {
+		t.Errorf("EnvKeys = %v, want sorted names including PATH", plan.EnvKeys)
+	}
+	if s := plan.String(); !strings.Contains(s, "would run as follows") || !strings.Contains(s, "Command: bash -c 'touch marker'") {
+		t.Errorf("String() =\n%s", s)
+	}
+}
+
+func TestPlanBlocked(t *testing.T) {
+	sb := newTestSandbox(t, nil)
+
+	plan, err := sb.Plan(context.Background(), "bash", []string{"-c", "sudo shutdown -h now"}, nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !plan.Blocked || !strings.Contains(plan.Reason, `"shutdown"`) {
+		t.Errorf("Blocked = %v, Reason = %q; want blocked by the shutdown pattern", plan.Blocked, plan.Reason)
+	}
+	if !strings.Contains(plan.String(), "would be BLOCKED") {
+		t.Errorf("String() =\n%s", plan.String())
+	}
+
+	plan, err = sb.PlanScript(context.Background(), "python3", "import os\nos.system('reboot')\n", nil)
+	if err != nil {
+		t.Fatalf("PlanScript: %v", err)
+	}
+	if !plan.Blocked {
+		t.Error("script running reboot not planned as blocked")
+	}
+
+	sb = newTestSandbox(t, func(c *Config) { c.CommandAllowlist = []string{"go"} })
+	plan, err = sb.Plan(context.Background(), "curl", []string{"https://example.com"}, nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !plan.Blocked || !strings.Contains(plan.Reason, ErrCommandNotAllowed.Error()) {
+		t.Errorf("Blocked = %v, Reason = %q; want blocked by the allowlist", plan.Blocked, plan.Reason)
+	}
+}
+
+func TestPlanScript(t *testing.T) {
+	sb := newTestSandbox(t, func(c *Config) { c.MaxOpenFiles = 0 })
+
+	plan, err := sb.PlanScript(context.Background(), "python3", "1 + 1", nil)
+	if err != nil {
+		t.Fatalf("PlanScript: %v", err)
+	}
+	if plan.Kind != "script" || plan.Interpreter != "python3" || plan.Blocked {
+		t.Errorf("plan = %+v", plan)
+	}
+	if plan.Script != wrapPythonScript("1 + 1") {
+		t.Errorf("Script is not the wrapped script:\n%s", plan.Script)
+	}
+	dir, _ := sb.config.scriptDirPath()
+	if last := plan.Argv[len(plan.Argv)-1]; plan.Argv[0] != "python3" || filepath.Dir(last) != dir || !strings.HasSuffix(last, ".py") {
+		t.Errorf("Argv = %q, want python3 running a script in %s", plan.Argv, dir)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Error("PlanScript created the script directory")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	sb := newTestSandbox(t, func(c *Config) { c.DryRun = true })
+	ctx := context.Background()
+
+	result, err := sb.Execute(ctx, "bash", []string{"-c", "touch marker"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode != 0 || !strings.HasPrefix(result.Stdout, "Dry run: this command would run") {
+		t.Errorf("exit %d, stdout:\n%s", result.ExitCode, result.Stdout)
+	}
+	if _, err := os.Stat(filepath.Join(sb.config.WorkingDir, "marker")); err == nil {
+		t.Error("command ran in dry-run mode")
+	}
+
+	// A blocked command's plan says so
+	result, err = sb.Execute(ctx, "shutdown", []string{"now"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.HasPrefix(result.Stdout, "Dry run: this command would be BLOCKED") {
+		t.Errorf("blocked command in dry-run mode: stdout:\n%s", result.Stdout)
+	}
+
+	if _, err := sb.StartBackground(ctx, "sleep", []string{"10"}, nil); !errors.Is(err, ErrDryRun) {
+		t.Errorf("StartBackground: err = %v, want ErrDryRun", err)
+	}
+}
+
+// TestPlanScriptInterpreters checks the command line each built-in
+// interpreter gets, which does not need the interpreters installed
+func TestPlanScriptInterpreters(t *testing.T) {
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxOpenFiles = 0
+		c.Interpreters = map[string]InterpreterSpec{
+			"ruby":   {Extension: ".rb", Args: []string{"-W0"}},
+			"elixir": {Extension: ".exs"},
+		}
+	})
+
+	tests := []struct {
+		interpreter string
+		args        []string // Between the interpreter and the script
+		extension   string
+	}{
+		{"perl", nil, ".pl"},
+		{"php", nil, ".php"},
+		{"deno", []string{"run"}, ".ts"},
+		{"bun", []string{"run"}, ".ts"},
+		{"ruby", []string{"-W0"}, ".rb"},
+		{"bundle exec ruby", []string{"exec", "ruby", "-W0"}, ".rb"},
+		{"elixir", nil, ".exs"},
+		{"unknown-lang", nil, ".tmp"},
+	}
+	for _, tt := range tests {
+		plan, err := sb.PlanScript(context.Background(), tt.interpreter, "print 1", nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.interpreter, err)
+			continue
+		}
+		argv := plan.Argv
+		program := strings.Fields(tt.interpreter)[0]
+		if len(argv) != len(tt.args)+2 || argv[0] != program || !reflect.DeepEqual(argv[1:len(argv)-1], append([]string{}, tt.args...)) {
+			t.Errorf("%s: Argv = %q, want %s %q <script>", tt.interpreter, argv, program, tt.args)
+			continue
+		}
+		if script := argv[len(argv)-1]; !strings.HasSuffix(script, tt.extension) {
+			t.Errorf("%s: script %q, want a %s file", tt.interpreter, script, tt.extension)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package sandbox
+
+// defaultAllowedEnv returns the environment variables passed through to
+// commands by default
+func defaultAllowedEnv() []string {
+	return []string{
+		"PATH",
+		"HOME",
+		"USER",
+		"LANG",
+		"LC_ALL",
+	}
+}
+
+// defaultPath is used when PATH is not passed through to commands
+func defaultPath() string {
+	return "/usr/local/bin:/usr/bin:/bin"
+}
+
+// envKeysEqual compares environment variable names, which are case
+// sensitive outside Windows
+func envKeysEqual(a, b string) bool {
+	return a == b
+}
+
+// foldEnvKey normalizes an environment variable name for comparison; names
+// are case sensitive outside Windows
+func foldEnvKey(name string) string {
+	return name
+}
+
+// probedInterpreters are the interpreters Capabilities looks for, in the
+// order they are reported
+var probedInterpreters = []string{"bash", "python3", "node", "go", "ruby", "perl", "php", "deno", "bun"}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package sandbox
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultAllowedEnv returns the environment variables passed through to
+// commands by default. Windows programs need SystemRoot, ComSpec and PATHEXT
+// to start at all, and temp/profile directories for most tooling.
+func defaultAllowedEnv() []string {
+	return []string{
+		"PATH",
+		"PATHEXT",
+		"SystemRoot",
+		"SystemDrive",
+		"windir",
+		"ComSpec",
+		"TEMP",
+		"TMP",
+		"USERPROFILE",
+		"USERNAME",
+		"APPDATA",
+		"LOCALAPPDATA",
+		"ProgramFiles",
+		"ProgramData",
+	}
+}
+
+// defaultPath is used when PATH is not passed through to commands
+func defaultPath() string {
+	root := os.Getenv("SystemRoot")
+	if root == "" {
+		root = `C:\Windows`
+	}
+	return strings.Join([]string{
+		root + `\System32`,
+		root,
+		root + `\System32\WindowsPowerShell\v1.0`,
+	}, ";")
+}
+
+// envKeysEqual compares environment variable names, which are case
+// insensitive on Windows ("Path" and "PATH" are the same variable)
+func envKeysEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// foldEnvKey normalizes an environment variable name for comparison; names
+// are case insensitive on Windows
+func foldEnvKey(name string) string {
+	return strings.ToUpper(name)
+}
+
+// probedInterpreters are the interpreters Capabilities looks for, in the
+// order they are reported
+var probedInterpreters = []string{"powershell", "python", "node", "go", "ruby", "perl", "php", "deno", "bun"}
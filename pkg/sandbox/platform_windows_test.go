@@ -0,0 +1,96 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWindowsEnvironment(t *testing.T) {
+	t.Setenv("Path", `C:\Tools;C:\Windows\System32`)
+	sb := newTestSandbox(t, nil)
+
+	env := sb.buildEnvironment()
+	var paths []string
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.EqualFold(name, "PATH") {
+			paths = append(paths, value)
+		}
+	}
+	// "Path" is the same variable as PATH, so no default is added
+	if len(paths) != 1 || paths[0] != `C:\Tools;C:\Windows\System32` {
+		t.Errorf("PATH entries = %q, want only the inherited Path", paths)
+	}
+	if root := os.Getenv("SystemRoot"); root != "" && !containsString(env, "SystemRoot="+root) {
+		t.Errorf("SystemRoot is not passed through: %q", env)
+	}
+
+	// Without PATH the default points into SystemRoot
+	sb = newTestSandbox(t, func(c *Config) { c.AllowedEnv = []string{"SystemRoot"} })
+	env = sb.buildEnvironment()
+	want := "PATH=" + defaultPath()
+	if !containsString(env, want) {
+		t.Errorf("environment %q does not contain %q", env, want)
+	}
+	if !strings.Contains(defaultPath(), `\System32`) {
+		t.Errorf("defaultPath() = %q, want System32", defaultPath())
+	}
+}
+
+func TestWindowsInterpreters(t *testing.T) {
+	sb := newTestSandbox(t, nil)
+	for _, tt := range []struct {
+		interpreter string
+		extension   string
+	}{
+		{"powershell", ".ps1"},
+		{"pwsh", ".ps1"},
+		{"cmd", ".cmd"},
+		{"python", ".py"},
+	} {
+		if spec := sb.interpreterSpec(tt.interpreter); spec.Extension != tt.extension {
+			t.Errorf("%s: extension = %q, want %q", tt.interpreter, spec.Extension, tt.extension)
+		}
+	}
+
+	for _, tt := range []struct {
+		interpreter string
+		script      string
+	}{
+		{"cmd", "@echo off\r\necho hello\r\n"},
+		{"powershell", "Write-Output 'hello'\n"},
+	} {
+		requirePrograms(t, tt.interpreter)
+		result, err := sb.ExecuteScript(context.Background(), tt.interpreter, tt.script)
+		if err != nil {
+			t.Errorf("%s: %v", tt.interpreter, err)
+			continue
+		}
+		if result.ExitCode != 0 || strings.TrimSpace(result.Stdout) != "hello" {
+			t.Errorf("%s: exit %d, stdout %q, stderr %q", tt.interpreter, result.ExitCode, result.Stdout, result.Stderr)
+		}
+	}
+}
+
+func TestWithinDirWindows(t *testing.T) {
+	root := `C:\work\project`
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{`C:\work\project\main.go`, true},
+		{`c:\WORK\Project\main.go`, true},
+		{`C:\work\project`, true},
+		{`C:\work\project2\main.go`, false},
+		{`C:\work\other`, false},
+		{`D:\work\project\main.go`, false},
+		{`\\server\share\project`, false},
+	}
+	for _, tt := range tests {
+		if got := withinDir(root, tt.path); got != tt.want {
+			t.Errorf("withinDir(%q, %q) = %v, want %v", root, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// I/O scheduling priorities for Config.IOPriority
+const (
+	// IOPriorityLow uses the lowest level of the best-effort class, so
+	// commands yield disk bandwidth to interactive programs
+	IOPriorityLow = "low"
+
+	// IOPriorityIdle only gives commands disk time when no other process
+	// wants it
+	IOPriorityIdle = "idle"
+)
+
+// ErrUnknownIOPriority is returned by NewProcessSandbox for an unrecognized
+// Config.IOPriority
+var ErrUnknownIOPriority = errors.New("unknown I/O priority")
+
+// validatePriority checks the configured I/O priority name
+func validatePriority(config *Config) error {
+	switch config.IOPriority {
+	case "", IOPriorityLow, IOPriorityIdle:
+		return nil
+	}
+	return fmt.Errorf("%w: %q (available: %s, %s)", ErrUnknownIOPriority, config.IOPriority, IOPriorityLow, IOPriorityIdle)
+}
+
+// hasPriority reports whether commands run at a changed priority
+func (c *Config) hasPriority() bool {
+	return c.NicePriority != 0 || c.IOPriority != ""
+}
+
+// prioritySummary describes the configured priority, e.g. "nice 10, io
+// idle", or "" when it is unchanged
+func (c *Config) prioritySummary() string {
+	var parts []string
+	if c.NicePriority != 0 {
+		parts = append(parts, fmt.Sprintf("nice %d", c.NicePriority))
+	}
+	if c.IOPriority != "" {
+		parts = append(parts, "io "+c.IOPriority)
+	}
+	return strings.Join(parts, ", ")
+}
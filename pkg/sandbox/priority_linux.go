@@ -0,0 +1,49 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// prioritySupported reports whether CPU and I/O priorities can be applied
+const prioritySupported = true
+
+// ioprio_set(2) arguments, which the syscall package does not export
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassShift  = 13
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	ioprioLowestLevel = 7
+)
+
+// applyPriority sets the configured nice value and I/O priority on a started
+// child process. Processes it starts afterwards inherit them. Lowering the
+// nice value below the current one needs privileges.
+func applyPriority(pid int, config *Config) error {
+	var errs []string
+
+	if config.NicePriority != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, config.NicePriority); err != nil {
+			errs = append(errs, fmt.Sprintf("nice %d: %v", config.NicePriority, err))
+		}
+	}
+
+	if config.IOPriority != "" {
+		ioprio := ioprioClassBE<<ioprioClassShift | ioprioLowestLevel
+		if config.IOPriority == IOPriorityIdle {
+			ioprio = ioprioClassIdle << ioprioClassShift
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(ioprio)); errno != 0 {
+			errs = append(errs, fmt.Sprintf("io %s: %v", config.IOPriority, errno))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to set priority: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
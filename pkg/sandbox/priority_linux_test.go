@@ -0,0 +1,81 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// niceScript prints the nice value of a process the command starts, field
+// 19 of /proc/<pid>/stat. The sleep gives the sandbox time to set the
+// priority, which it does right after the command starts.
+const niceScript = "sleep 0.2; awk '{print $19}' /proc/self/stat"
+
+// ownNice returns the nice value of the test process
+func ownNice(t *testing.T) string {
+	t.Helper()
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		t.Skipf("cannot read /proc: %v", err)
+	}
+	// Fields after the parenthesized command name start at the state, 3
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return fields[19-3]
+}
+
+func TestNicePriority(t *testing.T) {
+	requirePrograms(t, "bash", "sleep", "awk")
+	baseline := ownNice(t)
+	if baseline != "0" && os.Geteuid() != 0 {
+		t.Skipf("tests run at nice %s; setting nice 10 may need privileges", baseline)
+	}
+
+	sb := newTestSandbox(t, func(c *Config) { c.NicePriority = 10 })
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", niceScript})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "10" {
+		t.Errorf("child nice value = %q, want 10 (stderr %q)", got, result.Stderr)
+	}
+
+	plan, err := sb.Plan(context.Background(), "bash", []string{"-c", niceScript}, nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Priority != "nice 10" {
+		t.Errorf("plan Priority = %q, want %q", plan.Priority, "nice 10")
+	}
+
+	// The default leaves the priority unchanged
+	sb = newTestSandbox(t, nil)
+	result, err = sb.Execute(context.Background(), "bash", []string{"-c", niceScript})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != baseline {
+		t.Errorf("child nice value = %q with the default config, want the inherited %s", got, baseline)
+	}
+}
+
+func TestIOPriority(t *testing.T) {
+	requirePrograms(t, "bash", "sleep", "ionice")
+
+	sb := newTestSandbox(t, func(c *Config) { c.IOPriority = IOPriorityIdle })
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", "sleep 0.2; ionice -p $$"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "idle" {
+		t.Errorf("ionice = %q, want idle (stderr %q)", got, result.Stderr)
+	}
+
+	_, err = NewProcessSandbox(&Config{WorkingDir: t.TempDir(), IOPriority: "realtime"})
+	if !errors.Is(err, ErrUnknownIOPriority) {
+		t.Errorf("NewProcessSandbox: err = %v, want ErrUnknownIOPriority", err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// prioritySupported reports whether CPU and I/O priorities can be applied
+const prioritySupported = false
+
+func applyPriority(pid int, config *Config) error {
+	return errors.New("process priorities are not supported on this platform")
+}
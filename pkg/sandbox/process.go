@@ -7,126 +7,327 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/looper-ai/looper/pkg/truncate"
 )
 
-// ErrBlacklistedCommand is returned when a command matches a blacklist pattern
-var ErrBlacklistedCommand = errors.New("command blocked by blacklist")
+var (
+	// ErrBlacklistedCommand is returned when a command matches a blacklist pattern
+	ErrBlacklistedCommand = errors.New("command blocked by blacklist")
+
+	// ErrInvalidBlacklistPattern is returned by NewProcessSandbox when a
+	// blacklist pattern cannot be compiled
+	ErrInvalidBlacklistPattern = errors.New("invalid blacklist pattern")
+
+	// ErrInvalidSecretPattern is returned by NewProcessSandbox when a secret
+	// redaction pattern cannot be compiled
+	ErrInvalidSecretPattern = errors.New("invalid secret pattern")
+
+	// ErrCommandNotAllowed is returned in allowlist mode when a command runs
+	// a program that is not on the allowlist
+	ErrCommandNotAllowed = errors.New("command not in allowlist")
+
+	// ErrExecutionTimeout is returned alongside the partial result when a
+	// command exceeds its timeout
+	ErrExecutionTimeout = errors.New("execution timed out")
+
+	// ErrInvalidWorkingDir is returned when a per-call working directory
+	// escapes the sandbox or does not exist
+	ErrInvalidWorkingDir = errors.New("invalid working directory")
+
+	// ErrInterpreterNotFound is returned by ExecuteScript when the
+	// interpreter or launcher is not installed
+	ErrInterpreterNotFound = errors.New("interpreter not installed")
+
+	// ErrExecutionCancelled is returned alongside the partial result when the
+	// caller's context is cancelled while a command is running
+	ErrExecutionCancelled = errors.New("execution cancelled")
+
+	// ErrPTYUnavailable is returned when a command asks for a pseudo-terminal
+	// and none can be allocated
+	ErrPTYUnavailable = errors.New("pseudo-terminal unavailable")
+
+	// ErrSandboxClosed is returned by StartBackground after Close
+	ErrSandboxClosed = errors.New("sandbox closed")
+
+	// ErrDryRun is returned by StartBackground when Config.DryRun is set
+	ErrDryRun = errors.New("dry run: background processes are not started")
+
+	// ErrTooManyBackgroundProcesses is returned by StartBackground when
+	// Config.MaxBackgroundProcesses are already running
+	ErrTooManyBackgroundProcesses = errors.New("too many background processes")
+)
 
 // ProcessSandbox implements Sandbox using process-level isolation
 type ProcessSandbox struct {
-	config *Config
+	// mu guards config against runtime mutation through SetEnv and UnsetEnv
+	mu             sync.RWMutex
+	config         *Config
+	blacklist      []blacklistMatcher
+	secretPatterns []*regexp.Regexp
+
+	netOnce sync.Once
+	netErr  error
+
+	// Go scripts share a persistent scratch module; goMu serializes its
+	// builds and goEnv caches the go cache locations. goCacheDir holds the
+	// sandbox's own caches when confinement hides the host's.
+	goMu       sync.Mutex
+	goEnvOnce  sync.Once
+	goEnv      []string
+	goCacheDir string
+
+	// Confinement backend, probed on first use
+	confineOnce sync.Once
+	confinePath string
+	confineErr  error
+	scriptDir   string // Created on first use; guarded by mu
+
+	// prlimit(1), which applies rlimits before exec, located on first use
+	prlimitOnce sync.Once
+	prlimitPath string
+
+	// auditMu serializes audit log writes; auditPrev is the hash of the
+	// last entry written, which chains entries together
+	auditMu   sync.Mutex
+	auditPrev string
+
+	// slots bounds concurrent executions when MaxConcurrentExecutions is
+	// set; inFlight counts running executions either way
+	slots    chan struct{}
+	inFlight atomic.Int64
+
+	// Background processes, stopped by Close
+	procMu     sync.Mutex
+	procs      map[string]*backgroundProcess
+	nextProcID int
+	closed     bool
 }
 
-// NewProcessSandbox creates a new process-based sandbox
-func NewProcessSandbox(config *Config) *ProcessSandbox {
+// NewProcessSandbox creates a new process-based sandbox. Blacklist patterns
+// are compiled up front; an error lists every pattern that is invalid.
+func NewProcessSandbox(config *Config) (*ProcessSandbox, error) {
 	if config == nil {
 		config = DefaultConfig(".")
 	}
-	return &ProcessSandbox{
-		config: config,
+	if err := validateIsolation(config.Isolation); err != nil {
+		return nil, err
+	}
+	if err := validatePriority(config); err != nil {
+		return nil, err
+	}
+	blacklist, err := compileBlacklist(config.CommandBlacklist)
+	if err != nil {
+		return nil, err
+	}
+	secretPatterns, err := compileSecretPatterns(config.SecretPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if config.StaleScriptAge > 0 {
+		if dir, err := config.scriptDirPath(); err == nil {
+			sweepStaleScripts(dir, config.StaleScriptAge)
+		}
+	}
+	if config.hasResourceLimits() && !resourceLimitsSupported {
+		log.Printf("sandbox: resource limits are not supported on this platform; commands will run unlimited")
+	}
+	if config.hasPriority() && !prioritySupported {
+		log.Printf("sandbox: process priorities are not supported on this platform; commands will run at normal priority")
+	}
+	s := &ProcessSandbox{
+		config:         config,
+		blacklist:      blacklist,
+		secretPatterns: secretPatterns,
+		auditPrev:      config.AuditPrevHash,
 	}
+	if config.MaxConcurrentExecutions > 0 {
+		s.slots = make(chan struct{}, config.MaxConcurrentExecutions)
+	}
+	return s, nil
+}
+
+// SetEnv sets a custom environment variable for all subsequent executions
+func (s *ProcessSandbox) SetEnv(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.CustomEnv == nil {
+		s.config.CustomEnv = make(map[string]string)
+	}
+	s.config.CustomEnv[key] = value
+}
+
+// UnsetEnv removes a custom environment variable set with SetEnv or the
+// sandbox config
+func (s *ProcessSandbox) UnsetEnv(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.config.CustomEnv, key)
 }
 
 func (s *ProcessSandbox) WorkingDir() string {
 	return s.config.WorkingDir
 }
 
-// checkBlacklist checks if the command or script contains blacklisted patterns
-func (s *ProcessSandbox) checkBlacklist(input string) error {
-	if len(s.config.CommandBlacklist) == 0 {
-		return nil
+// NetworkDisabled reports whether commands run without network access.
+// Namespace support is probed on first use and a warning is logged if the
+// requested isolation cannot be enforced.
+func (s *ProcessSandbox) NetworkDisabled() bool {
+	if !s.config.DisableNetwork {
+		return false
 	}
+	s.netOnce.Do(func() {
+		s.netErr = probeNetworkIsolation()
+		if s.netErr != nil {
+			log.Printf("WARNING: sandbox: network isolation requested but unavailable (%v); commands WILL have network access", s.netErr)
+		}
+	})
+	return s.netErr == nil
+}
 
-	// Normalize input for checking
-	normalizedInput := strings.ToLower(input)
-	// Remove extra whitespace
-	normalizedInput = regexp.MustCompile(`\s+`).ReplaceAllString(normalizedInput, " ")
-
-	for _, pattern := range s.config.CommandBlacklist {
-		normalizedPattern := strings.ToLower(pattern)
-
-		// Convert glob-style wildcards to regex
-		// Escape regex special chars except *
-		escaped := regexp.QuoteMeta(normalizedPattern)
-		// Convert * back to regex .*
-		regexPattern := strings.ReplaceAll(escaped, `\*`, `.*`)
+func (s *ProcessSandbox) Execute(ctx context.Context, command string, args []string) (*ExecutionResult, error) {
+	return s.ExecuteWithOptions(ctx, command, args, nil)
+}
 
-		re, err := regexp.Compile(regexPattern)
+// ExecuteWithOptions runs a command with per-call options. Every attempt,
+// including blocked ones, is recorded in the audit log if one is configured.
+func (s *ProcessSandbox) ExecuteWithOptions(ctx context.Context, command string, args []string, opts *ExecOptions) (*ExecutionResult, error) {
+	if s.config.DryRun {
+		plan, err := s.Plan(ctx, command, args, opts)
 		if err != nil {
-			// If pattern is invalid, do simple substring match
-			if strings.Contains(normalizedInput, normalizedPattern) {
-				return fmt.Errorf("%w: matches pattern %q", ErrBlacklistedCommand, pattern)
-			}
-			continue
+			return nil, err
 		}
+		return dryRunResult(plan), nil
+	}
+	start := time.Now()
+	result, err := s.executeCommand(ctx, command, args, opts)
+	s.auditCommand("command", start, command, args, opts, result, err)
+	return result, err
+}
 
-		if re.MatchString(normalizedInput) {
-			return fmt.Errorf("%w: matches pattern %q", ErrBlacklistedCommand, pattern)
-		}
+func (s *ProcessSandbox) executeCommand(ctx context.Context, command string, args []string, opts *ExecOptions) (*ExecutionResult, error) {
+	if err := s.checkAllowlist(command, args); err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	if err := s.checkCommandBlacklist(command, args); err != nil {
+		return nil, err
+	}
 
-func (s *ProcessSandbox) Execute(ctx context.Context, command string, args []string) (*ExecutionResult, error) {
-	// Build full command string for blacklist checking
-	fullCommand := command + " " + strings.Join(args, " ")
-	if err := s.checkBlacklist(fullCommand); err != nil {
+	release, err := s.acquireSlot(ctx)
+	if err != nil {
 		return nil, err
 	}
+	defer release()
 
 	// Apply timeout
-	if s.config.Timeout > 0 {
+	limits := s.effectiveLimits(s.limitsProgram(command, args), opts)
+	if limits.Timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
 		defer cancel()
 	}
 
 	cmd := exec.CommandContext(ctx, command, args...)
-	return s.runCommand(ctx, cmd)
+	return s.runCommand(ctx, cmd, opts, limits)
 }
 
 func (s *ProcessSandbox) ExecuteScript(ctx context.Context, interpreter string, script string) (*ExecutionResult, error) {
+	return s.ExecuteScriptWithOptions(ctx, interpreter, script, nil)
+}
+
+// ExecuteScriptWithOptions runs a script with per-call options. Every
+// attempt, including blocked ones, is recorded in the audit log if one is
+// configured.
+func (s *ProcessSandbox) ExecuteScriptWithOptions(ctx context.Context, interpreter string, script string, opts *ExecOptions) (*ExecutionResult, error) {
+	if s.config.DryRun {
+		plan, err := s.PlanScript(ctx, interpreter, script, opts)
+		if err != nil {
+			return nil, err
+		}
+		return dryRunResult(plan), nil
+	}
+	start := time.Now()
+	result, err := s.executeScript(ctx, interpreter, script, opts)
+	s.auditScript(start, interpreter, script, opts, result, err)
+	return result, err
+}
+
+func (s *ProcessSandbox) executeScript(ctx context.Context, interpreter string, script string, opts *ExecOptions) (*ExecutionResult, error) {
+	// The interpreter may be a launcher command such as "bundle exec ruby";
+	// its last word names the language
+	launcher := strings.Fields(interpreter)
+	if len(launcher) == 0 {
+		return nil, fmt.Errorf("interpreter is required")
+	}
+	language := launcher[len(launcher)-1]
+
+	if err := s.checkScriptAllowlist(launcher, script); err != nil {
+		return nil, err
+	}
+
 	// Check script content against blacklist
-	if err := s.checkBlacklist(script); err != nil {
+	if err := s.checkScriptBlacklist(language, script); err != nil {
 		return nil, err
 	}
 
+	if !InterpreterAvailable(launcher[0]) {
+		return nil, fmt.Errorf("%w: %s was not found on PATH", ErrInterpreterNotFound, launcher[0])
+	}
+
+	release, err := s.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Apply timeout
-	if s.config.Timeout > 0 {
+	limits := s.effectiveLimits(language, opts)
+	if limits.Timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
 		defer cancel()
 	}
 
 	// For Python, wrap the script to behave like a REPL (auto-print expressions)
-	if interpreter == "python" || interpreter == "python3" {
+	if language == "python" || language == "python3" {
 		script = wrapPythonScript(script)
 	}
+	if language == "php" {
+		script = wrapPHPScript(script)
+	}
+
+	// Plain go scripts build in a persistent module with warm caches unless
+	// the go interpreter has been overridden
+	if _, custom := s.config.Interpreters["go"]; interpreter == "go" && !custom {
+		return s.executeGoScript(ctx, script, opts, limits)
+	}
+
+	// WebAssembly scripts are binary modules rather than source text
+	if language == wasmInterpreter {
+		return s.executeWasm(ctx, launcher, script, opts, limits)
+	}
+
+	spec := s.interpreterSpec(language)
 
 	// Create temporary script file
-	tmpDir := os.TempDir()
-	var ext string
-	switch interpreter {
-	case "python", "python3":
-		ext = ".py"
-	case "node", "nodejs":
-		ext = ".js"
-	case "bash", "sh":
-		ext = ".sh"
-	case "go":
-		ext = ".go"
-	default:
-		ext = ".tmp"
-	}
-
-	tmpFile, err := os.CreateTemp(tmpDir, "looper-script-*"+ext)
+	tmpDir, err := s.scriptTempDir()
+	if err != nil {
+		return nil, err
+	}
+	tmpFile, err := os.CreateTemp(tmpDir, scriptFilePrefix+"*"+spec.Extension)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp script: %w", err)
 	}
@@ -139,81 +340,290 @@ func (s *ProcessSandbox) ExecuteScript(ctx context.Context, interpreter string,
 	}
 	tmpFile.Close()
 
-	// Make script executable for shell scripts
-	if interpreter == "bash" || interpreter == "sh" {
-		os.Chmod(tmpPath, 0755)
+	// Make the script executable by its owner if the interpreter wants it
+	// (Windows has no mode bits)
+	if spec.Executable && runtime.GOOS != "windows" {
+		os.Chmod(tmpPath, 0700)
 	}
 
-	// Build command based on interpreter
-	var cmd *exec.Cmd
-	switch interpreter {
-	case "go":
-		cmd = exec.CommandContext(ctx, "go", "run", tmpPath)
-	default:
-		cmd = exec.CommandContext(ctx, interpreter, tmpPath)
-	}
+	// Run as: launcher [launcher args...] [spec args...] script
+	args := append(append(append([]string{}, launcher[1:]...), spec.Args...), tmpPath)
+	cmd := exec.CommandContext(ctx, launcher[0], args...)
 
-	return s.runCommand(ctx, cmd)
+	return s.runCommand(ctx, cmd, opts, limits)
 }
 
-func (s *ProcessSandbox) runCommand(ctx context.Context, cmd *exec.Cmd) (*ExecutionResult, error) {
-	// Set working directory
-	absWorkDir, err := filepath.Abs(s.config.WorkingDir)
-	if err != nil {
-		return nil, fmt.Errorf("invalid working directory: %w", err)
+// runCommand runs a prepared command whose context already carries
+// limits.Timeout, capturing at most limits.MaxOutputBytes of each stream
+func (s *ProcessSandbox) runCommand(ctx context.Context, cmd *exec.Cmd, opts *ExecOptions, limits Limits) (*ExecutionResult, error) {
+	if opts == nil {
+		opts = &ExecOptions{}
 	}
-	cmd.Dir = absWorkDir
-
-	// Set up environment
-	env := s.buildEnvironment()
-	cmd.Env = env
+	if opts.PTY != nil && opts.Stdin != "" {
+		return nil, fmt.Errorf("stdin cannot be combined with a pseudo-terminal")
+	}
+	// A pseudo-terminal already merges the streams into stdout
+	combine := opts.CombineOutput && opts.PTY == nil
+	if err := s.prepareCommand(cmd, opts); err != nil {
+		return nil, err
+	}
+	limitedAtExec := s.wrapResourceLimits(cmd)
 
 	// Set up output capture with size limits
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &limitedWriter{w: &stdout, limit: s.config.MaxOutputBytes}
-	cmd.Stderr = &limitedWriter{w: &stderr, limit: s.config.MaxOutputBytes}
+	stdout := newLimitedWriter(limits.MaxOutputBytes)
+	stderr := newLimitedWriter(limits.MaxOutputBytes)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if combine {
+		// exec gives the command a single pipe for both streams when they
+		// are the same writer, so output is captured in the order written
+		cmd.Stderr = stdout
+	}
+
+	// Secrets are redacted from both streamed and captured output
+	redactor := s.newRedactor()
+
+	// Tee output to the streaming callback, if any. Redaction comes before
+	// the output is split into lines, so a secret that spans lines or
+	// writes is still caught.
+	var streams []*lineWriter
+	var redacting []*redactWriter
+	if opts.OnOutput != nil {
+		var mu sync.Mutex
+		onOutput := opts.OnOutput
+		if opts.PTY != nil && !opts.PTY.KeepANSI {
+			onOutput = func(stream string, chunk []byte) {
+				opts.OnOutput(stream, []byte(stripTerminal(string(chunk))))
+			}
+		}
+		stdoutLines := &lineWriter{stream: "stdout", fn: onOutput, mu: &mu}
+		stderrLines := &lineWriter{stream: "stderr", fn: onOutput, mu: &mu}
+		streams = []*lineWriter{stdoutLines, stderrLines}
+		var stdoutStream, stderrStream io.Writer = stdoutLines, stderrLines
+		if redactor != nil {
+			stdoutRedact, stderrRedact := redactor.writer(stdoutLines), redactor.writer(stderrLines)
+			redacting = []*redactWriter{stdoutRedact, stderrRedact}
+			stdoutStream, stderrStream = stdoutRedact, stderrRedact
+		}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdoutStream)
+		if combine {
+			cmd.Stderr = cmd.Stdout
+		} else {
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, stderrStream)
+		}
+	}
+
+	// Track the latest diagnostic line for progress reports
+	var tracked *lastLineWriter
+	if opts.OnProgress != nil {
+		tracked = &lastLineWriter{}
+		if combine {
+			w := io.MultiWriter(cmd.Stdout, tracked)
+			cmd.Stdout, cmd.Stderr = w, w
+		} else {
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, tracked)
+		}
+	}
+
+	// Attach a pseudo-terminal; its output feeds the stdout writers
+	var terminal *ptySession
+	output := cmd.Stdout
+	if opts.PTY != nil {
+		var err error
+		if terminal, err = openPTY(cmd, opts.PTY); err != nil {
+			return nil, err
+		}
+	}
+
+	// A pseudo-terminal already puts the command in its own session and
+	// process group
+	if opts.PTY == nil {
+		setProcessGroup(cmd)
+	}
+	stop := s.setGracefulCancel(ctx, cmd)
 
 	// Run command
 	startTime := time.Now()
-	err = cmd.Run()
+	err := cmd.Start()
+	if terminal != nil {
+		if err != nil {
+			terminal.abort()
+		} else {
+			terminal.started(output)
+		}
+	}
+	if err == nil {
+		if resourceLimitsSupported && s.config.hasResourceLimits() && !limitedAtExec {
+			if limitErr := applyResourceLimits(cmd.Process.Pid, s.config); limitErr != nil {
+				log.Printf("sandbox: %v", limitErr)
+			}
+		}
+		if prioritySupported && s.config.hasPriority() {
+			if prioErr := applyPriority(cmd.Process.Pid, s.config); prioErr != nil {
+				log.Printf("WARNING: sandbox: %v", prioErr)
+			}
+		}
+		waitProgress := func() {}
+		if opts.OnProgress != nil {
+			waitProgress = reportProgress(opts, startTime, tracked, redactor, stop.exited)
+		}
+		err = cmd.Wait()
+		close(stop.exited)
+		waitProgress()
+		if terminal != nil {
+			terminal.finish()
+		}
+	}
 	duration := time.Since(startTime)
 
+	// Deliver held-back and trailing partial lines before the result is
+	// returned
+	for _, rw := range redacting {
+		rw.Flush()
+	}
+	for _, lw := range streams {
+		lw.Flush()
+	}
+
+	captured := stdout.String()
+	if opts.PTY != nil && !opts.PTY.KeepANSI {
+		captured = stripTerminal(captured)
+	}
+
 	result := &ExecutionResult{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
+		Stdout:   redactor.redact(limitLines(captured, s.config.MaxOutputLines)),
+		Stderr:   redactor.redact(limitLines(stderr.String(), s.config.MaxOutputLines)),
 		Duration: duration,
+		Limits:   limits,
+
+		StdoutTruncated:    stdout.dropped > 0,
+		StdoutDroppedBytes: stdout.dropped,
+		StdoutTotalBytes:   stdout.total,
+		StderrTruncated:    stderr.dropped > 0,
+		StderrDroppedBytes: stderr.dropped,
+		StderrTotalBytes:   stderr.total,
+	}
+	if combine {
+		result.Combined, result.Stdout = result.Stdout, ""
+		result.CombinedTruncated, result.StdoutTruncated = result.StdoutTruncated, false
+		result.CombinedDroppedBytes, result.StdoutDroppedBytes = result.StdoutDroppedBytes, 0
+		result.CombinedTotalBytes, result.StdoutTotalBytes = result.StdoutTotalBytes, 0
 	}
 
-	// Check for timeout
-	if ctx.Err() == context.DeadlineExceeded {
+	result.ResourceUsage = collectResourceUsage(cmd.ProcessState)
+	result.LimitExceeded = detectLimitExceeded(cmd.ProcessState, result, s.config)
+
+	result.StoppedBy = stop.signal()
+	result.Signal = exitSignal(cmd.ProcessState)
+
+	// Check for timeout or caller cancellation
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
 		result.TimedOut = true
 		result.ExitCode = -1
-		return result, nil
+		return result, ErrExecutionTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		result.ExitCode = -1
+		return result, ErrExecutionCancelled
 	}
 
 	// Get exit code
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
+			// -1 when a signal ended the process
 			result.ExitCode = exitErr.ExitCode()
 		} else {
 			return nil, fmt.Errorf("execution failed: %w", err)
 		}
 	}
+	if s.config.Isolation == BackendGVisor && s.confined() {
+		result.IsolationFailure = detectGVisorFailure(result)
+	}
 
 	return result, nil
 }
 
-func (s *ProcessSandbox) buildEnvironment() []string {
-	env := make([]string, 0)
+// prepareCommand sets the working directory, environment, confinement and
+// stdin of cmd
+func (s *ProcessSandbox) prepareCommand(cmd *exec.Cmd, opts *ExecOptions) error {
+	// Set working directory
+	absWorkDir, err := s.ResolveWorkingDir(opts.WorkingDir)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = absWorkDir
 
-	// Copy allowed environment variables
-	for _, key := range s.config.AllowedEnv {
-		if val, ok := os.LookupEnv(key); ok {
-			env = append(env, key+"="+val)
+	// Set up environment; variables already on cmd are added last so they
+	// take precedence
+	env := append(s.buildEnvironment(), cmd.Env...)
+	cmd.Env = env
+
+	// Under confinement, network isolation is part of the backend's setup
+	if s.confined() && cmd.Err == nil {
+		if err := s.wrapConfined(cmd); err != nil {
+			return err
 		}
+	} else if s.NetworkDisabled() {
+		cmd.SysProcAttr = networkIsolationAttr()
+	}
+
+	// Pipe stdin; exec closes the pipe once the reader is drained so the
+	// child sees EOF
+	if opts.Stdin != "" {
+		cmd.Stdin = strings.NewReader(opts.Stdin)
+	}
+	return nil
+}
+
+// ResolveWorkingDir returns the absolute directory a command runs in. An
+// absolute override is used as given and a relative one is resolved against
+// the sandbox working directory; either way it must not escape it.
+func (s *ProcessSandbox) ResolveWorkingDir(override string) (string, error) {
+	root, err := filepath.Abs(s.config.WorkingDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidWorkingDir, err)
+	}
+	if override == "" {
+		return root, nil
 	}
 
-	// Add custom environment variables
+	dir := override
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	if !withinDir(root, dir) {
+		return "", fmt.Errorf("%w: %s is outside the sandbox", ErrInvalidWorkingDir, override)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %s is not a directory", ErrInvalidWorkingDir, override)
+	}
+
+	return dir, nil
+}
+
+// withinDir reports whether path is root or a descendant of it. Both paths
+// must be absolute and clean. On Windows filepath.Rel fails for a path on
+// another drive and ignores case, so drive letters are confined too.
+func withinDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (s *ProcessSandbox) buildEnvironment() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Copy allowed environment variables
+	env := s.config.parentEnvironment()
+
+	// Add custom environment variables, which are set even if denied
 	for key, val := range s.config.CustomEnv {
 		env = append(env, key+"="+val)
 	}
@@ -221,74 +631,273 @@ func (s *ProcessSandbox) buildEnvironment() []string {
 	// Ensure PATH includes common binary locations
 	hasPath := false
 	for _, e := range env {
-		if strings.HasPrefix(e, "PATH=") {
+		if key, _, _ := strings.Cut(e, "="); envKeysEqual(key, "PATH") {
 			hasPath = true
 			break
 		}
 	}
 	if !hasPath {
-		env = append(env, "PATH=/usr/local/bin:/usr/bin:/bin")
+		env = append(env, "PATH="+defaultPath())
 	}
 
 	return env
 }
 
-// limitedWriter wraps a writer and limits the amount of data written
+// stopState records how a command was stopped after its context ended
+type stopState struct {
+	exited chan struct{} // Closed once the command has been waited for
+
+	mu  sync.Mutex
+	sig string
+}
+
+func (st *stopState) setSignal(sig string) {
+	st.mu.Lock()
+	st.sig = sig
+	st.mu.Unlock()
+}
+
+// signal returns "SIGTERM" or "SIGKILL" if the command was stopped, or ""
+func (st *stopState) signal() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.sig
+}
+
+// setGracefulCancel replaces the immediate SIGKILL exec.CommandContext sends
+// when the context ends. On a timeout the process group gets SIGTERM and
+// Config.KillGrace to exit, so it can flush output and clean up, before it
+// is killed; output from the grace period is captured as usual. A caller
+// cancellation, or a zero KillGrace, kills the group at once.
+func (s *ProcessSandbox) setGracefulCancel(ctx context.Context, cmd *exec.Cmd) *stopState {
+	stop := &stopState{exited: make(chan struct{})}
+	if cmd.Cancel == nil {
+		return stop
+	}
+
+	grace := s.config.KillGrace
+	if !gracefulStopSupported {
+		grace = 0
+	}
+	cmd.Cancel = func() error {
+		if grace <= 0 || errors.Is(ctx.Err(), context.Canceled) {
+			stop.setSignal("SIGKILL")
+			return killProcessGroup(cmd.Process)
+		}
+		stop.setSignal("SIGTERM")
+		go func() {
+			select {
+			case <-stop.exited:
+			case <-time.After(grace):
+				stop.setSignal("SIGKILL")
+				killProcessGroup(cmd.Process)
+			}
+		}()
+		return terminateProcessGroup(cmd.Process)
+	}
+	// Stop waiting for output pipes held open by processes that escaped the
+	// group shortly after the kill
+	cmd.WaitDelay = grace + time.Second
+	return stop
+}
+
+// limitedWriter captures at most limit bytes of output. It keeps the
+// beginning of the stream plus a rolling window of its end, since failures
+// usually print last, and counts the bytes dropped in between.
 type limitedWriter struct {
-	w       io.Writer
-	limit   int64
-	written int64
+	head      bytes.Buffer
+	headLimit int64
+	tail      *byteRing
+	dropped   int64
+	total     int64
+}
+
+func newLimitedWriter(limit int64) *limitedWriter {
+	if limit < 0 {
+		limit = 0
+	}
+	tailLimit := limit / 4
+	return &limitedWriter{headLimit: limit - tailLimit, tail: newByteRing(int(tailLimit))}
 }
 
 func (lw *limitedWriter) Write(p []byte) (n int, err error) {
-	if lw.written >= lw.limit {
-		return len(p), nil // Silently discard
+	n = len(p) // Report full length written to avoid breaking callers
+	lw.total += int64(n)
+
+	if remaining := lw.headLimit - int64(lw.head.Len()); remaining > 0 {
+		if int64(len(p)) <= remaining {
+			lw.head.Write(p)
+			return n, nil
+		}
+		lw.head.Write(p[:remaining])
+		p = p[remaining:]
 	}
 
-	remaining := lw.limit - lw.written
-	if int64(len(p)) > remaining {
-		p = p[:remaining]
+	lw.dropped += int64(lw.tail.write(p))
+	return n, nil
+}
+
+// String returns the captured output, with a marker where bytes were dropped
+func (lw *limitedWriter) String() string {
+	if lw.dropped == 0 {
+		return lw.head.String() + string(lw.tail.bytes())
 	}
+	notice := truncate.Notice(lw.total-lw.dropped, lw.total, "bytes")
+	return fmt.Sprintf("%s\n%s\n%s", lw.head.String(), notice, lw.tail.bytes())
+}
 
-	n, err = lw.w.Write(p)
-	lw.written += int64(n)
-	return len(p), err // Report full length written to avoid breaking callers
+// FormatBytes renders a byte count for humans, e.g. "3.4MB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
-// wrapPythonScript wraps Python code to auto-print expression results like a REPL
-func wrapPythonScript(script string) string {
-	// Check if the script is simple enough to benefit from REPL-style output
-	// Multi-line scripts or scripts with explicit prints don't need wrapping
-	trimmed := strings.TrimSpace(script)
-
-	// If the script contains print statements or is multi-line with statements,
-	// don't wrap it - execute as-is
-	if strings.Contains(trimmed, "print(") ||
-		strings.Contains(trimmed, "print ") ||
-		strings.Contains(trimmed, "import ") ||
-		strings.Contains(trimmed, "def ") ||
-		strings.Contains(trimmed, "class ") ||
-		strings.Contains(trimmed, "if ") ||
-		strings.Contains(trimmed, "for ") ||
-		strings.Contains(trimmed, "while ") ||
-		strings.Contains(trimmed, "with ") ||
-		strings.Contains(trimmed, "try:") {
+// limitLines keeps the first and last lines of output when it exceeds max
+// lines, replacing the middle with a marker. It runs after the byte limit, so
+// the two limits compose: bytes bound capture, lines bound what is returned.
+func limitLines(output string, max int) string {
+	if max <= 0 || output == "" {
+		return output
+	}
+
+	trailingNewline := strings.HasSuffix(output, "\n")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) <= max {
+		return output
+	}
+
+	head := (max + 1) / 2
+	tail := max - head
+
+	kept := make([]string, 0, max+1)
+	kept = append(kept, lines[:head]...)
+	kept = append(kept, truncate.Notice(int64(head+tail), int64(len(lines)), "lines"))
+	kept = append(kept, lines[len(lines)-tail:]...)
+
+	limited := strings.Join(kept, "\n")
+	if trailingNewline {
+		limited += "\n"
+	}
+	return limited
+}
+
+// lineWriter buffers output and hands complete lines to a callback. The
+// mutex is shared between a command's stdout and stderr writers so the
+// callback is never invoked concurrently.
+type lineWriter struct {
+	stream string
+	fn     func(stream string, chunk []byte)
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := make([]byte, i+1)
+		copy(line, lw.buf[:i+1])
+		lw.buf = lw.buf[i+1:]
+		lw.fn(lw.stream, line)
+	}
+	return len(p), nil
+}
+
+// Flush delivers any buffered partial line
+func (lw *lineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if len(lw.buf) > 0 {
+		lw.fn(lw.stream, lw.buf)
+		lw.buf = nil
+	}
+}
+
+// wrapPHPScript adds the opening <?php tag that PHP code needs to run
+// rather than be echoed as text, unless the script already starts with one.
+// The tag goes after a #! line, which the PHP CLI only skips on line one.
+func wrapPHPScript(script string) string {
+	if hasPHPOpenTag(script) {
 		return script
 	}
+	shebang := shebangLine(script)
+	rest := script[len(shebang):]
+	if shebang != "" && !strings.HasSuffix(shebang, "\n") {
+		shebang += "\n"
+	}
+	return shebang + "<?php\n" + rest
+}
+
+// hasPHPOpenTag reports whether a script starts with <?php, ignoring a #!
+// line and leading whitespace
+func hasPHPOpenTag(script string) bool {
+	trimmed := strings.TrimLeft(script[len(shebangLine(script)):], " \t\r\n")
+	return len(trimmed) >= 5 && strings.EqualFold(trimmed[:5], "<?php")
+}
 
-	// For simple expressions, wrap to auto-print the result
+// shebangLine returns the #! line a script starts with, including its
+// newline, or "" if it has none
+func shebangLine(script string) string {
+	if !strings.HasPrefix(script, "#!") {
+		return ""
+	}
+	if i := strings.IndexByte(script, '\n'); i >= 0 {
+		return script[:i+1]
+	}
+	return script
+}
+
+// wrapPythonScript wraps Python code to print the value of a final
+// expression statement like a REPL. The wrapper parses the script with the
+// ast module, runs everything but the last statement, then evaluates that
+// statement and prints its repr if it is an expression with a value other
+// than None. The code runs in the __main__ namespace, so
+// `if __name__ == "__main__":` and pickling of its functions work as usual.
+func wrapPythonScript(script string) string {
 	// Use base64 to safely embed the code
 	encoded := base64.StdEncoding.EncodeToString([]byte(script))
 
-	wrapper := fmt.Sprintf(`import base64
-_code = base64.b64decode("%s").decode("utf-8")
-try:
-    _result = eval(compile(_code, '<input>', 'eval'))
-    if _result is not None:
-        print(repr(_result))
-except SyntaxError:
-    exec(compile(_code, '<input>', 'exec'))
+	return fmt.Sprintf(`def _looper_run():
+    import ast, base64, linecache, sys, traceback
+    code = base64.b64decode("%s").decode("utf-8")
+    # Let tracebacks show the script's source lines
+    linecache.cache["<input>"] = (len(code), None, code.splitlines(True), "<input>")
+    namespace = globals()
+    del namespace["_looper_run"]
+    try:
+        tree = ast.parse(code, "<input>")
+        last = None
+        if tree.body and isinstance(tree.body[-1], ast.Expr):
+            last = ast.Expression(tree.body.pop().value)
+        exec(compile(tree, "<input>", "exec"), namespace)
+        if last is not None:
+            result = eval(compile(last, "<input>", "eval"), namespace)
+            if result is not None:
+                print(repr(result))
+    except SystemExit:
+        raise
+    except BaseException as e:
+        # Report the error without the wrapper's frames
+        tb = e.__traceback__
+        while tb is not None and tb.tb_frame.f_code.co_filename != "<input>":
+            tb = tb.tb_next
+        traceback.print_exception(type(e), e, tb)
+        sys.exit(1)
+_looper_run()
 `, encoded)
-
-	return wrapper
 }
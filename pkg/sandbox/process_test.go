@@ -0,0 +1,512 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSandbox returns a sandbox with DefaultConfig in a temporary
+// working directory, adjusted by configure if it is not nil
+func newTestSandbox(t *testing.T, configure func(*Config)) *ProcessSandbox {
+	t.Helper()
+	config := DefaultConfig(t.TempDir())
+	if configure != nil {
+		configure(config)
+	}
+	sb, err := NewProcessSandbox(config)
+	if err != nil {
+		t.Fatalf("NewProcessSandbox: %v", err)
+	}
+	t.Cleanup(func() { sb.Close() })
+	return sb
+}
+
+// requirePrograms skips the test unless every program is on the PATH
+func requirePrograms(t testing.TB, programs ...string) {
+	t.Helper()
+	for _, program := range programs {
+		if !InterpreterAvailable(program) {
+			t.Skipf("%s is not installed", program)
+		}
+	}
+}
+
+func TestExecuteCancelled(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	result, err := sb.Execute(ctx, "sleep", []string{"10"})
+
+	if !errors.Is(err, ErrExecutionCancelled) {
+		t.Fatalf("err = %v, want ErrExecutionCancelled", err)
+	}
+	if errors.Is(err, ErrExecutionTimeout) {
+		t.Errorf("cancellation also matches ErrExecutionTimeout")
+	}
+	if wrapped := fmt.Errorf("bash: %w", err); ClassifyError(wrapped) != ErrorClassCancelled {
+		t.Errorf("ClassifyError(wrapped) = %v, want %v", ClassifyError(wrapped), ErrorClassCancelled)
+	}
+	if result == nil {
+		t.Fatal("no result returned with the cancellation error")
+	}
+	if result.TimedOut {
+		t.Error("TimedOut set on a cancelled command")
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("ExitCode = %d, want -1", result.ExitCode)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled command took %s to return", elapsed)
+	}
+}
+
+func TestExecuteDeadline(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.Timeout = 200 * time.Millisecond
+		c.KillGrace = 0
+	})
+
+	result, err := sb.Execute(context.Background(), "sleep", []string{"10"})
+
+	if !errors.Is(err, ErrExecutionTimeout) {
+		t.Fatalf("err = %v, want ErrExecutionTimeout", err)
+	}
+	if errors.Is(err, ErrExecutionCancelled) {
+		t.Errorf("timeout also matches ErrExecutionCancelled")
+	}
+	if wrapped := fmt.Errorf("bash: %w", err); ClassifyError(wrapped) != ErrorClassTimeout {
+		t.Errorf("ClassifyError(wrapped) = %v, want %v", ClassifyError(wrapped), ErrorClassTimeout)
+	}
+	if result == nil {
+		t.Fatal("no result returned with the timeout error")
+	}
+	if !result.TimedOut {
+		t.Error("TimedOut not set")
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("ExitCode = %d, want -1", result.ExitCode)
+	}
+}
+
+func TestExecuteCallerDeadline(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, nil)
+
+	// A deadline on the caller's context counts as a timeout, like
+	// Config.Timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := sb.Execute(ctx, "sleep", []string{"10"})
+	if !errors.Is(err, ErrExecutionTimeout) {
+		t.Fatalf("err = %v, want ErrExecutionTimeout", err)
+	}
+}
+
+func TestExecuteStdin(t *testing.T) {
+	requirePrograms(t, "cat")
+	sb := newTestSandbox(t, nil)
+
+	input := "first line\nsecond line\n"
+	result, err := sb.ExecuteWithOptions(context.Background(), "cat", nil, &ExecOptions{Stdin: input})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	if result.Stdout != input {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, input)
+	}
+}
+
+func TestExecuteScriptStdin(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, nil)
+
+	// Reading to EOF returns only once the sandbox closes stdin
+	script := "import sys\nlines = sys.stdin.read().splitlines()\nprint(len(lines), lines[-1].upper())\n"
+	result, err := sb.ExecuteScriptWithOptions(context.Background(), "python3", script, &ExecOptions{Stdin: "a\nb\nlast\n"})
+	if err != nil {
+		t.Fatalf("ExecuteScriptWithOptions: %v", err)
+	}
+	if got, want := strings.TrimSpace(result.Stdout), "3 LAST"; got != want {
+		t.Errorf("Stdout = %q, want %q (stderr %q)", got, want, result.Stderr)
+	}
+}
+
+func TestResolveWorkingDir(t *testing.T) {
+	sb := newTestSandbox(t, nil)
+	root := sb.config.WorkingDir
+	if err := os.MkdirAll(filepath.Join(root, "services", "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+
+	tests := []struct {
+		override string
+		want     string // Relative to root; unused when the override is rejected
+		wantErr  bool
+	}{
+		{override: "", want: "."},
+		{override: "services/api", want: "services/api"},
+		{override: "services/../services/api/", want: "services/api"},
+		{override: filepath.Join(root, "services"), want: "services"},
+		{override: root, want: "."},
+		{override: "..", wantErr: true},
+		{override: "../" + filepath.Base(outside), wantErr: true},
+		{override: "services/../../x", wantErr: true},
+		{override: outside, wantErr: true},
+		{override: "missing", wantErr: true},
+		{override: "README", wantErr: true},
+	}
+	for _, tt := range tests {
+		dir, err := sb.ResolveWorkingDir(tt.override)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidWorkingDir) {
+				t.Errorf("ResolveWorkingDir(%q) = %q, %v; want ErrInvalidWorkingDir", tt.override, dir, err)
+			}
+			continue
+		}
+		if want := filepath.Join(root, tt.want); err != nil || dir != want {
+			t.Errorf("ResolveWorkingDir(%q) = %q, %v; want %q", tt.override, dir, err, want)
+		}
+	}
+}
+
+func TestExecuteWorkingDir(t *testing.T) {
+	requirePrograms(t, "pwd")
+	sb := newTestSandbox(t, nil)
+	sub := filepath.Join(sb.config.WorkingDir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sb.ExecuteWithOptions(context.Background(), "pwd", nil, &ExecOptions{WorkingDir: "sub"})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); filepath.Base(got) != "sub" {
+		t.Errorf("pwd = %q, want %q", got, sub)
+	}
+
+	_, err = sb.ExecuteWithOptions(context.Background(), "pwd", nil, &ExecOptions{WorkingDir: "../"})
+	if !errors.Is(err, ErrInvalidWorkingDir) {
+		t.Errorf("err = %v, want ErrInvalidWorkingDir for a working directory outside the sandbox", err)
+	}
+}
+
+func TestExecutePerCallTimeout(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.Timeout = 30 * time.Second
+		c.KillGrace = 0
+	})
+
+	start := time.Now()
+	result, err := sb.ExecuteWithOptions(context.Background(), "sleep", []string{"10"}, &ExecOptions{Timeout: 200 * time.Millisecond})
+	if !errors.Is(err, ErrExecutionTimeout) {
+		t.Fatalf("err = %v, want ErrExecutionTimeout", err)
+	}
+	if !result.TimedOut {
+		t.Error("TimedOut not set")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("per-call timeout of 200ms took %s", elapsed)
+	}
+}
+
+func TestExecuteTimeoutCappedByMaxTimeout(t *testing.T) {
+	requirePrograms(t, "sleep")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxTimeout = 200 * time.Millisecond
+		c.KillGrace = 0
+	})
+
+	start := time.Now()
+	_, err := sb.ExecuteWithOptions(context.Background(), "sleep", []string{"10"}, &ExecOptions{Timeout: time.Hour})
+	if !errors.Is(err, ErrExecutionTimeout) {
+		t.Fatalf("err = %v, want ErrExecutionTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout capped at 200ms took %s", elapsed)
+	}
+}
+
+func TestExecuteOutputTruncated(t *testing.T) {
+	requirePrograms(t, "bash", "head", "tr")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxOutputBytes = 1 << 20
+	})
+
+	// 2MB between a first and a last line
+	script := "echo BEGIN; head -c 2097152 /dev/zero | tr '\\0' x; echo; echo FINISH"
+	result, err := sb.ExecuteScript(context.Background(), "bash", script)
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+
+	total := int64(len("BEGIN\n") + 2097152 + len("\n") + len("FINISH\n"))
+	if !result.StdoutTruncated {
+		t.Error("StdoutTruncated not set")
+	}
+	if result.StdoutTotalBytes != total {
+		t.Errorf("StdoutTotalBytes = %d, want %d", result.StdoutTotalBytes, total)
+	}
+	if want := total - 1<<20; result.StdoutDroppedBytes != want {
+		t.Errorf("StdoutDroppedBytes = %d, want %d", result.StdoutDroppedBytes, want)
+	}
+	if !strings.HasPrefix(result.Stdout, "BEGIN\n") {
+		t.Errorf("head of the output was not kept: %q", result.Stdout[:20])
+	}
+	if !strings.HasSuffix(result.Stdout, "\nFINISH\n") {
+		t.Errorf("tail of the output was not kept: %q", result.Stdout[len(result.Stdout)-20:])
+	}
+	if !strings.Contains(result.Stdout, "[showing 1048576 of") {
+		t.Error("no truncation marker in the output")
+	}
+	if result.StderrTruncated {
+		t.Error("StderrTruncated set for empty stderr")
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	lw := newLimitedWriter(8)
+	for _, chunk := range []string{"abc", "def", "ghij", "klmnop"} {
+		if n, err := lw.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+
+	// A quarter of the limit is kept from the end
+	if lw.total != 16 || lw.dropped != 8 {
+		t.Errorf("total, dropped = %d, %d; want 16, 8", lw.total, lw.dropped)
+	}
+	if want := "abcdef\n[showing 8 of 16 bytes]\nop"; lw.String() != want {
+		t.Errorf("String() = %q, want %q", lw.String(), want)
+	}
+
+	lw = newLimitedWriter(8)
+	lw.Write([]byte("short"))
+	if lw.dropped != 0 || lw.String() != "short" {
+		t.Errorf("output within the limit changed: %q, %d dropped", lw.String(), lw.dropped)
+	}
+}
+
+func TestPythonScriptWrapper(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, nil)
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		// Expressions containing keywords
+		{script: "data = [3, 1, 2]\nmax(x for x in data)", want: "3\n"},
+		{script: "flag = False\n'yes' if flag else 'no'", want: "'no'\n"},
+		{script: "rows = ['with x', 'for y']\n[r for r in rows if 'with ' in r]", want: "['with x']\n"},
+		{script: "'for' in 'format' and 'import' not in 'important'", want: "False\n"},
+
+		// Multi-line scripts ending in an expression
+		{script: "x = 1\nif x:\n    y = 2\ny * 21", want: "42\n"},
+		{script: "def f():\n    return {'k': 1}\n\nf()\n", want: "{'k': 1}\n"},
+		{script: "total = 0\nfor i in range(4):\n    total += i\ntotal  # sum", want: "6\n"},
+
+		// Explicit prints and scripts without a final expression
+		{script: "print('hello')", want: "hello\n"},
+		{script: "print('a')\nprint('b')\n", want: "a\nb\n"},
+		{script: "for i in range(2):\n    print(i)", want: "0\n1\n"},
+		{script: "x = 5", want: ""},
+		{script: "None", want: ""},
+		{script: "import sys", want: ""},
+	}
+	for _, tt := range tests {
+		result, err := sb.ExecuteScript(context.Background(), "python3", tt.script)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.script, err)
+		}
+		if result.Stdout != tt.want || result.ExitCode != 0 {
+			t.Errorf("%q: stdout = %q, exit %d, want %q (stderr %q)", tt.script, result.Stdout, result.ExitCode, tt.want, result.Stderr)
+		}
+	}
+}
+
+func TestPythonScriptWrapperErrors(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, nil)
+
+	// Tracebacks show the script's lines, not the wrapper's
+	result, err := sb.ExecuteScript(context.Background(), "python3", "x = 1\nx / 0")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 1 || !strings.Contains(result.Stderr, "ZeroDivisionError") || !strings.Contains(result.Stderr, "x / 0") {
+		t.Errorf("exit %d, stderr %q; want a ZeroDivisionError traceback", result.ExitCode, result.Stderr)
+	}
+	if strings.Contains(result.Stderr, "_looper_run") {
+		t.Errorf("traceback includes the wrapper: %q", result.Stderr)
+	}
+
+	result, err = sb.ExecuteScript(context.Background(), "python3", "import sys\nsys.exit(3)")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("sys.exit(3): exit %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+}
+
+func TestExecuteGracefulStop(t *testing.T) {
+	requirePrograms(t, "bash", "sleep")
+	if !gracefulStopSupported {
+		t.Skip("no SIGTERM on this platform")
+	}
+
+	tests := []struct {
+		name          string
+		trap          string
+		grace         time.Duration
+		wantStoppedBy string
+		wantOutput    string
+	}{
+		{"exits on SIGTERM", "trap 'echo cleanup; exit 1' TERM", 5 * time.Second, "SIGTERM", "cleanup"},
+		{"ignores SIGTERM", "trap 'echo ignored' TERM", 300 * time.Millisecond, "SIGKILL", "ignored"},
+		{"no grace period", "trap 'echo cleanup; exit 1' TERM", 0, "SIGKILL", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := newTestSandbox(t, func(c *Config) {
+				c.Timeout = 300 * time.Millisecond
+				c.KillGrace = tt.grace
+			})
+
+			// wait returns as soon as a trapped signal arrives, where a
+			// foreground sleep would delay the trap until it exits
+			script := tt.trap + "\necho ready\nwhile true; do sleep 1 & wait; done\n"
+			result, err := sb.Execute(context.Background(), "bash", []string{"-c", script})
+
+			if !errors.Is(err, ErrExecutionTimeout) {
+				t.Fatalf("err = %v, want ErrExecutionTimeout", err)
+			}
+			if result.StoppedBy != tt.wantStoppedBy {
+				t.Errorf("StoppedBy = %q, want %q", result.StoppedBy, tt.wantStoppedBy)
+			}
+			if !strings.Contains(result.Stdout, "ready") {
+				t.Errorf("output before the timeout lost: %q", result.Stdout)
+			}
+			if tt.wantOutput != "" && !strings.Contains(result.Stdout, tt.wantOutput) {
+				t.Errorf("stdout = %q, want output from the grace period %q", result.Stdout, tt.wantOutput)
+			}
+			if tt.grace > 0 && result.Duration > tt.grace+2*time.Second {
+				t.Errorf("took %v with a %v grace period", result.Duration, tt.grace)
+			}
+		})
+	}
+}
+
+func TestExecuteCombinedOutput(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+	script := "for i in $(seq 1 50); do echo out $i; echo err $i >&2; done"
+
+	var want strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&want, "out %d\nerr %d\n", i, i)
+	}
+
+	var streamed []string
+	result, err := sb.ExecuteWithOptions(context.Background(), "bash", []string{"-c", script}, &ExecOptions{
+		CombineOutput: true,
+		OnOutput: func(stream string, chunk []byte) {
+			streamed = append(streamed, stream)
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	if result.Combined != want.String() {
+		t.Errorf("Combined lost the write order:\n%s", result.Combined)
+	}
+	if result.Stdout != "" || result.Stderr != "" {
+		t.Errorf("Stdout = %q, Stderr = %q; want both empty", result.Stdout, result.Stderr)
+	}
+	if len(streamed) != 100 {
+		t.Errorf("OnOutput called %d times, want 100", len(streamed))
+	}
+	for _, stream := range streamed {
+		if stream != "stdout" {
+			t.Errorf("OnOutput stream = %q, want stdout for combined output", stream)
+			break
+		}
+	}
+
+	// Without CombineOutput the streams are captured separately
+	result, err = sb.ExecuteWithOptions(context.Background(), "bash", []string{"-c", script}, nil)
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	if result.Combined != "" || !strings.HasPrefix(result.Stdout, "out 1\nout 2\n") || !strings.HasPrefix(result.Stderr, "err 1\nerr 2\n") {
+		t.Errorf("separate streams: Stdout = %q, Stderr = %q, Combined = %q", result.Stdout, result.Stderr, result.Combined)
+	}
+}
+
+func TestExecuteStreamsWhileRunning(t *testing.T) {
+	requirePrograms(t, "bash", "sleep")
+	sb := newTestSandbox(t, nil)
+	script := "for i in 1 2 3 4 5; do echo line $i; sleep 0.1; done; echo warning >&2; printf partial"
+
+	type chunk struct {
+		stream, text string
+		at           time.Duration
+	}
+	var mu sync.Mutex
+	var chunks []chunk
+	start := time.Now()
+	result, err := sb.ExecuteWithOptions(context.Background(), "bash", []string{"-c", script}, &ExecOptions{
+		OnOutput: func(stream string, data []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			chunks = append(chunks, chunk{stream, string(data), time.Since(start)})
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []string
+	for _, c := range chunks {
+		got = append(got, c.stream+": "+c.text)
+	}
+	want := []string{
+		"stdout: line 1\n", "stdout: line 2\n", "stdout: line 3\n", "stdout: line 4\n", "stdout: line 5\n",
+		"stderr: warning\n", "stdout: partial",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("chunks = %q, want %q", got, want)
+	}
+
+	// Each line arrives as it is printed, not when the command exits
+	if first := chunks[0].at; first > result.Duration-300*time.Millisecond {
+		t.Errorf("first line delivered after %s of a %s run", first, result.Duration)
+	}
+	if spread := chunks[4].at - chunks[0].at; spread < 200*time.Millisecond {
+		t.Errorf("lines 1 to 5 delivered within %s, want them spread over about 400ms", spread)
+	}
+	if result.Stdout != "line 1\nline 2\nline 3\nline 4\nline 5\npartial" {
+		t.Errorf("Stdout = %q", result.Stdout)
+	}
+}
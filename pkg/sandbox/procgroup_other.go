@@ -0,0 +1,29 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+)
+
+// gracefulStopSupported is false where there is no SIGTERM to send
+const gracefulStopSupported = false
+
+// setProcessGroup is a no-op where process groups are not supported;
+// signals only reach the process itself
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalProcessGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+// terminateProcessGroup kills the process, since there is no SIGTERM to
+// send on these platforms
+func terminateProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
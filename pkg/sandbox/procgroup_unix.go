@@ -0,0 +1,38 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// gracefulStopSupported reports whether processes can be asked to exit
+// with SIGTERM before being killed
+const gracefulStopSupported = true
+
+// setProcessGroup starts cmd as the leader of a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup sends sig to every process in p's group
+func signalProcessGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+func terminateProcessGroup(p *os.Process) error {
+	return signalProcessGroup(p, syscall.SIGTERM)
+}
+
+func killProcessGroup(p *os.Process) error {
+	return signalProcessGroup(p, syscall.SIGKILL)
+}
@@ -0,0 +1,89 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultProgressInterval is how often OnProgress is called when
+// ExecOptions.ProgressInterval is not set
+const defaultProgressInterval = 10 * time.Second
+
+// maxProgressLine bounds the output line quoted in a progress message
+const maxProgressLine = 200
+
+// ProgressCallback receives a one-line progress message while a command is
+// still running
+type ProgressCallback func(msg string)
+
+// lastLineWriter remembers the last complete non-empty line written to it
+type lastLineWriter struct {
+	mu      sync.Mutex
+	partial string
+	last    string
+}
+
+func (w *lastLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	if len(w.partial) > maxProgressLine {
+		w.partial = w.partial[:maxProgressLine]
+	}
+	for i := len(lines) - 2; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			w.last = line
+			break
+		}
+	}
+	return len(p), nil
+}
+
+func (w *lastLineWriter) Last() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
+
+// reportProgress calls opts.OnProgress every progress interval until
+// exited is closed, quoting the latest line of tracked output with secrets
+// redacted. The returned function waits for the reporter to stop, so no
+// call happens after the execution returns.
+func reportProgress(opts *ExecOptions, start time.Time, tracked *lastLineWriter, redactor *redactor, exited <-chan struct{}) func() {
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-exited:
+				return
+			case <-ticker.C:
+				opts.OnProgress(progressMessage(time.Since(start), redactor.redact(tracked.Last())))
+			}
+		}
+	}()
+	return func() { <-done }
+}
+
+// progressMessage renders a progress line, e.g. "still running after 30s:
+// ok  pkg/agent 1.2s"
+func progressMessage(elapsed time.Duration, line string) string {
+	msg := fmt.Sprintf("still running after %s", elapsed.Round(time.Second))
+	if line == "" {
+		return msg
+	}
+	if runes := []rune(line); len(runes) > maxProgressLine {
+		line = string(runes[:maxProgressLine]) + "..."
+	}
+	return msg + ": " + line
+}
@@ -0,0 +1,107 @@
+package sandbox
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPTYCols = 80
+	defaultPTYRows = 24
+
+	// ptyDrainTimeout bounds how long output is read after the command
+	// exits; a background child holding the terminal open would otherwise
+	// block the read forever
+	ptyDrainTimeout = 200 * time.Millisecond
+)
+
+// PTYOptions runs a command attached to a pseudo-terminal instead of pipes,
+// for programs that check isatty or only show progress on a terminal.
+// Stdout and stderr share the terminal, so all output is captured as stdout.
+type PTYOptions struct {
+	Cols int // Terminal width (0 = 80)
+	Rows int // Terminal height (0 = 24)
+
+	// KeepANSI keeps escape sequences and carriage-return redraws in the
+	// captured output. By default they are stripped, leaving the text as it
+	// would finally appear on screen.
+	KeepANSI bool
+}
+
+func (o *PTYOptions) size() (cols, rows int) {
+	cols, rows = o.Cols, o.Rows
+	if cols <= 0 {
+		cols = defaultPTYCols
+	}
+	if rows <= 0 {
+		rows = defaultPTYRows
+	}
+	return cols, rows
+}
+
+// ptySession copies terminal output to a writer while a command runs
+type ptySession struct {
+	master *os.File
+	slave  *os.File
+	done   chan struct{}
+}
+
+// started closes the parent's copy of the terminal and starts copying its
+// output to out
+func (p *ptySession) started(out io.Writer) {
+	p.slave.Close()
+	go func() {
+		defer close(p.done)
+		// Reads fail with EIO once every process has closed the terminal
+		io.Copy(out, p.master)
+	}()
+}
+
+// finish waits briefly for remaining output and releases the terminal
+func (p *ptySession) finish() {
+	select {
+	case <-p.done:
+	case <-time.After(ptyDrainTimeout):
+	}
+	p.master.Close()
+	<-p.done
+}
+
+// abort releases the terminal of a command that failed to start
+func (p *ptySession) abort() {
+	p.slave.Close()
+	p.master.Close()
+}
+
+var (
+	// ansiEscape matches CSI and OSC sequences and other two-byte escapes
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?<=>!]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+)
+
+// stripTerminal removes escape sequences from terminal output, turns CRLF
+// line endings into LF, and keeps only the final redraw of lines rewritten
+// with carriage returns, such as progress bars
+func stripTerminal(output string) string {
+	output = ansiEscape.ReplaceAllString(output, "")
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	if !strings.Contains(output, "\r") {
+		return output
+	}
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		// A trailing \r only returns the cursor; the last non-empty
+		// segment is what remains visible
+		segments := strings.Split(line, "\r")
+		for j := len(segments) - 1; j >= 0; j-- {
+			if segments[j] != "" || j == 0 {
+				lines[i] = segments[j]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY allocates a pseudo-terminal of the given size and attaches cmd to
+// it as its controlling terminal
+func openPTY(cmd *exec.Cmd, opts *PTYOptions) (*ptySession, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPTYUnavailable, err)
+	}
+
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("%w: unlockpt: %v", ErrPTYUnavailable, err)
+	}
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("%w: ptsname: %v", ErrPTYUnavailable, err)
+	}
+
+	cols, rows := opts.size()
+	ws := struct{ rows, cols, x, y uint16 }{rows: uint16(rows), cols: uint16(cols)}
+	if err := ioctl(master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("%w: set size: %v", ErrPTYUnavailable, err)
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("%w: %v", ErrPTYUnavailable, err)
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0 // Child's stdin
+
+	return &ptySession{master: master, slave: slave, done: make(chan struct{})}, nil
+}
+
+// ioctl runs an ioctl on f. It goes through SyscallConn because f.Fd would
+// switch the file to blocking mode, and closing it could then no longer
+// interrupt a pending read.
+func ioctl(f *os.File, req, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
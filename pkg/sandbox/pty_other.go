@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+func openPTY(cmd *exec.Cmd, opts *PTYOptions) (*ptySession, error) {
+	return nil, fmt.Errorf("%w: not supported on %s", ErrPTYUnavailable, runtime.GOOS)
+}
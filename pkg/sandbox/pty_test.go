@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// executePTY runs a bash command on a terminal, skipping the test where
+// terminals are unavailable
+func executePTY(t *testing.T, sb *ProcessSandbox, script string, pty *PTYOptions) *ExecutionResult {
+	t.Helper()
+	result, err := sb.ExecuteWithOptions(context.Background(), "bash", []string{"-c", script}, &ExecOptions{PTY: pty})
+	if errors.Is(err, ErrPTYUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions: %v", err)
+	}
+	return result
+}
+
+func TestExecutePTY(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+	script := "if test -t 1; then echo tty; else echo notty; fi"
+
+	result := executePTY(t, sb, script, &PTYOptions{})
+	if got := strings.TrimSpace(result.Stdout); got != "tty" {
+		t.Errorf("with PTY: stdout = %q, want tty", got)
+	}
+	if strings.Contains(result.Stdout, "\r") {
+		t.Errorf("CRLF line endings kept: %q", result.Stdout)
+	}
+
+	result, err := sb.Execute(context.Background(), "bash", []string{"-c", script})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "notty" {
+		t.Errorf("without PTY: stdout = %q, want notty", got)
+	}
+}
+
+func TestExecutePTYSizeAndStderr(t *testing.T) {
+	requirePrograms(t, "bash", "stty")
+	sb := newTestSandbox(t, nil)
+
+	result := executePTY(t, sb, "stty size; echo err >&2", &PTYOptions{Cols: 132, Rows: 40})
+	if got, want := result.Stdout, "40 132\nerr\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if result.Stderr != "" {
+		t.Errorf("stderr = %q, want it merged into stdout", result.Stderr)
+	}
+}
+
+func TestExecutePTYEscapes(t *testing.T) {
+	requirePrograms(t, "bash")
+	sb := newTestSandbox(t, nil)
+	script := `printf '\033[31mred\033[0m\n'; printf '10%%\r50%%\r100%%\n'`
+
+	result := executePTY(t, sb, script, &PTYOptions{})
+	if got, want := result.Stdout, "red\n100%\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+
+	result = executePTY(t, sb, script, &PTYOptions{KeepANSI: true})
+	if !strings.Contains(result.Stdout, "\x1b[31m") || !strings.Contains(result.Stdout, "\r") {
+		t.Errorf("KeepANSI stdout = %q, want the escapes kept", result.Stdout)
+	}
+}
+
+func TestExecutePTYStdin(t *testing.T) {
+	requirePrograms(t, "cat")
+	sb := newTestSandbox(t, nil)
+
+	_, err := sb.ExecuteWithOptions(context.Background(), "cat", nil, &ExecOptions{PTY: &PTYOptions{}, Stdin: "input"})
+	if err == nil {
+		t.Error("PTY with Stdin was accepted")
+	}
+}
+
+func TestStripTerminal(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain\n", "plain\n"},
+		{"line\r\n", "line\n"},
+		{"\x1b[1;32mok\x1b[0m\r\n", "ok\n"},
+		{"\x1b]0;title\x07text", "text"},
+		{"\x1b[?25lhidden cursor\x1b[?25h", "hidden cursor"},
+		{"[1/3]\r[2/3]\r[3/3]\r\ndone\r\n", "[3/3]\ndone\n"},
+		{"progress 50%\r", "progress 50%"},
+	}
+	for _, tt := range tests {
+		if got := stripTerminal(tt.in); got != tt.want {
+			t.Errorf("stripTerminal(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
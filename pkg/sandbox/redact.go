@@ -0,0 +1,231 @@
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// minSecretLength is the shortest env var value that is redacted; shorter
+// values such as "1" or "true" would mangle unrelated output
+const minSecretLength = 6
+
+// DefaultSecretEnv returns the default names of environment variables whose
+// values are redacted from command output. Names may use * wildcards.
+func DefaultSecretEnv() []string {
+	return []string{
+		"*_API_KEY",
+		"*_TOKEN",
+		"*_SECRET",
+		"*_SECRET_KEY",
+		"*_PASSWORD",
+		"AWS_ACCESS_KEY_ID",
+	}
+}
+
+// DefaultSecretPatterns returns the default regexes for well-known
+// credential formats that are redacted from command output
+func DefaultSecretPatterns() []string {
+	return []string{
+		`\bsk-ant-[A-Za-z0-9_-]{10,}`,        // Anthropic
+		`\bsk-(proj-)?[A-Za-z0-9_-]{20,}`,    // OpenAI
+		`\b(AKIA|ASIA)[0-9A-Z]{16}`,          // AWS access key ID
+		`\bgh[pousr]_[A-Za-z0-9]{36,}`,       // GitHub
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`,     // Slack
+		`\bAIza[0-9A-Za-z_-]{35}`,            // Google API key
+		`-----BEGIN [A-Z ]*PRIVATE KEY-----`, // PEM private key header
+	}
+}
+
+// compileSecretPatterns compiles redaction regexes, reporting all invalid
+// ones together
+func compileSecretPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	var invalid []string
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q (%v)", pattern, err))
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSecretPattern, strings.Join(invalid, ", "))
+	}
+	return compiled, nil
+}
+
+// secretValue is the value of a secret environment variable
+type secretValue struct {
+	name  string
+	value string
+}
+
+// redactor replaces secrets in command output
+type redactor struct {
+	values   []secretValue
+	patterns []*regexp.Regexp
+}
+
+// newRedactor snapshots the secret values visible to a command: matching
+// variables from CustomEnv and the parent environment. It returns nil when
+// redaction is disabled.
+func (s *ProcessSandbox) newRedactor() *redactor {
+	if s.config.DisableRedaction {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var values []secretValue
+	add := func(name, value string) {
+		if len(value) < minSecretLength || seen[value] || !isSecretEnv(name, s.config.SecretEnv) {
+			return
+		}
+		seen[value] = true
+		values = append(values, secretValue{name: name, value: value})
+	}
+
+	for name, value := range s.config.CustomEnv {
+		add(name, value)
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			add(name, value)
+		}
+	}
+
+	// Replace longer values first so a secret containing another is
+	// redacted as a whole
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i].value) > len(values[j].value)
+	})
+
+	return &redactor{values: values, patterns: s.secretPatterns}
+}
+
+func isSecretEnv(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces secret env var values with [REDACTED:NAME] and pattern
+// matches with [REDACTED]
+func (r *redactor) redact(output string) string {
+	if r == nil || output == "" {
+		return output
+	}
+	for _, secret := range r.values {
+		output = strings.ReplaceAll(output, secret.value, "[REDACTED:"+secret.name+"]")
+	}
+	for _, re := range r.patterns {
+		output = re.ReplaceAllString(output, "[REDACTED]")
+	}
+	return output
+}
+
+// redactTokenWindow caps how much trailing output a redactWriter holds back
+// in case it is the start of a pattern match
+const redactTokenWindow = 256
+
+// redactWriter redacts a stream of writes before passing it on. A secret
+// can be split across writes, so the end of each write that could still
+// become one is held back until the next write or Flush.
+type redactWriter struct {
+	r   *redactor
+	w   io.Writer
+	buf string
+}
+
+// writer returns a redactWriter that writes to w
+func (r *redactor) writer(w io.Writer) *redactWriter {
+	return &redactWriter{r: r, w: w}
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.buf += string(p)
+	cut := rw.r.safeCut(rw.buf)
+	if cut == 0 {
+		return len(p), nil
+	}
+	out := rw.r.redact(rw.buf[:cut])
+	rw.buf = rw.buf[cut:]
+	if _, err := io.WriteString(rw.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out whatever is held back
+func (rw *redactWriter) Flush() error {
+	if rw.buf == "" {
+		return nil
+	}
+	out := rw.r.redact(rw.buf)
+	rw.buf = ""
+	_, err := io.WriteString(rw.w, out)
+	return err
+}
+
+// safeCut returns how much of buf can be redacted and written now: all of
+// it but a trailing part that may continue in the next write, either the
+// start of a secret value or, with patterns, the last whitespace-delimited
+// token. A secret that straddles the cut is held back whole.
+func (r *redactor) safeCut(buf string) int {
+	cut := len(buf) - r.partialValue(buf)
+	if len(r.patterns) > 0 {
+		token := len(buf) - strings.LastIndexAny(buf, " \t\r\n") - 1
+		cut = min(cut, len(buf)-min(token, redactTokenWindow))
+	}
+
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for _, secret := range r.values {
+			for i := 0; ; {
+				j := strings.Index(buf[i:], secret.value)
+				if j < 0 || i+j >= cut {
+					break
+				}
+				if start := i + j; start+len(secret.value) > cut {
+					cut, moved = start, true
+					break
+				}
+				i += j + 1
+			}
+		}
+		for _, re := range r.patterns {
+			for _, m := range re.FindAllStringIndex(buf, -1) {
+				if m[0] < cut && m[1] > cut {
+					cut, moved = m[0], true
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// partialValue returns the length of the longest suffix of buf that is the
+// start of a secret value
+func (r *redactor) partialValue(buf string) int {
+	longest := 0
+	for _, secret := range r.values {
+		for n := min(len(secret.value)-1, len(buf)); n > longest; n-- {
+			if strings.HasSuffix(buf, secret.value[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,75 @@
+package sandbox
+
+// byteRing keeps the last size bytes written to it. The buffer grows with
+// the data up to size and is then reused in place, so each write costs
+// O(len(p)) however much has been written before.
+type byteRing struct {
+	size  int
+	buf   []byte
+	start int // Index of the oldest byte once buf has reached size
+	n     int // Bytes held
+}
+
+func newByteRing(size int) *byteRing {
+	if size < 0 {
+		size = 0
+	}
+	return &byteRing{size: size}
+}
+
+// write appends p, overwriting the oldest bytes once the ring is full, and
+// returns the number of bytes dropped, including any of p itself
+func (r *byteRing) write(p []byte) (dropped int) {
+	if r.size == 0 {
+		return len(p)
+	}
+
+	// p alone fills the ring
+	if len(p) >= r.size {
+		dropped = r.n + len(p) - r.size
+		if len(r.buf) < r.size {
+			r.buf = make([]byte, r.size)
+		}
+		copy(r.buf, p[len(p)-r.size:])
+		r.start, r.n = 0, r.size
+		return dropped
+	}
+
+	// Grow until the buffer reaches its full size
+	if len(r.buf) < r.size {
+		if len(r.buf)+len(p) <= r.size {
+			r.buf = append(r.buf, p...)
+			r.n = len(r.buf)
+			return 0
+		}
+		grown := make([]byte, r.size)
+		copy(grown, r.buf)
+		r.buf = grown
+	}
+
+	if overflow := r.n + len(p) - r.size; overflow > 0 {
+		dropped = overflow
+		r.start = (r.start + overflow) % r.size
+		r.n -= overflow
+	}
+	end := (r.start + r.n) % r.size
+	copied := copy(r.buf[end:], p)
+	copy(r.buf, p[copied:])
+	r.n += len(p)
+	return dropped
+}
+
+// len returns the number of bytes held
+func (r *byteRing) len() int {
+	return r.n
+}
+
+// bytes returns a copy of the bytes held, oldest first
+func (r *byteRing) bytes() []byte {
+	out := make([]byte, 0, r.n)
+	if r.start+r.n <= len(r.buf) {
+		return append(out, r.buf[r.start:r.start+r.n]...)
+	}
+	out = append(out, r.buf[r.start:]...)
+	return append(out, r.buf[:r.n-len(out)]...)
+}
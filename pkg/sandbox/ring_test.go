@@ -0,0 +1,51 @@
+package sandbox
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// TestByteRing checks the ring against keeping everything and slicing off
+// the end, over random write sizes around the ring size
+func TestByteRing(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, 7, 64} {
+		r := newByteRing(size)
+		var all []byte
+		dropped := 0
+		for i := 0; i < 500; i++ {
+			p := make([]byte, rng.Intn(2*size+2))
+			rng.Read(p)
+			all = append(all, p...)
+			dropped += r.write(p)
+
+			want := all
+			if len(want) > size {
+				want = want[len(want)-size:]
+			}
+			if got := r.bytes(); !bytes.Equal(got, want) || r.len() != len(want) {
+				t.Fatalf("size %d, write %d: holds %x, want %x", size, i, got, want)
+			}
+			if dropped != len(all)-len(want) {
+				t.Fatalf("size %d, write %d: dropped %d, want %d", size, i, dropped, len(all)-len(want))
+			}
+		}
+	}
+}
+
+// BenchmarkLimitedWriter writes small chunks far past the limit, which
+// must cost the same per write however much output came before
+func BenchmarkLimitedWriter(b *testing.B) {
+	chunk := bytes.Repeat([]byte("x"), 100)
+	for _, limit := range []int64{64 << 10, 1 << 20} {
+		b.Run(FormatBytes(limit), func(b *testing.B) {
+			lw := newLimitedWriter(limit)
+			b.SetBytes(int64(len(chunk)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				lw.Write(chunk)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// rlimitNPROC is not exported by the syscall package
+const rlimitNPROC = 0x6
+
+// resourceLimitsSupported reports whether rlimits can be applied to children
+const resourceLimitsSupported = true
+
+// prlimitArgs returns the prlimit(1) options that set the configured
+// rlimits. Each limit is capped at the hard limit this process runs under:
+// children inherit it and an unprivileged prlimit cannot raise it, so
+// asking for more would make every command fail to start.
+func prlimitArgs(config *Config) []string {
+	var args []string
+	limit := func(option string, resource int, soft, hard uint64) {
+		var current syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &current); err == nil {
+			soft, hard = min(soft, current.Max), min(hard, current.Max)
+		}
+		args = append(args, fmt.Sprintf("--%s=%d:%d", option, soft, hard))
+	}
+
+	if config.MaxCPUSeconds > 0 {
+		// As in applyResourceLimits, the hard limit one second later
+		// guarantees a SIGKILL if the process ignores SIGXCPU
+		limit("cpu", syscall.RLIMIT_CPU, uint64(config.MaxCPUSeconds), uint64(config.MaxCPUSeconds)+1)
+	}
+	if config.MaxMemoryBytes > 0 {
+		limit("as", syscall.RLIMIT_AS, uint64(config.MaxMemoryBytes), uint64(config.MaxMemoryBytes))
+	}
+	if config.MaxProcesses > 0 {
+		limit("nproc", rlimitNPROC, uint64(config.MaxProcesses), uint64(config.MaxProcesses))
+	}
+	if config.MaxOpenFiles > 0 {
+		limit("nofile", syscall.RLIMIT_NOFILE, uint64(config.MaxOpenFiles), uint64(config.MaxOpenFiles))
+	}
+	return args
+}
+
+// applyResourceLimits sets the configured rlimits on a started child process.
+// It is the fallback when prlimit(1) is not installed: limits are applied
+// with prlimit(2) immediately after start, so a child that forks or
+// allocates within its first instructions may briefly exceed them.
+func applyResourceLimits(pid int, config *Config) error {
+	var errs []string
+
+	if config.MaxCPUSeconds > 0 {
+		// The soft limit delivers SIGXCPU; the hard limit one second later
+		// guarantees a SIGKILL if the process ignores it
+		limit := &syscall.Rlimit{Cur: uint64(config.MaxCPUSeconds), Max: uint64(config.MaxCPUSeconds) + 1}
+		if err := prlimit(pid, syscall.RLIMIT_CPU, limit); err != nil {
+			errs = append(errs, fmt.Sprintf("cpu: %v", err))
+		}
+	}
+
+	if config.MaxMemoryBytes > 0 {
+		limit := &syscall.Rlimit{Cur: uint64(config.MaxMemoryBytes), Max: uint64(config.MaxMemoryBytes)}
+		if err := prlimit(pid, syscall.RLIMIT_AS, limit); err != nil {
+			errs = append(errs, fmt.Sprintf("memory: %v", err))
+		}
+	}
+
+	if config.MaxProcesses > 0 {
+		limit := &syscall.Rlimit{Cur: uint64(config.MaxProcesses), Max: uint64(config.MaxProcesses)}
+		if err := prlimit(pid, rlimitNPROC, limit); err != nil {
+			errs = append(errs, fmt.Sprintf("processes: %v", err))
+		}
+	}
+
+	if config.MaxOpenFiles > 0 {
+		limit := &syscall.Rlimit{Cur: uint64(config.MaxOpenFiles), Max: uint64(config.MaxOpenFiles)}
+		if err := prlimit(pid, syscall.RLIMIT_NOFILE, limit); err != nil {
+			errs = append(errs, fmt.Sprintf("open files: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply resource limits: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func prlimit(pid int, resource int, limit *syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// detectLimitExceeded inspects a finished process and reports which configured
+// limit, if any, caused it to die
+func detectLimitExceeded(state *os.ProcessState, result *ExecutionResult, config *Config) string {
+	if state == nil || state.Success() {
+		return ""
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		switch status.Signal() {
+		case syscall.SIGXCPU:
+			if config.MaxCPUSeconds > 0 {
+				return LimitCPU
+			}
+		case syscall.SIGKILL:
+			if config.MaxCPUSeconds > 0 && result.ResourceUsage != nil &&
+				result.ResourceUsage.UserCPU+result.ResourceUsage.SystemCPU >= time.Duration(config.MaxCPUSeconds)*time.Second {
+				return LimitCPU
+			}
+		}
+	}
+
+	if config.MaxMemoryBytes > 0 {
+		if result.ResourceUsage != nil && result.ResourceUsage.MaxRSSBytes >= config.MaxMemoryBytes*9/10 {
+			return LimitMemory
+		}
+		if containsAny(result.Stderr+result.Combined, memoryFailureSignatures) {
+			return LimitMemory
+		}
+	}
+
+	// Hitting the process or open-file limit makes fork or open fail rather
+	// than killing the process, so only the error messages it printed on
+	// the way out tell
+	if config.MaxProcesses > 0 && containsAny(result.Stderr+result.Combined, processFailureSignatures) {
+		return LimitProcesses
+	}
+	if config.MaxOpenFiles > 0 && containsAny(result.Stderr+result.Combined, openFileFailureSignatures) {
+		return LimitOpenFiles
+	}
+
+	return ""
+}
@@ -0,0 +1,157 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestMemoryLimitExceeded(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxMemoryBytes = 256 << 20
+	})
+
+	// Allocate 1GB, four times the limit
+	result, err := sb.ExecuteScript(context.Background(), "python3", "data = bytearray(1 << 30)\nprint(len(data))\n")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode == 0 {
+		t.Fatalf("allocation over the limit succeeded: stdout %q", result.Stdout)
+	}
+	if result.LimitExceeded != LimitMemory {
+		t.Errorf("LimitExceeded = %q, want %q (stderr %q)", result.LimitExceeded, LimitMemory, result.Stderr)
+	}
+}
+
+func TestMemoryLimitNotExceeded(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxMemoryBytes = 256 << 20
+	})
+
+	result, err := sb.ExecuteScript(context.Background(), "python3", "data = bytearray(16 << 20)\nprint(len(data))\n")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+	if result.LimitExceeded != "" {
+		t.Errorf("LimitExceeded = %q for an allocation within the limit", result.LimitExceeded)
+	}
+}
+
+func TestProcessLimitExceeded(t *testing.T) {
+	requirePrograms(t, "python3")
+	if os.Geteuid() == 0 {
+		t.Skip("RLIMIT_NPROC does not apply to root")
+	}
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxProcesses = 64
+	})
+
+	// A bounded fork loop; the children exit on their own
+	script := "import os, time\nfor i in range(512):\n    if os.fork() == 0:\n        time.sleep(1)\n        os._exit(0)\nprint('forked all')\n"
+	result, err := sb.ExecuteScript(context.Background(), "python3", script)
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode == 0 {
+		t.Fatalf("fork loop over the limit succeeded: stdout %q", result.Stdout)
+	}
+	if result.LimitExceeded != LimitProcesses {
+		t.Errorf("LimitExceeded = %q, want %q (stderr %q)", result.LimitExceeded, LimitProcesses, result.Stderr)
+	}
+}
+
+func TestOpenFileLimitExceeded(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxOpenFiles = 32
+	})
+
+	result, err := sb.ExecuteScript(context.Background(), "python3", "import os\nfiles = [open(os.devnull) for _ in range(256)]\nprint(len(files))\n")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode == 0 {
+		t.Fatalf("opening files over the limit succeeded: stdout %q", result.Stdout)
+	}
+	if result.LimitExceeded != LimitOpenFiles {
+		t.Errorf("LimitExceeded = %q, want %q (stderr %q)", result.LimitExceeded, LimitOpenFiles, result.Stderr)
+	}
+}
+
+func TestResourceLimitsAppliedAtExec(t *testing.T) {
+	requirePrograms(t, "prlimit", "cat")
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxOpenFiles = 32
+	})
+
+	plan, err := sb.Plan(context.Background(), "cat", []string{"/proc/self/limits"}, nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Argv[0] != "prlimit" || !containsString(plan.Argv, "--nofile=32:32") {
+		t.Errorf("Argv = %q, want the command wrapped in prlimit", plan.Argv)
+	}
+
+	// cat reads its limits as soon as it runs, before a limit applied after
+	// start could land
+	result, err := sb.Execute(context.Background(), "cat", []string{"/proc/self/limits"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !regexp.MustCompile(`Max open files\s+32\s+32\s`).MatchString(result.Stdout) {
+		t.Errorf("limits of the command:\n%s", result.Stdout)
+	}
+}
+
+func TestResourceLimitsCappedAtHardLimit(t *testing.T) {
+	requirePrograms(t, "prlimit", "true")
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Max > 1<<30 {
+		t.Skip("hard open file limit is unbounded")
+	}
+	sb := newTestSandbox(t, func(c *Config) {
+		c.MaxOpenFiles = int(current.Max) + 1
+	})
+
+	// A limit above the inherited hard limit must not stop commands from
+	// starting
+	result, err := sb.Execute(context.Background(), "true", nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+}
+
+func TestDefaultResourceLimits(t *testing.T) {
+	config := DefaultConfig(t.TempDir())
+
+	// RLIMIT_NPROC counts all of the user's processes, so a default would
+	// make commands fail to fork on a busy host
+	if config.MaxProcesses != 0 {
+		t.Errorf("MaxProcesses = %d by default, want 0 (off)", config.MaxProcesses)
+	}
+	if config.MaxOpenFiles != DefaultMaxOpenFiles {
+		t.Errorf("MaxOpenFiles = %d by default, want %d", config.MaxOpenFiles, DefaultMaxOpenFiles)
+	}
+	for _, arg := range prlimitArgs(config) {
+		if strings.HasPrefix(arg, "--nproc") {
+			t.Errorf("default prlimit options %q limit processes", prlimitArgs(config))
+		}
+	}
+}
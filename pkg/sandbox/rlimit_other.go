@@ -0,0 +1,23 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+)
+
+// resourceLimitsSupported reports whether rlimits can be applied to children
+const resourceLimitsSupported = false
+
+func prlimitArgs(config *Config) []string {
+	return nil
+}
+
+func applyResourceLimits(pid int, config *Config) error {
+	return errors.New("resource limits are not supported on this platform")
+}
+
+func detectLimitExceeded(state *os.ProcessState, result *ExecutionResult, config *Config) string {
+	return ""
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package sandbox
+
+import "os"
+
+// collectResourceUsage reports CPU times only; memory and context switch
+// counters are not available on this platform
+func collectResourceUsage(state *os.ProcessState) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		UserCPU:   state.UserTime(),
+		SystemCPU: state.SystemTime(),
+	}
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// collectResourceUsage extracts rusage data from a finished process
+func collectResourceUsage(state *os.ProcessState) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+
+	usage := &ResourceUsage{
+		UserCPU:   state.UserTime(),
+		SystemCPU: state.SystemTime(),
+	}
+
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return usage
+	}
+
+	// ru_maxrss is reported in bytes on macOS and kilobytes everywhere else
+	maxRSS := int64(rusage.Maxrss)
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		maxRSS *= 1024
+	}
+	usage.MaxRSSBytes = maxRSS
+	usage.VoluntaryCtxSwitches = int64(rusage.Nvcsw)
+	usage.InvoluntaryCtxSwitches = int64(rusage.Nivcsw)
+
+	return usage
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceUsage(t *testing.T) {
+	requirePrograms(t, "python3")
+	sb := newTestSandbox(t, nil)
+
+	// Multiplying writes every page, where bytearray(n) may leave them
+	// untouched
+	result, err := sb.ExecuteScript(context.Background(), "python3", "data = b'x' * (64 << 20)\nprint(len(data))\n")
+	if err != nil {
+		t.Fatalf("ExecuteScript: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("ExitCode = %d, stderr %q", result.ExitCode, result.Stderr)
+	}
+
+	usage := result.ResourceUsage
+	if usage == nil {
+		t.Fatal("ResourceUsage not set")
+	}
+	if usage.MaxRSSBytes < 64<<20 {
+		t.Errorf("MaxRSSBytes = %d, want at least the 64MB allocated", usage.MaxRSSBytes)
+	}
+	if usage.UserCPU+usage.SystemCPU <= 0 {
+		t.Errorf("no CPU time recorded: %+v", usage)
+	}
+	if summary := usage.Summary(); !strings.Contains(summary, "max RSS") {
+		t.Errorf("Summary() = %q, want the max RSS", summary)
+	}
+}
+
+func TestResourceUsageSummary(t *testing.T) {
+	usage := &ResourceUsage{UserCPU: 812 * time.Millisecond, SystemCPU: 104 * time.Millisecond}
+	if got, want := usage.Summary(), "cpu 812ms user + 104ms sys"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	usage.MaxRSSBytes = 45 << 20
+	if got := usage.Summary(); !strings.HasSuffix(got, ", max RSS "+FormatBytes(45<<20)) {
+		t.Errorf("Summary() = %q, want the max RSS at the end", got)
+	}
+}
@@ -2,16 +2,125 @@ package sandbox
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"time"
 )
 
-// ExecutionResult contains the result of a sandboxed execution
+// ExecutionResult contains the result of a sandboxed execution.
+//
+// ExitCode is the status the process exited with. It is -1 whenever the
+// process did not exit on its own: TimedOut is then set if the timeout
+// stopped it (and ErrExecutionTimeout is returned with the result), and
+// Signal names the signal that ended it.
 type ExecutionResult struct {
 	Stdout   string        `json:"stdout"`
 	Stderr   string        `json:"stderr"`
 	ExitCode int           `json:"exit_code"`
 	Duration time.Duration `json:"duration"`
 	TimedOut bool          `json:"timed_out"`
+
+	// Signal is the signal that terminated the process, such as "SIGSEGV",
+	// or "SIGTERM" or "SIGKILL" after a timeout (Unix only)
+	Signal string `json:"signal,omitempty"`
+
+	// StoppedBy is "SIGTERM" when a timed-out or cancelled process exited
+	// after being asked to terminate, or "SIGKILL" when it had to be killed
+	StoppedBy string `json:"stopped_by,omitempty"`
+
+	// Truncation flags are set when output exceeded MaxOutputBytes. The
+	// captured output keeps the beginning and end of the stream with a marker
+	// where the dropped bytes were. The totals count every byte written.
+	StdoutTruncated    bool  `json:"stdout_truncated,omitempty"`
+	StdoutDroppedBytes int64 `json:"stdout_dropped_bytes,omitempty"`
+	StdoutTotalBytes   int64 `json:"stdout_total_bytes,omitempty"`
+	StderrTruncated    bool  `json:"stderr_truncated,omitempty"`
+	StderrDroppedBytes int64 `json:"stderr_dropped_bytes,omitempty"`
+	StderrTotalBytes   int64 `json:"stderr_total_bytes,omitempty"`
+
+	// Combined holds stdout and stderr interleaved in the order they were
+	// written when ExecOptions.CombineOutput is set; Stdout and Stderr are
+	// then empty. It is limited and truncated like a single stream.
+	Combined             string `json:"combined,omitempty"`
+	CombinedTruncated    bool   `json:"combined_truncated,omitempty"`
+	CombinedDroppedBytes int64  `json:"combined_dropped_bytes,omitempty"`
+	CombinedTotalBytes   int64  `json:"combined_total_bytes,omitempty"`
+
+	// ResourceUsage is populated after the process exits. Fields the
+	// platform cannot report are left zero.
+	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
+
+	// LimitExceeded names the resource limit that stopped the process
+	// (LimitCPU, LimitMemory, LimitProcesses or LimitOpenFiles), if any
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+
+	// IsolationFailure explains a failure caused by the isolation backend
+	// rather than the command, such as runsc failing to start the sandbox
+	IsolationFailure string `json:"isolation_failure,omitempty"`
+
+	// Limits are the timeout and output ceiling the call ran with, after
+	// per-call and per-interpreter overrides
+	Limits Limits `json:"limits"`
+}
+
+// ResourceUsage describes the resources consumed by a sandboxed process
+type ResourceUsage struct {
+	UserCPU                time.Duration `json:"user_cpu"`
+	SystemCPU              time.Duration `json:"system_cpu"`
+	MaxRSSBytes            int64         `json:"max_rss_bytes"`
+	VoluntaryCtxSwitches   int64         `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches int64         `json:"involuntary_ctx_switches"`
+}
+
+// Summary renders the usage on one line, e.g. "cpu 0.8s user + 0.1s sys,
+// max RSS 45.3MB". Max RSS is omitted where the platform does not report it.
+func (u *ResourceUsage) Summary() string {
+	s := fmt.Sprintf("cpu %s user + %s sys", u.UserCPU.Round(time.Millisecond), u.SystemCPU.Round(time.Millisecond))
+	if u.MaxRSSBytes > 0 {
+		s += ", max RSS " + FormatBytes(u.MaxRSSBytes)
+	}
+	return s
+}
+
+// ExecOptions holds per-call execution options. A nil *ExecOptions is
+// equivalent to the zero value.
+type ExecOptions struct {
+	// Stdin is piped to the child process, which sees EOF once it has been
+	// fully consumed. When empty the child's stdin is the null device.
+	Stdin string
+
+	// OnOutput, if set, receives output as it is produced, one line at a
+	// time, with stream set to "stdout" or "stderr". It is never called
+	// concurrently, every call completes before Execute returns, and it sees
+	// output beyond MaxOutputBytes even though the captured result does not.
+	OnOutput func(stream string, chunk []byte)
+
+	// WorkingDir overrides the sandbox working directory for this call. A
+	// relative path is resolved against the sandbox working directory, and
+	// the result must stay inside it.
+	WorkingDir string
+
+	// Timeout overrides the sandbox timeout for this call. It is capped at
+	// Config.MaxTimeout when that is set.
+	Timeout time.Duration
+
+	// PTY, if set, runs the command under a pseudo-terminal (Linux only).
+	// Stdin cannot be used with it.
+	PTY *PTYOptions
+
+	// CombineOutput sends stdout and stderr through one pipe and captures
+	// them together in ExecutionResult.Combined, keeping compiler errors
+	// next to the output that preceded them. OnOutput then receives all
+	// output as "stdout". It has no effect with PTY, which already merges
+	// the streams into Stdout.
+	CombineOutput bool
+
+	// OnProgress, if set, is called every ProgressInterval (default 10s)
+	// while the command runs, with how long it has been running and the
+	// latest line it wrote to stderr (or to the combined output). Calls
+	// complete before Execute returns.
+	OnProgress       ProgressCallback
+	ProgressInterval time.Duration
 }
 
 // Sandbox is the interface for sandboxed code execution
@@ -19,61 +128,228 @@ type Sandbox interface {
 	// Execute runs a command in the sandbox
 	Execute(ctx context.Context, command string, args []string) (*ExecutionResult, error)
 
-	// ExecuteScript runs a script in the sandbox
+	// ExecuteWithOptions runs a command in the sandbox with per-call options
+	ExecuteWithOptions(ctx context.Context, command string, args []string, opts *ExecOptions) (*ExecutionResult, error)
+
+	// ExecuteScript runs a script in the sandbox. The interpreter may include
+	// a launcher, e.g. "bundle exec ruby"; the script path is appended.
+	// For "wasmtime" the script is a WebAssembly module instead: the path
+	// of a .wasm file in the workspace or the module's base64 encoding.
 	ExecuteScript(ctx context.Context, interpreter string, script string) (*ExecutionResult, error)
 
+	// ExecuteScriptWithOptions runs a script in the sandbox with per-call options
+	ExecuteScriptWithOptions(ctx context.Context, interpreter string, script string, opts *ExecOptions) (*ExecutionResult, error)
+
+	// Plan reports what ExecuteWithOptions would run, without running it
+	Plan(ctx context.Context, command string, args []string, opts *ExecOptions) (*ExecutionPlan, error)
+
+	// PlanScript reports what ExecuteScriptWithOptions would run, without
+	// running it
+	PlanScript(ctx context.Context, interpreter string, script string, opts *ExecOptions) (*ExecutionPlan, error)
+
+	// StartBackground starts a long-running command and returns a handle to
+	// it without waiting for it to exit
+	StartBackground(ctx context.Context, command string, args []string, opts *ExecOptions) (ProcessHandle, error)
+
+	// Close stops all background processes started in the sandbox
+	Close() error
+
 	// WorkingDir returns the sandbox working directory
 	WorkingDir() string
+
+	// ResolveWorkingDir returns the absolute directory a command with
+	// ExecOptions.WorkingDir set to override runs in, or ErrInvalidWorkingDir
+	// if it is outside the sandbox or not a directory
+	ResolveWorkingDir(override string) (string, error)
+
+	// NetworkDisabled reports whether executed commands are cut off from the
+	// network. It returns false when isolation was requested but cannot be
+	// enforced on this host.
+	NetworkDisabled() bool
+
+	// Capabilities probes the interpreters available on this host and
+	// reports the network, isolation and limit settings in effect
+	Capabilities(ctx context.Context) (*Capabilities, error)
 }
 
 // Config holds sandbox configuration
 type Config struct {
 	WorkingDir       string            // Working directory for execution
 	Timeout          time.Duration     // Maximum execution time
-	AllowedEnv       []string          // Environment variables to pass through
+	MaxTimeout       time.Duration     // Upper bound for per-call timeout overrides (0 = no cap)
+	KillGrace        time.Duration     // Time between SIGTERM and SIGKILL on timeout (0 = kill at once)
+	AllowedEnv       []string          // Environment variables to pass through; names may use * wildcards
 	CustomEnv        map[string]string // Custom environment variables to set
 	MaxOutputBytes   int64             // Maximum output size in bytes
-	CommandBlacklist []string          // Patterns to block (supports wildcards)
+	MaxOutputLines   int               // Maximum output lines per stream, keeping head and tail (0 = unlimited)
+	CommandBlacklist []string          // Patterns to block: literal, glob with *, or "re:" regex; "any:" matches anywhere
+
+	// InheritAllEnv passes the whole parent environment through to commands
+	// except variables matching DeniedEnv. DeniedEnv applies to AllowedEnv
+	// as well: deny beats allow, which beats inherit. CustomEnv is always set.
+	InheritAllEnv bool
+	DeniedEnv     []string
+
+	// CommandAllowlist, when non-empty, restricts execution to the listed
+	// programs. Shell commands (bash -c) are parsed and the first word of
+	// each pipeline segment is checked; scripts may only use listed
+	// interpreters. Entries are names looked up on PATH or absolute paths;
+	// a program run by path must be the same file as an entry. The
+	// blacklist still applies on top of it.
+	CommandAllowlist []string
+
+	// Interpreters adds or overrides entries of DefaultInterpreters,
+	// keyed by interpreter name, e.g. {"lua": {Extension: ".lua"}}
+	Interpreters map[string]InterpreterSpec
+
+	// PerInterpreterLimits overrides Timeout and MaxOutputBytes by program:
+	// the interpreter name for scripts, and for commands the program run,
+	// or the first program of a shell command string. A per-call timeout
+	// still takes precedence. DefaultConfig uses DefaultInterpreterLimits.
+	PerInterpreterLimits map[string]Limits
+
+	// Isolation selects the confinement backend (default BackendProcess).
+	// With BackendBwrap or BackendNsjail only WorkingDir (read-write) and
+	// ReadOnlyPaths are visible to commands; BackendFirejail drops
+	// privileges and BackendGVisor interposes gVisor's kernel, but both
+	// leave the filesystem visible. If the backend is
+	// unavailable a warning is logged and commands run unconfined.
+	Isolation IsolationBackend
+
+	// ReadOnlyPaths are the host paths visible read-only under confinement
+	// (nil uses DefaultReadOnlyPaths)
+	ReadOnlyPaths []string
+
+	// ScriptDir holds the temporary files scripts are written to, created
+	// with owner-only permissions (default: .looper/tmp under WorkingDir)
+	ScriptDir string
+
+	// StaleScriptAge is the age after which script files left behind by a
+	// killed run are deleted when a sandbox is created (0 = never)
+	StaleScriptAge time.Duration
+
+	// GoScratchDir is the persistent module go scripts are built in, which
+	// keeps the build cache warm between calls (default: .looper/go-scratch
+	// under WorkingDir)
+	GoScratchDir string
+
+	// MaxConcurrentExecutions bounds how many commands and scripts run at
+	// once across everything sharing the sandbox (0 = unlimited). Further
+	// calls wait for a slot, or until their context ends; the timeout starts
+	// once they run. Background processes are bounded separately by
+	// MaxBackgroundProcesses, since one may hold a slot indefinitely.
+	MaxConcurrentExecutions int
+
+	// MaxBackgroundProcesses bounds how many background processes run at
+	// once (0 = unlimited). StartBackground fails with
+	// ErrTooManyBackgroundProcesses rather than waiting, as a running
+	// process may never exit.
+	MaxBackgroundProcesses int
+
+	// Resource limits applied to each child process (0 = unlimited).
+	// Enforced on Linux; other platforms log a warning and run unlimited.
+	// Commands start under prlimit(1) so the limits hold from their first
+	// instruction; without prlimit they are applied just after start.
+	// DefaultConfig bounds open files where this is supported, as a defense
+	// against descriptor leaks.
+	//
+	// MaxProcesses is off by default. RLIMIT_NPROC counts every process and
+	// thread owned by the user running looper, including the user's other
+	// programs, so a value below that total makes every fork in the sandbox
+	// fail with EAGAIN. Set it (e.g. to DefaultMaxProcesses) on hosts where
+	// the sandbox runs as a dedicated user.
+	MaxCPUSeconds  int   // CPU time limit (RLIMIT_CPU)
+	MaxMemoryBytes int64 // Virtual memory limit (RLIMIT_AS)
+	MaxProcesses   int   // Process count limit for the sandbox user (RLIMIT_NPROC)
+	MaxOpenFiles   int   // Open file descriptor limit (RLIMIT_NOFILE)
+
+	// NicePriority and IOPriority lower the CPU and disk priority of child
+	// processes so builds don't make the machine stutter (Linux only).
+	// NicePriority is a nice value from 1 (slightly lower) to 19 (lowest);
+	// IOPriority is IOPriorityLow or IOPriorityIdle. The zero values leave
+	// priorities unchanged. Failing to apply them logs a warning and the
+	// command runs anyway.
+	NicePriority int
+	IOPriority   string
+
+	// Secret redaction. Values of environment variables whose names match
+	// SecretEnv (from CustomEnv or the parent environment) are replaced with
+	// [REDACTED:NAME] in command output, and matches of the SecretPatterns
+	// regexes with [REDACTED]. DisableRedaction turns this off for debugging.
+	SecretEnv        []string
+	SecretPatterns   []string
+	DisableRedaction bool
+
+	// AuditLog, if set, receives one JSON line per execution attempt,
+	// including attempts refused by the blacklist or allowlist. See
+	// AuditEntry for the format. AuditPrevHash continues the hash chain of
+	// entries already in the log (see AuditChainHead).
+	AuditLog      io.Writer
+	AuditPrevHash string
+
+	// OnBlocked, if set, is called with each command or script the
+	// blacklist refuses, e.g. to raise an alert. It runs on the goroutine
+	// of the refused call, so it must be quick and safe for concurrent use.
+	// Plans and dry runs do not trigger it.
+	OnBlocked func(*BlacklistError)
+
+	// DryRun makes Execute and ExecuteScript return the execution plan as
+	// stdout with exit code 0 instead of running anything (see Plan), and
+	// StartBackground fail with ErrDryRun. Dry runs are not audited.
+	DryRun bool
+
+	// DisableNetwork runs commands in an isolated network namespace (Linux
+	// only). Where this cannot be enforced a warning is logged and commands
+	// keep network access.
+	DisableNetwork bool
 }
 
 // DefaultConfig returns a default sandbox configuration
 func DefaultConfig(workingDir string) *Config {
-	return &Config{
-		WorkingDir:     workingDir,
-		Timeout:        30 * time.Second,
-		MaxOutputBytes: 1024 * 1024, // 1MB
-		AllowedEnv: []string{
-			"PATH",
-			"HOME",
-			"USER",
-			"LANG",
-			"LC_ALL",
-		},
+	config := &Config{
+		WorkingDir:       workingDir,
+		Timeout:          30 * time.Second,
+		MaxTimeout:       10 * time.Minute,
+		KillGrace:        5 * time.Second,
+		MaxOutputBytes:   1024 * 1024, // 1MB
+		StaleScriptAge:   24 * time.Hour,
+		AllowedEnv:       defaultAllowedEnv(),
+		DeniedEnv:        DefaultDeniedEnv(),
 		CustomEnv:        make(map[string]string),
 		CommandBlacklist: DefaultBlacklist(),
+		SecretEnv:        DefaultSecretEnv(),
+		SecretPatterns:   DefaultSecretPatterns(),
+
+		PerInterpreterLimits: DefaultInterpreterLimits(),
+	}
+	if resourceLimitsSupported {
+		config.MaxOpenFiles = DefaultMaxOpenFiles
 	}
+	return config
 }
 
-// DefaultBlacklist returns a default list of dangerous command patterns
+// DefaultBlacklist returns a default list of dangerous command patterns.
+// Most patterns only match at command positions, so "passwd" blocks running
+// passwd but not cat /etc/passwd; "any:" patterns match anywhere.
 func DefaultBlacklist() []string {
 	return []string{
 		// Destructive file operations
 		"rm -rf /",
-		"rm -rf /*",
+		`rm -rf /\*`,
 		"rm -rf ~",
 		"rm -rf .",
 		"rm -rf ..",
 		"rm -fr /",
-		"rm -fr /*",
-		"> /dev/sda",
+		`rm -fr /\*`,
+		"any:> /dev/sda",
 		"dd if=/dev/zero of=/dev/sda",
 		"dd if=/dev/random of=/dev/sda",
-		"mkfs.",
+		"mkfs*",
 		"wipefs",
 
 		// Fork bombs and resource exhaustion
-		":(){ :|:& };:",
-		"fork while fork",
+		"any::(){ :|:& };:",
+		"any:fork while fork",
 
 		// System manipulation
 		"chmod -R 777 /",
@@ -87,7 +363,7 @@ func DefaultBlacklist() []string {
 		"telinit 0",
 
 		// Network attacks
-		"nc -l", // Netcat listener (could be used for reverse shells)
+		"nc -l*", // Netcat listener (could be used for reverse shells)
 
 		// Dangerous downloads and execution
 		"curl * | sh",
@@ -97,8 +373,8 @@ func DefaultBlacklist() []string {
 
 		// History/log tampering
 		"history -c",
-		"cat /dev/null >",
-		"> ~/.bash_history",
+		"cat /dev/null >*",
+		"any:> ~/.bash_history",
 
 		// Privilege escalation attempts
 		"sudo su",
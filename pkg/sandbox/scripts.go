@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scriptFilePrefix starts the name of every temporary script file, which
+// lets the stale sweep recognize them
+const scriptFilePrefix = "looper-script-"
+
+// scriptDirPath returns the configured script directory as an absolute path
+func (c *Config) scriptDirPath() (string, error) {
+	dir := c.ScriptDir
+	if dir == "" {
+		dir = filepath.Join(c.WorkingDir, ".looper", "tmp")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid script directory: %w", err)
+	}
+	return abs, nil
+}
+
+// scriptTempDir returns the directory scripts are written to, creating it
+// private to the current user on first use. It is inside the workspace by
+// default, so confined commands see it through the workspace mount.
+func (s *ProcessSandbox) scriptTempDir() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scriptDir == "" {
+		dir, err := s.config.scriptDirPath()
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create script directory: %w", err)
+		}
+		s.scriptDir = dir
+	}
+	return s.scriptDir, nil
+}
+
+// sweepStaleScripts deletes script files older than maxAge, left behind by
+// runs that were killed before they could clean up. Failures are logged.
+func sweepStaleScripts(dir string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("sandbox: failed to sweep stale scripts: %v", err)
+		}
+		return
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), scriptFilePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			log.Printf("sandbox: failed to remove stale script: %v", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScriptFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions on Windows")
+	}
+	requirePrograms(t, "bash", "python3")
+	sb := newTestSandbox(t, nil)
+	dir := filepath.Join(sb.config.WorkingDir, ".looper", "tmp")
+
+	// Each script reports its own path and mode
+	result, err := sb.ExecuteScript(context.Background(), "python3", "import os\nst = os.stat(__file__)\nprint(os.path.dirname(__file__), oct(st.st_mode & 0o777))\n")
+	if err != nil {
+		t.Fatalf("python3: %v", err)
+	}
+	if got, want := strings.TrimSpace(result.Stdout), dir+" 0o600"; got != want {
+		t.Errorf("python3 script: %q, want %q (stderr %q)", got, want, result.Stderr)
+	}
+
+	// Shell scripts are made executable, by their owner only
+	result, err = sb.ExecuteScript(context.Background(), "bash", `echo "$(dirname "$0") $(stat -c %a "$0" 2>/dev/null || stat -f %Lp "$0")"`)
+	if err != nil {
+		t.Fatalf("bash: %v", err)
+	}
+	if got, want := strings.TrimSpace(result.Stdout), dir+" 700"; got != want {
+		t.Errorf("bash script: %q, want %q", got, want)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("script directory mode = %o, want 700", perm)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("script files left behind: %v", entries)
+	}
+}
+
+func TestSweepStaleScripts(t *testing.T) {
+	workDir := t.TempDir()
+	scriptDir := filepath.Join(t.TempDir(), "scripts")
+	if err := os.Mkdir(scriptDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]bool{ // Name and whether the sweep removes it
+		scriptFilePrefix + "old.py":  true,
+		scriptFilePrefix + "old.sh":  true,
+		scriptFilePrefix + "new.py":  false,
+		"notes-old.txt":              false,
+		scriptFilePrefix + "old-dir": false,
+	}
+	for name := range files {
+		path := filepath.Join(scriptDir, name)
+		var err error
+		if strings.HasSuffix(name, "-dir") {
+			err = os.Mkdir(path, 0700)
+		} else {
+			err = os.WriteFile(path, []byte("x"), 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(name, "new") {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	config := DefaultConfig(workDir)
+	config.ScriptDir = scriptDir
+	config.StaleScriptAge = 24 * time.Hour
+	sb, err := NewProcessSandbox(config)
+	if err != nil {
+		t.Fatalf("NewProcessSandbox: %v", err)
+	}
+	sb.Close()
+
+	for name, removed := range files {
+		_, err := os.Stat(filepath.Join(scriptDir, name))
+		if exists := err == nil; exists == removed {
+			t.Errorf("%s: exists = %v after the sweep", name, exists)
+		}
+	}
+}
+
+func TestSweepStaleScriptsDisabled(t *testing.T) {
+	workDir := t.TempDir()
+	scriptDir := filepath.Join(workDir, ".looper", "tmp")
+	if err := os.MkdirAll(scriptDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(scriptDir, scriptFilePrefix+"old.py")
+	if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(path, old, old)
+
+	newTestSandbox(t, func(c *Config) {
+		c.WorkingDir = workDir
+		c.StaleScriptAge = 0
+	})
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("script removed with StaleScriptAge 0: %v", err)
+	}
+}
+
+func TestWrapPHPScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"no tag", "echo 1;", "<?php\necho 1;"},
+		{"tag", "<?php echo 1;", "<?php echo 1;"},
+		{"tag after whitespace", "\n  <?PHP echo 1;", "\n  <?PHP echo 1;"},
+		{"shebang and tag", "#!/usr/bin/env php\n<?php echo 1;", "#!/usr/bin/env php\n<?php echo 1;"},
+		{"shebang without tag", "#!/usr/bin/env php\necho 1;", "#!/usr/bin/env php\n<?php\necho 1;"},
+		{"shebang only", "#!/usr/bin/env php", "#!/usr/bin/env php\n<?php\n"},
+		{"tag later on", "echo 1; ?>\n<?php echo 2;", "<?php\necho 1; ?>\n<?php echo 2;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapPHPScript(tt.script); got != tt.want {
+				t.Errorf("wrapPHPScript(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
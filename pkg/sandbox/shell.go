@@ -0,0 +1,401 @@
+package sandbox
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envAssignment matches a leading VAR=value word in a shell command
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// redirection matches a leading redirection word such as >out, 2>&1 or <in
+var redirection = regexp.MustCompile(`^[0-9]*(<|>|&>)`)
+
+// durationArg matches numeric wrapper arguments such as "nice 10" or
+// "timeout 5s"
+var durationArg = regexp.MustCompile(`^[0-9.]+[smhd]?$`)
+
+// commandWrappers run the command given in their arguments
+var commandWrappers = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "nohup": true, "exec": true,
+	"nice": true, "time": true, "timeout": true, "xargs": true,
+	"command": true, "builtin": true, "stdbuf": true, "setsid": true,
+}
+
+// wrapperValueOptions are wrapper options that take the following word as
+// their value
+var wrapperValueOptions = map[string]bool{
+	"-u": true, "-g": true, "-n": true, "-s": true, "-k": true, "-C": true,
+}
+
+// shellCommand is a simple command parsed from a shell script
+type shellCommand struct {
+	words []string
+	pipe  bool // Output is piped into the next command
+}
+
+func isShell(command string) bool {
+	switch programName(command) {
+	case "bash", "sh", "zsh", "dash":
+		return true
+	}
+	return false
+}
+
+// programName returns the lowercased base name of a program without a
+// Windows .exe suffix, accepting either path separator
+func programName(command string) string {
+	if i := strings.LastIndexAny(command, `/\`); i >= 0 {
+		command = command[i+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(command), ".exe")
+}
+
+// shellScriptArg returns the script a shell invocation runs: the argument
+// of "bash -c", "powershell -Command" or everything after "cmd /C"
+func shellScriptArg(command string, args []string) (string, bool) {
+	switch program := programName(command); {
+	case isShell(program):
+		if len(args) >= 2 && args[0] == "-c" {
+			return args[1], true
+		}
+	case program == "powershell" || program == "pwsh":
+		for i := 0; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "-Command") || strings.EqualFold(args[i], "-c") {
+				return strings.Join(args[i+1:], " "), true
+			}
+		}
+	case program == "cmd":
+		for i := 0; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "/C") || strings.EqualFold(args[i], "/K") {
+				return strings.Join(args[i+1:], " "), true
+			}
+		}
+	}
+	return "", false
+}
+
+// shellPrograms returns the program of each simple command in a shell
+// script. This is a best-effort parse: it understands quoting, pipelines,
+// lists (&&, ||, ;, &), subshells, command substitution, redirections,
+// leading VAR=value assignments and common compound-command keywords, but
+// not the full shell grammar.
+func shellPrograms(script string) []string {
+	var programs []string
+	for _, cmd := range parseShell(script) {
+		if i := commandStart(cmd.words); i >= 0 {
+			programs = append(programs, cmd.words[i])
+		}
+	}
+	return programs
+}
+
+// commandStart returns the index of the program word of a simple command,
+// or -1 if it has none
+func commandStart(words []string) int {
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case envAssignment.MatchString(word):
+			continue
+		case redirection.MatchString(word):
+			// A bare operator takes the following word as its target
+			if strings.TrimLeft(word, "0123456789<>&") == "" {
+				i++
+			}
+			continue
+		}
+
+		switch word {
+		case "!", "{", "}", "if", "then", "else", "elif", "fi", "do", "done",
+			"while", "until", "time", "esac":
+			continue
+		case "for", "case", "select", "in":
+			// The rest of the segment is a word list, not a command
+			return -1
+		}
+		return i
+	}
+	return -1
+}
+
+// commandStarts returns the index of the program word of a simple command
+// followed by the index of every program it runs through wrappers such as
+// sudo, env or xargs
+func commandStarts(words []string) []int {
+	i := commandStart(words)
+	if i < 0 {
+		return nil
+	}
+
+	starts := []int{i}
+	for commandWrappers[filepath.Base(words[i])] {
+		i++
+		for i < len(words) {
+			word := words[i]
+			switch {
+			case wrapperValueOptions[word]:
+				i += 2
+				continue
+			case strings.HasPrefix(word, "-"), envAssignment.MatchString(word), durationArg.MatchString(word):
+				i++
+				continue
+			}
+			break
+		}
+		if i >= len(words) {
+			break
+		}
+		starts = append(starts, i)
+	}
+	return starts
+}
+
+// expandShellCommands appends the commands run by nested shells
+// (bash -c '...') and eval to cmds, up to a fixed depth
+func expandShellCommands(cmds []shellCommand) []shellCommand {
+	const maxDepth = 3
+
+	pending := cmds
+	for depth := 0; depth < maxDepth && len(pending) > 0; depth++ {
+		var nested []shellCommand
+		for _, cmd := range pending {
+			for _, start := range commandStarts(cmd.words) {
+				program := cmd.words[start]
+				args := cmd.words[start+1:]
+				if programName(program) == "eval" {
+					nested = append(nested, parseShell(strings.Join(args, " "))...)
+					continue
+				}
+				// Options may precede -c, as in "sh -e -c"
+				for i := range args {
+					if script, ok := shellScriptArg(program, args[i:]); ok {
+						nested = append(nested, parseShell(script)...)
+						break
+					}
+				}
+			}
+		}
+		cmds = append(cmds, nested...)
+		pending = nested
+	}
+	return cmds
+}
+
+// parseShell splits a shell script into simple commands, each a list of
+// unquoted words. Comments are dropped.
+func parseShell(script string) []shellCommand {
+	var (
+		commands []shellCommand
+		words    []string
+		word     strings.Builder
+		inWord   bool
+		quote    rune
+		// nesting tracks open subshells and command substitutions so that
+		// parsing resumes in the enclosing command when they close
+		nesting []shellNesting
+	)
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func(pipe bool) {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, shellCommand{words: words, pipe: pipe})
+			words = nil
+		}
+	}
+	open := func(closer rune) {
+		nesting = append(nesting, shellNesting{
+			closer: closer,
+			quote:  quote,
+			words:  words,
+			word:   word.String(),
+			inWord: inWord,
+		})
+		words, quote, inWord = nil, 0, false
+		word.Reset()
+	}
+	closeNesting := func(closer rune) bool {
+		if len(nesting) == 0 || nesting[len(nesting)-1].closer != closer {
+			return false
+		}
+		endCommand(false)
+		outer := nesting[len(nesting)-1]
+		nesting = nesting[:len(nesting)-1]
+		words, quote, inWord = outer.words, outer.quote, outer.inWord
+		word.WriteString(outer.word)
+		return true
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if quote != 0 {
+			switch {
+			case r == quote:
+				quote = 0
+			case quote == '"' && r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+				// Command substitution inside double quotes still runs a command
+				i++
+				open(')')
+			case quote == '"' && r == '`':
+				open('`')
+			case r == '\\' && quote == '"' && i+1 < len(runes):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+			continue
+		}
+
+		switch r {
+		case '\'', '"':
+			quote = r
+			inWord = true
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+					inWord = true
+				}
+			}
+		case ' ', '\t', '\r':
+			endWord()
+		case '#':
+			if inWord {
+				word.WriteRune(r)
+				continue
+			}
+			// Comment until end of line
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case '&':
+			// Part of a redirection such as 2>&1 or &>file
+			if (i > 0 && (runes[i-1] == '>' || runes[i-1] == '<')) || (i+1 < len(runes) && runes[i+1] == '>') {
+				word.WriteRune(r)
+				inWord = true
+				continue
+			}
+			endCommand(false)
+		case '$':
+			if i+1 < len(runes) && runes[i+1] == '(' {
+				i++
+				open(')')
+				continue
+			}
+			word.WriteRune(r)
+			inWord = true
+		case '(':
+			open(')')
+		case ')':
+			if !closeNesting(')') {
+				endCommand(false)
+			}
+		case '`':
+			if !closeNesting('`') {
+				open('`')
+			}
+		case '|':
+			if i+1 < len(runes) && runes[i+1] == '|' {
+				i++
+				endCommand(false)
+				continue
+			}
+			if i+1 < len(runes) && runes[i+1] == '&' {
+				i++
+			}
+			endCommand(true)
+		case ';', '\n':
+			endCommand(false)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand(false)
+
+	return commands
+}
+
+// shellNesting is an open subshell or command substitution together with
+// the parser state of the command it appears in, restored when it closes
+type shellNesting struct {
+	closer rune
+	quote  rune
+	words  []string
+	word   string
+	inWord bool
+}
+
+// scriptLiterals returns the string literals of a script written in a
+// non-shell language, skipping comments. Python uses # comments and triple
+// quotes; node, deno, bun and go use // and /* */ comments and backtick
+// strings; php uses both comment styles; other interpreters are assumed to
+// use # comments.
+func scriptLiterals(interpreter, script string) []string {
+	var (
+		hashComments = true
+		cComments    = false
+		backticks    = false
+		triple       = false
+	)
+	switch filepath.Base(interpreter) {
+	case "python", "python3":
+		triple = true
+	case "node", "nodejs", "deno", "bun", "go":
+		hashComments, cComments, backticks = false, true, true
+	case "php":
+		cComments = true
+	}
+
+	var literals []string
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		rest := script[i:]
+		switch {
+		case hashComments && c == '#', cComments && strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				return literals
+			}
+			i += end
+		case cComments && strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return literals
+			}
+			i += end + 3
+		case triple && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			delim := rest[:3]
+			end := strings.Index(rest[3:], delim)
+			if end < 0 {
+				return append(literals, rest[3:])
+			}
+			literals = append(literals, rest[3:3+end])
+			i += end + 5
+		case c == '"' || c == '\'' || (backticks && c == '`'):
+			var lit strings.Builder
+			j := i + 1
+			for ; j < len(script) && script[j] != c; j++ {
+				if script[j] == '\\' && c != '`' && j+1 < len(script) {
+					j++
+				}
+				lit.WriteByte(script[j])
+			}
+			literals = append(literals, lit.String())
+			i = j
+		}
+	}
+	return literals
+}
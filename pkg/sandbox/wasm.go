@@ -0,0 +1,142 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// wasmInterpreter is the interpreter that runs WebAssembly modules. Its
+// "script" is binary: a module path or base64-encoded module bytes.
+const wasmInterpreter = "wasmtime"
+
+// wasmMagic starts every WebAssembly binary module
+var wasmMagic = []byte("\x00asm")
+
+// ErrInvalidWasmModule is returned by ExecuteScript when a wasm script is
+// neither a .wasm file in the workspace nor a base64-encoded module
+var ErrInvalidWasmModule = errors.New("invalid WebAssembly module")
+
+// executeWasm runs a WebAssembly module with wasmtime. The script is either
+// the path of a .wasm file, relative to the working directory and inside
+// the sandbox, or the base64-encoded module, which is written to a temp
+// file first. The working directory is the module's only preopened
+// directory ("--dir=." in the default interpreter table).
+func (s *ProcessSandbox) executeWasm(ctx context.Context, launcher []string, script string, opts *ExecOptions, limits Limits) (*ExecutionResult, error) {
+	var workingDir string
+	if opts != nil {
+		workingDir = opts.WorkingDir
+	}
+	module, cleanup, err := s.wasmModule(script, workingDir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	spec := s.interpreterSpec(wasmInterpreter)
+	args := append(append(append([]string{}, launcher[1:]...), spec.Args...), module)
+	cmd := exec.CommandContext(ctx, launcher[0], args...)
+	return s.runCommand(ctx, cmd, opts, limits)
+}
+
+// wasmModule returns the path of the module a wasm script names or
+// contains. cleanup removes any temp file and is never nil.
+func (s *ProcessSandbox) wasmModule(script, workingDir string) (path string, cleanup func(), err error) {
+	cleanup = func() {}
+	script = strings.TrimSpace(script)
+
+	if isWasmPath(script) {
+		path, err := s.wasmModulePath(script, workingDir)
+		return path, cleanup, err
+	}
+
+	data, err := decodeWasm(script)
+	if err != nil {
+		return "", cleanup, err
+	}
+	tmpDir, err := s.scriptTempDir()
+	if err != nil {
+		return "", cleanup, err
+	}
+	tmpFile, err := os.CreateTemp(tmpDir, scriptFilePrefix+"*"+s.interpreterSpec(wasmInterpreter).Extension)
+	if err != nil {
+		return "", cleanup, fmt.Errorf("failed to create temp module: %w", err)
+	}
+	path = tmpFile.Name()
+	cleanup = func() { os.Remove(path) }
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		cleanup()
+		return "", func() {}, fmt.Errorf("failed to write module: %w", err)
+	}
+	tmpFile.Close()
+	return path, cleanup, nil
+}
+
+// wasmModulePath resolves a module path against the working directory. The
+// file must lie inside the sandbox and start with the wasm magic number.
+func (s *ProcessSandbox) wasmModulePath(name, workingDir string) (string, error) {
+	dir, err := s.ResolveWorkingDir(workingDir)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.Abs(s.config.WorkingDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidWorkingDir, err)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+	if !withinDir(root, path) {
+		return "", fmt.Errorf("%w: %s is outside the workspace", ErrInvalidWasmModule, name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidWasmModule, err)
+	}
+	defer f.Close()
+	header := make([]byte, len(wasmMagic))
+	if _, err := f.Read(header); err != nil || !bytes.Equal(header, wasmMagic) {
+		return "", fmt.Errorf("%w: %s is not a WebAssembly binary", ErrInvalidWasmModule, name)
+	}
+	return path, nil
+}
+
+// isWasmPath reports whether a wasm script names a module file rather than
+// holding its base64 encoding, which never contains a '.'
+func isWasmPath(script string) bool {
+	return strings.HasSuffix(strings.ToLower(script), ".wasm") && !strings.ContainsFunc(script, unicode.IsSpace)
+}
+
+// decodeWasm decodes a base64-encoded module, padded or not and ignoring
+// line breaks, and checks that the result is a WebAssembly binary
+func decodeWasm(script string) ([]byte, error) {
+	encoded := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, script)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: code is neither a .wasm path nor valid base64: %v", ErrInvalidWasmModule, err)
+	}
+	if !bytes.HasPrefix(data, wasmMagic) {
+		return nil, fmt.Errorf("%w: decoded code does not start with the wasm magic number", ErrInvalidWasmModule)
+	}
+	return data, nil
+}
@@ -1,31 +1,122 @@
 package skills
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 )
 
+// SkillEntryFile is the entry point of a skill packaged as a directory,
+// e.g. skills/deploy/SKILL.md. The other files in the directory are the
+// skill's bundled resources.
+const SkillEntryFile = "SKILL.md"
+
+// GlobalSkillsPathEnv names the environment variable that overrides the
+// global skill directories with a list separated like PATH
+const GlobalSkillsPathEnv = "LOOPER_SKILLS_PATH"
+
+// Origin records which kind of directory a skill was discovered in
+type Origin string
+
+const (
+	// OriginGlobal is a user-level skill directory shared by every workspace
+	OriginGlobal Origin = "global"
+	// OriginAdditional is a directory from DiscoveryConfig.AdditionalSkillDirs
+	OriginAdditional Origin = "additional"
+	// OriginWorkspace is the workspace skills/ directory
+	OriginWorkspace Origin = "workspace"
+)
+
+// DiscoveryConfig configures where skills are discovered
+type DiscoveryConfig struct {
+	// WorkspaceRoot is the workspace directory. Its skills/ subdirectory is
+	// scanned last, so workspace skills override skills from other directories.
+	WorkspaceRoot string
+
+	// GlobalSkillDirs are user-level skill directories, such as
+	// DefaultGlobalSkillDirs, scanned in order before every other directory
+	GlobalSkillDirs []string
+
+	// AdditionalSkillDirs are extra skill directories scanned in order after
+	// the global directories and before the workspace skills directory.
+	// Later directories take precedence when two skills share a name.
+	AdditionalSkillDirs []string
+}
+
+// DefaultGlobalSkillDirs returns the user-level skill directories: the
+// LOOPER_SKILLS_PATH list if it is set, otherwise ~/.looper/skills. It
+// returns nil if neither is available.
+func DefaultGlobalSkillDirs() []string {
+	if path := os.Getenv(GlobalSkillsPathEnv); path != "" {
+		var dirs []string
+		for _, dir := range filepath.SplitList(path) {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+		return dirs
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".looper", "skills")}
+}
+
+// ErrDiscoveryIncomplete is returned by DiscoverWithContext when the context
+// ends before every skill directory has been scanned
+var ErrDiscoveryIncomplete = errors.New("skill discovery incomplete")
+
+// skillRoot is a directory scanned for skills
+type skillRoot struct {
+	dir    string
+	origin Origin
+}
+
 // Discovery handles finding and loading skills from a workspace
 type Discovery struct {
 	workspaceRoot string
 	skillsDir     string
+	globalDirs    []string
+	extraDirs     []string
 	loader        *Loader
 	mu            sync.RWMutex
 	skills        map[string]*Skill // Loaded skills by name
 	fileIndex     map[string]string // Map of skill name to file path
+	dirIndex      map[string]string // Map of directory skill name to its directory
+	originIndex   map[string]Origin // Map of skill name to the kind of directory it came from
 	discovered    bool              // Whether discovery has been performed
+
+	// generation counts scans and resets; a walk only merges what it found
+	// while no newer scan or reset has happened
+	generation int
+
+	// skillName reads a skill's name from its frontmatter. Tests replace it
+	// to simulate a slow filesystem.
+	skillName func(path string) string
 }
 
 // NewDiscovery creates a new skill discovery instance
-func NewDiscovery(workspaceRoot string) *Discovery {
+func NewDiscovery(config *DiscoveryConfig) *Discovery {
+	if config == nil {
+		config = &DiscoveryConfig{WorkspaceRoot: "."}
+	}
 	return &Discovery{
-		workspaceRoot: workspaceRoot,
-		skillsDir:     filepath.Join(workspaceRoot, "skills"),
+		workspaceRoot: config.WorkspaceRoot,
+		skillsDir:     filepath.Join(config.WorkspaceRoot, "skills"),
+		globalDirs:    append([]string(nil), config.GlobalSkillDirs...),
+		extraDirs:     append([]string(nil), config.AdditionalSkillDirs...),
 		loader:        NewLoader(),
 		skills:        make(map[string]*Skill),
 		fileIndex:     make(map[string]string),
+		dirIndex:      make(map[string]string),
+		originIndex:   make(map[string]Origin),
+		skillName:     extractSkillName,
 	}
 }
 
@@ -35,32 +126,172 @@ func (d *Discovery) SetSkillsDir(dir string) {
 	defer d.mu.Unlock()
 	d.skillsDir = dir
 	d.discovered = false
+	d.generation++
 	d.skills = make(map[string]*Skill)
 	d.fileIndex = make(map[string]string)
+	d.dirIndex = make(map[string]string)
+	d.originIndex = make(map[string]Origin)
 }
 
-// Discover scans the skills directory and indexes available skills
+// Discover scans the skills directories and indexes available skills
 // This performs lazy discovery - it finds skill files but doesn't load them
 func (d *Discovery) Discover() error {
+	return d.DiscoverWithContext(context.Background())
+}
+
+// DiscoverWithContext is Discover bounded by ctx. The directories are
+// walked in a goroutine, so a walk stuck on a slow filesystem does not hold
+// up the caller: when ctx ends, the skills indexed so far are kept, the
+// index counts as discovered and the error wraps ErrDiscoveryIncomplete and
+// the context's error. The walk stops at its next file, but one stuck in a
+// read lingers until the read returns; the skill it was reading is then
+// merged too, unless Refresh, SetSkillsDir or another scan came first.
+func (d *Discovery) DiscoverWithContext(ctx context.Context) error {
+	d.mu.Lock()
+	roots := d.skillRoots()
+	d.generation++
+	generation := d.generation
+	d.mu.Unlock()
+
+	found := newSkillIndex()
+	done := make(chan error, 1)
+	go func() {
+		// Scan in precedence order so later directories overwrite earlier entries
+		var err error
+		for _, root := range roots {
+			if err = d.discoverDir(ctx, found, root.dir, root.origin); err != nil {
+				break
+			}
+		}
+		d.merge(found, generation)
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		d.merge(found, generation)
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && (err == nil || errors.Is(err, ctxErr)) {
+		return fmt.Errorf("%w: %w", ErrDiscoveryIncomplete, ctxErr)
+	}
+	return err
+}
+
+// merge copies the skills a walk has found so far into the index and marks
+// it discovered, unless the walk has been superseded
+func (d *Discovery) merge(found *skillIndex, generation int) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if d.generation != generation {
+		return
+	}
+	found.mergeInto(d)
+	d.discovered = true
+}
 
+// skillIndex collects the skills found by a discovery walk until they are
+// merged into the Discovery
+type skillIndex struct {
+	mu      sync.Mutex
+	files   map[string]string // Skill name to file path
+	dirs    map[string]string // Directory skill name to its directory
+	origins map[string]Origin // Skill name to the kind of directory it came from
+}
+
+func newSkillIndex() *skillIndex {
+	return &skillIndex{
+		files:   make(map[string]string),
+		dirs:    make(map[string]string),
+		origins: make(map[string]Origin),
+	}
+}
+
+// add records a skill found in a directory of the given origin
+func (idx *skillIndex) add(name, path string, origin Origin, packaged bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.files[name] = path
+	idx.origins[name] = origin
+	if packaged {
+		idx.dirs[name] = filepath.Dir(path)
+	} else {
+		delete(idx.dirs, name)
+	}
+}
+
+// mergeInto copies the index into d, whose lock the caller holds
+func (idx *skillIndex) mergeInto(d *Discovery) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for name, path := range idx.files {
+		d.fileIndex[name] = path
+		d.originIndex[name] = idx.origins[name]
+		if dir, ok := idx.dirs[name]; ok {
+			d.dirIndex[name] = dir
+		} else {
+			delete(d.dirIndex, name)
+		}
+	}
+}
+
+// skillRoots returns the directories to scan, lowest precedence first:
+// global, then additional, then the workspace skills directory
+func (d *Discovery) skillRoots() []skillRoot {
+	roots := make([]skillRoot, 0, len(d.globalDirs)+len(d.extraDirs)+1)
+	for _, dir := range d.globalDirs {
+		roots = append(roots, skillRoot{dir: dir, origin: OriginGlobal})
+	}
+	for _, dir := range d.extraDirs {
+		roots = append(roots, skillRoot{dir: dir, origin: OriginAdditional})
+	}
+	return append(roots, skillRoot{dir: d.skillsDir, origin: OriginWorkspace})
+}
+
+// discoverDir indexes the skills in a single directory: loose .md files,
+// and subdirectories with a SKILL.md entry point, whose other files are
+// bundled resources rather than skills. When a loose file and a directory
+// skill share a name, the directory skill is used and a warning is logged.
+// Each skill is added to idx as soon as it is found, so the skills found
+// before ctx ends or a read blocks are not lost.
+func (d *Discovery) discoverDir(ctx context.Context, idx *skillIndex, skillsDir string, origin Origin) error {
 	// Check if skills directory exists
-	if _, err := os.Stat(d.skillsDir); os.IsNotExist(err) {
-		d.discovered = true
+	if _, err := os.Stat(skillsDir); os.IsNotExist(err) {
 		return nil // No skills directory is fine
 	}
 
+	loose := make(map[string]string)    // Skill name to loose file
+	packaged := make(map[string]string) // Skill name to SKILL.md
+
 	// Walk the skills directory
-	err := filepath.Walk(d.skillsDir, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(skillsDir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			return nil // Skip files we can't access
 		}
 
-		// Skip directories
 		if info.IsDir() {
+			if path == skillsDir {
+				return nil
+			}
 			// Skip hidden directories
-			if strings.HasPrefix(info.Name(), ".") && path != d.skillsDir {
+			if strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			entry := filepath.Join(path, SkillEntryFile)
+			if _, err := os.Stat(entry); err == nil {
+				if skillName := d.skillName(entry); skillName != "" {
+					if file, ok := loose[skillName]; ok {
+						log.Printf("WARNING: skills: %s and %s both define skill %q; using %s", file, entry, skillName, entry)
+					}
+					packaged[skillName] = entry
+					idx.add(skillName, entry, origin, true)
+				}
 				return filepath.SkipDir
 			}
 			return nil
@@ -72,20 +303,27 @@ func (d *Discovery) Discover() error {
 		}
 
 		// Try to extract skill name from frontmatter without fully loading
-		skillName := d.extractSkillName(path)
-		if skillName != "" {
-			d.fileIndex[skillName] = path
+		skillName := d.skillName(path)
+		if skillName == "" {
+			return nil
 		}
-
+		if entry, ok := packaged[skillName]; ok {
+			log.Printf("WARNING: skills: %s and %s both define skill %q; using %s", path, entry, skillName, entry)
+			return nil
+		}
+		loose[skillName] = path
+		idx.add(skillName, path, origin, false)
 		return nil
 	})
-
-	d.discovered = true
-	return err
 }
 
-// extractSkillName reads just enough of the file to get the skill name
-func (d *Discovery) extractSkillName(filePath string) string {
+// extractSkillName reads just enough of the file to get the skill name.
+// Only regular files are read: opening a FIFO would block until something
+// writes to it.
+func extractSkillName(filePath string) string {
+	if info, err := os.Stat(filePath); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
 	file, err := os.Open(filePath)
 	if err != nil {
 		return ""
@@ -147,6 +385,7 @@ type SkillInfo struct {
 	Name        string
 	Description string
 	FilePath    string
+	Origin      Origin
 }
 
 // ListWithDescriptions returns skills with their descriptions
@@ -199,6 +438,7 @@ func (d *Discovery) ListWithInfo() []SkillInfo {
 		info := SkillInfo{
 			Name:     name,
 			FilePath: d.getRelativePath(path),
+			Origin:   d.originIndex[name],
 		}
 
 		// Try to get description from cache first
@@ -223,10 +463,11 @@ func (d *Discovery) ListWithInfo() []SkillInfo {
 	return result
 }
 
-// getRelativePath returns the path relative to workspace root
+// getRelativePath returns the path relative to workspace root, or the full
+// path for skills that live outside the workspace
 func (d *Discovery) getRelativePath(fullPath string) string {
 	rel, err := filepath.Rel(d.workspaceRoot, fullPath)
-	if err != nil {
+	if err != nil || strings.HasPrefix(rel, "..") {
 		return fullPath
 	}
 	return rel
@@ -253,6 +494,8 @@ func (d *Discovery) Get(name string) (*Skill, error) {
 
 	// Find file path
 	filePath, ok := d.fileIndex[name]
+	dir := d.dirIndex[name]
+	origin := d.originIndex[name]
 	d.mu.RUnlock()
 
 	if !ok {
@@ -264,6 +507,8 @@ func (d *Discovery) Get(name string) (*Skill, error) {
 	if err != nil {
 		return nil, err
 	}
+	skill.Dir = dir
+	skill.Origin = origin
 
 	// Cache it
 	d.mu.Lock()
@@ -296,13 +541,28 @@ func (d *Discovery) Refresh() error {
 	d.mu.Lock()
 	d.skills = make(map[string]*Skill)
 	d.fileIndex = make(map[string]string)
+	d.dirIndex = make(map[string]string)
+	d.originIndex = make(map[string]Origin)
 	d.discovered = false
+	d.generation++
 	d.mu.Unlock()
 
 	return d.Discover()
 }
 
-// SkillsDir returns the skills directory path
+// SkillsDir returns the workspace skills directory path
 func (d *Discovery) SkillsDir() string {
 	return d.skillsDir
 }
+
+// SkillDirs returns every directory scanned for skills, lowest precedence first
+func (d *Discovery) SkillDirs() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	roots := d.skillRoots()
+	dirs := make([]string, len(roots))
+	for i, root := range roots {
+		dirs[i] = root.dir
+	}
+	return dirs
+}
@@ -0,0 +1,298 @@
+package skills
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeSkill writes a skill file with the given name and description,
+// creating its directories
+func writeSkill(t *testing.T, path, name, description string) {
+	t.Helper()
+	writeFile(t, path, "---\nname: "+name+"\ndescription: "+description+"\n---\n\n# "+name+"\n")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureLog redirects the standard logger for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func sortedNames(d *Discovery) []string {
+	names := d.List()
+	sort.Strings(names)
+	return names
+}
+
+func TestDiscoverSkillLayouts(t *testing.T) {
+	root := t.TempDir()
+	skillsDir := filepath.Join(root, "skills")
+
+	// Loose files, at the top level and nested
+	writeSkill(t, filepath.Join(skillsDir, "loose.md"), "loose", "A loose skill")
+	writeSkill(t, filepath.Join(skillsDir, "group", "deep.md"), "deep", "A nested loose skill")
+
+	// A directory skill whose bundled files are not skills themselves
+	writeSkill(t, filepath.Join(skillsDir, "deploy", SkillEntryFile), "deploy", "Deploy the service")
+	writeFile(t, filepath.Join(skillsDir, "deploy", "scripts", "run.sh"), "#!/bin/sh\necho deploying\n")
+	writeSkill(t, filepath.Join(skillsDir, "deploy", "reference.md"), "deploy-reference", "Bundled reference doc")
+
+	// A loose file and a directory skill with the same name
+	writeSkill(t, filepath.Join(skillsDir, "dup.md"), "dup", "The loose duplicate")
+	writeSkill(t, filepath.Join(skillsDir, "dup", SkillEntryFile), "dup", "The directory duplicate")
+
+	// Hidden directories are skipped
+	writeSkill(t, filepath.Join(skillsDir, ".hidden", SkillEntryFile), "hidden", "Never indexed")
+
+	logs := captureLog(t)
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root})
+	if err := d.Discover(); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	if got, want := sortedNames(d), []string{"deep", "deploy", "dup", "loose"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skills = %v, want %v", got, want)
+	}
+
+	deploy, err := d.Get("deploy")
+	if err != nil || deploy == nil {
+		t.Fatalf("Get(deploy) = %v, %v", deploy, err)
+	}
+	if deploy.FilePath != filepath.Join(skillsDir, "deploy", SkillEntryFile) || deploy.Dir != filepath.Join(skillsDir, "deploy") {
+		t.Errorf("deploy FilePath = %q, Dir = %q", deploy.FilePath, deploy.Dir)
+	}
+	if prompt := deploy.ToPrompt(); !strings.Contains(prompt, "supporting files in `"+deploy.Dir+"/`") {
+		t.Errorf("ToPrompt() = %q, want it to point at the supporting files", prompt)
+	}
+
+	loose, err := d.Get("loose")
+	if err != nil || loose == nil {
+		t.Fatalf("Get(loose) = %v, %v", loose, err)
+	}
+	if loose.Dir != "" || strings.Contains(loose.ToPrompt(), "supporting files") {
+		t.Errorf("loose skill has a directory: Dir = %q", loose.Dir)
+	}
+
+	// The directory skill wins the collision, with a warning naming both
+	dup, err := d.Get("dup")
+	if err != nil || dup == nil {
+		t.Fatalf("Get(dup) = %v, %v", dup, err)
+	}
+	if dup.Description != "The directory duplicate" || dup.Dir != filepath.Join(skillsDir, "dup") {
+		t.Errorf("dup resolved to %s (Dir %q), want the directory skill", dup.FilePath, dup.Dir)
+	}
+	if !strings.Contains(logs.String(), `both define skill "dup"`) || !strings.Contains(logs.String(), "dup.md") {
+		t.Errorf("no collision warning logged: %q", logs.String())
+	}
+
+	// The resolution does not depend on walk order across rescans
+	for i := 0; i < 3; i++ {
+		if err := d.Refresh(); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		if dup, _ := d.Get("dup"); dup == nil || dup.Dir == "" {
+			t.Fatalf("dup resolved to the loose file after a refresh")
+		}
+	}
+}
+
+func TestDiscoverGlobalAndWorkspace(t *testing.T) {
+	root := t.TempDir()
+	global := t.TempDir()
+	writeSkill(t, filepath.Join(global, "shared.md"), "shared", "From the global library")
+	writeSkill(t, filepath.Join(global, "personal", SkillEntryFile), "personal", "Only in the global library")
+	writeSkill(t, filepath.Join(root, "skills", "shared.md"), "shared", "From the workspace")
+
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root, GlobalSkillDirs: []string{global}})
+
+	if got, want := sortedNames(d), []string{"personal", "shared"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("skills = %v, want %v", got, want)
+	}
+
+	// The workspace skill takes precedence on the conflicting name
+	shared, err := d.Get("shared")
+	if err != nil || shared == nil {
+		t.Fatalf("Get(shared) = %v, %v", shared, err)
+	}
+	if shared.Description != "From the workspace" || shared.Origin != OriginWorkspace {
+		t.Errorf("shared = %q from %s, want the workspace skill", shared.Description, shared.Origin)
+	}
+	personal, err := d.Get("personal")
+	if err != nil || personal == nil {
+		t.Fatalf("Get(personal) = %v, %v", personal, err)
+	}
+	if personal.Origin != OriginGlobal || personal.Dir != filepath.Join(global, "personal") {
+		t.Errorf("personal Origin = %s, Dir = %q", personal.Origin, personal.Dir)
+	}
+
+	// Skills outside the workspace are listed with their full path
+	for _, info := range d.ListWithInfo() {
+		switch info.Name {
+		case "shared":
+			if info.Origin != OriginWorkspace || info.FilePath != filepath.Join("skills", "shared.md") {
+				t.Errorf("shared info = %+v", info)
+			}
+		case "personal":
+			if info.Origin != OriginGlobal || info.FilePath != filepath.Join(global, "personal", SkillEntryFile) {
+				t.Errorf("personal info = %+v", info)
+			}
+		}
+	}
+
+	// Refresh rescans every root
+	writeSkill(t, filepath.Join(global, "added.md"), "added", "Added after discovery")
+	if err := os.Remove(filepath.Join(root, "skills", "shared.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got, want := sortedNames(d), []string{"added", "personal", "shared"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skills after Refresh = %v, want %v", got, want)
+	}
+	if shared, _ := d.Get("shared"); shared == nil || shared.Origin != OriginGlobal {
+		t.Errorf("shared after removing the workspace copy = %+v, want the global skill", shared)
+	}
+}
+
+func TestDefaultGlobalSkillDirs(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	t.Setenv(GlobalSkillsPathEnv, "/opt/skills"+sep+sep+"/home/me/skills")
+	if got, want := DefaultGlobalSkillDirs(), []string{"/opt/skills", "/home/me/skills"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DefaultGlobalSkillDirs() = %v, want %v", got, want)
+	}
+
+	home := t.TempDir()
+	t.Setenv(GlobalSkillsPathEnv, "")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if got, want := DefaultGlobalSkillDirs(), []string{filepath.Join(home, ".looper", "skills")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DefaultGlobalSkillDirs() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, filepath.Join(root, "skills", "lint.md"), "lint", "Run the linters")
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := d.DiscoverWithContext(ctx)
+	if !errors.Is(err, ErrDiscoveryIncomplete) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want ErrDiscoveryIncomplete wrapping context.Canceled", err)
+	}
+
+	// The scan counts as done, so listing does not start another one
+	d.mu.RLock()
+	discovered := d.discovered
+	d.mu.RUnlock()
+	if !discovered {
+		t.Error("index not marked discovered after the context ended")
+	}
+}
+
+func TestDiscoverStuckRead(t *testing.T) {
+	root := t.TempDir()
+	skillsDir := filepath.Join(root, "skills")
+	writeSkill(t, filepath.Join(skillsDir, "a.md"), "a", "Found before the stuck read")
+	writeSkill(t, filepath.Join(skillsDir, "b.md"), "b", "Stuck on a slow filesystem")
+	writeSkill(t, filepath.Join(skillsDir, "c.md"), "c", "Never reached")
+
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root})
+	reached := make(chan struct{})
+	release := make(chan struct{})
+	d.skillName = func(path string) string {
+		if filepath.Base(path) == "b.md" {
+			close(reached)
+			<-release
+		}
+		return extractSkillName(path)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-reached
+		cancel()
+	}()
+	if err := d.DiscoverWithContext(ctx); !errors.Is(err, ErrDiscoveryIncomplete) {
+		t.Fatalf("err = %v, want ErrDiscoveryIncomplete", err)
+	}
+
+	// The skill found before the walk got stuck is kept
+	if got, want := sortedNames(d), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skills while the read is stuck = %v, want %v", got, want)
+	}
+
+	// Once the read returns, its skill is merged in; the walk stops there
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(sortedNames(d), []string{"a", "b"}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("skills after the read returned = %v, want [a b]", sortedNames(d))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiscoverStuckReadSuperseded(t *testing.T) {
+	root := t.TempDir()
+	writeSkill(t, filepath.Join(root, "skills", "stale.md"), "stale", "In the old skills directory")
+
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root})
+	reached := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	var once sync.Once
+	d.skillName = func(path string) string {
+		once.Do(func() {
+			close(reached)
+			<-release
+			close(finished)
+		})
+		return extractSkillName(path)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-reached
+		cancel()
+	}()
+	d.DiscoverWithContext(ctx)
+
+	// Switching to another skills directory supersedes the stuck walk
+	d.SetSkillsDir(t.TempDir())
+	if err := d.Discover(); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	close(release)
+	<-finished
+	time.Sleep(50 * time.Millisecond) // Let the stuck walk try to merge
+
+	if names := d.List(); len(names) != 0 {
+		t.Errorf("skills = %v, want the stuck walk's results dropped", names)
+	}
+}
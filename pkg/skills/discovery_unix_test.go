@@ -0,0 +1,37 @@
+//go:build unix
+
+package skills
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDiscoverSkipsFIFO(t *testing.T) {
+	root := t.TempDir()
+	skillsDir := filepath.Join(root, "skills")
+	writeSkill(t, filepath.Join(skillsDir, "build.md"), "build", "Build the project")
+	writeSkill(t, filepath.Join(skillsDir, "zz", SkillEntryFile), "zz", "After the FIFOs")
+	writeFile(t, filepath.Join(skillsDir, "piped", "notes.txt"), "")
+	for _, fifo := range []string{"pipe.md", filepath.Join("piped", SkillEntryFile)} {
+		if err := syscall.Mkfifo(filepath.Join(skillsDir, fifo), 0644); err != nil {
+			t.Skipf("mkfifo: %v", err)
+		}
+	}
+
+	// Opening a FIFO blocks until a writer appears, so reading one would
+	// stall the scan
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := NewDiscovery(&DiscoveryConfig{WorkspaceRoot: root})
+	if err := d.DiscoverWithContext(ctx); err != nil {
+		t.Fatalf("DiscoverWithContext: %v", err)
+	}
+	if got, want := sortedNames(d), []string{"build", "zz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skills = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package skills
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// skillCorpus is SKILL.md content for the fuzz tests: valid skills, missing
+// fields and delimiters, malformed YAML, huge fields and Unicode
+var skillCorpus = []string{
+	"---\nname: test\ndescription: A test skill\n---\n\n# Test\n\nDo the thing.\n",
+	"---\nname: test\ndescription: |\n  Spans\n  lines\n---\nbody",
+	"---\r\nname: test\r\ndescription: CRLF line endings\r\n---\r\nbody\r\n",
+	"---\nname: test\n---\nbody",
+	"---\ndescription: no name\n---\nbody",
+	"---\nname: ''\ndescription: ''\n---\n",
+	"---\n---\n",
+	"---\nname: test\ndescription: unclosed\n",
+	"name: test\ndescription: no opening delimiter\n---\n",
+	"",
+	"---",
+	"---\nname: [unterminated\ndescription: x\n---\n",
+	"---\nname: {a: b}\ndescription: x\n---\n",
+	"---\nname: test\n  description: bad indent\n---\n",
+	"---\nname: &a test\ndescription: *a\n---\n",
+	"---\nname: *missing\ndescription: x\n---\n",
+	"---\nname: !!binary aGVsbG8=\ndescription: x\n---\n",
+	"---\nname: test\ndescription: x\nname: duplicate\n---\n",
+	"---\nname: test\ndescription: \"\\uD800\"\n---\n",
+	"---\nname: tést-技能-🚀\ndescription: Ünïcödé\u200b and RTL \u202e text\n---\nbody",
+	"\ufeff---\nname: bom\ndescription: byte order mark\n---\n",
+	"---\nname: test\ndescription: " + strings.Repeat("x", 1<<17) + "\n---\n" + strings.Repeat("body ", 1<<14),
+	"---\nname: test\ndescription: x\n---\n---\nname: second\n---\n",
+	"---\nname: \x00\x01\ndescription: \xff\xfe\n---\n",
+}
+
+// FuzzSkillLoader parses arbitrary SKILL.md content. Run with:
+//
+//	go test ./pkg/skills -fuzz=FuzzSkillLoader -fuzztime=60s
+func FuzzSkillLoader(f *testing.F) {
+	for _, content := range skillCorpus {
+		f.Add(content)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		skill, err := NewLoader().LoadFromString(content, "SKILL.md")
+		if err != nil {
+			if skill != nil {
+				t.Errorf("skill %+v returned with error %v", skill, err)
+			}
+			if err.Error() == "" {
+				t.Error("empty error message")
+			}
+			return
+		}
+
+		if skill.Name == "" || skill.Description == "" {
+			t.Errorf("skill without a name or description loaded: %+v", skill)
+		}
+		if strings.HasPrefix(skill.Content, "\n") {
+			t.Errorf("content has leading empty lines: %q", skill.Content)
+		}
+		if skill.FilePath != "SKILL.md" {
+			t.Errorf("FilePath = %q", skill.FilePath)
+		}
+	})
+}
+
+// FuzzSkillRoundTrip writes a skill with arbitrary fields and loads it back
+func FuzzSkillRoundTrip(f *testing.F) {
+	f.Add("test", "A test skill", "# Test\n\nDo the thing.\n")
+	f.Add("tést-技能-🚀", "Spans\nlines\n---\nwith a delimiter", "---\nbody with a delimiter")
+	f.Add("name: nested", "# not a comment", "\r\nCRLF\r\n")
+	f.Add("  padded  ", "'quoted'", "")
+	f.Add("name", "trailing newlines\n\n", "\x80")
+	f.Add(strings.Repeat("n", 256), strings.Repeat("d", 4096), strings.Repeat("b\n", 512))
+
+	f.Fuzz(func(t *testing.T, name, description, body string) {
+		if !utf8.ValidString(name) || !utf8.ValidString(description) {
+			t.Skip("YAML strings are UTF-8")
+		}
+		frontmatter, err := yaml.Marshal(Frontmatter{Name: name, Description: description})
+		if err != nil {
+			t.Skip(err)
+		}
+		// yaml.v3 cannot round-trip every string, such as a lone "\n"
+		var decoded Frontmatter
+		if yaml.Unmarshal(frontmatter, &decoded) != nil || decoded.Name != name || decoded.Description != description {
+			t.Skip("not representable in YAML")
+		}
+		content := "---\n" + string(frontmatter) + "---\n" + body
+
+		skill, err := NewLoader().LoadFromString(content, "SKILL.md")
+		switch {
+		case name == "":
+			if err == nil || !strings.Contains(err.Error(), "'name'") {
+				t.Errorf("missing name: err = %v, want it to name the field", err)
+			}
+			return
+		case description == "":
+			if err == nil || !strings.Contains(err.Error(), "'description'") {
+				t.Errorf("missing description: err = %v, want it to name the field", err)
+			}
+			return
+		case err != nil:
+			t.Fatalf("valid skill failed to load: %v\n%s", err, content)
+		}
+
+		if skill.Name != name {
+			t.Errorf("Name = %q, want %q", skill.Name, name)
+		}
+		if skill.Description != description {
+			t.Errorf("Description = %q, want %q", skill.Description, description)
+		}
+		if want := strings.TrimLeft(body, "\n"); skill.Content != want {
+			t.Errorf("Content = %q, want %q", skill.Content, want)
+		}
+	})
+}
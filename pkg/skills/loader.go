@@ -1,7 +1,6 @@
 package skills
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -19,75 +18,14 @@ func NewLoader() *Loader {
 
 // Load reads and parses a skill file
 func (l *Loader) Load(filePath string) (*Skill, error) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open skill file: %w", err)
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	// Check for frontmatter start
-	if !scanner.Scan() {
+	if len(data) == 0 {
 		return nil, fmt.Errorf("empty skill file")
 	}
-
-	firstLine := scanner.Text()
-	if strings.TrimSpace(firstLine) != "---" {
-		return nil, fmt.Errorf("skill file must start with YAML frontmatter (---)")
-	}
-
-	// Read frontmatter
-	var frontmatterLines []string
-	foundEnd := false
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "---" {
-			foundEnd = true
-			break
-		}
-		frontmatterLines = append(frontmatterLines, line)
-	}
-
-	if !foundEnd {
-		return nil, fmt.Errorf("unclosed frontmatter (missing closing ---)")
-	}
-
-	// Parse frontmatter
-	frontmatterYAML := strings.Join(frontmatterLines, "\n")
-	var frontmatter Frontmatter
-	if err := yaml.Unmarshal([]byte(frontmatterYAML), &frontmatter); err != nil {
-		return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
-	}
-
-	// Validate required fields
-	if frontmatter.Name == "" {
-		return nil, fmt.Errorf("skill frontmatter must have a 'name' field")
-	}
-	if frontmatter.Description == "" {
-		return nil, fmt.Errorf("skill frontmatter must have a 'description' field")
-	}
-
-	// Read content (everything after frontmatter)
-	var contentLines []string
-	for scanner.Scan() {
-		contentLines = append(contentLines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading skill file: %w", err)
-	}
-
-	// Trim leading empty lines from content
-	content := strings.TrimLeft(strings.Join(contentLines, "\n"), "\n")
-
-	return &Skill{
-		Name:        frontmatter.Name,
-		Description: frontmatter.Description,
-		Content:     content,
-		FilePath:    filePath,
-	}, nil
+	return l.LoadFromString(string(data), filePath)
 }
 
 // LoadFromString parses a skill from a string (useful for testing)
@@ -98,14 +36,14 @@ func (l *Loader) LoadFromString(content string, filePath string) (*Skill, error)
 	}
 
 	// Check for frontmatter start
-	if strings.TrimSpace(lines[0]) != "---" {
+	if !isFrontmatterDelimiter(lines[0]) {
 		return nil, fmt.Errorf("skill must start with YAML frontmatter (---)")
 	}
 
 	// Find frontmatter end
 	frontmatterEnd := -1
 	for i := 1; i < len(lines); i++ {
-		if strings.TrimSpace(lines[i]) == "---" {
+		if isFrontmatterDelimiter(lines[i]) {
 			frontmatterEnd = i
 			break
 		}
@@ -115,8 +53,9 @@ func (l *Loader) LoadFromString(content string, filePath string) (*Skill, error)
 		return nil, fmt.Errorf("unclosed frontmatter (missing closing ---)")
 	}
 
-	// Parse frontmatter
-	frontmatterYAML := strings.Join(lines[1:frontmatterEnd], "\n")
+	// Parse frontmatter. Every line keeps its newline, which a block scalar
+	// with keep chomping (|+) reads as part of the value.
+	frontmatterYAML := strings.Join(lines[1:frontmatterEnd], "\n") + "\n"
 	var frontmatter Frontmatter
 	if err := yaml.Unmarshal([]byte(frontmatterYAML), &frontmatter); err != nil {
 		return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
@@ -143,3 +82,10 @@ func (l *Loader) LoadFromString(content string, filePath string) (*Skill, error)
 		FilePath:    filePath,
 	}, nil
 }
+
+// isFrontmatterDelimiter reports whether line is a "---" frontmatter
+// delimiter. It must not be indented, since an indented "---" is part of a
+// YAML block scalar.
+func isFrontmatterDelimiter(line string) bool {
+	return strings.TrimRight(line, " \t\r") == "---"
+}
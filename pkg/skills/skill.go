@@ -13,6 +13,15 @@ type Skill struct {
 
 	// FilePath is the path to the skill file
 	FilePath string `json:"file_path"`
+
+	// Dir is the directory of a skill packaged as skills/<name>/SKILL.md,
+	// which holds its supporting files such as scripts, templates and
+	// reference docs. It is empty for a skill that is a single file.
+	Dir string `json:"dir,omitempty"`
+
+	// Origin is the kind of directory the skill was discovered in; a
+	// workspace skill overrides a global one with the same name
+	Origin Origin `json:"origin,omitempty"`
 }
 
 // Frontmatter represents the YAML frontmatter of a skill file
@@ -23,5 +32,9 @@ type Frontmatter struct {
 
 // ToPrompt converts the skill to a reference string (name, description, path only)
 func (s *Skill) ToPrompt() string {
-	return "- **" + s.Name + "** (`" + s.FilePath + "`): " + s.Description
+	prompt := "- **" + s.Name + "** (`" + s.FilePath + "`): " + s.Description
+	if s.Dir != "" {
+		prompt += " (supporting files in `" + s.Dir + "/`)"
+	}
+	return prompt
 }
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ToolCapability is a kind of access a tool needs, used to decide which
+// tools a deployment allows
+type ToolCapability string
+
+const (
+	// CapabilityRead reads workspace files
+	CapabilityRead ToolCapability = "read"
+
+	// CapabilityWrite creates or modifies workspace files
+	CapabilityWrite ToolCapability = "write"
+
+	// CapabilityExecute runs commands or code
+	CapabilityExecute ToolCapability = "execute"
+
+	// CapabilityNetwork reaches network services
+	CapabilityNetwork ToolCapability = "network"
+)
+
+// ErrUnknownCapability is returned by CheckCapabilities for a value that is
+// not one of the ToolCapability constants
+var ErrUnknownCapability = errors.New("unknown tool capability")
+
+// CapableTool is implemented by tools that declare the access they need.
+// Every built-in tool does.
+type CapableTool interface {
+	Tool
+
+	// Capabilities returns the kinds of access the tool needs
+	Capabilities() []ToolCapability
+}
+
+// CheckCapabilities returns an error for the first value in caps that is
+// not one of the ToolCapability constants
+func CheckCapabilities(caps []ToolCapability) error {
+	for _, c := range caps {
+		switch c {
+		case CapabilityRead, CapabilityWrite, CapabilityExecute, CapabilityNetwork:
+		default:
+			return fmt.Errorf("%w: %q (available: %s, %s, %s, %s)", ErrUnknownCapability, c,
+				CapabilityRead, CapabilityWrite, CapabilityExecute, CapabilityNetwork)
+		}
+	}
+	return nil
+}
+
+// ParseCapabilities splits a comma-separated list such as "read,write" into
+// capabilities. Use CheckCapabilities to validate the result.
+func ParseCapabilities(list string) []ToolCapability {
+	caps := []ToolCapability{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			caps = append(caps, ToolCapability(name))
+		}
+	}
+	return caps
+}
+
+// CapabilitiesAllowed reports whether every capability t declares is in
+// allowed. Tools that do not implement CapableTool are never allowed, since
+// what they do is unknown.
+func CapabilitiesAllowed(t Tool, allowed []ToolCapability) bool {
+	capable, ok := t.(CapableTool)
+	if !ok {
+		return false
+	}
+	for _, c := range capable.Capabilities() {
+		found := false
+		for _, a := range allowed {
+			if a == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
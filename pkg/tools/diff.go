@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change
+	diffContext = 3
+
+	// maxDiffEdits bounds the work spent diffing; files that differ by more
+	// lines than this are summarized instead
+	maxDiffEdits = 2000
+)
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff of two texts labelled with path, or ""
+// if they are equal
+func unifiedDiff(path, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+
+	ops, ok := diffLines(oldLines, newLines)
+	if !ok {
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(too many changes to diff: %d lines replaced by %d)\n",
+			path, path, len(oldLines), len(newLines))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+
+	// Walk the edit script, emitting a hunk for each run of changes together
+	// with its surrounding context
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			oldLine++
+			newLine++
+			continue
+		}
+
+		// Extend the hunk while changes are within 2*diffContext lines of
+		// each other
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(ops) && ops[gap].kind == ' ' {
+				gap++
+			}
+			if gap == len(ops) || gap-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = gap
+		}
+
+		hunkOld, hunkNew := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			body.WriteByte(op.kind)
+			body.WriteString(op.line)
+			body.WriteByte('\n')
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n%s", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount), body.String())
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// hunkRange formats a hunk's line range; an empty range names the line
+// before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits text into lines without their terminators
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm. It
+// reports false if the texts differ by more than maxDiffEdits lines.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Insertion
+			} else {
+				x = v[offset+k-1] + 1 // Deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Backtrack through the saved frontiers to recover the edit script
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[offset+k-1] < prev[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -2,10 +2,16 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/looper-ai/looper/pkg/sandbox"
+	"github.com/looper-ai/looper/pkg/truncate"
 )
 
 // ExecuteTool runs code in a sandboxed environment
@@ -24,8 +30,14 @@ func (t *ExecuteTool) Name() string {
 	return "execute"
 }
 
+func (t *ExecuteTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityExecute}
+}
+
 func (t *ExecuteTool) Description() string {
-	return "Execute code or shell commands in a sandboxed environment. Supports bash, python, node, and go."
+	return "Execute code or shell commands in a sandboxed environment. Supports " + strings.Join(t.languages(), ", ") + ". Deno and bun run TypeScript. Ruby runs through 'bundle exec' when the working directory has a Gemfile. " +
+		"PHP code gets an opening <?php tag if it lacks one and loads Composer's vendor/autoload.php when the working directory has a composer.json. " +
+		"For wasm, code is a compiled WebAssembly module (from Rust, C, etc.), either base64-encoded or the path of a .wasm file in the workspace; it runs under wasmtime with the working directory mounted." + networkNotice(t.sandbox) + limitsNotice(t.sandbox)
 }
 
 func (t *ExecuteTool) Schema() map[string]interface{} {
@@ -34,13 +46,24 @@ func (t *ExecuteTool) Schema() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"language": map[string]interface{}{
 				"type":        "string",
-				"description": "The language/interpreter to use: 'bash', 'python', 'node', or 'go'",
-				"enum":        []string{"bash", "python", "node", "go"},
+				"description": "The language/interpreter to use",
+				"enum":        t.languages(),
 			},
 			"code": map[string]interface{}{
 				"type":        "string",
-				"description": "The code to execute",
+				"description": "The code to execute. For wasm: a base64-encoded WebAssembly module or the workspace path of a .wasm file.",
+			},
+			"stdin": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional input piped to the program's standard input",
+			},
+			"use_npx": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For node: run through npx so the project's package.json dependencies and binaries are used. Requires a package.json in the working directory.",
 			},
+			"cwd":             cwdSchema(),
+			"timeout_seconds": timeoutSchema(),
+			"dry_run":         dryRunSchema(),
 		},
 		"required": []string{"language", "code"},
 	}
@@ -57,31 +80,65 @@ func (t *ExecuteTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("code is required")
 	}
 
-	// Map language to interpreter
-	var interpreter string
+	interpreter, ok := scriptInterpreters[language]
+	if !ok {
+		if !t.configuredLanguage(language) {
+			return "", fmt.Errorf("unsupported language: %s", language)
+		}
+		interpreter = language
+	}
+
+	opts := execOptionsFromArgs(ctx, args)
+
+	// Use the project's dependency environment when there is one
+	projectDir, err := t.sandbox.ResolveWorkingDir(opts.WorkingDir)
+	if err != nil {
+		return "", err
+	}
 	switch language {
-	case "bash":
-		interpreter = "bash"
-	case "python":
-		interpreter = "python3"
+	case "ruby":
+		if fileExists(filepath.Join(projectDir, "Gemfile")) {
+			interpreter = "bundle exec ruby"
+		}
+	case "php":
+		autoload := filepath.Join(projectDir, "vendor", "autoload.php")
+		if fileExists(filepath.Join(projectDir, "composer.json")) && fileExists(autoload) {
+			code = requirePHPAutoload(code, autoload)
+		}
 	case "node":
-		interpreter = "node"
-	case "go":
-		interpreter = "go"
-	default:
-		return "", fmt.Errorf("unsupported language: %s", language)
+		if useNpx, _ := args["use_npx"].(bool); useNpx {
+			if !fileExists(filepath.Join(projectDir, "package.json")) {
+				return "", fmt.Errorf("use_npx requires a package.json in the working directory")
+			}
+			interpreter = "npx node"
+		}
 	}
 
-	result, err := t.sandbox.ExecuteScript(ctx, interpreter, code)
-	if err != nil {
-		return "", fmt.Errorf("execution failed: %w", err)
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		plan, err := t.sandbox.PlanScript(ctx, interpreter, code, opts)
+		if err != nil {
+			return "", executionError(err)
+		}
+		return plan.String(), nil
 	}
 
+	result, err := t.sandbox.ExecuteScriptWithOptions(ctx, interpreter, code, opts)
+	if err := executionError(err); err != nil {
+		return "", err
+	}
+	reportExecution(ctx, result)
+
 	// Format output
 	var output strings.Builder
 
 	if result.TimedOut {
-		output.WriteString("⚠️ Execution timed out\n\n")
+		output.WriteString(timeoutNotice(result))
+	}
+	if result.LimitExceeded != "" {
+		output.WriteString(limitNotice(result.LimitExceeded))
+	}
+	if result.IsolationFailure != "" {
+		output.WriteString("⚠️ Sandbox isolation error: " + result.IsolationFailure + "\n\n")
 	}
 
 	if result.Stdout != "" {
@@ -100,30 +157,97 @@ func (t *ExecuteTool) Execute(ctx context.Context, args map[string]interface{})
 		}
 	}
 
-	output.WriteString(fmt.Sprintf("\nExit code: %d", result.ExitCode))
-	output.WriteString(fmt.Sprintf("\nDuration: %s", result.Duration))
+	writeTruncationNotice(&output, result)
+
+	output.WriteString("\n" + exitStatus(result))
+	output.WriteString("\n" + durationLine(result))
 
 	return output.String(), nil
 }
 
-// BashTool runs bash commands directly
+// interpreterLister is implemented by sandboxes that accept interpreters
+// beyond the built-in table, such as ProcessSandbox with Config.Interpreters
+type interpreterLister interface {
+	ConfiguredInterpreters() []string
+}
+
+// languages returns the built-in languages followed by any extra
+// interpreters configured in the sandbox
+func (t *ExecuteTool) languages() []string {
+	languages := append([]string{}, scriptLanguages...)
+	lister, ok := t.sandbox.(interpreterLister)
+	if !ok {
+		return languages
+	}
+	for _, name := range lister.ConfiguredInterpreters() {
+		if _, builtin := scriptInterpreters[name]; !builtin {
+			languages = append(languages, name)
+		}
+	}
+	return languages
+}
+
+// configuredLanguage reports whether language is an extra interpreter
+// configured in the sandbox
+func (t *ExecuteTool) configuredLanguage(language string) bool {
+	lister, ok := t.sandbox.(interpreterLister)
+	if !ok {
+		return false
+	}
+	for _, name := range lister.ConfiguredInterpreters() {
+		if name == language {
+			return true
+		}
+	}
+	return false
+}
+
+// shellSpec describes how a shell runs a command string
+type shellSpec struct {
+	name    string   // Tool name and how the shell is described to the model
+	program string   // Executable
+	args    []string // Arguments preceding the command string
+}
+
+// command returns the program and arguments that run a command string
+func (s shellSpec) command(command string) (string, []string) {
+	args := append(append([]string{}, s.args...), command)
+	return s.program, args
+}
+
+// BashTool runs shell commands directly
 type BashTool struct {
 	sandbox sandbox.Sandbox
+	shell   shellSpec
 }
 
 // NewBashTool creates a new bash tool
 func NewBashTool(sb sandbox.Sandbox) *BashTool {
 	return &BashTool{
 		sandbox: sb,
+		shell:   shellSpec{name: "bash", program: "bash", args: []string{"-c"}},
+	}
+}
+
+// NewShellTool creates a shell tool for the host OS: bash on Unix and
+// PowerShell on Windows
+func NewShellTool(sb sandbox.Sandbox) *BashTool {
+	return &BashTool{
+		sandbox: sb,
+		shell:   defaultShell,
 	}
 }
 
 func (t *BashTool) Name() string {
-	return "bash"
+	return t.shell.name
+}
+
+func (t *BashTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityExecute}
 }
 
 func (t *BashTool) Description() string {
-	return "Execute a bash command in a sandboxed environment."
+	return "Execute a " + t.shell.name + " command in a sandboxed environment." + networkNotice(t.sandbox) + limitsNotice(t.sandbox)
 }
 
 func (t *BashTool) Schema() map[string]interface{} {
@@ -132,8 +256,23 @@ func (t *BashTool) Schema() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"command": map[string]interface{}{
 				"type":        "string",
-				"description": "The bash command to execute",
+				"description": "The " + t.shell.name + " command to execute",
+			},
+			"stdin": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional input piped to the command's standard input (e.g. a patch for 'patch -p1')",
+			},
+			"pty": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Run under a pseudo-terminal, for programs that behave differently or hang without a TTY. Stdout and stderr are combined and terminal escape codes are stripped. Cannot be combined with stdin.",
+			},
+			"separate_streams": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Return stdout and stderr separately instead of interleaved in the order they were written. Defaults to false.",
 			},
+			"cwd":             cwdSchema(),
+			"timeout_seconds": timeoutSchema(),
+			"dry_run":         dryRunSchema(),
 		},
 		"required": []string{"command"},
 	}
@@ -145,16 +284,46 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		return "", fmt.Errorf("command is required")
 	}
 
-	result, err := t.sandbox.Execute(ctx, "bash", []string{"-c", command})
-	if err != nil {
-		return "", fmt.Errorf("execution failed: %w", err)
+	opts := execOptionsFromArgs(ctx, args)
+	if pty, ok := args["pty"].(bool); ok && pty {
+		opts.PTY = &sandbox.PTYOptions{}
+	}
+	separate, _ := args["separate_streams"].(bool)
+	opts.CombineOutput = !separate
+
+	program, programArgs := t.shell.command(command)
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		plan, err := t.sandbox.Plan(ctx, program, programArgs, opts)
+		if err != nil {
+			return "", executionError(err)
+		}
+		return plan.String(), nil
+	}
+
+	result, err := t.sandbox.ExecuteWithOptions(ctx, program, programArgs, opts)
+	if err := executionError(err); err != nil {
+		return "", err
 	}
+	reportExecution(ctx, result)
 
 	// Format output
 	var output strings.Builder
 
 	if result.TimedOut {
-		output.WriteString("⚠️ Execution timed out\n\n")
+		output.WriteString(timeoutNotice(result))
+	}
+	if result.LimitExceeded != "" {
+		output.WriteString(limitNotice(result.LimitExceeded))
+	}
+	if result.IsolationFailure != "" {
+		output.WriteString("⚠️ Sandbox isolation error: " + result.IsolationFailure + "\n\n")
+	}
+
+	if result.Combined != "" {
+		output.WriteString(result.Combined)
+		if !strings.HasSuffix(result.Combined, "\n") {
+			output.WriteString("\n")
+		}
 	}
 
 	if result.Stdout != "" {
@@ -172,9 +341,330 @@ func (t *BashTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		output.WriteString(result.Stderr)
 	}
 
+	writeTruncationNotice(&output, result)
+
 	if result.ExitCode != 0 {
-		output.WriteString(fmt.Sprintf("\nExit code: %d", result.ExitCode))
+		output.WriteString("\n" + exitStatus(result))
 	}
+	output.WriteString("\n" + durationLine(result))
 
 	return output.String(), nil
 }
+
+// cwdSchema describes the per-call working directory parameter
+func cwdSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": "Optional working directory relative to the workspace root (e.g. 'services/api'). Must stay inside the workspace.",
+	}
+}
+
+// timeoutSchema describes the per-call timeout parameter
+func timeoutSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "number",
+		"description": "Optional timeout in seconds for long-running work such as builds. Capped by the sandbox maximum.",
+	}
+}
+
+// dryRunSchema describes the parameter that shows the execution plan
+// instead of running
+func dryRunSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "boolean",
+		"description": "Show what would be executed (working directory, command line, environment, timeout, whether it is blocked) without running it",
+	}
+}
+
+// execOptionsFromArgs builds sandbox options from the optional parameters
+// shared by the bash and execute tools
+func execOptionsFromArgs(ctx context.Context, args map[string]interface{}) *sandbox.ExecOptions {
+	opts := &sandbox.ExecOptions{OnOutput: streamOutput(ctx), OnProgress: streamProgress(ctx)}
+	if stdin, ok := args["stdin"].(string); ok {
+		opts.Stdin = stdin
+	}
+	if cwd, ok := args["cwd"].(string); ok {
+		opts.WorkingDir = cwd
+	}
+	if ts, ok := args["timeout_seconds"].(float64); ok && ts > 0 {
+		opts.Timeout = time.Duration(ts * float64(time.Second))
+	}
+	return opts
+}
+
+// networkNotice tells the model up front when commands have no network
+// access, so it doesn't keep retrying downloads
+func networkNotice(sb sandbox.Sandbox) string {
+	if sb.NetworkDisabled() {
+		return " Network access is disabled: commands cannot reach the internet or local network services."
+	}
+	return ""
+}
+
+// limitsReporter is implemented by sandboxes that can describe their
+// execution limits, such as ProcessSandbox
+type limitsReporter interface {
+	DefaultLimits() sandbox.Limits
+	InterpreterLimits() map[string]sandbox.Limits
+}
+
+// limitsNotice summarizes the sandbox timeouts and output ceilings so the
+// model can plan long-running work, e.g. " Limits: 30s timeout and 1.0MB
+// of output per stream by default; go: 5m timeout."
+func limitsNotice(sb sandbox.Sandbox) string {
+	reporter, ok := sb.(limitsReporter)
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	defaults := reporter.DefaultLimits()
+	b.WriteString(" Limits: ")
+	b.WriteString(describeLimits(defaults))
+	b.WriteString(" by default")
+
+	overrides := reporter.InterpreterLimits()
+	for _, name := range sortedLimitNames(overrides) {
+		fmt.Fprintf(&b, "; %s: %s", name, describeLimits(overrides[name]))
+	}
+	b.WriteString(". Pass timeout_seconds for anything slower.")
+	return b.String()
+}
+
+// describeLimits renders the set fields of l, e.g. "5m timeout"
+func describeLimits(l sandbox.Limits) string {
+	var parts []string
+	if l.Timeout > 0 {
+		parts = append(parts, shortDuration(l.Timeout)+" timeout")
+	}
+	if l.MaxOutputBytes > 0 {
+		parts = append(parts, sandbox.FormatBytes(l.MaxOutputBytes)+" of output per stream")
+	}
+	if len(parts) == 0 {
+		return "no timeout"
+	}
+	return strings.Join(parts, " and ")
+}
+
+func sortedLimitNames(limits map[string]sandbox.Limits) []string {
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shortDuration formats d without trailing zero units, e.g. "5m" rather
+// than "5m0s"
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// writeTruncationNotice appends a visible marker when output was cut, so the
+// model doesn't mistake partial output for the complete result
+func writeTruncationNotice(output *strings.Builder, result *sandbox.ExecutionResult) {
+	if result.StdoutTruncated {
+		output.WriteString("\nstdout truncated " + truncate.Notice(result.StdoutTotalBytes-result.StdoutDroppedBytes, result.StdoutTotalBytes, "bytes"))
+	}
+	if result.StderrTruncated {
+		output.WriteString("\nstderr truncated " + truncate.Notice(result.StderrTotalBytes-result.StderrDroppedBytes, result.StderrTotalBytes, "bytes"))
+	}
+	if result.CombinedTruncated {
+		output.WriteString("\noutput truncated " + truncate.Notice(result.CombinedTotalBytes-result.CombinedDroppedBytes, result.CombinedTotalBytes, "bytes"))
+	}
+}
+
+// executionError converts a sandbox error into the error reported to the
+// model. Timeouts return nil because the partial result is still useful and
+// is rendered with a timeout notice.
+func executionError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, sandbox.ErrExecutionTimeout):
+		return nil
+	case errors.Is(err, sandbox.ErrExecutionCancelled):
+		return fmt.Errorf("execution cancelled by user")
+	case errors.Is(err, sandbox.ErrInterpreterNotFound):
+		return fmt.Errorf("%w; choose another language or install it", err)
+	default:
+		return fmt.Errorf("execution failed: %w", err)
+	}
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// requirePHPAutoload makes PHP code load a Composer autoloader before its
+// own statements. The require goes after any declare statements and the
+// namespace declaration, which PHP only accepts at the start of a file.
+// Code without an opening tag gets one, after a leading #! line.
+func requirePHPAutoload(code, autoload string) string {
+	require := "require_once '" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(autoload) + "';"
+
+	var shebang string
+	if strings.HasPrefix(code, "#!") {
+		i := strings.IndexByte(code, '\n')
+		if i < 0 {
+			i = len(code)
+			code += "\n"
+		}
+		shebang, code = code[:i+1], code[i+1:]
+	}
+	if trimmed := strings.TrimLeft(code, " \t\r\n"); len(trimmed) >= 5 && strings.EqualFold(trimmed[:5], "<?php") {
+		code = trimmed[5:]
+	} else {
+		code = "\n" + code
+	}
+
+	at := phpPreambleEnd(code)
+	return shebang + "<?php" + code[:at] + " " + require + code[at:]
+}
+
+// phpPreambleEnd returns the offset in PHP code following its opening tag
+// at which the first ordinary statement may go: past any declare
+// statements and a namespace declaration, or just inside the brace of a
+// bracketed namespace
+func phpPreambleEnd(code string) int {
+	end := 0
+	for i := skipPHPSpace(code, 0); ; i = skipPHPSpace(code, i) {
+		rest := code[i:]
+		var keyword string
+		switch {
+		case hasPHPKeyword(rest, "declare"):
+			keyword = "declare"
+		case hasPHPKeyword(rest, "namespace"):
+			keyword = "namespace"
+		default:
+			return end
+		}
+
+		j := strings.IndexAny(rest, ";{")
+		if j < 0 {
+			return end
+		}
+		if keyword == "namespace" {
+			return i + j + 1
+		}
+		if rest[j] == '{' {
+			// A block declare such as declare(ticks=1) { ... }
+			return end
+		}
+		i += j + 1
+		end = i
+	}
+}
+
+// hasPHPKeyword reports whether code starts with keyword, matched without
+// regard to case as PHP does, as a whole word rather than a name prefix or
+// a namespace-relative name such as namespace\f()
+func hasPHPKeyword(code, keyword string) bool {
+	if len(code) < len(keyword) || !strings.EqualFold(code[:len(keyword)], keyword) {
+		return false
+	}
+	if len(code) == len(keyword) {
+		return true
+	}
+	next := code[len(keyword)]
+	return next != '\\' && next != '_' && !('a' <= next && next <= 'z' || 'A' <= next && next <= 'Z' || '0' <= next && next <= '9' || next >= 0x80)
+}
+
+// skipPHPSpace returns the offset of the first byte at or after i that is
+// not whitespace or part of a comment
+func skipPHPSpace(code string, i int) int {
+	for i < len(code) {
+		rest := code[i:]
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(code[i])):
+			i++
+		case strings.HasPrefix(rest, "//"), strings.HasPrefix(rest, "#") && !strings.HasPrefix(rest, "#["):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				return len(code)
+			}
+			i += end + 1
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return len(code)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// durationLine reports the wall-clock duration of an execution and what it
+// consumed, e.g. "Duration: 1.2s (cpu 0.8s user + 0.1s sys, max RSS 45.3MB)"
+func durationLine(result *sandbox.ExecutionResult) string {
+	line := fmt.Sprintf("Duration: %s", result.Duration.Round(time.Millisecond))
+	if result.ResourceUsage != nil {
+		line += " (" + result.ResourceUsage.Summary() + ")"
+	}
+	return line
+}
+
+// exitStatus is the line describing how a command ended: "Exit code: 2",
+// or for a process that did not exit on its own "Exit: timed out after
+// 30s" or "Exit: killed by SIGSEGV" rather than its exit code of -1
+func exitStatus(result *sandbox.ExecutionResult) string {
+	if !result.TimedOut && result.Signal == "" {
+		return fmt.Sprintf("Exit code: %d", result.ExitCode)
+	}
+	return "Exit: " + exitDescription(result)
+}
+
+// exitDescription describes how a command ended: "exit code 2", "timed out
+// after 30s" or "killed by SIGSEGV"
+func exitDescription(result *sandbox.ExecutionResult) string {
+	switch {
+	case result.TimedOut:
+		return timeoutDescription(result)
+	case result.Signal != "":
+		return "killed by " + result.Signal
+	default:
+		return fmt.Sprintf("exit code %d", result.ExitCode)
+	}
+}
+
+// timeoutDescription returns "timed out after 30s" with the limit the call
+// ran with, or the time it ran for if the limit is not known
+func timeoutDescription(result *sandbox.ExecutionResult) string {
+	limit := result.Limits.Timeout
+	if limit <= 0 {
+		limit = result.Duration.Round(time.Millisecond)
+	}
+	return fmt.Sprintf("timed out after %s", limit)
+}
+
+// timeoutNotice describes a timed-out execution, including whether the
+// process exited on SIGTERM or had to be killed
+func timeoutNotice(result *sandbox.ExecutionResult) string {
+	if result.StoppedBy == "" {
+		return fmt.Sprintf("⚠️ Execution %s\n\n", timeoutDescription(result))
+	}
+	return fmt.Sprintf("⚠️ Execution %s (stopped by %s)\n\n", timeoutDescription(result), result.StoppedBy)
+}
+
+// limitNotice explains which resource limit stopped a command. The process
+// and open-file limits make calls fail instead of killing the process.
+func limitNotice(limit string) string {
+	switch limit {
+	case sandbox.LimitProcesses:
+		return "⚠️ Command failed: hit the sandbox limit on processes, so it could not fork or start threads\n\n"
+	case sandbox.LimitOpenFiles:
+		return "⚠️ Command failed: hit the sandbox limit on open files\n\n"
+	default:
+		return fmt.Sprintf("⚠️ Process killed: exceeded %s limit\n\n", limit)
+	}
+}
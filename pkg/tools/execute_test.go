@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// newTestSandbox returns a sandbox with DefaultConfig rooted in a temporary
+// directory
+func newTestSandbox(t *testing.T) *sandbox.ProcessSandbox {
+	t.Helper()
+	sb, err := sandbox.NewProcessSandbox(sandbox.DefaultConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewProcessSandbox: %v", err)
+	}
+	t.Cleanup(func() { sb.Close() })
+	return sb
+}
+
+func TestExecuteToolAbsoluteCwd(t *testing.T) {
+	sb := newTestSandbox(t)
+	root, err := sb.ResolveWorkingDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(root, "web")
+	if err := os.Mkdir(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The project files are looked up in the cwd, absolute or not
+	tool := NewExecuteTool(sb)
+	for _, cwd := range []string{project, "web"} {
+		out, err := tool.Execute(context.Background(), map[string]interface{}{
+			"language": "node",
+			"code":     "console.log(1)",
+			"use_npx":  true,
+			"cwd":      cwd,
+			"dry_run":  true,
+		})
+		if err != nil {
+			t.Errorf("cwd %q: %v", cwd, err)
+			continue
+		}
+		if !strings.Contains(out, "npx node") {
+			t.Errorf("cwd %q: plan does not run through npx:\n%s", cwd, out)
+		}
+	}
+
+	_, err = tool.Execute(context.Background(), map[string]interface{}{
+		"language": "node",
+		"code":     "console.log(1)",
+		"cwd":      t.TempDir(),
+		"dry_run":  true,
+	})
+	if err == nil {
+		t.Error("cwd outside the sandbox was accepted")
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result sandbox.ExecutionResult
+		want   string
+	}{
+		{"normal exit", sandbox.ExecutionResult{}, "Exit code: 0"},
+		{"non-zero exit", sandbox.ExecutionResult{ExitCode: 3}, "Exit code: 3"},
+		{"signal", sandbox.ExecutionResult{ExitCode: -1, Signal: "SIGKILL"}, "Exit: killed by SIGKILL"},
+		{
+			"timeout",
+			sandbox.ExecutionResult{ExitCode: -1, Signal: "SIGKILL", TimedOut: true, Duration: 30*time.Second + 4*time.Millisecond, Limits: sandbox.Limits{Timeout: 30 * time.Second}},
+			"Exit: timed out after 30s",
+		},
+		{"timeout without a limit", sandbox.ExecutionResult{ExitCode: -1, TimedOut: true, Duration: 1500 * time.Millisecond}, "Exit: timed out after 1.5s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitStatus(&tt.result); got != tt.want {
+				t.Errorf("exitStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePHPAutoload(t *testing.T) {
+	const require = "require_once '/app/vendor/autoload.php';"
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"no tag", "echo 1;", "<?php " + require + "\necho 1;"},
+		{"tag", "<?php\necho 1;", "<?php " + require + "\necho 1;"},
+		{"upper-case tag after blank lines", "\n\n<?PHP echo 1;", "<?php " + require + " echo 1;"},
+		{"shebang", "#!/usr/bin/env php\n<?php\necho 1;", "#!/usr/bin/env php\n<?php " + require + "\necho 1;"},
+		{"shebang without tag", "#!/usr/bin/env php\necho 1;", "#!/usr/bin/env php\n<?php " + require + "\necho 1;"},
+		{"shebang only", "#!/usr/bin/env php", "#!/usr/bin/env php\n<?php " + require + "\n"},
+		{
+			"declare",
+			"<?php\ndeclare(strict_types=1);\necho 1;",
+			"<?php\ndeclare(strict_types=1); " + require + "\necho 1;",
+		},
+		{
+			"declare and namespace after comments",
+			"<?php\n/* header */\ndeclare(strict_types=1);\n// app code\nnamespace App\\Models;\nuse Foo\\Bar;",
+			"<?php\n/* header */\ndeclare(strict_types=1);\n// app code\nnamespace App\\Models; " + require + "\nuse Foo\\Bar;",
+		},
+		{
+			"bracketed namespace",
+			"<?php\nnamespace App {\n    echo 1;\n}",
+			"<?php\nnamespace App { " + require + "\n    echo 1;\n}",
+		},
+		{"namespace-relative call", "<?php namespace\\run();", "<?php " + require + " namespace\\run();"},
+		{"name starting with a keyword", "<?php declared();", "<?php " + require + " declared();"},
+		{"block declare", "<?php declare(ticks=1) { tick(); }", "<?php " + require + " declare(ticks=1) { tick(); }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requirePHPAutoload(tt.code, "/app/vendor/autoload.php"); got != tt.want {
+				t.Errorf("requirePHPAutoload(%q) =\n%s\nwant\n%s", tt.code, got, tt.want)
+			}
+		})
+	}
+
+	// Quotes and backslashes in the path are escaped
+	if got := requirePHPAutoload("<?php", `/it's\vendor/autoload.php`); !strings.Contains(got, `require_once '/it\'s\\vendor/autoload.php';`) {
+		t.Errorf("path not escaped: %s", got)
+	}
+}
+
+func TestExecuteToolPHPAutoload(t *testing.T) {
+	if !sandbox.InterpreterAvailable("php") {
+		t.Skip("php is not installed")
+	}
+	sb := newTestSandbox(t)
+	root, err := sb.ResolveWorkingDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "vendor"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "composer.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "vendor", "autoload.php"), []byte("<?php\nfunction greeting() { return 'autoloaded'; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// strict_types and namespace must stay the first statements
+	out, err := NewExecuteTool(sb).Execute(context.Background(), map[string]interface{}{
+		"language": "php",
+		"code":     "<?php\ndeclare(strict_types=1);\nnamespace App;\necho \\greeting(), \"\\n\";",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, "autoloaded") {
+		t.Errorf("output does not show the autoloaded function:\n%s", out)
+	}
+}
+
+func TestExecuteToolInterpreters(t *testing.T) {
+	tests := []struct {
+		language string
+		code     string
+	}{
+		{"ruby", "puts [1, 2].sum + 39"},
+		{"perl", "my @n = (40, 2); print $n[0] + $n[1], \"\\n\";"},
+		{"php", "<?php echo 40 + 2, \"\\n\";"},
+		// Type annotations only run if the script gets a .ts extension
+		{"deno", "const n: number = 42;\nconsole.log(n);"},
+		{"bun", "const n: number = 42;\nconsole.log(n);"},
+	}
+
+	sb := newTestSandbox(t)
+	tool := NewExecuteTool(sb)
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			if !sandbox.InterpreterAvailable(scriptInterpreters[tt.language]) {
+				t.Skipf("%s is not installed", tt.language)
+			}
+			out, err := tool.Execute(context.Background(), map[string]interface{}{
+				"language": tt.language,
+				"code":     tt.code,
+			})
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !strings.Contains(out, "STDOUT:\n42\n") || !strings.Contains(out, "Exit code: 0") {
+				t.Errorf("output:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestExecuteToolInterpreterNotInstalled(t *testing.T) {
+	config := sandbox.DefaultConfig(t.TempDir())
+	config.Interpreters = map[string]sandbox.InterpreterSpec{"looper-missing-lang": {Extension: ".ml"}}
+	sb, err := sandbox.NewProcessSandbox(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sb.Close() })
+
+	_, err = NewExecuteTool(sb).Execute(context.Background(), map[string]interface{}{
+		"language": "looper-missing-lang",
+		"code":     "print 1",
+	})
+	if !errors.Is(err, sandbox.ErrInterpreterNotFound) || !strings.Contains(err.Error(), "install") {
+		t.Errorf("err = %v, want ErrInterpreterNotFound with a hint", err)
+	}
+}
+
+func TestExecuteToolHiddenInterpreter(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	sb := newTestSandbox(t)
+
+	_, err := NewExecuteTool(sb).Execute(context.Background(), map[string]interface{}{
+		"language": "python",
+		"code":     "print(1)",
+	})
+	if !errors.Is(err, sandbox.ErrInterpreterNotFound) {
+		t.Fatalf("err = %v, want ErrInterpreterNotFound", err)
+	}
+	if !strings.Contains(err.Error(), scriptInterpreters["python"]) {
+		t.Errorf("error %q does not name the interpreter", err)
+	}
+
+	caps, err := sb.Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if len(caps.Interpreters) != 0 || !strings.Contains(caps.Summary(), "Interpreters: none found") {
+		t.Errorf("Interpreters = %+v, Summary() = %q; want none", caps.Interpreters, caps.Summary())
+	}
+}
@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// ErrInvalidToolDefinition is returned when an external tool definition
+// fails validation
+var ErrInvalidToolDefinition = errors.New("invalid tool definition")
+
+// toolNamePattern matches names accepted by both LLM providers
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ExternalToolDefinition declares a tool whose implementation lives outside
+// looper. Exactly one of Command or URL must be set. The tool's arguments are
+// passed as a JSON object: on stdin for a command, or as the POST body for a
+// URL. The command's stdout or the response body is the tool result.
+type ExternalToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+
+	// Command is the handler executable and its arguments. A relative
+	// executable path containing a separator is resolved against the
+	// directory of the definitions file.
+	Command []string `json:"command,omitempty"`
+
+	// URL is an HTTP endpoint that receives the arguments as a POST request
+	URL string `json:"url,omitempty"`
+
+	// TimeoutSeconds bounds a single call (0 uses the sandbox timeout for
+	// commands and 30 seconds for URLs)
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// externalToolsFile is the layout of an external tool definitions file
+type externalToolsFile struct {
+	Tools []ExternalToolDefinition `json:"tools"`
+}
+
+// LoadExternalTools reads tool definitions from a JSON file of the form
+// {"tools": [...]} and returns the generated tools. Command handlers run in
+// sb. Every definition is validated; the error names the definition that
+// failed.
+func LoadExternalTools(path string, sb sandbox.Sandbox) ([]Tool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool definitions: %w", err)
+	}
+
+	var file externalToolsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tool definitions %s: %w", path, err)
+	}
+
+	baseDir := filepath.Dir(path)
+	seen := make(map[string]bool, len(file.Tools))
+	tools := make([]Tool, 0, len(file.Tools))
+
+	for i, def := range file.Tools {
+		if err := validateExternalTool(&def); err != nil {
+			return nil, fmt.Errorf("tool definition %d (%q) in %s: %w", i, def.Name, path, err)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("tool definition %d (%q) in %s: %w: duplicate name", i, def.Name, path, ErrInvalidToolDefinition)
+		}
+		seen[def.Name] = true
+
+		if len(def.Command) > 0 && !filepath.IsAbs(def.Command[0]) && strings.ContainsRune(def.Command[0], filepath.Separator) {
+			def.Command = append([]string{filepath.Join(baseDir, def.Command[0])}, def.Command[1:]...)
+		}
+		tools = append(tools, &ExternalTool{def: def, sandbox: sb})
+	}
+
+	return tools, nil
+}
+
+func validateExternalTool(def *ExternalToolDefinition) error {
+	if !toolNamePattern.MatchString(def.Name) {
+		return fmt.Errorf("%w: name must be 1-64 letters, digits, '_' or '-'", ErrInvalidToolDefinition)
+	}
+	if strings.TrimSpace(def.Description) == "" {
+		return fmt.Errorf("%w: description is required", ErrInvalidToolDefinition)
+	}
+	if (len(def.Command) == 0) == (def.URL == "") {
+		return fmt.Errorf("%w: exactly one of command or url is required", ErrInvalidToolDefinition)
+	}
+	if def.URL != "" && !strings.HasPrefix(def.URL, "http://") && !strings.HasPrefix(def.URL, "https://") {
+		return fmt.Errorf("%w: url must be http or https", ErrInvalidToolDefinition)
+	}
+	if def.TimeoutSeconds < 0 {
+		return fmt.Errorf("%w: timeout_seconds must not be negative", ErrInvalidToolDefinition)
+	}
+
+	if def.Parameters == nil {
+		def.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if def.Parameters["type"] != "object" {
+		return fmt.Errorf("%w: parameters must be an object schema", ErrInvalidToolDefinition)
+	}
+	if err := validateSchemaMap(def.Parameters, "parameters"); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToolDefinition, err)
+	}
+	return nil
+}
+
+// validateSchemaMap checks the parts of a JSON Schema that tools rely on:
+// known types, well-formed properties and items, and required names that
+// exist
+func validateSchemaMap(schema map[string]interface{}, path string) error {
+	if _, ok := schema["$ref"]; ok {
+		return nil
+	}
+
+	typ, ok := schema["type"].(string)
+	if !ok {
+		return fmt.Errorf("%s: type must be a string", path)
+	}
+	switch typ {
+	case "object", "array", "string", "integer", "number", "boolean", "null":
+	default:
+		return fmt.Errorf("%s: unknown type %q", path, typ)
+	}
+
+	if raw, ok := schema["properties"]; ok {
+		props, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.properties: must be an object", path)
+		}
+		for name, rawProp := range props {
+			prop, ok := rawProp.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.properties.%s: must be an object", path, name)
+			}
+			if err := validateSchemaMap(prop, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if raw, ok := schema["required"]; ok {
+		required, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.required: must be an array", path)
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, r := range required {
+			name, ok := r.(string)
+			if !ok {
+				return fmt.Errorf("%s.required: entries must be strings", path)
+			}
+			if _, ok := props[name]; !ok {
+				return fmt.Errorf("%s.required: %q is not a property", path, name)
+			}
+		}
+	}
+
+	if raw, ok := schema["items"]; ok {
+		items, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.items: must be an object", path)
+		}
+		if err := validateSchemaMap(items, path+".items"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExternalTool proxies calls to an external command or HTTP endpoint
+type ExternalTool struct {
+	def     ExternalToolDefinition
+	sandbox sandbox.Sandbox
+}
+
+func (t *ExternalTool) Name() string {
+	return t.def.Name
+}
+
+// Capabilities reports network access for URL handlers and execution for
+// command handlers
+func (t *ExternalTool) Capabilities() []ToolCapability {
+	if t.def.URL != "" {
+		return []ToolCapability{CapabilityNetwork}
+	}
+	return []ToolCapability{CapabilityExecute}
+}
+
+func (t *ExternalTool) Description() string {
+	return t.def.Description
+}
+
+func (t *ExternalTool) Schema() map[string]interface{} {
+	return t.def.Parameters
+}
+
+func (t *ExternalTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode arguments: %w", err)
+	}
+
+	if t.def.URL != "" {
+		return t.executeHTTP(ctx, input)
+	}
+	return t.executeCommand(ctx, input)
+}
+
+func (t *ExternalTool) executeCommand(ctx context.Context, input []byte) (string, error) {
+	opts := &sandbox.ExecOptions{
+		Stdin:    string(input),
+		OnOutput: streamOutput(ctx),
+		Timeout:  time.Duration(t.def.TimeoutSeconds) * time.Second,
+	}
+
+	result, err := t.sandbox.ExecuteWithOptions(ctx, t.def.Command[0], t.def.Command[1:], opts)
+	if err := executionError(err); err != nil {
+		return "", err
+	}
+	reportExecution(ctx, result)
+
+	if result.TimedOut || result.Signal != "" {
+		return "", fmt.Errorf("handler %s", exitDescription(result))
+	}
+	if result.ExitCode != 0 {
+		msg := strings.TrimSpace(result.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(result.Stdout)
+		}
+		return "", fmt.Errorf("handler exited with code %d: %s", result.ExitCode, msg)
+	}
+	return result.Stdout, nil
+}
+
+func (t *ExternalTool) executeHTTP(ctx context.Context, input []byte) (string, error) {
+	timeout := 30 * time.Second
+	if t.def.TimeoutSeconds > 0 {
+		timeout = time.Duration(t.def.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.def.URL, bytes.NewReader(input))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("handler request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read handler response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("handler returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
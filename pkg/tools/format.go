@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// formatBatchSize bounds the number of files passed to one formatter run
+const formatBatchSize = 100
+
+// formatSkipDirs are directories of third-party code that are not formatted
+// when a directory is given
+var formatSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// DefaultFormatters returns the formatter commands used by NewFormatTool,
+// keyed by file extension. The files to format are appended to the command.
+func DefaultFormatters() map[string][]string {
+	prettier := []string{"prettier", "--write", "--log-level", "warn"}
+	return map[string][]string{
+		".go":   {"gofmt", "-w"},
+		".py":   {"black", "--quiet"},
+		".js":   prettier,
+		".jsx":  prettier,
+		".ts":   prettier,
+		".tsx":  prettier,
+		".css":  prettier,
+		".scss": prettier,
+		".json": prettier,
+		".html": prettier,
+		".md":   prettier,
+		".yaml": prettier,
+		".yml":  prettier,
+	}
+}
+
+// FormatTool runs the configured formatter for each file type over a file
+// or directory through the sandbox, and reports which files changed
+type FormatTool struct {
+	workspaceRoot string
+	sandbox       sandbox.Sandbox
+	formatters    map[string][]string
+}
+
+// NewFormatTool creates a new format tool. formatters maps a file extension
+// such as ".go" to a command that formats the files appended to it in
+// place; nil uses DefaultFormatters.
+func NewFormatTool(workspaceRoot string, sb sandbox.Sandbox, formatters map[string][]string) *FormatTool {
+	if formatters == nil {
+		formatters = DefaultFormatters()
+	}
+	return &FormatTool{
+		workspaceRoot: workspaceRoot,
+		sandbox:       sb,
+		formatters:    formatters,
+	}
+}
+
+func (t *FormatTool) Name() string {
+	return "format"
+}
+
+func (t *FormatTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityWrite, CapabilityExecute}
+}
+
+func (t *FormatTool) Description() string {
+	return "Format a file, or every supported file under a directory, in place with the project's formatter for each file type (" +
+		strings.Join(t.describeFormatters(), ", ") + "). Reports which files changed. Use it after editing instead of running formatters through bash."
+}
+
+func (t *FormatTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The file or directory to format, relative to the workspace root. Use \".\" for the whole workspace.",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *FormatTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return "", fmt.Errorf("path is required; use \".\" to format the whole workspace")
+	}
+	target := filepath.Join(t.workspaceRoot, path)
+	if err := checkSearchPath(t.workspaceRoot, target); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", fmt.Errorf("cannot access path: %w", err)
+	}
+
+	files, err := t.collectFiles(target, info)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "No files with a configured formatter found.", nil
+	}
+
+	var changed, notes []string
+	formatted := 0
+	for _, ext := range sortedKeys(files) {
+		command := t.formatters[ext]
+		paths := files[ext]
+		if !sandbox.InterpreterAvailable(command[0]) {
+			notes = append(notes, fmt.Sprintf("%s is not installed; skipped %d %s file(s). Do not retry; format them another way or leave them as they are.", command[0], len(paths), ext))
+			continue
+		}
+
+		before := hashFiles(paths)
+		for start := 0; start < len(paths); start += formatBatchSize {
+			batch := paths[start:min(start+formatBatchSize, len(paths))]
+			result, err := t.sandbox.ExecuteWithOptions(ctx, command[0], append(command[1:len(command):len(command)], batch...), &sandbox.ExecOptions{CombineOutput: true})
+			if err := executionError(err); err != nil {
+				return "", err
+			}
+			if result.TimedOut || result.ExitCode != 0 {
+				notes = append(notes, fmt.Sprintf("%s failed (%s):\n%s", command[0], exitDescription(result), strings.TrimSpace(result.Combined)))
+				continue
+			}
+			formatted += len(batch)
+		}
+		for _, path := range paths {
+			if hash, ok := fileHash(path); ok && hash != before[path] {
+				rel, _ := filepath.Rel(t.workspaceRoot, path)
+				changed = append(changed, rel)
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Formatted %d file(s), %d changed", formatted, len(changed))
+	for _, rel := range changed {
+		b.WriteString("\n  " + rel)
+	}
+	for _, note := range notes {
+		b.WriteString("\n\n" + note)
+	}
+	return b.String(), nil
+}
+
+// collectFiles returns the files to format under target, grouped by
+// extension. A directory is walked, skipping hidden and vendored
+// directories; a single file is formatted only if its type has a formatter.
+func (t *FormatTool) collectFiles(target string, info os.FileInfo) (map[string][]string, error) {
+	files := make(map[string][]string)
+	add := func(path string) {
+		ext := strings.ToLower(filepath.Ext(path))
+		if command := t.formatters[ext]; len(command) > 0 {
+			files[ext] = append(files[ext], path)
+		}
+	}
+
+	if !info.IsDir() {
+		add(target)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no formatter is configured for %q files", filepath.Ext(target))
+		}
+		return files, nil
+	}
+
+	err := filepath.WalkDir(target, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != target && (strings.HasPrefix(d.Name(), ".") || formatSkipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && !strings.HasPrefix(d.Name(), ".") {
+			add(path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// describeFormatters lists the formatter programs and the extensions they
+// handle, e.g. "gofmt for .go"
+func (t *FormatTool) describeFormatters() []string {
+	byProgram := make(map[string][]string)
+	for ext, command := range t.formatters {
+		if len(command) > 0 {
+			byProgram[command[0]] = append(byProgram[command[0]], ext)
+		}
+	}
+	var parts []string
+	for _, program := range sortedKeys(byProgram) {
+		exts := byProgram[program]
+		sort.Strings(exts)
+		parts = append(parts, program+" for "+strings.Join(exts, " "))
+	}
+	return parts
+}
+
+// hashFiles returns the content hash of each readable file
+func hashFiles(paths []string) map[string][sha256.Size]byte {
+	hashes := make(map[string][sha256.Size]byte, len(paths))
+	for _, path := range paths {
+		if hash, ok := fileHash(path); ok {
+			hashes[path] = hash
+		}
+	}
+	return hashes
+}
+
+func fileHash(path string) ([sha256.Size]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(data), true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
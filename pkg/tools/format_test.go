@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+func TestFormatTool(t *testing.T) {
+	if !sandbox.InterpreterAvailable("sed") {
+		t.Skip("sed is not installed")
+	}
+	sb := newTestSandbox(t)
+	root, err := sb.ResolveWorkingDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		"messy.txt":                  "bad spacing\n",
+		"clean.txt":                  "fine\n",
+		"sub/nested.txt":             "bad again\n",
+		"node_modules/dep/index.txt": "bad but vendored\n",
+		"notes.md":                   "# Notes\n",
+		"script.sh":                  "echo bad\n",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := NewFormatTool(root, sb, map[string][]string{
+		".txt": {"sed", "-i", "s/bad/good/"},
+		".md":  {"looper-missing-formatter"},
+	})
+
+	// The path is required; "." covers the whole workspace
+	for _, args := range []map[string]interface{}{{}, {"path": ""}} {
+		if _, err := tool.Execute(context.Background(), args); err == nil || !strings.Contains(err.Error(), "path is required") {
+			t.Errorf("Execute(%v): err = %v, want path is required", args, err)
+		}
+	}
+
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"path": "sub/nested.txt"})
+	if err != nil {
+		t.Fatalf("format one file: %v", err)
+	}
+	if !strings.HasPrefix(out, "Formatted 1 file(s), 1 changed") {
+		t.Errorf("format one file:\n%s", out)
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]interface{}{"path": "."})
+	if err != nil {
+		t.Fatalf("format the workspace: %v", err)
+	}
+	if !strings.HasPrefix(out, "Formatted 3 file(s), 1 changed\n  messy.txt") {
+		t.Errorf("format the workspace:\n%s", out)
+	}
+	if !strings.Contains(out, "looper-missing-formatter is not installed; skipped 1 .md file(s). Do not retry") {
+		t.Errorf("missing formatter not reported:\n%s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "node_modules", "dep", "index.txt")); string(data) != "bad but vendored\n" {
+		t.Errorf("vendored file formatted: %q", data)
+	}
+
+	// Paths outside the workspace, without a formatter or missing fail
+	for _, path := range []string{"../outside.txt", "script.sh", "missing.txt"} {
+		if _, err := tool.Execute(context.Background(), map[string]interface{}{"path": path}); err == nil {
+			t.Errorf("format %s: no error", path)
+		}
+	}
+}
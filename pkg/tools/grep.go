@@ -3,31 +3,67 @@ package tools
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/looper-ai/looper/pkg/truncate"
 )
 
 // GrepTool searches for patterns in files
 type GrepTool struct {
 	workspaceRoot string
+
+	maxFileSize     int64
+	binaryDetection bool
+}
+
+// GrepOption configures a GrepTool
+type GrepOption func(*GrepTool)
+
+// WithGrepMaxFileSize sets the size above which files are skipped (0 =
+// unlimited). Defaults to DefaultMaxFileSize.
+func WithGrepMaxFileSize(bytes int64) GrepOption {
+	return func(t *GrepTool) {
+		t.maxFileSize = bytes
+	}
+}
+
+// WithGrepBinaryDetection sets whether files containing NUL bytes are
+// skipped. Enabled by default.
+func WithGrepBinaryDetection(enabled bool) GrepOption {
+	return func(t *GrepTool) {
+		t.binaryDetection = enabled
+	}
 }
 
 // NewGrepTool creates a new grep tool
-func NewGrepTool(workspaceRoot string) *GrepTool {
-	return &GrepTool{
-		workspaceRoot: workspaceRoot,
+func NewGrepTool(workspaceRoot string, opts ...GrepOption) *GrepTool {
+	t := &GrepTool{
+		workspaceRoot:   workspaceRoot,
+		maxFileSize:     DefaultMaxFileSize,
+		binaryDetection: true,
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
 }
 
 func (t *GrepTool) Name() string {
 	return "grep"
 }
 
+func (t *GrepTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityRead}
+}
+
 func (t *GrepTool) Description() string {
-	return "Search for a regex pattern in files within the workspace. Returns matching lines with file paths and line numbers."
+	return "Search for a regex pattern (or a fixed string) in files within the workspace. Returns matching lines with file paths and line numbers."
 }
 
 func (t *GrepTool) Schema() map[string]interface{} {
@@ -50,10 +86,26 @@ func (t *GrepTool) Schema() map[string]interface{} {
 				"type":        "boolean",
 				"description": "Whether to perform case-insensitive matching",
 			},
+			"fixed_string": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat the pattern as a literal string instead of a regex (like grep -F). Defaults to false.",
+			},
+			"whole_word": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Only match the pattern as a whole word, bounded by non-word characters. Defaults to false.",
+			},
 			"max_results": map[string]interface{}{
 				"type":        "integer",
 				"description": "Maximum number of results to return. Defaults to 100.",
 			},
+			"group_by_file": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Group results under a '=== file ===' header per file, listing only 'line: content' below. Defaults to false.",
+			},
+			"max_results_per_file": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of results to return from a single file. Defaults to no per-file limit.",
+			},
 		},
 		"required": []string{"pattern"},
 	}
@@ -70,14 +122,8 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		searchPath = filepath.Join(t.workspaceRoot, p)
 	}
 
-	// Validate path is within workspace
-	absPath, err := filepath.Abs(searchPath)
-	if err != nil {
-		return "", fmt.Errorf("invalid path: %w", err)
-	}
-	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
-	if !strings.HasPrefix(absPath, absWorkspace) {
-		return "", fmt.Errorf("path must be within workspace")
+	if err := checkSearchPath(t.workspaceRoot, searchPath); err != nil {
+		return "", err
 	}
 
 	caseInsensitive := false
@@ -85,6 +131,16 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		caseInsensitive = ci
 	}
 
+	fixedString := false
+	if fs, ok := args["fixed_string"].(bool); ok {
+		fixedString = fs
+	}
+
+	wholeWord := false
+	if ww, ok := args["whole_word"].(bool); ok {
+		wholeWord = ww
+	}
+
 	maxResults := 100
 	if mr, ok := args["max_results"].(float64); ok {
 		maxResults = int(mr)
@@ -95,20 +151,131 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		include = inc
 	}
 
-	// Compile regex
-	flags := ""
-	if caseInsensitive {
-		flags = "(?i)"
+	groupByFile := false
+	if g, ok := args["group_by_file"].(bool); ok {
+		groupByFile = g
 	}
-	re, err := regexp.Compile(flags + pattern)
+
+	maxPerFile := 0
+	if mpf, ok := args["max_results_per_file"].(float64); ok {
+		maxPerFile = int(mpf)
+	}
+
+	match, err := lineMatcher(pattern, fixedString, wholeWord, caseInsensitive)
 	if err != nil {
-		return "", fmt.Errorf("invalid regex pattern: %w", err)
+		return "", err
+	}
+
+	// Matches past max_results are only counted, and only up to countLimit,
+	// so a broad pattern does not read the whole workspace
+	countLimit := grepCountLimit
+	if maxResults > countLimit {
+		countLimit = maxResults
 	}
 
 	var results []string
 	resultCount := 0
+	totalCount := 0 // Matches found, including those past the caps
+	stopped := false
+
+	err = walkTextFiles(ctx, searchPath, include, t.maxFileSize, t.binaryDetection, func(path string, reader io.Reader) error {
+		relPath, _ := filepath.Rel(t.workspaceRoot, path)
+		scanner := bufio.NewScanner(reader)
+		lineNum := 0
+		fileCount := 0
+		fileTotal := 0
 
-	err = filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
+		for scanner.Scan() {
+			lineNum++
+			line := scanner.Text()
+
+			if match(line) {
+				totalCount++
+				fileTotal++
+				// Past a cap, keep counting so the notice can report the total
+				if resultCount >= maxResults || (maxPerFile > 0 && fileCount >= maxPerFile) {
+					if totalCount >= countLimit {
+						stopped = true
+						break
+					}
+					continue
+				}
+
+				if groupByFile {
+					if fileCount == 0 {
+						if len(results) > 0 {
+							results = append(results, "")
+						}
+						results = append(results, fmt.Sprintf("=== %s ===", relPath))
+					}
+					results = append(results, fmt.Sprintf("%d: %s", lineNum, line))
+				} else {
+					results = append(results, fmt.Sprintf("%s:%d: %s", relPath, lineNum, line))
+				}
+				resultCount++
+				fileCount++
+			}
+		}
+
+		if fileTotal > fileCount && resultCount < maxResults {
+			results = append(results, fmt.Sprintf("... more matches in %s %s", relPath, matchNotice(fileCount, fileTotal, stopped)))
+		}
+		if stopped {
+			return errStopWalk
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+
+	if totalCount > resultCount && (resultCount >= maxResults || stopped) {
+		results = append(results, "\n"+matchNotice(resultCount, totalCount, stopped))
+	}
+
+	if len(results) == 0 {
+		return "No matches found.", nil
+	}
+
+	return strings.Join(results, "\n"), nil
+}
+
+// grepCountLimit is how many matches grep counts, shown or not, before it
+// stops searching
+const grepCountLimit = 10000
+
+// errStopWalk ends a walkTextFiles walk early without an error
+var errStopWalk = errors.New("stop walk")
+
+// matchNotice reports how many of the matches found are shown; once
+// counting has stopped, total is only a lower bound
+func matchNotice(shown, total int, stopped bool) string {
+	if stopped {
+		return truncate.NoticeAtLeast(int64(shown), int64(total), "matches")
+	}
+	return truncate.Notice(int64(shown), int64(total), "matches")
+}
+
+// checkSearchPath rejects a search path outside the workspace
+func checkSearchPath(workspaceRoot, searchPath string) error {
+	absPath, err := filepath.Abs(searchPath)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	absWorkspace, _ := filepath.Abs(workspaceRoot)
+	if !withinWorkspace(absWorkspace, absPath) {
+		return fmt.Errorf("path must be within workspace")
+	}
+	return nil
+}
+
+// walkTextFiles calls fn with each file under searchPath that a search
+// should read: hidden files and directories are skipped, as are files not
+// matching include, files over maxFileSize (0 = unlimited) and, with
+// binaryDetection, files that look binary. Unreadable files are skipped.
+func walkTextFiles(ctx context.Context, searchPath, include string, maxFileSize int64, binaryDetection bool, fn func(path string, r io.Reader) error) error {
+	return filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
@@ -134,8 +301,7 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 			}
 		}
 
-		// Skip binary files (simple heuristic)
-		if info.Size() > 10*1024*1024 { // Skip files larger than 10MB
+		if maxFileSize > 0 && info.Size() > maxFileSize {
 			return nil
 		}
 
@@ -152,35 +318,45 @@ func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		}
 		defer file.Close()
 
-		relPath, _ := filepath.Rel(t.workspaceRoot, path)
-		scanner := bufio.NewScanner(file)
-		lineNum := 0
-
-		for scanner.Scan() {
-			lineNum++
-			line := scanner.Text()
-
-			if re.MatchString(line) {
-				results = append(results, fmt.Sprintf("%s:%d: %s", relPath, lineNum, line))
-				resultCount++
-
-				if resultCount >= maxResults {
-					results = append(results, fmt.Sprintf("\n... truncated (showing %d of potentially more results)", maxResults))
-					return filepath.SkipAll
-				}
+		reader := bufio.NewReader(file)
+		if binaryDetection {
+			if head, _ := reader.Peek(binarySniffLength); looksBinary(head) {
+				return nil
 			}
 		}
-
-		return nil
+		return fn(path, reader)
 	})
+}
 
-	if err != nil && err != filepath.SkipAll {
-		return "", fmt.Errorf("search failed: %w", err)
+// lineMatcher builds the match function for a search. Fixed strings use
+// plain substring matching unless whole-word matching is requested, in which
+// case the quoted string is wrapped in \b anchors like a regex pattern.
+func lineMatcher(pattern string, fixedString, wholeWord, caseInsensitive bool) (func(string) bool, error) {
+	if fixedString && !wholeWord {
+		if caseInsensitive {
+			needle := strings.ToLower(pattern)
+			return func(line string) bool {
+				return strings.Contains(strings.ToLower(line), needle)
+			}, nil
+		}
+		return func(line string) bool {
+			return strings.Contains(line, pattern)
+		}, nil
 	}
 
-	if len(results) == 0 {
-		return "No matches found.", nil
+	if fixedString {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if caseInsensitive {
+		pattern = "(?i)" + pattern
 	}
 
-	return strings.Join(results, "\n"), nil
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return re.MatchString, nil
 }
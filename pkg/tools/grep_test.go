@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepMaxResults(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte(strings.Repeat("match\n", 30)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("match\nother\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewGrepTool(root)
+
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"pattern": "match", "max_results": float64(5)})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if n := strings.Count(out, ": match"); n != 5 {
+		t.Errorf("%d results, want 5:\n%s", n, out)
+	}
+	if !strings.HasSuffix(out, "[showing 5 of 31 matches]") {
+		t.Errorf("output does not report the total:\n%s", out)
+	}
+
+	// A per-file cap notes the rest of each file
+	out, err = tool.Execute(context.Background(), map[string]interface{}{"pattern": "match", "max_results_per_file": float64(2)})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, "... more matches in a.txt [showing 2 of 30 matches]") || !strings.Contains(out, "b.txt:1: match") {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestGrepStopsCounting(t *testing.T) {
+	root := t.TempDir()
+	// a.txt alone has more matches than grep counts, so b.txt is not read
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte(strings.Repeat("match\n", grepCountLimit+500)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte(strings.Repeat("match\n", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := NewGrepTool(root).Execute(context.Background(), map[string]interface{}{"pattern": "match", "max_results": float64(5)})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.HasSuffix(out, "[showing 5 of 10000+ matches]") {
+		t.Errorf("output does not report a lower bound:\n%s", out)
+	}
+}
+
+func TestGrepNoMatches(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("nothing here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := NewGrepTool(root).Execute(context.Background(), map[string]interface{}{"pattern": "match"})
+	if err != nil || out != "No matches found." {
+		t.Errorf("Execute = %q, %v", out, err)
+	}
+}
@@ -25,6 +25,10 @@ func (t *ListDirTool) Name() string {
 	return "list_dir"
 }
 
+func (t *ListDirTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityRead}
+}
+
 func (t *ListDirTool) Description() string {
 	return "List the contents of a directory in the workspace. Shows files and subdirectories."
 }
@@ -45,6 +49,11 @@ func (t *ListDirTool) Schema() map[string]interface{} {
 				"type":        "integer",
 				"description": "Maximum depth for recursive listing. Defaults to 3.",
 			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"description": "Output format: 'flat' lists one path per line, 'tree' renders a tree like the Unix tree command. Defaults to 'flat'.",
+				"enum":        []string{"flat", "tree"},
+			},
 		},
 		"required": []string{},
 	}
@@ -64,7 +73,7 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
-	if !strings.HasPrefix(absPath, absWorkspace) {
+	if !withinWorkspace(absWorkspace, absPath) {
 		return "", fmt.Errorf("path must be within workspace")
 	}
 
@@ -90,10 +99,23 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{})
 		maxDepth = int(md)
 	}
 
+	format := "flat"
+	if f, ok := args["format"].(string); ok && f != "" {
+		format = f
+	}
+	if format != "flat" && format != "tree" {
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+
 	var entries []string
 
-	if recursive {
-		err = t.listRecursive(ctx, fullPath, "", 0, maxDepth, &entries)
+	if format == "tree" {
+		if !recursive {
+			maxDepth = 0
+		}
+		err = t.listRecursive(ctx, fullPath, "", "", 0, maxDepth, true, &entries)
+	} else if recursive {
+		err = t.listRecursive(ctx, fullPath, "", "", 0, maxDepth, false, &entries)
 	} else {
 		err = t.listFlat(ctx, fullPath, &entries)
 	}
@@ -106,6 +128,15 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{})
 		return "Directory is empty.", nil
 	}
 
+	if format == "tree" {
+		// Tree lines are emitted in display order under a root line
+		root := path
+		if root == "" {
+			root = "."
+		}
+		return strings.TrimSuffix(root, "/") + "/\n" + strings.Join(entries, "\n"), nil
+	}
+
 	sort.Strings(entries)
 	return strings.Join(entries, "\n"), nil
 }
@@ -138,7 +169,10 @@ func (t *ListDirTool) listFlat(ctx context.Context, dir string, entries *[]strin
 	return nil
 }
 
-func (t *ListDirTool) listRecursive(ctx context.Context, basePath, relPath string, depth, maxDepth int, entries *[]string) error {
+// listRecursive walks the directory tree. In tree mode each entry is rendered
+// with a connector chosen by whether it is the last visible entry in its
+// parent, and treePrefix carries the vertical guides for ancestor levels.
+func (t *ListDirTool) listRecursive(ctx context.Context, basePath, relPath, treePrefix string, depth, maxDepth int, tree bool, entries *[]string) error {
 	if depth > maxDepth {
 		return nil
 	}
@@ -155,21 +189,40 @@ func (t *ListDirTool) listRecursive(ctx context.Context, basePath, relPath strin
 		return nil // Skip directories we can't read
 	}
 
+	// Skip hidden files
+	visible := make([]os.DirEntry, 0, len(items))
 	for _, item := range items {
-		// Skip hidden files
-		if strings.HasPrefix(item.Name(), ".") {
-			continue
+		if !strings.HasPrefix(item.Name(), ".") {
+			visible = append(visible, item)
 		}
+	}
 
+	for i, item := range visible {
 		itemRelPath := filepath.Join(relPath, item.Name())
-		if item.IsDir() {
-			*entries = append(*entries, itemRelPath+"/")
-			if err := t.listRecursive(ctx, basePath, itemRelPath, depth+1, maxDepth, entries); err != nil {
-				return err
+
+		childPrefix := treePrefix
+		if tree {
+			connector, guide := "├── ", "│   "
+			if i == len(visible)-1 {
+				connector, guide = "└── ", "    "
+			}
+			name := item.Name()
+			if item.IsDir() {
+				name += "/"
 			}
+			*entries = append(*entries, treePrefix+connector+name)
+			childPrefix = treePrefix + guide
+		} else if item.IsDir() {
+			*entries = append(*entries, itemRelPath+"/")
 		} else {
 			*entries = append(*entries, itemRelPath)
 		}
+
+		if item.IsDir() {
+			if err := t.listRecursive(ctx, basePath, itemRelPath, childPrefix, depth+1, maxDepth, tree, entries); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+const (
+	defaultProcessTailLines = 50
+	defaultProcessStopGrace = 5 * time.Second
+
+	// maxExitedProcesses is how many exited processes stay listed, with
+	// their output, before the oldest are forgotten
+	maxExitedProcesses = 20
+)
+
+// ProcessTool manages long-running background processes such as dev
+// servers and file watchers
+type ProcessTool struct {
+	sandbox sandbox.Sandbox
+
+	mu       sync.Mutex
+	handles  map[string]sandbox.ProcessHandle
+	commands map[string]string
+	started  []string // IDs in the order they were started
+}
+
+// NewProcessTool creates a new process tool
+func NewProcessTool(sb sandbox.Sandbox) *ProcessTool {
+	return &ProcessTool{
+		sandbox:  sb,
+		handles:  make(map[string]sandbox.ProcessHandle),
+		commands: make(map[string]string),
+	}
+}
+
+func (t *ProcessTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityExecute}
+}
+
+func (t *ProcessTool) Description() string {
+	return "Manage long-running background processes such as dev servers or watchers. " +
+		"'start' runs a " + defaultShell.name + " command in the background and returns its id; " +
+		"'status' and 'output' inspect it; 'stop' terminates it and everything it spawned; 'list' shows all processes. " +
+		"Use the execute or " + defaultShell.name + " tools for commands that finish on their own."
+}
+
+func (t *ProcessTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{"start", "status", "output", "stop", "list"},
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "The " + defaultShell.name + " command to run (for start)",
+			},
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "The process id returned by start (for status, output and stop)",
+			},
+			"tail_lines": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of trailing output lines to return (for output). Defaults to %d.", defaultProcessTailLines),
+			},
+			"cwd": cwdSchema(),
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ProcessTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, ok := args["action"].(string)
+	if !ok || action == "" {
+		return "", fmt.Errorf("action is required")
+	}
+
+	switch action {
+	case "start":
+		return t.start(ctx, args)
+	case "list":
+		return t.list(), nil
+	case "status", "output", "stop":
+	default:
+		return "", fmt.Errorf("unsupported action: %s", action)
+	}
+
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("id is required for %s", action)
+	}
+	t.mu.Lock()
+	handle, ok := t.handles[id]
+	t.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("no process with id %q", id)
+	}
+
+	switch action {
+	case "status":
+		return describeProcess(handle), nil
+	case "output":
+		tail := defaultProcessTailLines
+		if n, ok := args["tail_lines"].(float64); ok && n > 0 {
+			tail = int(n)
+		}
+		output := handle.Output(tail)
+		if output == "" {
+			return describeProcess(handle) + "\n(no output)", nil
+		}
+		return describeProcess(handle) + "\n" + output, nil
+	default:
+		if err := handle.Stop(defaultProcessStopGrace); err != nil {
+			return "", err
+		}
+		return describeProcess(handle), nil
+	}
+}
+
+func (t *ProcessTool) start(ctx context.Context, args map[string]interface{}) (string, error) {
+	command, ok := args["command"].(string)
+	if !ok || command == "" {
+		return "", fmt.Errorf("command is required for start")
+	}
+
+	opts := &sandbox.ExecOptions{}
+	if cwd, ok := args["cwd"].(string); ok {
+		opts.WorkingDir = cwd
+	}
+
+	program, programArgs := defaultShell.command(command)
+	handle, err := t.sandbox.StartBackground(ctx, program, programArgs, opts)
+	if err := executionError(err); err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	t.handles[handle.ID()] = handle
+	t.commands[handle.ID()] = command
+	t.started = append(t.started, handle.ID())
+	t.forgetExited()
+	t.mu.Unlock()
+
+	return fmt.Sprintf("Started process %s: %s", handle.ID(), command), nil
+}
+
+func (t *ProcessTool) list() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.handles) == 0 {
+		return "No background processes"
+	}
+	ids := make([]string, 0, len(t.handles))
+	for id := range t.handles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "%s: %s\n", describeProcess(t.handles[id]), t.commands[id])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// forgetExited drops the oldest exited processes beyond
+// maxExitedProcesses, so a long session does not keep the output of every
+// process it ever ran. The caller holds mu.
+func (t *ProcessTool) forgetExited() {
+	exited := 0
+	for _, id := range t.started {
+		if !t.handles[id].Running() {
+			exited++
+		}
+	}
+
+	kept := t.started[:0]
+	for _, id := range t.started {
+		if exited > maxExitedProcesses && !t.handles[id].Running() {
+			delete(t.handles, id)
+			delete(t.commands, id)
+			exited--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	t.started = kept
+}
+
+// describeProcess summarizes a process's state, e.g. "bg-1 running"
+func describeProcess(h sandbox.ProcessHandle) string {
+	if h.Running() {
+		return h.ID() + " running"
+	}
+	if code := h.ExitCode(); code >= 0 {
+		return fmt.Sprintf("%s exited with code %d", h.ID(), code)
+	}
+	return h.ID() + " was killed"
+}
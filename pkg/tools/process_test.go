@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// fakeProcess is a ProcessHandle that only reports whether it is running
+type fakeProcess struct {
+	sandbox.ProcessHandle
+	id      string
+	running bool
+}
+
+func (p *fakeProcess) ID() string    { return p.id }
+func (p *fakeProcess) Running() bool { return p.running }
+
+func TestProcessToolForgetsExited(t *testing.T) {
+	tool := NewProcessTool(nil)
+	for i := 0; i < maxExitedProcesses+10; i++ {
+		id := fmt.Sprintf("bg-%d", i+1)
+		tool.handles[id] = &fakeProcess{id: id, running: i%5 == 0}
+		tool.commands[id] = "sleep 1"
+		tool.started = append(tool.started, id)
+		tool.forgetExited()
+	}
+
+	exited := 0
+	for _, id := range tool.started {
+		if !tool.handles[id].Running() {
+			exited++
+		}
+	}
+	if exited != maxExitedProcesses {
+		t.Errorf("%d exited processes kept, want %d", exited, maxExitedProcesses)
+	}
+	if len(tool.handles) != len(tool.started) || len(tool.commands) != len(tool.started) {
+		t.Errorf("%d handles and %d commands for %d started IDs", len(tool.handles), len(tool.commands), len(tool.started))
+	}
+
+	// Running processes are never forgotten, and the oldest exited go first
+	if _, ok := tool.handles["bg-1"]; !ok {
+		t.Error("running process bg-1 was forgotten")
+	}
+	if _, ok := tool.handles["bg-2"]; ok {
+		t.Error("oldest exited process bg-2 was kept")
+	}
+	if _, ok := tool.handles[fmt.Sprintf("bg-%d", maxExitedProcesses+10)]; !ok {
+		t.Error("newest process was forgotten")
+	}
+}
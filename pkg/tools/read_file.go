@@ -2,31 +2,109 @@ package tools
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf16"
 )
 
+// DefaultMaxFileSize is the largest file read_file reads whole and grep
+// searches
+const DefaultMaxFileSize = 10 * 1024 * 1024
+
+// binarySniffLength is how much of a file is checked for NUL bytes to
+// detect binary content
+const binarySniffLength = 8000
+
 // ReadFileTool reads file contents
 type ReadFileTool struct {
 	workspaceRoot string
+	readOnlyRoots []string
+
+	maxFileSize     int64
+	binaryDetection bool
+	maxLines        int
+	encoding        string
+}
+
+// ReadFileOption configures a ReadFileTool
+type ReadFileOption func(*ReadFileTool)
+
+// WithMaxFileSize sets the largest file that is read whole (0 = unlimited).
+// Larger files can still be read by line range. Defaults to
+// DefaultMaxFileSize.
+func WithMaxFileSize(bytes int64) ReadFileOption {
+	return func(t *ReadFileTool) {
+		t.maxFileSize = bytes
+	}
+}
+
+// WithBinaryDetection sets whether files containing NUL bytes are refused
+// instead of returned as garbled text. Enabled by default.
+func WithBinaryDetection(enabled bool) ReadFileOption {
+	return func(t *ReadFileTool) {
+		t.binaryDetection = enabled
+	}
+}
+
+// WithMaxLines caps the number of lines returned by one call (0 =
+// unlimited, the default)
+func WithMaxLines(n int) ReadFileOption {
+	return func(t *ReadFileTool) {
+		t.maxLines = n
+	}
+}
+
+// WithEncoding sets the encoding files are decoded from: "utf-8" (the
+// default), "latin1" (ISO-8859-1), "utf-16le" or "utf-16be"
+func WithEncoding(enc string) ReadFileOption {
+	return func(t *ReadFileTool) {
+		t.encoding = enc
+	}
+}
+
+// WithReadOnlyRoots lets absolute paths inside the given directories be
+// read as well as the workspace, such as skill directories shared by every
+// workspace
+func WithReadOnlyRoots(dirs ...string) ReadFileOption {
+	return func(t *ReadFileTool) {
+		for _, dir := range dirs {
+			if abs, err := filepath.Abs(dir); err == nil {
+				t.readOnlyRoots = append(t.readOnlyRoots, abs)
+			}
+		}
+	}
 }
 
 // NewReadFileTool creates a new read file tool
-func NewReadFileTool(workspaceRoot string) *ReadFileTool {
-	return &ReadFileTool{
-		workspaceRoot: workspaceRoot,
+func NewReadFileTool(workspaceRoot string, opts ...ReadFileOption) *ReadFileTool {
+	t := &ReadFileTool{
+		workspaceRoot:   workspaceRoot,
+		maxFileSize:     DefaultMaxFileSize,
+		binaryDetection: true,
+		encoding:        "utf-8",
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
 func (t *ReadFileTool) Name() string {
 	return "read_file"
 }
 
+func (t *ReadFileTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityRead}
+}
+
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file from the workspace. Can optionally read specific line ranges."
+	return "Read the contents of a file from the workspace, or a skill file by the absolute path it is listed under. Can optionally read specific line ranges."
 }
 
 func (t *ReadFileTool) Schema() map[string]interface{} {
@@ -35,7 +113,7 @@ func (t *ReadFileTool) Schema() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"path": map[string]interface{}{
 				"type":        "string",
-				"description": "The file path relative to the workspace root",
+				"description": "The file path relative to the workspace root, or an absolute path within the workspace or a skill directory",
 			},
 			"start_line": map[string]interface{}{
 				"type":        "integer",
@@ -45,6 +123,10 @@ func (t *ReadFileTool) Schema() map[string]interface{} {
 				"type":        "integer",
 				"description": "The ending line number (inclusive). If not provided, reads to the end.",
 			},
+			"show_line_numbers": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Prefix each line with its line number and '|'. Set to false to get the raw file content. Defaults to true.",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -56,16 +138,9 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("path is required")
 	}
 
-	fullPath := filepath.Join(t.workspaceRoot, path)
-
-	// Validate path is within workspace
-	absPath, err := filepath.Abs(fullPath)
+	fullPath, err := t.resolvePath(path)
 	if err != nil {
-		return "", fmt.Errorf("invalid path: %w", err)
-	}
-	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
-	if !strings.HasPrefix(absPath, absWorkspace) {
-		return "", fmt.Errorf("path must be within workspace")
+		return "", err
 	}
 
 	// Check if file exists
@@ -90,6 +165,15 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 		endLine = int(el)
 	}
 
+	showLineNumbers := true
+	if sln, ok := args["show_line_numbers"].(bool); ok {
+		showLineNumbers = sln
+	}
+
+	if t.maxFileSize > 0 && info.Size() > t.maxFileSize && startLine <= 0 && endLine <= 0 {
+		return "", fmt.Errorf("file is %d bytes, larger than the %d byte limit; read it in parts with start_line and end_line", info.Size(), t.maxFileSize)
+	}
+
 	// Read file
 	file, err := os.Open(fullPath)
 	if err != nil {
@@ -97,9 +181,24 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 	}
 	defer file.Close()
 
+	reader := bufio.NewReader(file)
+	encoding := normalizeEncoding(t.encoding)
+	// UTF-16 text is full of NUL bytes, so it cannot be sniffed
+	if t.binaryDetection && !strings.HasPrefix(encoding, "utf-16") {
+		head, _ := reader.Peek(binarySniffLength)
+		if looksBinary(head) {
+			return "", fmt.Errorf("%s appears to be a binary file", path)
+		}
+	}
+	text, err := decodeText(reader, encoding)
+	if err != nil {
+		return "", err
+	}
+
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(text)
 	lineNum := 0
+	truncated := false
 
 	for scanner.Scan() {
 		lineNum++
@@ -121,7 +220,16 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 			break
 		}
 
-		lines = append(lines, fmt.Sprintf("%6d|%s", lineNum, scanner.Text()))
+		if t.maxLines > 0 && len(lines) >= t.maxLines {
+			truncated = true
+			break
+		}
+
+		if showLineNumbers {
+			lines = append(lines, fmt.Sprintf("%6d|%s", lineNum, scanner.Text()))
+		} else {
+			lines = append(lines, scanner.Text())
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -135,5 +243,93 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 		return "File is empty.", nil
 	}
 
-	return strings.Join(lines, "\n"), nil
+	result := strings.Join(lines, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n[... output limited to %d lines; continue with start_line=%d]", t.maxLines, lineNum)
+	}
+	return result, nil
+}
+
+// resolvePath returns the file a path argument names. Relative paths are
+// resolved against the workspace; absolute paths are used as given. Either
+// way the file must be in the workspace or a read-only root.
+func (t *ReadFileTool) resolvePath(path string) (string, error) {
+	fullPath := path
+	if !filepath.IsAbs(path) {
+		fullPath = filepath.Join(t.workspaceRoot, path)
+	}
+	absPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+
+	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
+	if withinWorkspace(absWorkspace, absPath) {
+		return absPath, nil
+	}
+	for _, root := range t.readOnlyRoots {
+		if withinWorkspace(root, absPath) {
+			return absPath, nil
+		}
+	}
+	return "", fmt.Errorf("path must be within workspace")
+}
+
+// looksBinary reports whether the start of a file contains a NUL byte,
+// which text files never do
+func looksBinary(head []byte) bool {
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+// normalizeEncoding returns the canonical name of an encoding accepted by
+// WithEncoding, or the name lowercased if it is not one of them
+func normalizeEncoding(encoding string) string {
+	switch enc := strings.ToLower(strings.ReplaceAll(encoding, "_", "-")); enc {
+	case "", "utf8":
+		return "utf-8"
+	case "latin-1", "iso-8859-1":
+		return "latin1"
+	case "utf16le":
+		return "utf-16le"
+	case "utf16be":
+		return "utf-16be"
+	default:
+		return enc
+	}
+}
+
+// decodeText returns a reader of r's content as UTF-8, given the normalized
+// name of its encoding
+func decodeText(r io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "utf-8":
+		return r, nil
+	case "latin1":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	case "utf-16le", "utf-16be":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if encoding == "utf-16be" {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		text := string(utf16.Decode(units))
+		return strings.NewReader(strings.TrimPrefix(text, "\ufeff")), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
 }
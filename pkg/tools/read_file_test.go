@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileReadOnlyRoots(t *testing.T) {
+	workspace := t.TempDir()
+	skills := t.TempDir()
+	outside := t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(workspace, "main.go"):            "package main",
+		filepath.Join(skills, "deploy", "SKILL.md"):    "Run make deploy",
+		filepath.Join(outside, "secrets.txt"):          "hunter2",
+		filepath.Join(workspace, "skills", "local.md"): "A workspace skill",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := NewReadFileTool(workspace, WithReadOnlyRoots(skills))
+
+	for path, want := range map[string]string{
+		"main.go": "package main",
+		filepath.Join(workspace, "skills", "local.md"): "A workspace skill",
+		filepath.Join(skills, "deploy", "SKILL.md"):    "Run make deploy",
+	} {
+		out, err := tool.Execute(context.Background(), map[string]interface{}{"path": path})
+		if err != nil {
+			t.Errorf("read %s: %v", path, err)
+		} else if !strings.Contains(out, want) {
+			t.Errorf("read %s = %q, want %q", path, out, want)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(outside, "secrets.txt"),
+		filepath.Join(skills, "..", filepath.Base(outside), "secrets.txt"),
+		filepath.Join("..", filepath.Base(outside), "secrets.txt"),
+	} {
+		if out, err := tool.Execute(context.Background(), map[string]interface{}{"path": path}); err == nil {
+			t.Errorf("read %s outside the workspace and skill roots: %q", path, out)
+		}
+	}
+
+	// Relative paths stay relative to the workspace, not the roots
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"path": filepath.Join("deploy", "SKILL.md")}); err == nil {
+		t.Error("relative path resolved against a read-only root")
+	}
+}
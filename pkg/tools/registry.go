@@ -2,6 +2,7 @@ package tools
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -52,7 +53,7 @@ func (r *Registry) List() []Tool {
 	return tools
 }
 
-// Names returns the names of all registered tools
+// Names returns the names of all registered tools, sorted
 func (r *Registry) Names() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -61,9 +62,18 @@ func (r *Registry) Names() []string {
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
+// Count returns the number of registered tools
+func (r *Registry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.tools)
+}
+
 // Unregister removes a tool from the registry
 func (r *Registry) Unregister(name string) bool {
 	r.mu.Lock()
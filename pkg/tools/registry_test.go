@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// namedTool is a tool that does nothing but has a name
+type namedTool string
+
+func (t namedTool) Name() string                   { return string(t) }
+func (t namedTool) Description() string            { return "" }
+func (t namedTool) Schema() map[string]interface{} { return nil }
+
+func (t namedTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func TestRegistryNamesSorted(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"write_file", "bash", "read_file", "grep", "execute"} {
+		if err := r.Register(namedTool(name)); err != nil {
+			t.Fatalf("Register(%q): %v", name, err)
+		}
+	}
+
+	want := []string{"bash", "execute", "grep", "read_file", "write_file"}
+	for i := 0; i < 10; i++ {
+		if got := r.Names(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Names() = %v, want %v", got, want)
+		}
+	}
+	if r.Count() != len(want) {
+		t.Errorf("Count() = %d, want %d", r.Count(), len(want))
+	}
+}
+
+func TestRegistryCount(t *testing.T) {
+	r := NewRegistry()
+	if r.Count() != 0 {
+		t.Errorf("Count() of a new registry = %d", r.Count())
+	}
+
+	r.Register(namedTool("a"))
+	r.Register(namedTool("b"))
+	if err := r.Register(namedTool("a")); err == nil {
+		t.Error("registering a duplicate name succeeded")
+	}
+	if r.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", r.Count())
+	}
+
+	r.Unregister("a")
+	if r.Count() != 1 {
+		t.Errorf("Count() after Unregister = %d, want 1", r.Count())
+	}
+	r.Clear()
+	if r.Count() != 0 || len(r.Names()) != 0 {
+		t.Errorf("Count() after Clear = %d, Names() = %v", r.Count(), r.Names())
+	}
+}
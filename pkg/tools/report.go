@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+// ExecutionReport records the outcome of the last command a tool ran.
+// Callers attach one to the context with WithExecutionReport before calling
+// Execute; tools that run commands fill it in. Tools that run nothing leave
+// Ran false.
+type ExecutionReport struct {
+	Ran      bool
+	ExitCode int
+	TimedOut bool
+}
+
+type executionReportKey struct{}
+
+// WithExecutionReport returns a context that carries report to tools
+func WithExecutionReport(ctx context.Context, report *ExecutionReport) context.Context {
+	return context.WithValue(ctx, executionReportKey{}, report)
+}
+
+// OutputHandler receives command output as it is produced
+type OutputHandler func(stream, chunk string)
+
+type outputHandlerKey struct{}
+
+// WithOutputHandler returns a context that streams the output of commands
+// run by tools to fn. All calls for a tool invocation complete before the
+// tool's Execute returns.
+func WithOutputHandler(ctx context.Context, fn OutputHandler) context.Context {
+	return context.WithValue(ctx, outputHandlerKey{}, fn)
+}
+
+// streamOutput returns a sandbox output callback forwarding to the context's
+// output handler, or nil if there is none
+func streamOutput(ctx context.Context) func(stream string, chunk []byte) {
+	fn, ok := ctx.Value(outputHandlerKey{}).(OutputHandler)
+	if !ok || fn == nil {
+		return nil
+	}
+	return func(stream string, chunk []byte) {
+		fn(stream, string(chunk))
+	}
+}
+
+// ProgressHandler receives progress messages from long-running commands
+type ProgressHandler func(message string)
+
+type progressHandlerKey struct{}
+
+// WithProgressHandler returns a context that passes progress messages from
+// slow commands run by tools, such as a test suite, to fn. All calls for a
+// tool invocation complete before the tool's Execute returns.
+func WithProgressHandler(ctx context.Context, fn ProgressHandler) context.Context {
+	return context.WithValue(ctx, progressHandlerKey{}, fn)
+}
+
+// streamProgress returns a sandbox progress callback forwarding to the
+// context's progress handler, or nil if there is none
+func streamProgress(ctx context.Context) sandbox.ProgressCallback {
+	fn, ok := ctx.Value(progressHandlerKey{}).(ProgressHandler)
+	if !ok || fn == nil {
+		return nil
+	}
+	return sandbox.ProgressCallback(fn)
+}
+
+// reportExecution records a sandbox result in the context's report, if any
+func reportExecution(ctx context.Context, result *sandbox.ExecutionResult) {
+	report, ok := ctx.Value(executionReportKey{}).(*ExecutionReport)
+	if !ok || report == nil || result == nil {
+		return
+	}
+	report.Ran = true
+	report.ExitCode = result.ExitCode
+	report.TimedOut = result.TimedOut
+}
@@ -0,0 +1,300 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaBuilder builds JSON Schemas for tool parameters with a fluent API.
+// Reusable sub-schemas are declared with Def and referenced with SchemaRef:
+//
+//	schema := tools.SchemaObject().
+//		Property("commit", tools.SchemaRef("#/$defs/Commit")).
+//		Required("commit").
+//		Def("Commit", tools.SchemaObject().
+//			Property("message", tools.SchemaString().Description("Commit message")).
+//			Required("message"))
+//	params := schema.Build()
+type SchemaBuilder struct {
+	typ         string
+	ref         string
+	description string
+	enum        []interface{}
+	properties  map[string]*SchemaBuilder
+	required    []string
+	items       *SchemaBuilder
+	defs        map[string]*SchemaBuilder
+}
+
+// SchemaObject starts an object schema
+func SchemaObject() *SchemaBuilder {
+	return &SchemaBuilder{typ: "object", properties: make(map[string]*SchemaBuilder)}
+}
+
+// SchemaString starts a string schema
+func SchemaString() *SchemaBuilder {
+	return &SchemaBuilder{typ: "string"}
+}
+
+// SchemaInteger starts an integer schema
+func SchemaInteger() *SchemaBuilder {
+	return &SchemaBuilder{typ: "integer"}
+}
+
+// SchemaNumber starts a number schema
+func SchemaNumber() *SchemaBuilder {
+	return &SchemaBuilder{typ: "number"}
+}
+
+// SchemaBoolean starts a boolean schema
+func SchemaBoolean() *SchemaBuilder {
+	return &SchemaBuilder{typ: "boolean"}
+}
+
+// SchemaArray starts an array schema whose elements match items
+func SchemaArray(items *SchemaBuilder) *SchemaBuilder {
+	return &SchemaBuilder{typ: "array", items: items}
+}
+
+// SchemaRef creates a reference to a definition, e.g. "#/$defs/Commit"
+func SchemaRef(ref string) *SchemaBuilder {
+	return &SchemaBuilder{ref: ref}
+}
+
+// Description sets the schema description
+func (b *SchemaBuilder) Description(description string) *SchemaBuilder {
+	b.description = description
+	return b
+}
+
+// Enum restricts the schema to the given values
+func (b *SchemaBuilder) Enum(values ...interface{}) *SchemaBuilder {
+	b.enum = append(b.enum, values...)
+	return b
+}
+
+// Property adds a property to an object schema
+func (b *SchemaBuilder) Property(name string, schema *SchemaBuilder) *SchemaBuilder {
+	if b.properties == nil {
+		b.properties = make(map[string]*SchemaBuilder)
+	}
+	b.properties[name] = schema
+	return b
+}
+
+// Required marks properties of an object schema as required
+func (b *SchemaBuilder) Required(names ...string) *SchemaBuilder {
+	b.required = append(b.required, names...)
+	return b
+}
+
+// Def declares a reusable sub-schema under $defs
+func (b *SchemaBuilder) Def(name string, schema *SchemaBuilder) *SchemaBuilder {
+	if b.defs == nil {
+		b.defs = make(map[string]*SchemaBuilder)
+	}
+	b.defs[name] = schema
+	return b
+}
+
+// Build returns the schema as a map suitable for Tool.Schema
+func (b *SchemaBuilder) Build() map[string]interface{} {
+	if b.ref != "" {
+		m := map[string]interface{}{"$ref": b.ref}
+		if b.description != "" {
+			m["description"] = b.description
+		}
+		return m
+	}
+
+	m := map[string]interface{}{"type": b.typ}
+	if b.description != "" {
+		m["description"] = b.description
+	}
+	if len(b.enum) > 0 {
+		m["enum"] = b.enum
+	}
+	if b.typ == "object" {
+		props := make(map[string]interface{}, len(b.properties))
+		for name, prop := range b.properties {
+			props[name] = prop.Build()
+		}
+		m["properties"] = props
+		m["required"] = append([]string{}, b.required...)
+	}
+	if b.items != nil {
+		m["items"] = b.items.Build()
+	}
+	if len(b.defs) > 0 {
+		defs := make(map[string]interface{}, len(b.defs))
+		for name, def := range b.defs {
+			defs[name] = def.Build()
+		}
+		m["$defs"] = defs
+	}
+	return m
+}
+
+// ValidationError describes an argument that does not match the schema
+type ValidationError struct {
+	// Path locates the offending value, e.g. "commit.message" or "files[2]"
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks tool arguments, as decoded from JSON, against the schema
+func (b *SchemaBuilder) Validate(args map[string]interface{}) []ValidationError {
+	v := &schemaValidator{defs: b.defs}
+	v.validate(b, args, "")
+	return v.errs
+}
+
+type schemaValidator struct {
+	defs map[string]*SchemaBuilder
+	errs []ValidationError
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) validate(schema *SchemaBuilder, value interface{}, path string) {
+	if schema.ref != "" {
+		name := strings.TrimPrefix(schema.ref, "#/$defs/")
+		def, ok := v.defs[name]
+		if !ok || name == schema.ref {
+			v.fail(path, "unresolvable reference %q", schema.ref)
+			return
+		}
+		v.validate(def, value, path)
+		return
+	}
+
+	if len(schema.enum) > 0 && !enumContains(schema.enum, value) {
+		v.fail(path, "must be one of %v", schema.enum)
+		return
+	}
+
+	switch schema.typ {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object")
+			return
+		}
+		for _, name := range schema.required {
+			if _, ok := obj[name]; !ok {
+				v.fail(joinPath(path, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := schema.properties[name]; ok {
+				v.validate(prop, obj[name], joinPath(path, name))
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "must be an array")
+			return
+		}
+		if schema.items != nil {
+			for i, item := range arr {
+				v.validate(schema.items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			v.fail(path, "must be a string")
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			v.fail(path, "must be an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			v.fail(path, "must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+		}
+	}
+}
+
+func enumContains(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if enumEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumEqual compares an enum value with an argument decoded from JSON.
+// Numbers compare by value whatever their Go type, so an enum value of 2
+// matches a decoded 2.0, but a number never equals a string such as "2".
+func enumEqual(enum, value interface{}) bool {
+	if x, ok := toFloat(enum); ok {
+		y, ok := toFloat(value)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(enum, value)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// commitSchema is the example from the SchemaBuilder documentation, with an
+// array of files and an enum
+func commitSchema() *SchemaBuilder {
+	return SchemaObject().
+		Property("commit", SchemaRef("#/$defs/Commit")).
+		Property("files", SchemaArray(SchemaString())).
+		Property("mode", SchemaString().Enum("amend", "new")).
+		Property("depth", SchemaInteger().Enum(1, 2, 3)).
+		Required("commit").
+		Def("Commit", SchemaObject().
+			Property("message", SchemaString().Description("Commit message")).
+			Property("signed", SchemaBoolean()).
+			Required("message"))
+}
+
+// decodeArgs decodes tool arguments the way they arrive from a provider
+func decodeArgs(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &args); err != nil {
+		t.Fatal(err)
+	}
+	return args
+}
+
+func TestSchemaBuild(t *testing.T) {
+	got, err := json.Marshal(commitSchema().Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$defs":{"Commit":{"properties":{"message":{"description":"Commit message","type":"string"},"signed":{"type":"boolean"}},"required":["message"],"type":"object"}},` +
+		`"properties":{"commit":{"$ref":"#/$defs/Commit"},"depth":{"enum":[1,2,3],"type":"integer"},"files":{"items":{"type":"string"},"type":"array"},"mode":{"enum":["amend","new"],"type":"string"}},` +
+		`"required":["commit"],"type":"object"}`
+	if string(got) != want {
+		t.Errorf("Build() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`{"commit":{"message":"fix"}}`, nil},
+		{`{"commit":{"message":"fix","signed":true},"files":["a.go","b.go"],"mode":"amend","depth":2}`, nil},
+		{`{}`, []string{"commit: is required"}},
+		{`{"commit":{}}`, []string{"commit.message: is required"}},
+		{`{"commit":{"message":3,"signed":"yes"}}`, []string{"commit.message: must be a string", "commit.signed: must be a boolean"}},
+		{`{"commit":{"message":"fix"},"files":["a.go",7]}`, []string{"files[1]: must be a string"}},
+		{`{"commit":{"message":"fix"},"files":"a.go"}`, []string{"files: must be an array"}},
+		{`{"commit":{"message":"fix"},"mode":"rebase"}`, []string{"mode: must be one of [amend new]"}},
+		{`{"commit":{"message":"fix"},"depth":2.5}`, []string{"depth: must be one of [1 2 3]"}},
+	}
+
+	schema := commitSchema()
+	for _, tt := range tests {
+		var got []string
+		for _, err := range schema.Validate(decodeArgs(t, tt.args)) {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%s) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaValidateUnresolvableRef(t *testing.T) {
+	schema := SchemaObject().Property("commit", SchemaRef("#/$defs/Missing"))
+	errs := schema.Validate(map[string]interface{}{"commit": map[string]interface{}{}})
+	if len(errs) != 1 || errs[0].Path != "commit" || !strings.Contains(errs[0].Message, "unresolvable") {
+		t.Errorf("errors = %v, want an unresolvable reference at commit", errs)
+	}
+}
+
+func TestSchemaEnumTypes(t *testing.T) {
+	tests := []struct {
+		enum  []interface{}
+		value interface{}
+		want  bool
+	}{
+		// Decoded JSON numbers are float64; enum values may be any number type
+		{[]interface{}{1, 2}, float64(2), true},
+		{[]interface{}{int64(5)}, float64(5), true},
+		{[]interface{}{uint8(5)}, json.Number("5"), true},
+		{[]interface{}{0.5}, float64(0.5), true},
+		{[]interface{}{1, 2}, float64(3), false},
+		// Values of different types never match, even when they print alike
+		{[]interface{}{1, 2}, "2", false},
+		{[]interface{}{"2"}, float64(2), false},
+		{[]interface{}{"true"}, true, false},
+		{[]interface{}{true}, "true", false},
+		{[]interface{}{nil}, "<nil>", false},
+		{[]interface{}{nil}, nil, true},
+		{[]interface{}{"amend"}, "amend", true},
+	}
+	for _, tt := range tests {
+		if got := enumContains(tt.enum, tt.value); got != tt.want {
+			t.Errorf("enumContains(%#v, %#v) = %v, want %v", tt.enum, tt.value, got, tt.want)
+		}
+	}
+}
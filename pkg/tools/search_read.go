@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/looper-ai/looper/pkg/truncate"
+)
+
+const (
+	defaultSearchReadContext  = 5
+	maxSearchReadContext      = 50
+	defaultSearchReadFiles    = 10
+	defaultSearchReadPerFile  = 20
+	defaultSearchReadMaxBytes = 30000
+)
+
+// SearchReadTool greps the workspace and returns each match with the lines
+// around it, so the model can read the code it found without a read_file
+// call per hit
+type SearchReadTool struct {
+	workspaceRoot string
+
+	maxFileSize     int64
+	binaryDetection bool
+}
+
+// NewSearchReadTool creates a new search_read tool. Like grep, it skips
+// binary files and files over DefaultMaxFileSize.
+func NewSearchReadTool(workspaceRoot string) *SearchReadTool {
+	return &SearchReadTool{
+		workspaceRoot:   workspaceRoot,
+		maxFileSize:     DefaultMaxFileSize,
+		binaryDetection: true,
+	}
+}
+
+func (t *SearchReadTool) Name() string {
+	return "search_read"
+}
+
+func (t *SearchReadTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityRead}
+}
+
+func (t *SearchReadTool) Description() string {
+	return "Search for a regex pattern (or a fixed string) in workspace files and return every match with the surrounding lines, " +
+		"grouped by file with line numbers ('>' marks matching lines). Use it instead of grep followed by read_file " +
+		"when you want to read the code around each hit."
+}
+
+func (t *SearchReadTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "The regex pattern to search for",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The file or directory path to search in (relative to workspace root). Defaults to workspace root.",
+			},
+			"include": map[string]interface{}{
+				"type":        "string",
+				"description": "File pattern to include (e.g., '*.go', '*.py'). Defaults to all files.",
+			},
+			"case_insensitive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether to perform case-insensitive matching",
+			},
+			"fixed_string": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat the pattern as a literal string instead of a regex (like grep -F). Defaults to false.",
+			},
+			"whole_word": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Only match the pattern as a whole word, bounded by non-word characters. Defaults to false.",
+			},
+			"context_lines": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of lines to show before and after each match (at most %d). Defaults to %d.", maxSearchReadContext, defaultSearchReadContext),
+			},
+			"max_files": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of files to return matches from. Defaults to %d.", defaultSearchReadFiles),
+			},
+			"max_matches_per_file": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of matches to show from a single file. Defaults to %d.", defaultSearchReadPerFile),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *SearchReadTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+
+	searchPath := t.workspaceRoot
+	if p, ok := args["path"].(string); ok && p != "" {
+		searchPath = filepath.Join(t.workspaceRoot, p)
+	}
+	if err := checkSearchPath(t.workspaceRoot, searchPath); err != nil {
+		return "", err
+	}
+
+	include, _ := args["include"].(string)
+	caseInsensitive, _ := args["case_insensitive"].(bool)
+	fixedString, _ := args["fixed_string"].(bool)
+	wholeWord, _ := args["whole_word"].(bool)
+
+	contextLines := defaultSearchReadContext
+	if n, ok := args["context_lines"].(float64); ok && n >= 0 {
+		contextLines = min(int(n), maxSearchReadContext)
+	}
+	maxFiles := defaultSearchReadFiles
+	if n, ok := args["max_files"].(float64); ok && n > 0 {
+		maxFiles = int(n)
+	}
+	maxPerFile := defaultSearchReadPerFile
+	if n, ok := args["max_matches_per_file"].(float64); ok && n > 0 {
+		maxPerFile = int(n)
+	}
+
+	match, err := lineMatcher(pattern, fixedString, wholeWord, caseInsensitive)
+	if err != nil {
+		return "", err
+	}
+
+	var sections []string
+	size := 0
+	full := false   // Set once a file did not fit in the output
+	fileCount := 0  // Files with matches, including those past the caps
+	shownFiles := 0 // Files whose matches are in sections
+
+	err = walkTextFiles(ctx, searchPath, include, t.maxFileSize, t.binaryDetection, func(path string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil
+		}
+		lines := splitLines(string(data))
+
+		var matches []int
+		for i, line := range lines {
+			if match(line) {
+				matches = append(matches, i)
+			}
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+		fileCount++
+		if full || shownFiles >= maxFiles {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(t.workspaceRoot, path)
+		section := matchWindows(relPath, lines, matches, contextLines, maxPerFile)
+		// The first file is always shown, cut down if need be
+		if size > 0 && size+len(section) > defaultSearchReadMaxBytes {
+			full = true
+			return nil
+		}
+		sections = append(sections, section)
+		size += len(section)
+		shownFiles++
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+
+	if len(sections) == 0 {
+		return "No matches found.", nil
+	}
+	output := strings.TrimSuffix(strings.Join(sections, "\n"), "\n")
+	if fileCount > shownFiles {
+		output += "\n\n" + truncate.Notice(int64(shownFiles), int64(fileCount), "files") +
+			" Narrow the search with path or include to see the rest."
+	}
+	return truncate.Bytes(output, defaultSearchReadMaxBytes), nil
+}
+
+// matchWindows renders the matches of one file: a header, then each match
+// with contextLines lines either side, merging windows that touch and
+// separating the rest with "--". Only the first maxMatches matches are shown.
+func matchWindows(relPath string, lines []string, matches []int, contextLines, maxMatches int) string {
+	var b strings.Builder
+	noun := "matches"
+	if len(matches) == 1 {
+		noun = "match"
+	}
+	fmt.Fprintf(&b, "=== %s (%d %s) ===\n", relPath, len(matches), noun)
+
+	shown := matches[:min(len(matches), maxMatches)]
+	isMatch := make(map[int]bool, len(matches))
+	for _, i := range matches {
+		isMatch[i] = true
+	}
+	width := len(fmt.Sprint(min(shown[len(shown)-1]+contextLines+1, len(lines))))
+
+	end := -1 // Index after the last line written
+	for _, i := range shown {
+		start := max(i-contextLines, 0)
+		if start < end {
+			start = end
+		} else if end >= 0 && start > end {
+			b.WriteString("--\n")
+		}
+		stop := min(i+contextLines+1, len(lines))
+		for j := start; j < stop; j++ {
+			marker := " "
+			if isMatch[j] {
+				marker = ">"
+			}
+			fmt.Fprintf(&b, "%s%*d: %s\n", marker, width, j+1, lines[j])
+		}
+		end = max(end, stop)
+	}
+
+	if len(matches) > len(shown) {
+		fmt.Fprintf(&b, "... more matches in %s %s\n", relPath, truncate.Notice(int64(len(shown)), int64(len(matches)), "matches"))
+	}
+	return b.String()
+}
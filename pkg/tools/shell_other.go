@@ -0,0 +1,28 @@
+//go:build !windows
+
+package tools
+
+// defaultShell runs command strings for the shell and wait tools
+var defaultShell = shellSpec{
+	name:    "bash",
+	program: "bash",
+	args:    []string{"-c"},
+}
+
+// scriptLanguages lists the execute tool's languages in the order they are
+// offered to the model
+var scriptLanguages = []string{"bash", "python", "node", "go", "ruby", "perl", "php", "deno", "bun", "wasm"}
+
+// scriptInterpreters maps execute tool languages to interpreters
+var scriptInterpreters = map[string]string{
+	"bash":   "bash",
+	"python": "python3",
+	"node":   "node",
+	"go":     "go",
+	"ruby":   "ruby",
+	"perl":   "perl",
+	"php":    "php",
+	"deno":   "deno",
+	"bun":    "bun",
+	"wasm":   "wasmtime",
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package tools
+
+// defaultShell runs command strings for the shell and wait tools. Windows
+// has no bash by default, so commands go to PowerShell.
+var defaultShell = shellSpec{
+	name:    "powershell",
+	program: "powershell",
+	args:    []string{"-NoProfile", "-NonInteractive", "-Command"},
+}
+
+// scriptLanguages lists the execute tool's languages in the order they are
+// offered to the model
+var scriptLanguages = []string{"powershell", "cmd", "python", "node", "go", "ruby", "perl", "php", "deno", "bun", "wasm"}
+
+// scriptInterpreters maps execute tool languages to interpreters. Python
+// installs on Windows provide python.exe rather than python3.exe.
+var scriptInterpreters = map[string]string{
+	"powershell": "powershell",
+	"cmd":        "cmd",
+	"python":     "python",
+	"node":       "node",
+	"go":         "go",
+	"ruby":       "ruby",
+	"perl":       "perl",
+	"php":        "php",
+	"deno":       "deno",
+	"bun":        "bun",
+	"wasm":       "wasmtime",
+}
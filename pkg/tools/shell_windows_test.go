@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+func TestShellToolWindows(t *testing.T) {
+	tool := NewShellTool(newTestSandbox(t))
+	if tool.Name() != "powershell" {
+		t.Fatalf("Name() = %q, want powershell", tool.Name())
+	}
+	if !sandbox.InterpreterAvailable("powershell") {
+		t.Skip("powershell is not installed")
+	}
+
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"command": "Write-Output ('a' + 'b')"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, "ab") {
+		t.Errorf("output = %q, want ab", out)
+	}
+}
+
+func TestExecuteToolWindowsLanguages(t *testing.T) {
+	if scriptInterpreters["python"] != "python" {
+		t.Errorf("python runs %q, want python.exe", scriptInterpreters["python"])
+	}
+	if _, ok := scriptInterpreters["bash"]; ok {
+		t.Error("bash is offered on Windows")
+	}
+
+	tool := NewExecuteTool(newTestSandbox(t))
+	out, err := tool.Execute(context.Background(), map[string]interface{}{
+		"language": "cmd",
+		"code":     "@echo off\r\necho hello from cmd\r\n",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, "hello from cmd") {
+		t.Errorf("output = %q", out)
+	}
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 
 	"github.com/looper-ai/looper/pkg/llm"
 )
@@ -21,6 +23,35 @@ type Tool interface {
 	Execute(ctx context.Context, args map[string]interface{}) (string, error)
 }
 
+// Result is the output of a RichTool: text plus any images it produced
+type Result struct {
+	Text   string
+	Images []llm.Image
+}
+
+// RichTool is implemented by tools whose results can include images, such
+// as a screenshot or chart tool. The agent calls ExecuteRich instead of
+// Execute for them and passes the images to vision-capable providers.
+type RichTool interface {
+	Tool
+
+	// ExecuteRich runs the tool like Execute but may attach images
+	ExecuteRich(ctx context.Context, args map[string]interface{}) (*Result, error)
+}
+
+// ExecuteResult runs a tool, through ExecuteRich if it is a RichTool
+func ExecuteResult(ctx context.Context, t Tool, args map[string]interface{}) (*Result, error) {
+	if rich, ok := t.(RichTool); ok {
+		result, err := rich.ExecuteRich(ctx, args)
+		if result == nil {
+			result = &Result{}
+		}
+		return result, err
+	}
+	text, err := t.Execute(ctx, args)
+	return &Result{Text: text}, err
+}
+
 // ToDefinition converts a Tool to an LLM ToolDefinition
 func ToDefinition(t Tool) llm.ToolDefinition {
 	return llm.ToolDefinition{
@@ -38,3 +69,14 @@ func ToDefinitions(tools []Tool) []llm.ToolDefinition {
 	}
 	return defs
 }
+
+// withinWorkspace reports whether an absolute path is the workspace or inside
+// it. filepath.Rel rejects paths on another drive and compares Windows paths
+// case-insensitively, which a plain prefix check does not.
+func withinWorkspace(workspace, path string) bool {
+	rel, err := filepath.Rel(workspace, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/looper-ai/looper/pkg/sandbox"
+)
+
+const (
+	defaultWaitTimeout  = 60 * time.Second
+	defaultWaitInterval = time.Second
+	minWaitInterval     = 100 * time.Millisecond
+)
+
+// WaitTool blocks until a condition is met or a timeout expires
+type WaitTool struct {
+	workspaceRoot string
+	sandbox       sandbox.Sandbox
+	maxTimeout    time.Duration
+}
+
+// NewWaitTool creates a new wait tool. maxTimeout caps the timeout the model
+// may request; zero uses the default timeout as the cap.
+func NewWaitTool(workspaceRoot string, sb sandbox.Sandbox, maxTimeout time.Duration) *WaitTool {
+	if maxTimeout <= 0 {
+		maxTimeout = defaultWaitTimeout
+	}
+	return &WaitTool{
+		workspaceRoot: workspaceRoot,
+		sandbox:       sb,
+		maxTimeout:    maxTimeout,
+	}
+}
+
+func (t *WaitTool) Name() string {
+	return "wait"
+}
+
+func (t *WaitTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityRead, CapabilityExecute}
+}
+
+func (t *WaitTool) Description() string {
+	return fmt.Sprintf("Wait until a condition is met instead of polling with repeated commands. "+
+		"Conditions: 'file_exists' (a file appears), 'file_changed' (a file is created, modified, or removed), "+
+		"or 'command_exit' (a %s command returns the expected exit code). Maximum timeout is %s.", defaultShell.name, t.maxTimeout)
+}
+
+func (t *WaitTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"condition": map[string]interface{}{
+				"type":        "string",
+				"description": "The condition to wait for",
+				"enum":        []string{"file_exists", "file_changed", "command_exit"},
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The file path relative to the workspace root (for file conditions)",
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "The " + defaultShell.name + " command to run on each check (for command_exit)",
+			},
+			"exit_code": map[string]interface{}{
+				"type":        "integer",
+				"description": "The exit code that satisfies command_exit. Defaults to 0.",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("How long to wait before giving up. Defaults to %s, capped at %s.", minDuration(defaultWaitTimeout, t.maxTimeout), t.maxTimeout),
+			},
+			"interval_seconds": map[string]interface{}{
+				"type":        "number",
+				"description": "How often to check the condition. Defaults to 1.",
+			},
+		},
+		"required": []string{"condition"},
+	}
+}
+
+func (t *WaitTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	condition, ok := args["condition"].(string)
+	if !ok || condition == "" {
+		return "", fmt.Errorf("condition is required")
+	}
+
+	timeout := minDuration(defaultWaitTimeout, t.maxTimeout)
+	if ts, ok := args["timeout_seconds"].(float64); ok && ts > 0 {
+		timeout = time.Duration(ts * float64(time.Second))
+	}
+	if timeout > t.maxTimeout {
+		timeout = t.maxTimeout
+	}
+
+	interval := defaultWaitInterval
+	if is, ok := args["interval_seconds"].(float64); ok && is > 0 {
+		interval = time.Duration(is * float64(time.Second))
+	}
+	if interval < minWaitInterval {
+		interval = minWaitInterval
+	}
+
+	var check func(ctx context.Context) (bool, string, error)
+	var description string
+
+	switch condition {
+	case "file_exists", "file_changed":
+		path, ok := args["path"].(string)
+		if !ok || path == "" {
+			return "", fmt.Errorf("path is required for %s", condition)
+		}
+		fullPath, err := t.resolvePath(path)
+		if err != nil {
+			return "", err
+		}
+		if condition == "file_exists" {
+			description = fmt.Sprintf("%s to exist", path)
+			check = func(ctx context.Context) (bool, string, error) {
+				_, err := os.Stat(fullPath)
+				return err == nil, fmt.Sprintf("%s exists", path), nil
+			}
+		} else {
+			description = fmt.Sprintf("%s to change", path)
+			initial := statFile(fullPath)
+			check = func(ctx context.Context) (bool, string, error) {
+				current := statFile(fullPath)
+				return current != initial, fmt.Sprintf("%s %s", path, current.describeChange(initial)), nil
+			}
+		}
+
+	case "command_exit":
+		command, ok := args["command"].(string)
+		if !ok || command == "" {
+			return "", fmt.Errorf("command is required for command_exit")
+		}
+		if t.sandbox == nil {
+			return "", fmt.Errorf("command_exit is not available without a sandbox")
+		}
+		expected := 0
+		if ec, ok := args["exit_code"].(float64); ok {
+			expected = int(ec)
+		}
+		description = fmt.Sprintf("`%s` to exit with code %d", command, expected)
+		check = func(ctx context.Context) (bool, string, error) {
+			program, programArgs := defaultShell.command(command)
+			result, err := t.sandbox.Execute(ctx, program, programArgs)
+			if err := executionError(err); err != nil {
+				return false, "", err
+			}
+			if result.TimedOut || result.ExitCode != expected {
+				return false, "", nil
+			}
+			return true, fmt.Sprintf("`%s` exited with code %d", command, result.ExitCode), nil
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported condition: %s", condition)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		met, detail, err := check(waitCtx)
+		if err != nil && ctx.Err() == nil && waitCtx.Err() == nil {
+			return "", err
+		}
+		if met {
+			return fmt.Sprintf("Condition met after %s: %s", time.Since(start).Round(time.Millisecond), detail), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-waitCtx.Done():
+			return fmt.Sprintf("Timed out after %s waiting for %s", timeout, description), nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolvePath resolves a workspace-relative path and validates it stays
+// within the workspace
+func (t *WaitTool) resolvePath(path string) (string, error) {
+	fullPath := filepath.Join(t.workspaceRoot, path)
+
+	absPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
+	if !withinWorkspace(absWorkspace, absPath) {
+		return "", fmt.Errorf("path must be within workspace")
+	}
+	return fullPath, nil
+}
+
+// fileState is a comparable snapshot of a file used to detect changes
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+func (s fileState) describeChange(previous fileState) string {
+	switch {
+	case s.exists && !previous.exists:
+		return "was created"
+	case !s.exists && previous.exists:
+		return "was removed"
+	default:
+		return "was modified"
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -2,28 +2,94 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+
+	"github.com/looper-ai/looper/pkg/truncate"
 )
 
+// ErrOverwriteDeclined is returned when the overwrite hook refuses a write
+var ErrOverwriteDeclined = errors.New("overwrite declined")
+
+// OverwriteRequest describes a write that would replace an existing,
+// non-empty file
+type OverwriteRequest struct {
+	Path    string // Path relative to the workspace root
+	OldSize int64  // Current size of the file in bytes
+	NewSize int64  // Size of the new content in bytes
+}
+
+// maxWriteDiffBytes caps the diff included in a write_file result
+const maxWriteDiffBytes = 4000
+
+// OverwriteConfirmFunc decides whether write_file may overwrite a file.
+// Returning false declines the write.
+type OverwriteConfirmFunc func(ctx context.Context, req OverwriteRequest) bool
+
 // WriteFileTool writes content to files
 type WriteFileTool struct {
 	workspaceRoot string
+
+	confirmOverwrite OverwriteConfirmFunc
+	showDiffs        bool
+
+	mu      sync.Mutex
+	created map[string]bool // Absolute paths of files this tool created
+}
+
+// WriteFileOption configures a WriteFileTool
+type WriteFileOption func(*WriteFileTool)
+
+// WithOverwriteConfirm installs an overwrite hook (see SetOverwriteConfirm)
+func WithOverwriteConfirm(fn OverwriteConfirmFunc) WriteFileOption {
+	return func(t *WriteFileTool) {
+		t.confirmOverwrite = fn
+	}
+}
+
+// WithShowDiffs makes results include diffs (see SetShowDiffs)
+func WithShowDiffs(show bool) WriteFileOption {
+	return func(t *WriteFileTool) {
+		t.showDiffs = show
+	}
 }
 
 // NewWriteFileTool creates a new write file tool
-func NewWriteFileTool(workspaceRoot string) *WriteFileTool {
-	return &WriteFileTool{
+func NewWriteFileTool(workspaceRoot string, opts ...WriteFileOption) *WriteFileTool {
+	t := &WriteFileTool{
 		workspaceRoot: workspaceRoot,
+		created:       make(map[string]bool),
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// SetOverwriteConfirm installs a hook that is asked before a non-empty file
+// is overwritten. Files the tool created itself during this session are
+// overwritten without asking. A nil hook allows all writes.
+func (t *WriteFileTool) SetOverwriteConfirm(fn OverwriteConfirmFunc) {
+	t.confirmOverwrite = fn
+}
+
+// SetShowDiffs makes results include a unified diff of the change, or the
+// line count of a new file. Off by default to save tokens.
+func (t *WriteFileTool) SetShowDiffs(show bool) {
+	t.showDiffs = show
 }
 
 func (t *WriteFileTool) Name() string {
 	return "write_file"
 }
 
+func (t *WriteFileTool) Capabilities() []ToolCapability {
+	return []ToolCapability{CapabilityWrite}
+}
+
 func (t *WriteFileTool) Description() string {
 	return "Write content to a file in the workspace. Creates the file if it doesn't exist, or overwrites it if it does. Creates parent directories as needed."
 }
@@ -64,7 +130,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 		return "", fmt.Errorf("invalid path: %w", err)
 	}
 	absWorkspace, _ := filepath.Abs(t.workspaceRoot)
-	if !strings.HasPrefix(absPath, absWorkspace) {
+	if !withinWorkspace(absWorkspace, absPath) {
 		return "", fmt.Errorf("path must be within workspace")
 	}
 
@@ -75,23 +141,65 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 	default:
 	}
 
+	// Check if file exists (for response message)
+	info, err := os.Stat(fullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	fileExists := err == nil
+
+	if fileExists && info.Size() > 0 && t.confirmOverwrite != nil && !t.createdFile(absPath) {
+		req := OverwriteRequest{Path: path, OldSize: info.Size(), NewSize: int64(len(content))}
+		if !t.confirmOverwrite(ctx, req) {
+			return "", fmt.Errorf("%w: %s was not modified", ErrOverwriteDeclined, path)
+		}
+	}
+
+	// Keep the previous content for the diff
+	var oldContent string
+	if fileExists && t.showDiffs {
+		if data, err := os.ReadFile(fullPath); err == nil {
+			oldContent = string(data)
+		}
+	}
+
 	// Create parent directories if needed
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	// Check if file exists (for response message)
-	_, err = os.Stat(fullPath)
-	fileExists := !os.IsNotExist(err)
-
 	// Write file
 	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
-	if fileExists {
-		return fmt.Sprintf("Successfully updated file: %s", path), nil
+	if !fileExists {
+		t.mu.Lock()
+		t.created[absPath] = true
+		t.mu.Unlock()
 	}
-	return fmt.Sprintf("Successfully created file: %s", path), nil
+
+	if !t.showDiffs {
+		if fileExists {
+			return fmt.Sprintf("Successfully updated file: %s", path), nil
+		}
+		return fmt.Sprintf("Successfully created file: %s", path), nil
+	}
+
+	if !fileExists {
+		return fmt.Sprintf("Successfully created file: %s (%d lines)", path, len(splitLines(content))), nil
+	}
+	diff := unifiedDiff(filepath.ToSlash(path), oldContent, content)
+	if diff == "" {
+		return fmt.Sprintf("Successfully updated file: %s (content unchanged)", path), nil
+	}
+	return fmt.Sprintf("Successfully updated file: %s\n\n%s", path, truncate.Bytes(diff, maxWriteDiffBytes)), nil
+}
+
+// createdFile reports whether the tool created the file at absPath
+func (t *WriteFileTool) createdFile(absPath string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.created[absPath]
 }
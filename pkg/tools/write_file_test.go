@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileCreateAndUpdate(t *testing.T) {
+	root := t.TempDir()
+	tool := NewWriteFileTool(root)
+	ctx := context.Background()
+
+	result, err := tool.Execute(ctx, map[string]interface{}{"path": "docs/notes.md", "content": "one\n"})
+	if err != nil || !strings.HasPrefix(result, "Successfully created file") {
+		t.Fatalf("create: %q, %v", result, err)
+	}
+	result, err = tool.Execute(ctx, map[string]interface{}{"path": "docs/notes.md", "content": "two\n"})
+	if err != nil || !strings.HasPrefix(result, "Successfully updated file") {
+		t.Fatalf("update: %q, %v", result, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "docs", "notes.md")); string(data) != "two\n" {
+		t.Errorf("content = %q", data)
+	}
+}
+
+func TestWriteFileStatError(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "README.md"), []byte("readme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	confirm := func(ctx context.Context, req OverwriteRequest) bool { return true }
+	tool := NewWriteFileTool(root, WithOverwriteConfirm(confirm), WithShowDiffs(true))
+
+	// A path below a regular file fails to stat with ENOTDIR, which must
+	// be returned rather than taken for an existing file
+	_, err := tool.Execute(context.Background(), map[string]interface{}{"path": "README.md/x", "content": "x"})
+	if err == nil {
+		t.Error("writing below a regular file succeeded")
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "README.md")); string(data) != "readme\n" {
+		t.Errorf("README.md changed to %q", data)
+	}
+}
@@ -0,0 +1,79 @@
+package truncate
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Truncation describes output that was cut
+type Truncation struct {
+	Shown int64
+	Total int64
+	Unit  string // "bytes", "lines", "matches", ...
+
+	// AtLeast is set when counting stopped early, so Total is a lower bound
+	AtLeast bool
+}
+
+// Formatter renders the marker for a truncation
+type Formatter func(Truncation) string
+
+// formatter holds the Formatter set with SetFormatter, if any
+var formatter atomic.Pointer[Formatter]
+
+// DefaultFormatter renders "[showing 500 of 12034 bytes]", or "[showing 100
+// of 10000+ matches]" when the total is a lower bound
+func DefaultFormatter(t Truncation) string {
+	more := ""
+	if t.AtLeast {
+		more = "+"
+	}
+	return fmt.Sprintf("[showing %d of %d%s %s]", t.Shown, t.Total, more, t.Unit)
+}
+
+// SetFormatter replaces the marker rendered by Notice, NoticeAtLeast and
+// Bytes, e.g. to match the style of a UI. nil restores DefaultFormatter.
+// It is safe to call while markers are being rendered.
+func SetFormatter(f Formatter) {
+	if f == nil {
+		formatter.Store(nil)
+		return
+	}
+	formatter.Store(&f)
+}
+
+func format(t Truncation) string {
+	if f := formatter.Load(); f != nil {
+		return (*f)(t)
+	}
+	return DefaultFormatter(t)
+}
+
+// Notice returns the truncation marker used wherever looper cuts output,
+// e.g. "[showing 500 of 12034 bytes]", so the model and the user can always
+// tell how much is missing
+func Notice(shown, total int64, unit string) string {
+	return format(Truncation{Shown: shown, Total: total, Unit: unit})
+}
+
+// NoticeAtLeast returns the truncation marker for output whose size was
+// only counted up to total, e.g. "[showing 100 of 10000+ matches]"
+func NoticeAtLeast(shown, total int64, unit string) string {
+	return format(Truncation{Shown: shown, Total: total, Unit: unit, AtLeast: true})
+}
+
+// Bytes returns s cut to at most max bytes, on a rune boundary, followed by
+// a Notice on its own line. Strings within the limit, or a max of 0 or less,
+// are returned unchanged.
+func Bytes(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "\n" + Notice(int64(cut), int64(len(s)), "bytes")
+}
@@ -0,0 +1,70 @@
+package truncate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNotice(t *testing.T) {
+	if got, want := Notice(500, 12034, "bytes"), "[showing 500 of 12034 bytes]"; got != want {
+		t.Errorf("Notice = %q, want %q", got, want)
+	}
+	if got, want := NoticeAtLeast(100, 10000, "matches"), "[showing 100 of 10000+ matches]"; got != want {
+		t.Errorf("NoticeAtLeast = %q, want %q", got, want)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 0, "hello"},
+		{"hello", -1, "hello"},
+		{"hello world", 5, "hello\n[showing 5 of 11 bytes]"},
+		// A cut inside a rune backs up to its start
+		{"héllo", 2, "h\n[showing 1 of 6 bytes]"},
+		{"日本語", 4, "日\n[showing 3 of 9 bytes]"},
+		{"日本語", 2, "\n[showing 0 of 9 bytes]"},
+	}
+	for _, tt := range tests {
+		got := Bytes(tt.s, tt.max)
+		if got != tt.want {
+			t.Errorf("Bytes(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Bytes(%q, %d) split a rune: %q", tt.s, tt.max, got)
+		}
+	}
+}
+
+func TestSetFormatter(t *testing.T) {
+	t.Cleanup(func() { SetFormatter(nil) })
+
+	SetFormatter(func(tr Truncation) string {
+		total := fmt.Sprint(tr.Total)
+		if tr.AtLeast {
+			total = "over " + total
+		}
+		return fmt.Sprintf("(%d/%s %s shown)", tr.Shown, total, tr.Unit)
+	})
+	if got, want := Notice(5, 11, "bytes"), "(5/11 bytes shown)"; got != want {
+		t.Errorf("Notice = %q, want %q", got, want)
+	}
+	if got, want := NoticeAtLeast(1, 9, "matches"), "(1/over 9 matches shown)"; got != want {
+		t.Errorf("NoticeAtLeast = %q, want %q", got, want)
+	}
+	if got := Bytes("hello world", 5); !strings.HasSuffix(got, "\n(5/11 bytes shown)") {
+		t.Errorf("Bytes = %q, want the custom marker", got)
+	}
+
+	SetFormatter(nil)
+	if got, want := Notice(5, 11, "bytes"), "[showing 5 of 11 bytes]"; got != want {
+		t.Errorf("after SetFormatter(nil): Notice = %q, want %q", got, want)
+	}
+}